@@ -53,17 +53,24 @@ func main() {
 	// Initialize router
 	router := mux.NewRouter()
 
-	// Initialize routes
-	routes.SetupRoutes(router, db)
-
 	// Setup chat hub
 	hub := chat.NewHub()
+	switch cfg.WSBackplane {
+	case "":
+		// Single instance: no backplane needed
+	case "sqlite":
+		backplane := chat.NewSQLiteBackplane(db.DB, cfg.WSBackplanePollInterval)
+		defer backplane.Close()
+		if err := hub.SetBackplane(backplane); err != nil {
+			log.Fatalf("Failed to subscribe to WebSocket backplane: %v", err)
+		}
+	default:
+		log.Fatalf("Unknown WS_BACKPLANE %q (expected empty or \"sqlite\")", cfg.WSBackplane)
+	}
 	go hub.Run()
 
-	// WebSocket endpoint
-	router.HandleFunc("/ws/{projectId}", func(w http.ResponseWriter, r *http.Request) {
-		chat.ServeWs(hub, w, r)
-	})
+	// Initialize routes (including the /ws/{projectId} WebSocket endpoint)
+	routes.SetupRoutes(router, db, hub, cfg)
 
 	// Enable CORS - allow all localhost origins for development
 	corsHandler := enableCORS(router)
@@ -3,29 +3,59 @@ package main
 import (
 	"fmt"
 	"log"
+	"os"
+	"time"
 
+	"backend/internal/auth/repository"
 	"backend/internal/auth/services"
+	"backend/internal/database"
 )
 
 func main() {
-	// Use the same secret as the server (from GetEnvJWTSecret)
-	secret := "taskify-dev-secret-change-in-production"
+	// Generate a token for user-1, or the user ID given as the first argument
+	userID := "user-1"
+	if len(os.Args) > 1 {
+		userID = os.Args[1]
+	}
 
-	// Create JWT service with 24 hour expiration
-	jwtService := services.NewJWTService(secret, 24)
+	// The server only accepts tokens that belong to a session, so start one
+	// in the server's database like a sign-in does
+	db, err := database.NewDB()
+	if err != nil {
+		log.Fatalf("Failed to connect to database: %v", err)
+	}
+	defer db.Close()
 
-	// Generate token for user-1
-	userID := "user-1"
-	email := "test@example.com"
+	userRepo := repository.NewUserRepository(db.DB)
+	if err := userRepo.InitTable(); err != nil {
+		log.Fatalf("Failed to initialize users table: %v", err)
+	}
+	sessionRepo := repository.NewSessionRepository(db.DB)
+	if err := sessionRepo.InitTable(); err != nil {
+		log.Fatalf("Failed to initialize sessions table: %v", err)
+	}
+
+	user, err := userRepo.GetUserByID(userID)
+	if err != nil {
+		log.Fatalf("Failed to get user: %v", err)
+	}
+	if user == nil {
+		log.Fatalf("User %q not found", userID)
+	}
+
+	// Use the same secret as the server (from GetEnvJWTSecret), with a 24
+	// hour access token and a 30 day session
+	jwtService := services.NewJWTService(services.GetEnvJWTSecret(), 24)
+	sessionService := services.NewSessionService(sessionRepo, userRepo, jwtService, 30*24*time.Hour)
 
-	token, err := jwtService.GenerateToken(userID, email)
+	resp, err := sessionService.StartSession(user, services.ClientInfo{UserAgent: "testtoken"})
 	if err != nil {
 		log.Fatalf("Failed to generate token: %v", err)
 	}
 
 	fmt.Println("Generated JWT Token:")
-	fmt.Println(token)
+	fmt.Println(resp.Token)
 	fmt.Println()
 	fmt.Println("Use with curl:")
-	fmt.Printf("curl -H \"Authorization: Bearer %s\" http://localhost:8080/api/projects/1/members\n", token)
+	fmt.Printf("curl -H \"Authorization: Bearer %s\" http://localhost:8080/api/projects/1/members\n", resp.Token)
 }
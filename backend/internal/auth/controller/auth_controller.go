@@ -4,10 +4,13 @@ import (
 	"encoding/json"
 	"errors"
 	"log"
+	"net"
 	"net/http"
 	"net/url"
 	"os"
 
+	"backend/internal/auth/middleware"
+	"backend/internal/auth/models"
 	"backend/internal/auth/services"
 
 	"github.com/gorilla/mux"
@@ -15,7 +18,11 @@ import (
 
 // AuthController handles HTTP requests for authentication
 type AuthController struct {
-	authService *services.AuthService
+	authService        *services.AuthService
+	sessionService     *services.SessionService
+	accessTokenService *services.AccessTokenService
+	twoFactorService   *services.TwoFactorService
+	verification       *services.EmailVerificationService
 }
 
 // NewAuthController creates a new AuthController
@@ -25,6 +32,26 @@ func NewAuthController(authService *services.AuthService) *AuthController {
 	}
 }
 
+// SetSessionService enables the refresh, logout and session endpoints
+func (c *AuthController) SetSessionService(sessionService *services.SessionService) {
+	c.sessionService = sessionService
+}
+
+// SetAccessTokenService enables the personal access token endpoints
+func (c *AuthController) SetAccessTokenService(accessTokenService *services.AccessTokenService) {
+	c.accessTokenService = accessTokenService
+}
+
+// SetTwoFactorService enables the two-factor authentication endpoints
+func (c *AuthController) SetTwoFactorService(twoFactorService *services.TwoFactorService) {
+	c.twoFactorService = twoFactorService
+}
+
+// SetEmailVerificationService enables the email verification endpoints
+func (c *AuthController) SetEmailVerificationService(verification *services.EmailVerificationService) {
+	c.verification = verification
+}
+
 // Register handles POST /api/auth/register
 func (c *AuthController) Register(w http.ResponseWriter, r *http.Request) {
 	// Decode request body
@@ -34,6 +61,8 @@ func (c *AuthController) Register(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	req.Client = clientInfo(r)
+
 	// Perform registration
 	resp, err := c.authService.Register(req)
 	if err != nil {
@@ -54,6 +83,8 @@ func (c *AuthController) Login(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	req.Client = clientInfo(r)
+
 	// Perform login
 	resp, err := c.authService.Login(req)
 	if err != nil {
@@ -80,7 +111,7 @@ func (c *AuthController) GoogleLoginWithIDToken(w http.ResponseWriter, r *http.R
 		return
 	}
 
-	resp, err := c.authService.GoogleLoginWithIDToken(r.Context(), req.IDToken)
+	resp, err := c.authService.GoogleLoginWithIDToken(services.WithClientInfo(r.Context(), clientInfo(r)), req.IDToken)
 	if err != nil {
 		c.handleAuthError(w, err)
 		return
@@ -109,7 +140,7 @@ func (c *AuthController) GoogleCallback(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	resp, err := c.authService.GoogleLoginWithCode(r.Context(), state, code)
+	resp, err := c.authService.GoogleLoginWithCode(services.WithClientInfo(r.Context(), clientInfo(r)), state, code)
 	if err != nil {
 		frontendURL := os.Getenv("FRONTEND_URL")
 		if frontendURL == "" {
@@ -126,7 +157,16 @@ func (c *AuthController) GoogleCallback(w http.ResponseWriter, r *http.Request)
 		frontendURL = "http://localhost:4200"
 	}
 	q := url.Values{}
+	if resp.TwoFactorRequired {
+		q.Set("two_factor_required", "true")
+		q.Set("challenge_token", resp.ChallengeToken)
+		http.Redirect(w, r, frontendURL+"/auth/google/callback?"+q.Encode(), http.StatusTemporaryRedirect)
+		return
+	}
 	q.Set("token", resp.Token)
+	if resp.RefreshToken != "" {
+		q.Set("refresh_token", resp.RefreshToken)
+	}
 	q.Set("name", resp.User.Name)
 	q.Set("email", resp.User.Email)
 	q.Set("id", resp.User.ID)
@@ -164,18 +204,37 @@ func (c *AuthController) UpdateMe(w http.ResponseWriter, r *http.Request) {
 
 	// Parse request body
 	var req struct {
-		Name string `json:"name"`
+		Name     *string `json:"name"`
+		Language *string `json:"language"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		c.writeError(w, http.StatusBadRequest, "Invalid request body")
 		return
 	}
+	if req.Name == nil && req.Language == nil {
+		c.writeError(w, http.StatusBadRequest, "name or language is required")
+		return
+	}
+
+	var user *models.UserResponse
+	var err error
 
 	// Update user name
-	user, err := c.authService.UpdateUserName(userID, req.Name)
-	if err != nil {
-		c.writeError(w, http.StatusBadRequest, err.Error())
-		return
+	if req.Name != nil {
+		user, err = c.authService.UpdateUserName(userID, *req.Name)
+		if err != nil {
+			c.writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+
+	// Update email language
+	if req.Language != nil {
+		user, err = c.authService.UpdateUserLanguage(userID, *req.Language)
+		if err != nil {
+			c.writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
 	}
 
 	// Return updated user
@@ -225,7 +284,7 @@ func (c *AuthController) VerifyOTP(w http.ResponseWriter, r *http.Request) {
 
 	resetToken, err := c.authService.VerifyResetOTP(req.Email, req.Code)
 	if err != nil {
-		c.writeError(w, http.StatusBadRequest, err.Error())
+		c.handleAuthError(w, err)
 		return
 	}
 
@@ -251,11 +310,7 @@ func (c *AuthController) ResetPassword(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if err := c.authService.ResetPassword(req.ResetToken, req.NewPassword); err != nil {
-		if errors.Is(err, services.ErrWeakPassword) {
-			c.writeError(w, http.StatusBadRequest, "Password must be at least 8 characters")
-			return
-		}
-		c.writeError(w, http.StatusBadRequest, err.Error())
+		c.handleAuthError(w, err)
 		return
 	}
 
@@ -264,6 +319,431 @@ func (c *AuthController) ResetPassword(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// VerifyEmail handles POST /api/auth/verify-email with the token from a
+// verification link
+func (c *AuthController) VerifyEmail(w http.ResponseWriter, r *http.Request) {
+	if c.verification == nil {
+		c.writeError(w, http.StatusServiceUnavailable, "Email verification is not enabled")
+		return
+	}
+
+	var req struct {
+		Token string `json:"token"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		c.writeError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if req.Token == "" {
+		c.writeError(w, http.StatusBadRequest, "token is required")
+		return
+	}
+
+	user, err := c.verification.Verify(req.Token)
+	if err != nil {
+		c.handleAuthError(w, err)
+		return
+	}
+
+	c.writeJSON(w, http.StatusOK, user.ToResponse())
+}
+
+// ResendVerification handles POST /api/auth/verify-email/resend
+func (c *AuthController) ResendVerification(w http.ResponseWriter, r *http.Request) {
+	if c.verification == nil {
+		c.writeError(w, http.StatusServiceUnavailable, "Email verification is not enabled")
+		return
+	}
+
+	userID := middleware.GetUserID(r.Context())
+	if userID == "" {
+		c.writeError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	if err := c.verification.Resend(userID); err != nil {
+		c.handleAuthError(w, err)
+		return
+	}
+
+	c.writeJSON(w, http.StatusOK, map[string]string{
+		"message": "A verification email has been sent.",
+	})
+}
+
+// Refresh handles POST /api/auth/refresh
+func (c *AuthController) Refresh(w http.ResponseWriter, r *http.Request) {
+	if c.sessionService == nil {
+		c.writeError(w, http.StatusServiceUnavailable, "Sessions are not enabled")
+		return
+	}
+
+	var req struct {
+		RefreshToken string `json:"refresh_token"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		c.writeError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if req.RefreshToken == "" {
+		c.writeError(w, http.StatusBadRequest, "refresh_token is required")
+		return
+	}
+
+	resp, err := c.sessionService.Refresh(req.RefreshToken, clientInfo(r))
+	if err != nil {
+		c.handleAuthError(w, err)
+		return
+	}
+
+	c.writeJSON(w, http.StatusOK, resp)
+}
+
+// Logout handles POST /api/auth/logout
+func (c *AuthController) Logout(w http.ResponseWriter, r *http.Request) {
+	if c.sessionService == nil {
+		c.writeError(w, http.StatusServiceUnavailable, "Sessions are not enabled")
+		return
+	}
+
+	var req struct {
+		RefreshToken string `json:"refresh_token"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		c.writeError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if req.RefreshToken == "" {
+		c.writeError(w, http.StatusBadRequest, "refresh_token is required")
+		return
+	}
+
+	if err := c.sessionService.Logout(req.RefreshToken); err != nil {
+		c.handleAuthError(w, err)
+		return
+	}
+
+	c.writeJSON(w, http.StatusOK, map[string]string{
+		"message": "Logged out",
+	})
+}
+
+// ListSessions handles GET /api/auth/sessions
+func (c *AuthController) ListSessions(w http.ResponseWriter, r *http.Request) {
+	userID, ok := c.sessionUser(w, r)
+	if !ok {
+		return
+	}
+
+	sessions, err := c.sessionService.ListSessions(userID, middleware.GetSessionID(r.Context()))
+	if err != nil {
+		c.handleAuthError(w, err)
+		return
+	}
+
+	c.writeJSON(w, http.StatusOK, sessions)
+}
+
+// RevokeSession handles DELETE /api/auth/sessions/{id}
+func (c *AuthController) RevokeSession(w http.ResponseWriter, r *http.Request) {
+	userID, ok := c.sessionUser(w, r)
+	if !ok {
+		return
+	}
+
+	if err := c.sessionService.RevokeSession(userID, mux.Vars(r)["id"]); err != nil {
+		c.handleAuthError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// RevokeOtherSessions handles DELETE /api/auth/sessions, signing the user out
+// of every session but the one making the request
+func (c *AuthController) RevokeOtherSessions(w http.ResponseWriter, r *http.Request) {
+	userID, ok := c.sessionUser(w, r)
+	if !ok {
+		return
+	}
+
+	revoked, err := c.sessionService.RevokeOtherSessions(userID, middleware.GetSessionID(r.Context()))
+	if err != nil {
+		c.handleAuthError(w, err)
+		return
+	}
+
+	c.writeJSON(w, http.StatusOK, map[string]int64{
+		"revoked": revoked,
+	})
+}
+
+// sessionUser reads the authenticated user for the session endpoints,
+// writing an error response when sessions are disabled or the user is missing
+func (c *AuthController) sessionUser(w http.ResponseWriter, r *http.Request) (string, bool) {
+	if c.sessionService == nil {
+		c.writeError(w, http.StatusServiceUnavailable, "Sessions are not enabled")
+		return "", false
+	}
+
+	userID := middleware.GetUserID(r.Context())
+	if userID == "" {
+		c.writeError(w, http.StatusUnauthorized, "Unauthorized")
+		return "", false
+	}
+	return userID, true
+}
+
+// VerifyTwoFactor handles POST /api/auth/2fa/verify, the second step of a
+// sign-in that returned two_factor_required
+func (c *AuthController) VerifyTwoFactor(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		ChallengeToken string `json:"challenge_token"`
+		Code           string `json:"code"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		c.writeError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if req.ChallengeToken == "" || req.Code == "" {
+		c.writeError(w, http.StatusBadRequest, "challenge_token and code are required")
+		return
+	}
+
+	resp, err := c.authService.VerifyTwoFactor(req.ChallengeToken, req.Code, clientInfo(r))
+	if err != nil {
+		c.handleAuthError(w, err)
+		return
+	}
+
+	c.writeJSON(w, http.StatusOK, resp)
+}
+
+// GetTwoFactorStatus handles GET /api/auth/2fa
+func (c *AuthController) GetTwoFactorStatus(w http.ResponseWriter, r *http.Request) {
+	userID, ok := c.twoFactorUser(w, r)
+	if !ok {
+		return
+	}
+
+	status, err := c.twoFactorService.Status(userID)
+	if err != nil {
+		c.handleAuthError(w, err)
+		return
+	}
+
+	c.writeJSON(w, http.StatusOK, status)
+}
+
+// SetupTwoFactor handles POST /api/auth/2fa/setup
+func (c *AuthController) SetupTwoFactor(w http.ResponseWriter, r *http.Request) {
+	userID, ok := c.twoFactorUser(w, r)
+	if !ok {
+		return
+	}
+
+	setup, err := c.twoFactorService.Setup(userID)
+	if err != nil {
+		c.handleAuthError(w, err)
+		return
+	}
+
+	c.writeJSON(w, http.StatusOK, setup)
+}
+
+// EnableTwoFactor handles POST /api/auth/2fa/enable
+func (c *AuthController) EnableTwoFactor(w http.ResponseWriter, r *http.Request) {
+	userID, ok := c.twoFactorUser(w, r)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		Code string `json:"code"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		c.writeError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if req.Code == "" {
+		c.writeError(w, http.StatusBadRequest, "code is required")
+		return
+	}
+
+	codes, err := c.twoFactorService.Enable(userID, req.Code)
+	if err != nil {
+		c.handleAuthError(w, err)
+		return
+	}
+
+	c.writeJSON(w, http.StatusOK, map[string][]string{
+		"recovery_codes": codes,
+	})
+}
+
+// DisableTwoFactor handles POST /api/auth/2fa/disable
+func (c *AuthController) DisableTwoFactor(w http.ResponseWriter, r *http.Request) {
+	userID, ok := c.twoFactorUser(w, r)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		Password string `json:"password"`
+		Code     string `json:"code"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		c.writeError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if req.Code == "" {
+		c.writeError(w, http.StatusBadRequest, "code is required")
+		return
+	}
+
+	if err := c.twoFactorService.Disable(userID, req.Password, req.Code); err != nil {
+		c.handleAuthError(w, err)
+		return
+	}
+
+	c.writeJSON(w, http.StatusOK, map[string]string{
+		"message": "Two-factor authentication disabled",
+	})
+}
+
+// RegenerateRecoveryCodes handles POST /api/auth/2fa/recovery-codes
+func (c *AuthController) RegenerateRecoveryCodes(w http.ResponseWriter, r *http.Request) {
+	userID, ok := c.twoFactorUser(w, r)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		Code string `json:"code"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		c.writeError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if req.Code == "" {
+		c.writeError(w, http.StatusBadRequest, "code is required")
+		return
+	}
+
+	codes, err := c.twoFactorService.RegenerateRecoveryCodes(userID, req.Code)
+	if err != nil {
+		c.handleAuthError(w, err)
+		return
+	}
+
+	c.writeJSON(w, http.StatusOK, map[string][]string{
+		"recovery_codes": codes,
+	})
+}
+
+// twoFactorUser reads the authenticated user for the two-factor endpoints,
+// writing an error response when they are disabled or the user is missing
+func (c *AuthController) twoFactorUser(w http.ResponseWriter, r *http.Request) (string, bool) {
+	if c.twoFactorService == nil {
+		c.writeError(w, http.StatusServiceUnavailable, "Two-factor authentication is not enabled")
+		return "", false
+	}
+
+	userID := middleware.GetUserID(r.Context())
+	if userID == "" {
+		c.writeError(w, http.StatusUnauthorized, "Unauthorized")
+		return "", false
+	}
+	return userID, true
+}
+
+// ListAccessTokens handles GET /api/me/tokens
+func (c *AuthController) ListAccessTokens(w http.ResponseWriter, r *http.Request) {
+	userID, ok := c.accessTokenUser(w, r)
+	if !ok {
+		return
+	}
+
+	tokens, err := c.accessTokenService.ListTokens(userID)
+	if err != nil {
+		c.handleAuthError(w, err)
+		return
+	}
+
+	c.writeJSON(w, http.StatusOK, tokens)
+}
+
+// CreateAccessToken handles POST /api/me/tokens. The response is the only
+// time the token's value is shown.
+func (c *AuthController) CreateAccessToken(w http.ResponseWriter, r *http.Request) {
+	userID, ok := c.accessTokenUser(w, r)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		Name          string              `json:"name"`
+		Scopes        []models.TokenScope `json:"scopes"`
+		ExpiresInDays int                 `json:"expires_in_days"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		c.writeError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	token, err := c.accessTokenService.CreateToken(userID, req.Name, req.Scopes, req.ExpiresInDays)
+	if err != nil {
+		c.handleAuthError(w, err)
+		return
+	}
+
+	c.writeJSON(w, http.StatusCreated, token)
+}
+
+// RevokeAccessToken handles DELETE /api/me/tokens/{id}
+func (c *AuthController) RevokeAccessToken(w http.ResponseWriter, r *http.Request) {
+	userID, ok := c.accessTokenUser(w, r)
+	if !ok {
+		return
+	}
+
+	if err := c.accessTokenService.RevokeToken(userID, mux.Vars(r)["id"]); err != nil {
+		c.handleAuthError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// accessTokenUser reads the authenticated user for the personal access token
+// endpoints, writing an error response when they are disabled or the user is
+// missing
+func (c *AuthController) accessTokenUser(w http.ResponseWriter, r *http.Request) (string, bool) {
+	if c.accessTokenService == nil {
+		c.writeError(w, http.StatusServiceUnavailable, "Personal access tokens are not enabled")
+		return "", false
+	}
+
+	userID := middleware.GetUserID(r.Context())
+	if userID == "" {
+		c.writeError(w, http.StatusUnauthorized, "Unauthorized")
+		return "", false
+	}
+	return userID, true
+}
+
+// clientInfo describes the device making the request, for the session list
+func clientInfo(r *http.Request) services.ClientInfo {
+	ip := r.RemoteAddr
+	if host, _, err := net.SplitHostPort(ip); err == nil {
+		ip = host
+	}
+	return services.ClientInfo{
+		UserAgent: r.UserAgent(),
+		IPAddress: ip,
+	}
+}
+
 // handleAuthError maps authentication errors to HTTP status codes
 func (c *AuthController) handleAuthError(w http.ResponseWriter, err error) {
 	switch {
@@ -277,6 +757,39 @@ func (c *AuthController) handleAuthError(w http.ResponseWriter, err error) {
 		c.writeError(w, http.StatusBadRequest, "Invalid email format")
 	case errors.Is(err, services.ErrUserInactive):
 		c.writeError(w, http.StatusForbidden, "User account is inactive")
+	case errors.Is(err, services.ErrInvalidRefreshToken):
+		c.writeError(w, http.StatusUnauthorized, "Invalid or expired refresh token")
+	case errors.Is(err, services.ErrRefreshTokenReused):
+		c.writeError(w, http.StatusUnauthorized, "Refresh token was already used; the session has been revoked")
+	case errors.Is(err, services.ErrSessionNotFound):
+		c.writeError(w, http.StatusNotFound, "Session not found")
+	case errors.Is(err, services.ErrInvalidAccessTokenRequest):
+		c.writeError(w, http.StatusBadRequest, err.Error())
+	case errors.Is(err, services.ErrAccessTokenNotFound):
+		c.writeError(w, http.StatusNotFound, "Personal access token not found")
+	case errors.Is(err, services.ErrTwoFactorAlreadyEnabled):
+		c.writeError(w, http.StatusConflict, "Two-factor authentication is already enabled")
+	case errors.Is(err, services.ErrTwoFactorNotEnabled):
+		c.writeError(w, http.StatusBadRequest, "Two-factor authentication is not enabled")
+	case errors.Is(err, services.ErrTwoFactorSetupRequired):
+		c.writeError(w, http.StatusBadRequest, "Start two-factor setup first")
+	case errors.Is(err, services.ErrInvalidTwoFactorCode):
+		c.writeError(w, http.StatusBadRequest, "Invalid two-factor code")
+	case errors.Is(err, services.ErrInvalidChallenge):
+		c.writeError(w, http.StatusUnauthorized, "Invalid or expired two-factor challenge")
+	case errors.Is(err, services.ErrIncorrectPassword):
+		c.writeError(w, http.StatusForbidden, "Password is incorrect")
+	case errors.Is(err, services.ErrOTPNotFound), errors.Is(err, services.ErrOTPExpired), errors.Is(err, services.ErrInvalidOTP),
+		errors.Is(err, services.ErrInvalidResetToken), errors.Is(err, services.ErrResetTokenExpired):
+		c.writeError(w, http.StatusBadRequest, err.Error())
+	case errors.Is(err, services.ErrOTPLocked):
+		c.writeError(w, http.StatusTooManyRequests, "Too many incorrect codes; request a new code later")
+	case errors.Is(err, services.ErrInvalidVerificationToken):
+		c.writeError(w, http.StatusBadRequest, "Invalid or expired verification link")
+	case errors.Is(err, services.ErrEmailAlreadyVerified):
+		c.writeError(w, http.StatusConflict, "Email address is already verified")
+	case errors.Is(err, services.ErrVerificationResendTooSoon):
+		c.writeError(w, http.StatusTooManyRequests, "A verification email was sent recently; please wait a minute")
 	case errors.Is(err, services.ErrGoogleNotConfigured):
 		c.writeError(w, http.StatusServiceUnavailable, "Google auth is not configured")
 	case errors.Is(err, services.ErrInvalidGoogleToken):
@@ -322,4 +835,17 @@ func (c *AuthController) RegisterRoutes(router *mux.Router) {
 	router.HandleFunc("/forgot-password", c.ForgotPassword).Methods("POST")
 	router.HandleFunc("/verify-otp", c.VerifyOTP).Methods("POST")
 	router.HandleFunc("/reset-password", c.ResetPassword).Methods("POST")
+	router.HandleFunc("/verify-email", c.VerifyEmail).Methods("POST")
+	router.HandleFunc("/verify-email/resend", c.ResendVerification).Methods("POST")
+	router.HandleFunc("/refresh", c.Refresh).Methods("POST")
+	router.HandleFunc("/logout", c.Logout).Methods("POST")
+	router.HandleFunc("/sessions", c.ListSessions).Methods("GET")
+	router.HandleFunc("/sessions", c.RevokeOtherSessions).Methods("DELETE")
+	router.HandleFunc("/sessions/{id}", c.RevokeSession).Methods("DELETE")
+	router.HandleFunc("/2fa/verify", c.VerifyTwoFactor).Methods("POST")
+	router.HandleFunc("/2fa", c.GetTwoFactorStatus).Methods("GET")
+	router.HandleFunc("/2fa/setup", c.SetupTwoFactor).Methods("POST")
+	router.HandleFunc("/2fa/enable", c.EnableTwoFactor).Methods("POST")
+	router.HandleFunc("/2fa/disable", c.DisableTwoFactor).Methods("POST")
+	router.HandleFunc("/2fa/recovery-codes", c.RegenerateRecoveryCodes).Methods("POST")
 }
@@ -0,0 +1,36 @@
+package middleware
+
+import (
+	"net/http"
+
+	"backend/internal/auth/models"
+	"backend/internal/auth/repository"
+
+	"github.com/gorilla/mux"
+)
+
+// RequireAdmin creates a middleware that only lets active users with the
+// admin role through. It must run after JWTAuthMiddleware.
+func RequireAdmin(userRepo *repository.UserRepository) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			userID := GetUserID(r.Context())
+			if userID == "" {
+				writeError(w, http.StatusUnauthorized, "Unauthorized")
+				return
+			}
+
+			user, err := userRepo.GetUserByID(userID)
+			if err != nil {
+				writeError(w, http.StatusInternalServerError, "Failed to check permissions")
+				return
+			}
+			if user == nil || !user.IsActive || user.Role != models.RoleAdmin {
+				writeError(w, http.StatusForbidden, "Admin access required")
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -3,48 +3,91 @@ package middleware
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"net/http"
 	"strings"
 
+	"backend/internal/auth/models"
 	"backend/internal/auth/services"
 
 	"github.com/gorilla/mux"
 )
 
-// JWTAuthMiddleware creates a middleware that validates JWT tokens
+// JWTAuthMiddleware creates a middleware that validates JWT tokens. It also
+// accepts personal access tokens (tfy_...), limited to what their scopes allow.
 func JWTAuthMiddleware(jwtService *services.JWTService) mux.MiddlewareFunc {
+	return jwtAuth(jwtService, false)
+}
+
+// JWTQueryAuthMiddleware is JWTAuthMiddleware that also accepts the token as
+// a ?token= query parameter, for endpoints opened by a browser EventSource,
+// which cannot set an Authorization header
+func JWTQueryAuthMiddleware(jwtService *services.JWTService) mux.MiddlewareFunc {
+	return jwtAuth(jwtService, true)
+}
+
+func jwtAuth(jwtService *services.JWTService, allowQueryToken bool) mux.MiddlewareFunc {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			// Extract token from Authorization header
 			authHeader := r.Header.Get("Authorization")
-			if authHeader == "" {
+			tokenString := ""
+			if authHeader == "" && allowQueryToken {
+				tokenString = r.URL.Query().Get("token")
+			}
+			if authHeader == "" && tokenString == "" {
 				writeError(w, http.StatusUnauthorized, "Authorization header required")
 				return
 			}
 
 			// Expected format: "Bearer <token>"
-			parts := strings.Split(authHeader, " ")
-			if len(parts) != 2 || parts[0] != "Bearer" {
-				writeError(w, http.StatusUnauthorized, "Invalid authorization header format")
-				return
+			if tokenString == "" {
+				parts := strings.Split(authHeader, " ")
+				if len(parts) != 2 || parts[0] != "Bearer" {
+					writeError(w, http.StatusUnauthorized, "Invalid authorization header format")
+					return
+				}
+				tokenString = parts[1]
 			}
 
-			tokenString := parts[1]
-
-			// Validate token
-			claims, err := jwtService.ValidateToken(tokenString)
+			// Validate token and the session it was issued for
+			claims, err := jwtService.AuthenticateBearer(tokenString)
 			if err != nil {
-				if err == services.ErrExpiredToken {
+				switch {
+				case errors.Is(err, services.ErrExpiredToken):
 					writeError(w, http.StatusUnauthorized, "Token has expired")
-					return
+				case errors.Is(err, services.ErrSessionRevoked):
+					writeError(w, http.StatusUnauthorized, "Session has been revoked")
+				case errors.Is(err, services.ErrUserInactive):
+					writeError(w, http.StatusUnauthorized, "User account is inactive")
+				case errors.Is(err, services.ErrInvalidToken):
+					writeError(w, http.StatusUnauthorized, "Invalid token")
+				default:
+					writeError(w, http.StatusInternalServerError, "Failed to validate session")
 				}
-				writeError(w, http.StatusUnauthorized, "Invalid token")
 				return
 			}
 
+			// Personal access tokens only reach what their scopes cover
+			if claims.Scopes != nil {
+				required, allowed := requiredTokenScope(r)
+				if !allowed {
+					writeError(w, http.StatusForbidden, "Personal access tokens cannot be used for this endpoint")
+					return
+				}
+				if !models.HasScope(claims.Scopes, required) {
+					writeError(w, http.StatusForbidden, "Token is missing the "+string(required)+" scope")
+					return
+				}
+			}
+
 			// Add user ID to request context
 			ctx := context.WithValue(r.Context(), "user_id", claims.UserID)
 			ctx = context.WithValue(ctx, "user_email", claims.Email)
+			ctx = context.WithValue(ctx, "session_id", claims.SessionID)
+			if claims.Scopes != nil {
+				ctx = context.WithValue(ctx, "token_scopes", claims.Scopes)
+			}
 
 			// Call next handler with updated context
 			next.ServeHTTP(w, r.WithContext(ctx))
@@ -68,6 +111,15 @@ func GetUserEmail(ctx context.Context) string {
 	return ""
 }
 
+// GetSessionID retrieves the session of the request's access token, which is
+// empty for tokens issued without one
+func GetSessionID(ctx context.Context) string {
+	if sessionID, ok := ctx.Value("session_id").(string); ok {
+		return sessionID
+	}
+	return ""
+}
+
 // writeError writes an error response
 func writeError(w http.ResponseWriter, status int, message string) {
 	w.Header().Set("Content-Type", "application/json")
@@ -1,12 +1,18 @@
 package middleware
 
 import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net"
 	"net/http"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 )
 
-// RateLimiter tracks requests per IP
+// RateLimiter tracks requests per key, such as a client IP
 type RateLimiter struct {
 	requests map[string][]time.Time
 	mu       sync.RWMutex
@@ -74,20 +80,68 @@ func (rl *RateLimiter) Allow(ip string) bool {
 	return true
 }
 
-// RateLimitMiddleware creates rate limiting middleware
+// RateLimitMiddleware creates rate limiting middleware. Each client IP may
+// make limit requests to a path per window, and so may each email address
+// named in a JSON request body, so guessing at one account from many
+// addresses is limited as well.
 func RateLimitMiddleware(limit int, window time.Duration) func(http.Handler) http.Handler {
 	rl := NewRateLimiter(limit, window)
 
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			ip := r.RemoteAddr
-			if rl.Allow(ip) {
+			allowed := rl.Allow(r.URL.Path + " ip:" + clientIP(r))
+			if email := requestEmail(r); email != "" {
+				// Both are counted, even when the IP is already over its limit
+				allowed = rl.Allow(r.URL.Path+" email:"+email) && allowed
+			}
+
+			if allowed {
 				next.ServeHTTP(w, r)
 			} else {
 				w.Header().Set("Content-Type", "application/json")
+				w.Header().Set("Retry-After", strconv.Itoa(int(rl.window.Seconds())))
 				w.WriteHeader(http.StatusTooManyRequests)
 				w.Write([]byte(`{"success":false,"error":"rate limit exceeded","code":"RATE_LIMIT"}`))
 			}
 		})
 	}
 }
+
+// clientIP returns the IP address of the client, without the port
+func clientIP(r *http.Request) string {
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		return host
+	}
+	return r.RemoteAddr
+}
+
+// maxRateLimitBody is how much of a request body is read looking for an email
+const maxRateLimitBody = 64 << 10
+
+// readCloser puts back the part of a body that was read ahead of the rest
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// requestEmail returns the normalized "email" field of a JSON request body,
+// or "" when there is none. The body is restored for the next handler.
+func requestEmail(r *http.Request) string {
+	if r.Body == nil {
+		return ""
+	}
+	original := r.Body
+	body, err := io.ReadAll(io.LimitReader(original, maxRateLimitBody))
+	r.Body = readCloser{io.MultiReader(bytes.NewReader(body), original), original}
+	if err != nil {
+		return ""
+	}
+
+	var req struct {
+		Email string `json:"email"`
+	}
+	if json.Unmarshal(body, &req) != nil {
+		return ""
+	}
+	return strings.ToLower(strings.Join(strings.Fields(req.Email), ""))
+}
@@ -0,0 +1,51 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"backend/internal/auth/models"
+)
+
+// taskWritePath matches the endpoints the write:tasks scope covers: tasks and
+// everything hanging off them
+var taskWritePath = regexp.MustCompile(`^/api/(tasks|comments|subtasks)(/|$)|^/api/projects/[^/]+/(stages/[^/]+/)?tasks$`)
+
+// personalTokenPaths can only be used after an interactive login, so a leaked
+// personal access token cannot mint more tokens, hijack sessions or change
+// two-factor settings
+var personalTokenPaths = []string{"/api/me/tokens", "/api/auth/sessions", "/api/auth/2fa"}
+
+// requiredTokenScope returns the scope a personal access token needs for the
+// request. It reports false when personal access tokens may not be used for
+// the request at all.
+func requiredTokenScope(r *http.Request) (models.TokenScope, bool) {
+	path := r.URL.Path
+	for _, prefix := range personalTokenPaths {
+		if path == prefix || strings.HasPrefix(path, prefix+"/") {
+			return "", false
+		}
+	}
+
+	switch {
+	case strings.HasPrefix(path, "/api/admin/"):
+		return models.ScopeAdmin, true
+	case r.Method == http.MethodGet || r.Method == http.MethodHead:
+		return models.ScopeReadProjects, true
+	case taskWritePath.MatchString(path):
+		return models.ScopeWriteTasks, true
+	default:
+		return models.ScopeAdmin, true
+	}
+}
+
+// GetTokenScopes retrieves the scopes of the personal access token the
+// request was authenticated with, or nil for a JWT
+func GetTokenScopes(ctx context.Context) []models.TokenScope {
+	if scopes, ok := ctx.Value("token_scopes").([]models.TokenScope); ok {
+		return scopes
+	}
+	return nil
+}
@@ -0,0 +1,64 @@
+package models
+
+import "time"
+
+// PersonalAccessTokenPrefix starts every personal access token, so they can
+// be told apart from JWTs and spotted by secret scanners
+const PersonalAccessTokenPrefix = "tfy_"
+
+// TokenScope limits what a personal access token may do
+type TokenScope string
+
+const (
+	// ScopeReadProjects allows every read (GET) request
+	ScopeReadProjects TokenScope = "read:projects"
+	// ScopeWriteTasks allows creating, changing and deleting tasks and their
+	// comments, subtasks, labels and watchers
+	ScopeWriteTasks TokenScope = "write:tasks"
+	// ScopeAdmin allows everything the token's user may do
+	ScopeAdmin TokenScope = "admin"
+)
+
+// TokenScopes lists every scope a personal access token can be given
+var TokenScopes = []TokenScope{ScopeReadProjects, ScopeWriteTasks, ScopeAdmin}
+
+// IsValid reports whether s is a known scope
+func (s TokenScope) IsValid() bool {
+	for _, scope := range TokenScopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// HasScope reports whether scopes grant required. The admin scope grants
+// every other scope.
+func HasScope(scopes []TokenScope, required TokenScope) bool {
+	for _, scope := range scopes {
+		if scope == required || scope == ScopeAdmin {
+			return true
+		}
+	}
+	return false
+}
+
+// PersonalAccessToken is a named, scoped API token a user creates for scripts
+// and CI. Only a hash of the token is stored; Token holds the plain value
+// only in the response that creates it.
+type PersonalAccessToken struct {
+	ID         string       `json:"id"`
+	UserID     string       `json:"-"`
+	Name       string       `json:"name"`
+	Prefix     string       `json:"prefix"`
+	Scopes     []TokenScope `json:"scopes"`
+	ExpiresAt  time.Time    `json:"expires_at"`
+	LastUsedAt *time.Time   `json:"last_used_at"`
+	CreatedAt  time.Time    `json:"created_at"`
+	Token      string       `json:"token,omitempty"`
+}
+
+// IsExpired reports whether the token has expired at the given time
+func (t *PersonalAccessToken) IsExpired(now time.Time) bool {
+	return !now.Before(t.ExpiresAt)
+}
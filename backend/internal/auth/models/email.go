@@ -0,0 +1,33 @@
+package models
+
+import "time"
+
+// EmailStatus is where an outbox email is in its delivery
+type EmailStatus string
+
+const (
+	// EmailPending is waiting for its first or next delivery attempt
+	EmailPending EmailStatus = "pending"
+	// EmailSent was accepted by the mail transport
+	EmailSent EmailStatus = "sent"
+	// EmailDead failed every attempt and is only retried by an admin
+	EmailDead EmailStatus = "dead"
+)
+
+// OutboxEmail is an email queued for delivery by the outbox worker. Bodies
+// carry one-time codes and links, so they are never serialized, and they are
+// cleared once the email is sent.
+type OutboxEmail struct {
+	ID            int64       `json:"id"`
+	To            string      `json:"to"`
+	Subject       string      `json:"subject"`
+	Body          string      `json:"-"`
+	HTMLBody      string      `json:"-"`
+	Status        EmailStatus `json:"status"`
+	Attempts      int         `json:"attempts"`
+	LastError     string      `json:"last_error,omitempty"`
+	NextAttemptAt time.Time   `json:"next_attempt_at"`
+	SentAt        *time.Time  `json:"sent_at,omitempty"`
+	CreatedAt     time.Time   `json:"created_at"`
+	UpdatedAt     time.Time   `json:"updated_at"`
+}
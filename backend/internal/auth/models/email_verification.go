@@ -0,0 +1,11 @@
+package models
+
+import "time"
+
+// EmailVerification is the pending verification of a user's email address.
+// The token mailed to the user is stored only as a hash.
+type EmailVerification struct {
+	UserID    string
+	ExpiresAt time.Time
+	SentAt    time.Time
+}
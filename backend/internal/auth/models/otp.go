@@ -0,0 +1,17 @@
+package models
+
+import "time"
+
+// PasswordResetOTP is the pending password reset of an email address: a
+// one-time code mailed to it and, once the code is verified, the reset token
+// it was exchanged for. Both are stored only as hashes.
+type PasswordResetOTP struct {
+	Email string
+	// CodeHash is empty once the code has been exchanged for a reset token
+	CodeHash  string
+	Attempts  int
+	ExpiresAt time.Time
+	SentAt    time.Time
+	// LockedUntil is set when too many wrong codes were tried
+	LockedUntil *time.Time
+}
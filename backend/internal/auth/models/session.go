@@ -0,0 +1,33 @@
+package models
+
+import "time"
+
+// RefreshToken is one refresh token of a session. Only a hash of the token
+// is stored. Every refresh rotates the token: the presented one is marked
+// rotated and a new one with the same FamilyID takes its place.
+type RefreshToken struct {
+	ID        string
+	FamilyID  string
+	UserID    string
+	TokenHash string
+	UserAgent string
+	IPAddress string
+	StartedAt time.Time
+	CreatedAt time.Time
+	ExpiresAt time.Time
+	RotatedAt *time.Time
+	RevokedAt *time.Time
+}
+
+// Session is a signed-in device as the user sees it: the family of refresh
+// tokens started by one login. Its ID is the family ID, which access tokens
+// carry in their "sid" claim.
+type Session struct {
+	ID         string    `json:"id"`
+	UserAgent  string    `json:"user_agent"`
+	IPAddress  string    `json:"ip_address"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastUsedAt time.Time `json:"last_used_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+	Current    bool      `json:"current"`
+}
@@ -0,0 +1,37 @@
+package models
+
+import "time"
+
+// TOTPSecret is a user's TOTP (RFC 6238) authenticator secret. It is pending
+// until the user proves their authenticator works by verifying a first code.
+type TOTPSecret struct {
+	UserID    string
+	Secret    string
+	EnabledAt *time.Time
+	// LastUsedStep is the time step of the last accepted code, so a code
+	// cannot be used twice
+	LastUsedStep int64
+}
+
+// TwoFactorChallenge is the pending second step of a sign-in, redeemed with a
+// TOTP or recovery code
+type TwoFactorChallenge struct {
+	ID        string
+	UserID    string
+	Attempts  int
+	ExpiresAt time.Time
+}
+
+// TwoFactorStatus describes a user's two-factor authentication
+type TwoFactorStatus struct {
+	Enabled                bool       `json:"enabled"`
+	EnabledAt              *time.Time `json:"enabled_at,omitempty"`
+	RecoveryCodesRemaining int        `json:"recovery_codes_remaining"`
+}
+
+// TwoFactorSetup is a new TOTP secret for the user to add to an authenticator
+// app, either by scanning OTPAuthURI as a QR code or by typing Secret
+type TwoFactorSetup struct {
+	Secret     string `json:"secret"`
+	OTPAuthURI string `json:"otpauth_uri"`
+}
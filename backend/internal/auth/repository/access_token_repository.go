@@ -0,0 +1,190 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"backend/internal/auth/models"
+)
+
+// AccessTokenRepository handles persistence for personal access tokens
+type AccessTokenRepository struct {
+	db *sql.DB
+}
+
+// NewAccessTokenRepository creates a new AccessTokenRepository
+func NewAccessTokenRepository(db *sql.DB) *AccessTokenRepository {
+	return &AccessTokenRepository{db: db}
+}
+
+// InitTable creates the personal access tokens table if it does not exist
+func (r *AccessTokenRepository) InitTable() error {
+	query := `
+		CREATE TABLE IF NOT EXISTS personal_access_tokens (
+			id TEXT PRIMARY KEY,
+			user_id TEXT NOT NULL,
+			name TEXT NOT NULL,
+			token_prefix TEXT NOT NULL,
+			token_hash TEXT NOT NULL UNIQUE,
+			scopes TEXT NOT NULL,
+			expires_at DATETIME NOT NULL,
+			last_used_at DATETIME,
+			created_at DATETIME NOT NULL,
+			revoked_at DATETIME,
+			FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+		)
+	`
+	if _, err := r.db.Exec(query); err != nil {
+		return fmt.Errorf("failed to create personal access tokens table: %v", err)
+	}
+
+	indexQuery := `CREATE INDEX IF NOT EXISTS idx_personal_access_tokens_user ON personal_access_tokens(user_id, revoked_at)`
+	if _, err := r.db.Exec(indexQuery); err != nil {
+		return fmt.Errorf("failed to create personal access token index: %v", err)
+	}
+
+	return nil
+}
+
+const accessTokenColumns = `id, user_id, name, token_prefix, scopes, expires_at, last_used_at, created_at`
+
+// Create stores a new personal access token under the hash of its value
+func (r *AccessTokenRepository) Create(token *models.PersonalAccessToken, tokenHash string) error {
+	_, err := r.db.Exec(`
+		INSERT INTO personal_access_tokens (id, user_id, name, token_prefix, token_hash, scopes, expires_at, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+	`,
+		token.ID,
+		token.UserID,
+		token.Name,
+		token.Prefix,
+		tokenHash,
+		joinScopes(token.Scopes),
+		token.ExpiresAt.UTC(),
+		token.CreatedAt.UTC(),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create personal access token: %v", err)
+	}
+	return nil
+}
+
+// GetActiveByHash retrieves the unrevoked token stored under tokenHash, or
+// nil when there is none. Expired tokens are returned; the caller checks.
+func (r *AccessTokenRepository) GetActiveByHash(tokenHash string) (*models.PersonalAccessToken, error) {
+	row := r.db.QueryRow("SELECT "+accessTokenColumns+" FROM personal_access_tokens WHERE token_hash = ? AND revoked_at IS NULL", tokenHash)
+
+	token, err := scanAccessToken(row)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get personal access token: %v", err)
+	}
+	return token, nil
+}
+
+// ListByUser lists the user's unrevoked tokens, newest first
+func (r *AccessTokenRepository) ListByUser(userID string) ([]models.PersonalAccessToken, error) {
+	rows, err := r.db.Query("SELECT "+accessTokenColumns+" FROM personal_access_tokens WHERE user_id = ? AND revoked_at IS NULL ORDER BY created_at DESC", userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list personal access tokens: %v", err)
+	}
+	defer rows.Close()
+
+	tokens := []models.PersonalAccessToken{}
+	for rows.Next() {
+		token, err := scanAccessToken(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan personal access token: %v", err)
+		}
+		tokens = append(tokens, *token)
+	}
+	return tokens, rows.Err()
+}
+
+// Revoke revokes one of the user's tokens. It reports false when the user has
+// no such unrevoked token.
+func (r *AccessTokenRepository) Revoke(userID, id string, now time.Time) (bool, error) {
+	result, err := r.db.Exec(`
+		UPDATE personal_access_tokens SET revoked_at = ?
+		WHERE id = ? AND user_id = ? AND revoked_at IS NULL
+	`, now.UTC(), id, userID)
+	if err != nil {
+		return false, fmt.Errorf("failed to revoke personal access token: %v", err)
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to revoke personal access token: %v", err)
+	}
+	return n > 0, nil
+}
+
+// RevokeAllByUser revokes every unrevoked token of the user
+func (r *AccessTokenRepository) RevokeAllByUser(userID string, now time.Time) error {
+	_, err := r.db.Exec(`
+		UPDATE personal_access_tokens SET revoked_at = ?
+		WHERE user_id = ? AND revoked_at IS NULL
+	`, now.UTC(), userID)
+	if err != nil {
+		return fmt.Errorf("failed to revoke personal access tokens: %v", err)
+	}
+	return nil
+}
+
+// TouchLastUsed records a use of the token. Uses less than resolution after
+// the recorded one are not written, so busy scripts do not cost a write per
+// request.
+func (r *AccessTokenRepository) TouchLastUsed(id string, now time.Time, resolution time.Duration) error {
+	_, err := r.db.Exec(`
+		UPDATE personal_access_tokens SET last_used_at = ?
+		WHERE id = ? AND (last_used_at IS NULL OR julianday(last_used_at) <= julianday(?))
+	`, now.UTC(), id, now.Add(-resolution).UTC())
+	if err != nil {
+		return fmt.Errorf("failed to record personal access token use: %v", err)
+	}
+	return nil
+}
+
+func scanAccessToken(row interface{ Scan(...interface{}) error }) (*models.PersonalAccessToken, error) {
+	var token models.PersonalAccessToken
+	var scopes string
+	var lastUsedAt sql.NullTime
+	err := row.Scan(
+		&token.ID,
+		&token.UserID,
+		&token.Name,
+		&token.Prefix,
+		&scopes,
+		&token.ExpiresAt,
+		&lastUsedAt,
+		&token.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	token.Scopes = splitScopes(scopes)
+	if lastUsedAt.Valid {
+		token.LastUsedAt = &lastUsedAt.Time
+	}
+	return &token, nil
+}
+
+// Scopes are stored space-separated, as in OAuth
+func joinScopes(scopes []models.TokenScope) string {
+	parts := make([]string, len(scopes))
+	for i, scope := range scopes {
+		parts[i] = string(scope)
+	}
+	return strings.Join(parts, " ")
+}
+
+func splitScopes(value string) []models.TokenScope {
+	scopes := []models.TokenScope{}
+	for _, part := range strings.Fields(value) {
+		scopes = append(scopes, models.TokenScope(part))
+	}
+	return scopes
+}
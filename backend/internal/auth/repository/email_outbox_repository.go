@@ -0,0 +1,237 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"backend/internal/auth/models"
+)
+
+// EmailOutboxRepository handles persistence for queued outbound emails
+type EmailOutboxRepository struct {
+	db *sql.DB
+}
+
+// NewEmailOutboxRepository creates a new EmailOutboxRepository
+func NewEmailOutboxRepository(db *sql.DB) *EmailOutboxRepository {
+	return &EmailOutboxRepository{db: db}
+}
+
+// InitTable creates the email outbox table if it does not exist
+func (r *EmailOutboxRepository) InitTable() error {
+	query := `
+		CREATE TABLE IF NOT EXISTS email_outbox (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			to_address TEXT NOT NULL,
+			subject TEXT NOT NULL,
+			body TEXT NOT NULL,
+			html_body TEXT,
+			status TEXT NOT NULL DEFAULT 'pending',
+			attempts INTEGER NOT NULL DEFAULT 0,
+			last_error TEXT,
+			next_attempt_at DATETIME NOT NULL,
+			sent_at DATETIME,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		)
+	`
+	if _, err := r.db.Exec(query); err != nil {
+		return fmt.Errorf("failed to create email outbox table: %v", err)
+	}
+
+	// Tables created before HTML emails lack the html_body column
+	hasHTML, err := hasColumn(r.db, "email_outbox", "html_body")
+	if err != nil {
+		return err
+	}
+	if !hasHTML {
+		if _, err := r.db.Exec(`ALTER TABLE email_outbox ADD COLUMN html_body TEXT`); err != nil {
+			return fmt.Errorf("failed to add html_body column: %v", err)
+		}
+	}
+
+	// Sent emails used to keep their bodies
+	if _, err := r.db.Exec(`UPDATE email_outbox SET body = '', html_body = NULL WHERE status = ? AND body <> ''`, models.EmailSent); err != nil {
+		return fmt.Errorf("failed to clear sent email bodies: %v", err)
+	}
+
+	indexQuery := `CREATE INDEX IF NOT EXISTS idx_email_outbox_status_next ON email_outbox(status, next_attempt_at)`
+	if _, err := r.db.Exec(indexQuery); err != nil {
+		return fmt.Errorf("failed to create email outbox index: %v", err)
+	}
+
+	return nil
+}
+
+// Enqueue stores a pending email that is due right away. htmlBody may be
+// empty for plain-text emails.
+func (r *EmailOutboxRepository) Enqueue(to, subject, body, htmlBody string) (int64, error) {
+	now := time.Now().UTC()
+	result, err := r.db.Exec(`
+		INSERT INTO email_outbox (to_address, subject, body, html_body, status, next_attempt_at, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+	`, to, subject, body, htmlBody, models.EmailPending, now, now, now)
+	if err != nil {
+		return 0, fmt.Errorf("failed to enqueue email: %v", err)
+	}
+	return result.LastInsertId()
+}
+
+// DueIDs lists pending emails whose next attempt is due, oldest first
+func (r *EmailOutboxRepository) DueIDs(now time.Time, limit int) ([]int64, error) {
+	rows, err := r.db.Query(`
+		SELECT id FROM email_outbox
+		WHERE status = ? AND julianday(next_attempt_at) <= julianday(?)
+		ORDER BY next_attempt_at ASC, id ASC
+		LIMIT ?
+	`, models.EmailPending, now.UTC(), limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list due emails: %v", err)
+	}
+	defer rows.Close()
+
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("failed to scan email id: %v", err)
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// Claim counts a delivery attempt and holds the email until leaseUntil, so
+// another worker (or this one after a crash) only picks it up again once the
+// lease runs out. It reports false when the email is no longer due.
+func (r *EmailOutboxRepository) Claim(id int64, now, leaseUntil time.Time) (bool, error) {
+	result, err := r.db.Exec(`
+		UPDATE email_outbox
+		SET attempts = attempts + 1, next_attempt_at = ?, updated_at = ?
+		WHERE id = ? AND status = ? AND julianday(next_attempt_at) <= julianday(?)
+	`, leaseUntil.UTC(), now.UTC(), id, models.EmailPending, now.UTC())
+	if err != nil {
+		return false, fmt.Errorf("failed to claim email: %v", err)
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to claim email: %v", err)
+	}
+	return n > 0, nil
+}
+
+// MarkSent records a successful delivery and clears the bodies, which are not
+// needed any more and may hold one-time codes or links
+func (r *EmailOutboxRepository) MarkSent(id int64, sentAt time.Time) error {
+	_, err := r.db.Exec(`
+		UPDATE email_outbox SET status = ?, body = '', html_body = NULL, last_error = NULL, sent_at = ?, updated_at = ? WHERE id = ?
+	`, models.EmailSent, sentAt.UTC(), sentAt.UTC(), id)
+	if err != nil {
+		return fmt.Errorf("failed to mark email sent: %v", err)
+	}
+	return nil
+}
+
+// MarkFailed records a failed attempt. The email is retried at nextAttemptAt,
+// or moved to the dead-letter state when dead is true.
+func (r *EmailOutboxRepository) MarkFailed(id int64, lastError string, nextAttemptAt time.Time, dead bool) error {
+	status := models.EmailPending
+	if dead {
+		status = models.EmailDead
+	}
+	_, err := r.db.Exec(`
+		UPDATE email_outbox SET status = ?, last_error = ?, next_attempt_at = ?, updated_at = ? WHERE id = ?
+	`, status, lastError, nextAttemptAt.UTC(), time.Now().UTC(), id)
+	if err != nil {
+		return fmt.Errorf("failed to record email failure: %v", err)
+	}
+	return nil
+}
+
+// Requeue makes a dead email pending again with a fresh set of attempts. It
+// reports false when the email does not exist or is not dead.
+func (r *EmailOutboxRepository) Requeue(id int64) (bool, error) {
+	now := time.Now().UTC()
+	result, err := r.db.Exec(`
+		UPDATE email_outbox SET status = ?, attempts = 0, next_attempt_at = ?, updated_at = ?
+		WHERE id = ? AND status = ?
+	`, models.EmailPending, now, now, id, models.EmailDead)
+	if err != nil {
+		return false, fmt.Errorf("failed to requeue email: %v", err)
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to requeue email: %v", err)
+	}
+	return n > 0, nil
+}
+
+// PurgeSentBefore deletes every email sent before cutoff and returns how many
+// were deleted
+func (r *EmailOutboxRepository) PurgeSentBefore(cutoff time.Time) (int64, error) {
+	result, err := r.db.Exec(`
+		DELETE FROM email_outbox WHERE status = ? AND julianday(sent_at) < julianday(?)
+	`, models.EmailSent, cutoff.UTC())
+	if err != nil {
+		return 0, fmt.Errorf("failed to purge sent emails: %v", err)
+	}
+	return result.RowsAffected()
+}
+
+const outboxColumns = `id, to_address, subject, body, COALESCE(html_body, ''), status, attempts, COALESCE(last_error, ''), next_attempt_at, sent_at, created_at, updated_at`
+
+// GetByID retrieves an outbox email, or nil when it does not exist
+func (r *EmailOutboxRepository) GetByID(id int64) (*models.OutboxEmail, error) {
+	email, err := scanOutboxEmail(r.db.QueryRow("SELECT "+outboxColumns+" FROM email_outbox WHERE id = ?", id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get email: %v", err)
+	}
+	return email, nil
+}
+
+// ListByStatus lists outbox emails with the given status, newest first
+func (r *EmailOutboxRepository) ListByStatus(status models.EmailStatus, limit, offset int) ([]models.OutboxEmail, int64, error) {
+	var total int64
+	if err := r.db.QueryRow("SELECT COUNT(*) FROM email_outbox WHERE status = ?", status).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("failed to count emails: %v", err)
+	}
+
+	rows, err := r.db.Query("SELECT "+outboxColumns+" FROM email_outbox WHERE status = ? ORDER BY updated_at DESC, id DESC LIMIT ? OFFSET ?",
+		status, limit, offset)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to list emails: %v", err)
+	}
+	defer rows.Close()
+
+	emails := []models.OutboxEmail{}
+	for rows.Next() {
+		email, err := scanOutboxEmail(rows)
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to scan email: %v", err)
+		}
+		emails = append(emails, *email)
+	}
+	return emails, total, rows.Err()
+}
+
+type outboxScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanOutboxEmail(row outboxScanner) (*models.OutboxEmail, error) {
+	var email models.OutboxEmail
+	var sentAt sql.NullTime
+	err := row.Scan(&email.ID, &email.To, &email.Subject, &email.Body, &email.HTMLBody, &email.Status, &email.Attempts,
+		&email.LastError, &email.NextAttemptAt, &sentAt, &email.CreatedAt, &email.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	if sentAt.Valid {
+		email.SentAt = &sentAt.Time
+	}
+	return &email, nil
+}
@@ -0,0 +1,93 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+
+	"backend/internal/auth/models"
+)
+
+// EmailVerificationRepository handles persistence for pending email address
+// verifications. Each user has at most one; sending a new link replaces it.
+type EmailVerificationRepository struct {
+	db *sql.DB
+}
+
+// NewEmailVerificationRepository creates a new EmailVerificationRepository
+func NewEmailVerificationRepository(db *sql.DB) *EmailVerificationRepository {
+	return &EmailVerificationRepository{db: db}
+}
+
+// InitTable creates the email_verifications table if it does not exist
+func (r *EmailVerificationRepository) InitTable() error {
+	query := `
+		CREATE TABLE IF NOT EXISTS email_verifications (
+			user_id TEXT PRIMARY KEY,
+			token_hash TEXT NOT NULL UNIQUE,
+			expires_at DATETIME NOT NULL,
+			sent_at DATETIME NOT NULL,
+			FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+		)
+	`
+	if _, err := r.db.Exec(query); err != nil {
+		return fmt.Errorf("failed to create email_verifications table: %v", err)
+	}
+	return nil
+}
+
+// Save stores the user's verification under the hash of its token, replacing
+// any earlier one
+func (r *EmailVerificationRepository) Save(verification *models.EmailVerification, tokenHash string) error {
+	_, err := r.db.Exec(`
+		INSERT INTO email_verifications (user_id, token_hash, expires_at, sent_at)
+		VALUES (?, ?, ?, ?)
+		ON CONFLICT(user_id) DO UPDATE SET
+			token_hash = excluded.token_hash,
+			expires_at = excluded.expires_at,
+			sent_at = excluded.sent_at
+	`, verification.UserID, tokenHash, verification.ExpiresAt.UTC(), verification.SentAt.UTC())
+	if err != nil {
+		return fmt.Errorf("failed to save email verification: %v", err)
+	}
+	return nil
+}
+
+// GetByUser retrieves the user's pending verification, or nil when there is
+// none
+func (r *EmailVerificationRepository) GetByUser(userID string) (*models.EmailVerification, error) {
+	return r.get("user_id = ?", userID)
+}
+
+// GetByTokenHash retrieves a verification by the hash of its token, or nil
+// when there is none
+func (r *EmailVerificationRepository) GetByTokenHash(tokenHash string) (*models.EmailVerification, error) {
+	return r.get("token_hash = ?", tokenHash)
+}
+
+// DeleteByTokenHash removes a verification once it is redeemed. It reports
+// false when the verification was already gone.
+func (r *EmailVerificationRepository) DeleteByTokenHash(tokenHash string) (bool, error) {
+	result, err := r.db.Exec("DELETE FROM email_verifications WHERE token_hash = ?", tokenHash)
+	if err != nil {
+		return false, fmt.Errorf("failed to delete email verification: %v", err)
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to delete email verification: %v", err)
+	}
+	return n > 0, nil
+}
+
+func (r *EmailVerificationRepository) get(where string, arg interface{}) (*models.EmailVerification, error) {
+	var verification models.EmailVerification
+	err := r.db.QueryRow(`
+		SELECT user_id, expires_at, sent_at FROM email_verifications WHERE `+where, arg,
+	).Scan(&verification.UserID, &verification.ExpiresAt, &verification.SentAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get email verification: %v", err)
+	}
+	return &verification, nil
+}
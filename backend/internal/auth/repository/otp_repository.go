@@ -0,0 +1,170 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"backend/internal/auth/models"
+)
+
+// OTPRepository handles persistence for password reset codes and the reset
+// tokens they are exchanged for. Each email address has at most one pending
+// reset; requesting a new code replaces it.
+type OTPRepository struct {
+	db *sql.DB
+}
+
+// NewOTPRepository creates a new OTPRepository
+func NewOTPRepository(db *sql.DB) *OTPRepository {
+	return &OTPRepository{db: db}
+}
+
+// InitTable creates the password_reset_otps table if it does not exist
+func (r *OTPRepository) InitTable() error {
+	query := `
+		CREATE TABLE IF NOT EXISTS password_reset_otps (
+			email TEXT PRIMARY KEY,
+			code_hash TEXT NOT NULL,
+			attempts INTEGER NOT NULL DEFAULT 0,
+			expires_at DATETIME NOT NULL,
+			sent_at DATETIME NOT NULL,
+			locked_until DATETIME,
+			reset_token_hash TEXT UNIQUE,
+			reset_expires_at DATETIME
+		)
+	`
+	if _, err := r.db.Exec(query); err != nil {
+		return fmt.Errorf("failed to create password_reset_otps table: %v", err)
+	}
+	return nil
+}
+
+// Get retrieves the pending reset of an email address, or nil when there is
+// none
+func (r *OTPRepository) Get(email string) (*models.PasswordResetOTP, error) {
+	var otp models.PasswordResetOTP
+	var lockedUntil sql.NullTime
+	err := r.db.QueryRow(`
+		SELECT email, code_hash, attempts, expires_at, sent_at, locked_until
+		FROM password_reset_otps WHERE email = ?
+	`, email).Scan(&otp.Email, &otp.CodeHash, &otp.Attempts, &otp.ExpiresAt, &otp.SentAt, &lockedUntil)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get OTP: %v", err)
+	}
+	if lockedUntil.Valid {
+		otp.LockedUntil = &lockedUntil.Time
+	}
+	return &otp, nil
+}
+
+// Save stores a new code for the email address, replacing any earlier code
+// or reset token, and clears out resets that have run out on the way
+func (r *OTPRepository) Save(otp *models.PasswordResetOTP) error {
+	now := time.Now().UTC()
+	if _, err := r.db.Exec(`
+		DELETE FROM password_reset_otps
+		WHERE julianday(expires_at) <= julianday(?)
+			AND (locked_until IS NULL OR julianday(locked_until) <= julianday(?))
+			AND (reset_expires_at IS NULL OR julianday(reset_expires_at) <= julianday(?))
+	`, now, now, now); err != nil {
+		return fmt.Errorf("failed to delete expired OTPs: %v", err)
+	}
+
+	_, err := r.db.Exec(`
+		INSERT INTO password_reset_otps (email, code_hash, attempts, expires_at, sent_at)
+		VALUES (?, ?, 0, ?, ?)
+		ON CONFLICT(email) DO UPDATE SET
+			code_hash = excluded.code_hash,
+			attempts = 0,
+			expires_at = excluded.expires_at,
+			sent_at = excluded.sent_at,
+			locked_until = NULL,
+			reset_token_hash = NULL,
+			reset_expires_at = NULL
+	`, otp.Email, otp.CodeHash, otp.ExpiresAt.UTC(), otp.SentAt.UTC())
+	if err != nil {
+		return fmt.Errorf("failed to save OTP: %v", err)
+	}
+	return nil
+}
+
+// CountAttempt records an attempt at the email address's code before it is
+// checked. It reports false, recording nothing, when the code already had
+// maxAttempts; the attempt that reaches maxAttempts locks the address until
+// lockedUntil.
+func (r *OTPRepository) CountAttempt(email string, maxAttempts int, lockedUntil time.Time) (bool, error) {
+	result, err := r.db.Exec(`
+		UPDATE password_reset_otps SET
+			attempts = attempts + 1,
+			locked_until = CASE WHEN attempts + 1 >= ? THEN ? ELSE locked_until END
+		WHERE email = ? AND attempts < ?
+	`, maxAttempts, lockedUntil.UTC(), email, maxAttempts)
+	if err != nil {
+		return false, fmt.Errorf("failed to record OTP attempt: %v", err)
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to record OTP attempt: %v", err)
+	}
+	return n > 0, nil
+}
+
+// SetResetToken exchanges the email address's code, identified by its hash,
+// for a reset token stored under tokenHash. It reports false when the code
+// was already exchanged or replaced.
+func (r *OTPRepository) SetResetToken(email, codeHash, tokenHash string, expiresAt time.Time) (bool, error) {
+	result, err := r.db.Exec(`
+		UPDATE password_reset_otps SET code_hash = '', reset_token_hash = ?, reset_expires_at = ?, locked_until = NULL
+		WHERE email = ? AND code_hash = ? AND code_hash <> ''
+	`, tokenHash, expiresAt.UTC(), email, codeHash)
+	if err != nil {
+		return false, fmt.Errorf("failed to store reset token: %v", err)
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to store reset token: %v", err)
+	}
+	return n > 0, nil
+}
+
+// ConsumeResetToken removes the reset stored under tokenHash and returns its
+// email address and expiry. It returns an empty email when no reset has that
+// token, including when another request consumed it first.
+func (r *OTPRepository) ConsumeResetToken(tokenHash string) (string, time.Time, error) {
+	var email string
+	var expiresAt time.Time
+	err := r.db.QueryRow(`
+		SELECT email, reset_expires_at FROM password_reset_otps WHERE reset_token_hash = ?
+	`, tokenHash).Scan(&email, &expiresAt)
+	if err == sql.ErrNoRows {
+		return "", time.Time{}, nil
+	}
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("failed to get reset token: %v", err)
+	}
+
+	result, err := r.db.Exec("DELETE FROM password_reset_otps WHERE reset_token_hash = ?", tokenHash)
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("failed to consume reset token: %v", err)
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("failed to consume reset token: %v", err)
+	}
+	if n == 0 {
+		return "", time.Time{}, nil
+	}
+	return email, expiresAt, nil
+}
+
+// Delete removes the pending reset of an email address
+func (r *OTPRepository) Delete(email string) error {
+	if _, err := r.db.Exec("DELETE FROM password_reset_otps WHERE email = ?", email); err != nil {
+		return fmt.Errorf("failed to delete OTP: %v", err)
+	}
+	return nil
+}
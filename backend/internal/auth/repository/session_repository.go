@@ -0,0 +1,283 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"backend/internal/auth/models"
+)
+
+// SessionRepository handles persistence for sessions and their refresh tokens
+type SessionRepository struct {
+	db *sql.DB
+}
+
+// NewSessionRepository creates a new SessionRepository
+func NewSessionRepository(db *sql.DB) *SessionRepository {
+	return &SessionRepository{db: db}
+}
+
+// InitTable creates the sessions table if it does not exist. Each row is one
+// refresh token; the rows sharing a family_id make up one session.
+func (r *SessionRepository) InitTable() error {
+	query := `
+		CREATE TABLE IF NOT EXISTS sessions (
+			id TEXT PRIMARY KEY,
+			family_id TEXT NOT NULL,
+			user_id TEXT NOT NULL,
+			token_hash TEXT NOT NULL UNIQUE,
+			user_agent TEXT NOT NULL DEFAULT '',
+			ip_address TEXT NOT NULL DEFAULT '',
+			started_at DATETIME NOT NULL,
+			created_at DATETIME NOT NULL,
+			expires_at DATETIME NOT NULL,
+			rotated_at DATETIME,
+			revoked_at DATETIME,
+			FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+		)
+	`
+	if _, err := r.db.Exec(query); err != nil {
+		return fmt.Errorf("failed to create sessions table: %v", err)
+	}
+
+	indexes := []string{
+		"CREATE INDEX IF NOT EXISTS idx_sessions_family ON sessions(family_id)",
+		"CREATE INDEX IF NOT EXISTS idx_sessions_user ON sessions(user_id, revoked_at)",
+		"CREATE INDEX IF NOT EXISTS idx_sessions_expires ON sessions(expires_at)",
+	}
+
+	for _, indexQuery := range indexes {
+		if _, err := r.db.Exec(indexQuery); err != nil {
+			return fmt.Errorf("failed to create session index: %v", err)
+		}
+	}
+
+	return nil
+}
+
+const refreshTokenColumns = `id, family_id, user_id, token_hash, user_agent, ip_address, started_at, created_at, expires_at, rotated_at, revoked_at`
+
+// CreateRefreshToken stores the first refresh token of a new session
+func (r *SessionRepository) CreateRefreshToken(token *models.RefreshToken) error {
+	if err := insertRefreshToken(r.db, token); err != nil {
+		return fmt.Errorf("failed to create refresh token: %v", err)
+	}
+	return nil
+}
+
+// GetRefreshTokenByHash retrieves a refresh token by the hash of its value,
+// or nil when no such token was ever issued
+func (r *SessionRepository) GetRefreshTokenByHash(tokenHash string) (*models.RefreshToken, error) {
+	row := r.db.QueryRow("SELECT "+refreshTokenColumns+" FROM sessions WHERE token_hash = ?", tokenHash)
+
+	var token models.RefreshToken
+	var rotatedAt, revokedAt sql.NullTime
+	err := row.Scan(
+		&token.ID,
+		&token.FamilyID,
+		&token.UserID,
+		&token.TokenHash,
+		&token.UserAgent,
+		&token.IPAddress,
+		&token.StartedAt,
+		&token.CreatedAt,
+		&token.ExpiresAt,
+		&rotatedAt,
+		&revokedAt,
+	)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get refresh token: %v", err)
+	}
+	if rotatedAt.Valid {
+		token.RotatedAt = &rotatedAt.Time
+	}
+	if revokedAt.Valid {
+		token.RevokedAt = &revokedAt.Time
+	}
+	return &token, nil
+}
+
+// RotateRefreshToken marks the token oldID as used and stores next in its
+// place. It reports false, storing nothing, when oldID was already rotated or
+// revoked, which happens when two requests race with the same token.
+func (r *SessionRepository) RotateRefreshToken(oldID string, next *models.RefreshToken) (bool, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return false, fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`
+		UPDATE sessions SET rotated_at = ?
+		WHERE id = ? AND rotated_at IS NULL AND revoked_at IS NULL
+	`, next.CreatedAt.UTC(), oldID)
+	if err != nil {
+		return false, fmt.Errorf("failed to rotate refresh token: %v", err)
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to rotate refresh token: %v", err)
+	}
+	if n == 0 {
+		return false, nil
+	}
+
+	if err := insertRefreshToken(tx, next); err != nil {
+		return false, fmt.Errorf("failed to store rotated refresh token: %v", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("failed to commit transaction: %v", err)
+	}
+	return true, nil
+}
+
+// IsSessionActive reports whether the user's session has a refresh token
+// that is neither revoked nor expired
+func (r *SessionRepository) IsSessionActive(familyID, userID string, now time.Time) (bool, error) {
+	var exists bool
+	err := r.db.QueryRow(`
+		SELECT EXISTS (
+			SELECT 1 FROM sessions
+			WHERE family_id = ? AND user_id = ? AND revoked_at IS NULL AND julianday(expires_at) > julianday(?)
+		)
+	`, familyID, userID, now.UTC()).Scan(&exists)
+	if err != nil {
+		return false, fmt.Errorf("failed to check session: %v", err)
+	}
+	return exists, nil
+}
+
+// ListActiveSessions lists the user's sessions that can still be refreshed,
+// most recently used first
+func (r *SessionRepository) ListActiveSessions(userID string, now time.Time) ([]models.Session, error) {
+	rows, err := r.db.Query(`
+		SELECT family_id, user_agent, ip_address, started_at, created_at, expires_at
+		FROM sessions
+		WHERE user_id = ? AND rotated_at IS NULL AND revoked_at IS NULL AND julianday(expires_at) > julianday(?)
+		ORDER BY created_at DESC
+	`, userID, now.UTC())
+	if err != nil {
+		return nil, fmt.Errorf("failed to list sessions: %v", err)
+	}
+	defer rows.Close()
+
+	sessions := []models.Session{}
+	for rows.Next() {
+		var session models.Session
+		if err := rows.Scan(
+			&session.ID,
+			&session.UserAgent,
+			&session.IPAddress,
+			&session.CreatedAt,
+			&session.LastUsedAt,
+			&session.ExpiresAt,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan session: %v", err)
+		}
+		sessions = append(sessions, session)
+	}
+	return sessions, rows.Err()
+}
+
+// RevokeFamily revokes every refresh token of a session
+func (r *SessionRepository) RevokeFamily(familyID string, now time.Time) error {
+	_, err := r.db.Exec(`
+		UPDATE sessions SET revoked_at = ? WHERE family_id = ? AND revoked_at IS NULL
+	`, now.UTC(), familyID)
+	if err != nil {
+		return fmt.Errorf("failed to revoke session: %v", err)
+	}
+	return nil
+}
+
+// RevokeUserSession revokes one of the user's sessions. It reports false when
+// the user has no such session that is still active.
+func (r *SessionRepository) RevokeUserSession(userID, familyID string, now time.Time) (bool, error) {
+	result, err := r.db.Exec(`
+		UPDATE sessions SET revoked_at = ?
+		WHERE user_id = ? AND family_id = ? AND revoked_at IS NULL
+	`, now.UTC(), userID, familyID)
+	if err != nil {
+		return false, fmt.Errorf("failed to revoke session: %v", err)
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to revoke session: %v", err)
+	}
+	return n > 0, nil
+}
+
+// RevokeUserSessions revokes all of the user's sessions except exceptFamilyID,
+// which may be empty, and returns how many sessions were revoked
+func (r *SessionRepository) RevokeUserSessions(userID, exceptFamilyID string, now time.Time) (int64, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	var count int64
+	err = tx.QueryRow(`
+		SELECT COUNT(DISTINCT family_id) FROM sessions
+		WHERE user_id = ? AND family_id != ? AND rotated_at IS NULL AND revoked_at IS NULL AND julianday(expires_at) > julianday(?)
+	`, userID, exceptFamilyID, now.UTC()).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count sessions: %v", err)
+	}
+
+	if _, err := tx.Exec(`
+		UPDATE sessions SET revoked_at = ?
+		WHERE user_id = ? AND family_id != ? AND revoked_at IS NULL
+	`, now.UTC(), userID, exceptFamilyID); err != nil {
+		return 0, fmt.Errorf("failed to revoke sessions: %v", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit transaction: %v", err)
+	}
+	return count, nil
+}
+
+// DeleteExpired removes refresh tokens that expired before the given time.
+// Sessions are kept until their newest token expires, since rotated tokens
+// are needed to detect reuse.
+func (r *SessionRepository) DeleteExpired(before time.Time) (int64, error) {
+	result, err := r.db.Exec(`
+		DELETE FROM sessions WHERE family_id IN (
+			SELECT family_id FROM sessions
+			GROUP BY family_id
+			HAVING julianday(MAX(expires_at)) < julianday(?)
+		)
+	`, before.UTC())
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete expired sessions: %v", err)
+	}
+	return result.RowsAffected()
+}
+
+type sqlExecer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+}
+
+func insertRefreshToken(db sqlExecer, token *models.RefreshToken) error {
+	_, err := db.Exec(`
+		INSERT INTO sessions (id, family_id, user_id, token_hash, user_agent, ip_address, started_at, created_at, expires_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`,
+		token.ID,
+		token.FamilyID,
+		token.UserID,
+		token.TokenHash,
+		token.UserAgent,
+		token.IPAddress,
+		token.StartedAt.UTC(),
+		token.CreatedAt.UTC(),
+		token.ExpiresAt.UTC(),
+	)
+	return err
+}
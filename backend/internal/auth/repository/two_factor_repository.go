@@ -0,0 +1,313 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"backend/internal/auth/models"
+)
+
+// TwoFactorRepository handles persistence for TOTP secrets, recovery codes
+// and pending two-factor sign-in challenges
+type TwoFactorRepository struct {
+	db *sql.DB
+}
+
+// NewTwoFactorRepository creates a new TwoFactorRepository
+func NewTwoFactorRepository(db *sql.DB) *TwoFactorRepository {
+	return &TwoFactorRepository{db: db}
+}
+
+// InitTable creates the two-factor tables if they do not exist
+func (r *TwoFactorRepository) InitTable() error {
+	tables := []struct {
+		name  string
+		query string
+	}{
+		{"user_totp", `
+			CREATE TABLE IF NOT EXISTS user_totp (
+				user_id TEXT PRIMARY KEY,
+				secret TEXT NOT NULL,
+				enabled_at DATETIME,
+				last_used_step INTEGER NOT NULL DEFAULT 0,
+				created_at DATETIME NOT NULL,
+				updated_at DATETIME NOT NULL,
+				FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+			)
+		`},
+		{"recovery_codes", `
+			CREATE TABLE IF NOT EXISTS recovery_codes (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				user_id TEXT NOT NULL,
+				code_hash TEXT NOT NULL,
+				used_at DATETIME,
+				created_at DATETIME NOT NULL,
+				FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+			)
+		`},
+		{"two_factor_challenges", `
+			CREATE TABLE IF NOT EXISTS two_factor_challenges (
+				id TEXT PRIMARY KEY,
+				user_id TEXT NOT NULL,
+				token_hash TEXT NOT NULL UNIQUE,
+				attempts INTEGER NOT NULL DEFAULT 0,
+				expires_at DATETIME NOT NULL,
+				created_at DATETIME NOT NULL,
+				FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+			)
+		`},
+	}
+	for _, table := range tables {
+		if _, err := r.db.Exec(table.query); err != nil {
+			return fmt.Errorf("failed to create %s table: %v", table.name, err)
+		}
+	}
+
+	indexes := []string{
+		"CREATE INDEX IF NOT EXISTS idx_recovery_codes_user ON recovery_codes(user_id, code_hash)",
+		"CREATE INDEX IF NOT EXISTS idx_two_factor_challenges_expires ON two_factor_challenges(expires_at)",
+	}
+	for _, indexQuery := range indexes {
+		if _, err := r.db.Exec(indexQuery); err != nil {
+			return fmt.Errorf("failed to create two-factor index: %v", err)
+		}
+	}
+
+	return nil
+}
+
+// GetTOTP retrieves the user's TOTP secret, or nil when they have none
+func (r *TwoFactorRepository) GetTOTP(userID string) (*models.TOTPSecret, error) {
+	var secret models.TOTPSecret
+	var enabledAt sql.NullTime
+	err := r.db.QueryRow(`
+		SELECT user_id, secret, enabled_at, last_used_step FROM user_totp WHERE user_id = ?
+	`, userID).Scan(&secret.UserID, &secret.Secret, &enabledAt, &secret.LastUsedStep)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get TOTP secret: %v", err)
+	}
+	if enabledAt.Valid {
+		secret.EnabledAt = &enabledAt.Time
+	}
+	return &secret, nil
+}
+
+// SavePendingTOTP stores a new, not yet enabled secret for the user,
+// replacing any earlier pending one
+func (r *TwoFactorRepository) SavePendingTOTP(userID, secret string) error {
+	now := time.Now().UTC()
+	_, err := r.db.Exec(`
+		INSERT INTO user_totp (user_id, secret, enabled_at, last_used_step, created_at, updated_at)
+		VALUES (?, ?, NULL, 0, ?, ?)
+		ON CONFLICT(user_id) DO UPDATE SET
+			secret = excluded.secret,
+			enabled_at = NULL,
+			last_used_step = 0,
+			updated_at = excluded.updated_at
+	`, userID, secret, now, now)
+	if err != nil {
+		return fmt.Errorf("failed to save TOTP secret: %v", err)
+	}
+	return nil
+}
+
+// EnableTOTP turns on the user's pending secret, recording the step of the
+// code that proved it, and stores their recovery codes
+func (r *TwoFactorRepository) EnableTOTP(userID string, step int64, recoveryCodeHashes []string) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	now := time.Now().UTC()
+	if _, err := tx.Exec(`
+		UPDATE user_totp SET enabled_at = ?, last_used_step = ?, updated_at = ? WHERE user_id = ?
+	`, now, step, now, userID); err != nil {
+		return fmt.Errorf("failed to enable TOTP: %v", err)
+	}
+
+	if err := replaceRecoveryCodes(tx, userID, recoveryCodeHashes, now); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %v", err)
+	}
+	return nil
+}
+
+// UseTOTPStep records that a code of the given time step was accepted. It
+// reports false when that step or a later one was already used.
+func (r *TwoFactorRepository) UseTOTPStep(userID string, step int64) (bool, error) {
+	result, err := r.db.Exec(`
+		UPDATE user_totp SET last_used_step = ?, updated_at = ?
+		WHERE user_id = ? AND last_used_step < ?
+	`, step, time.Now().UTC(), userID, step)
+	if err != nil {
+		return false, fmt.Errorf("failed to record TOTP use: %v", err)
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to record TOTP use: %v", err)
+	}
+	return n > 0, nil
+}
+
+// DeleteTOTP removes the user's secret and recovery codes
+func (r *TwoFactorRepository) DeleteTOTP(userID string) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("DELETE FROM user_totp WHERE user_id = ?", userID); err != nil {
+		return fmt.Errorf("failed to delete TOTP secret: %v", err)
+	}
+	if _, err := tx.Exec("DELETE FROM recovery_codes WHERE user_id = ?", userID); err != nil {
+		return fmt.Errorf("failed to delete recovery codes: %v", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %v", err)
+	}
+	return nil
+}
+
+// ReplaceRecoveryCodes swaps the user's recovery codes for a new set
+func (r *TwoFactorRepository) ReplaceRecoveryCodes(userID string, codeHashes []string) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	if err := replaceRecoveryCodes(tx, userID, codeHashes, time.Now().UTC()); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %v", err)
+	}
+	return nil
+}
+
+// UseRecoveryCode marks one of the user's unused recovery codes as used. It
+// reports false when no unused code has that hash.
+func (r *TwoFactorRepository) UseRecoveryCode(userID, codeHash string) (bool, error) {
+	result, err := r.db.Exec(`
+		UPDATE recovery_codes SET used_at = ?
+		WHERE id = (
+			SELECT id FROM recovery_codes
+			WHERE user_id = ? AND code_hash = ? AND used_at IS NULL
+			LIMIT 1
+		)
+	`, time.Now().UTC(), userID, codeHash)
+	if err != nil {
+		return false, fmt.Errorf("failed to use recovery code: %v", err)
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to use recovery code: %v", err)
+	}
+	return n > 0, nil
+}
+
+// CountUnusedRecoveryCodes counts the user's recovery codes not yet used
+func (r *TwoFactorRepository) CountUnusedRecoveryCodes(userID string) (int, error) {
+	var count int
+	err := r.db.QueryRow(`
+		SELECT COUNT(*) FROM recovery_codes WHERE user_id = ? AND used_at IS NULL
+	`, userID).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count recovery codes: %v", err)
+	}
+	return count, nil
+}
+
+// CreateChallenge stores a sign-in challenge under the hash of its token,
+// clearing out expired challenges on the way
+func (r *TwoFactorRepository) CreateChallenge(challenge *models.TwoFactorChallenge, tokenHash string) error {
+	now := time.Now().UTC()
+	if _, err := r.db.Exec(`
+		DELETE FROM two_factor_challenges WHERE julianday(expires_at) <= julianday(?)
+	`, now); err != nil {
+		return fmt.Errorf("failed to delete expired challenges: %v", err)
+	}
+
+	_, err := r.db.Exec(`
+		INSERT INTO two_factor_challenges (id, user_id, token_hash, attempts, expires_at, created_at)
+		VALUES (?, ?, ?, 0, ?, ?)
+	`, challenge.ID, challenge.UserID, tokenHash, challenge.ExpiresAt.UTC(), now)
+	if err != nil {
+		return fmt.Errorf("failed to create challenge: %v", err)
+	}
+	return nil
+}
+
+// GetChallengeByHash retrieves a challenge by the hash of its token, or nil
+// when there is none
+func (r *TwoFactorRepository) GetChallengeByHash(tokenHash string) (*models.TwoFactorChallenge, error) {
+	var challenge models.TwoFactorChallenge
+	err := r.db.QueryRow(`
+		SELECT id, user_id, attempts, expires_at FROM two_factor_challenges WHERE token_hash = ?
+	`, tokenHash).Scan(&challenge.ID, &challenge.UserID, &challenge.Attempts, &challenge.ExpiresAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get challenge: %v", err)
+	}
+	return &challenge, nil
+}
+
+// ClaimChallengeAttempt counts an attempt at a challenge before its code is
+// checked. It reports false when the challenge is gone, expired or has no
+// attempts left, so parallel requests cannot get past maxAttempts.
+func (r *TwoFactorRepository) ClaimChallengeAttempt(id string, maxAttempts int, now time.Time) (bool, error) {
+	result, err := r.db.Exec(`
+		UPDATE two_factor_challenges SET attempts = attempts + 1
+		WHERE id = ? AND attempts < ? AND julianday(expires_at) > julianday(?)
+	`, id, maxAttempts, now.UTC())
+	if err != nil {
+		return false, fmt.Errorf("failed to record challenge attempt: %v", err)
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to record challenge attempt: %v", err)
+	}
+	return n > 0, nil
+}
+
+// DeleteChallenge removes a challenge once it is redeemed or used up. It
+// reports false when the challenge was already gone.
+func (r *TwoFactorRepository) DeleteChallenge(id string) (bool, error) {
+	result, err := r.db.Exec("DELETE FROM two_factor_challenges WHERE id = ?", id)
+	if err != nil {
+		return false, fmt.Errorf("failed to delete challenge: %v", err)
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to delete challenge: %v", err)
+	}
+	return n > 0, nil
+}
+
+func replaceRecoveryCodes(tx *sql.Tx, userID string, codeHashes []string, now time.Time) error {
+	if _, err := tx.Exec("DELETE FROM recovery_codes WHERE user_id = ?", userID); err != nil {
+		return fmt.Errorf("failed to delete recovery codes: %v", err)
+	}
+	for _, codeHash := range codeHashes {
+		if _, err := tx.Exec(`
+			INSERT INTO recovery_codes (user_id, code_hash, created_at) VALUES (?, ?, ?)
+		`, userID, codeHash, now); err != nil {
+			return fmt.Errorf("failed to store recovery code: %v", err)
+		}
+	}
+	return nil
+}
@@ -21,8 +21,8 @@ func NewUserRepository(db *sql.DB) *UserRepository {
 // CreateUser inserts a new user into the database
 func (r *UserRepository) CreateUser(user *models.User) error {
 	query := `
-		INSERT INTO users (id, name, email, password_hash, role, is_active, created_at, updated_at)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		INSERT INTO users (id, name, email, password_hash, role, is_active, email_verified, language, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 	`
 	_, err := r.db.Exec(query,
 		user.ID,
@@ -31,6 +31,8 @@ func (r *UserRepository) CreateUser(user *models.User) error {
 		nullablePasswordHash(user.PasswordHash),
 		user.Role,
 		user.IsActive,
+		user.EmailVerified,
+		user.Language,
 		user.CreatedAt,
 		user.UpdatedAt,
 	)
@@ -43,7 +45,7 @@ func (r *UserRepository) CreateUser(user *models.User) error {
 // GetUserByEmail retrieves a user by email address
 func (r *UserRepository) GetUserByEmail(email string) (*models.User, error) {
 	query := `
-		SELECT id, name, email, password_hash, role, is_active, created_at, updated_at
+		SELECT id, name, email, password_hash, role, is_active, email_verified, language, created_at, updated_at
 		FROM users
 		WHERE email = ?
 	`
@@ -58,6 +60,8 @@ func (r *UserRepository) GetUserByEmail(email string) (*models.User, error) {
 		&passwordHash,
 		&user.Role,
 		&user.IsActive,
+		&user.EmailVerified,
+		&user.Language,
 		&user.CreatedAt,
 		&user.UpdatedAt,
 	)
@@ -74,7 +78,7 @@ func (r *UserRepository) GetUserByEmail(email string) (*models.User, error) {
 // GetUserByID retrieves a user by ID
 func (r *UserRepository) GetUserByID(id string) (*models.User, error) {
 	query := `
-		SELECT id, name, email, password_hash, role, is_active, created_at, updated_at
+		SELECT id, name, email, password_hash, role, is_active, email_verified, language, created_at, updated_at
 		FROM users
 		WHERE id = ?
 	`
@@ -89,6 +93,8 @@ func (r *UserRepository) GetUserByID(id string) (*models.User, error) {
 		&passwordHash,
 		&user.Role,
 		&user.IsActive,
+		&user.EmailVerified,
+		&user.Language,
 		&user.CreatedAt,
 		&user.UpdatedAt,
 	)
@@ -123,6 +129,8 @@ func (r *UserRepository) InitTable() error {
 			password_hash TEXT,
 			role TEXT DEFAULT 'user',
 			is_active INTEGER DEFAULT 1,
+			email_verified INTEGER NOT NULL DEFAULT 0,
+			language TEXT NOT NULL DEFAULT '',
 			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
 			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
 		)
@@ -136,6 +144,34 @@ func (r *UserRepository) InitTable() error {
 		return err
 	}
 
+	hasLanguage, err := hasColumn(r.db, "users", "language")
+	if err != nil {
+		return err
+	}
+	if !hasLanguage {
+		if _, err := r.db.Exec(`ALTER TABLE users ADD COLUMN language TEXT NOT NULL DEFAULT ''`); err != nil {
+			return fmt.Errorf("failed to add users language column: %v", err)
+		}
+	}
+
+	hasEmailVerified, err := hasColumn(r.db, "users", "email_verified")
+	if err != nil {
+		return err
+	}
+	if !hasEmailVerified {
+		// Accounts created before email verification existed keep working
+		// as before, so they count as verified
+		statements := []string{
+			`ALTER TABLE users ADD COLUMN email_verified INTEGER NOT NULL DEFAULT 0`,
+			`UPDATE users SET email_verified = 1`,
+		}
+		for _, statement := range statements {
+			if _, err := r.db.Exec(statement); err != nil {
+				return fmt.Errorf("failed to add users email_verified column: %v", err)
+			}
+		}
+	}
+
 	// Create index on email for faster lookups
 	indexQuery := `CREATE INDEX IF NOT EXISTS idx_users_email ON users(email)`
 	_, err = r.db.Exec(indexQuery)
@@ -158,6 +194,29 @@ func (r *UserRepository) SetUserActive(userID string, isActive bool) error {
 	return nil
 }
 
+// SetEmailVerified marks the user's email address as verified
+func (r *UserRepository) SetEmailVerified(userID string) error {
+	if _, err := r.db.Exec(
+		"UPDATE users SET email_verified = 1, updated_at = CURRENT_TIMESTAMP WHERE id = ?",
+		userID,
+	); err != nil {
+		return fmt.Errorf("failed to mark email verified: %v", err)
+	}
+	return nil
+}
+
+// ClearPassword removes the user's password, so they can only sign in with
+// Google or after a password reset
+func (r *UserRepository) ClearPassword(userID string) error {
+	if _, err := r.db.Exec(
+		"UPDATE users SET password_hash = NULL, updated_at = CURRENT_TIMESTAMP WHERE id = ?",
+		userID,
+	); err != nil {
+		return fmt.Errorf("failed to clear password: %v", err)
+	}
+	return nil
+}
+
 // UpdatePassword updates a user's password hash by email
 func (r *UserRepository) UpdatePassword(email, newPasswordHash string) error {
 	result, err := r.db.Exec(
@@ -256,6 +315,37 @@ func (r *UserRepository) passwordHashIsNotNull() (bool, error) {
 	return false, nil
 }
 
+// hasColumn reports whether the table has the named column
+func hasColumn(db *sql.DB, table, column string) (bool, error) {
+	rows, err := db.Query(fmt.Sprintf("PRAGMA table_info(%s)", table))
+	if err != nil {
+		return false, fmt.Errorf("failed to inspect %s table: %v", table, err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var cid int
+		var name string
+		var dataType string
+		var notNull int
+		var defaultValue sql.NullString
+		var pk int
+
+		if err := rows.Scan(&cid, &name, &dataType, &notNull, &defaultValue, &pk); err != nil {
+			return false, fmt.Errorf("failed to scan %s schema: %v", table, err)
+		}
+		if strings.EqualFold(name, column) {
+			return true, nil
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return false, fmt.Errorf("failed while reading %s schema: %v", table, err)
+	}
+
+	return false, nil
+}
+
 func nullablePasswordHash(value string) interface{} {
 	if value == "" {
 		return nil
@@ -283,3 +373,24 @@ func (r *UserRepository) UpdateName(id, name string) error {
 	}
 	return nil
 }
+
+// UpdateLanguage updates a user's preferred language by ID
+func (r *UserRepository) UpdateLanguage(id, language string) error {
+	query := `
+		UPDATE users
+		SET language = ?, updated_at = CURRENT_TIMESTAMP
+		WHERE id = ?
+	`
+	result, err := r.db.Exec(query, language, id)
+	if err != nil {
+		return fmt.Errorf("failed to update language: %v", err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %v", err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("user not found")
+	}
+	return nil
+}
@@ -0,0 +1,176 @@
+package services
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"backend/internal/auth/models"
+	"backend/internal/auth/repository"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// DefaultAccessTokenDays is how long a personal access token is valid
+	// when the request does not say
+	DefaultAccessTokenDays = 30
+	// MaxAccessTokenDays caps the lifetime of a personal access token
+	MaxAccessTokenDays = 365
+
+	// accessTokenUseResolution is how stale a token's last-used time may get
+	accessTokenUseResolution = time.Minute
+)
+
+var (
+	ErrInvalidAccessTokenRequest = errors.New("invalid personal access token request")
+	ErrAccessTokenNotFound       = errors.New("personal access token not found")
+)
+
+// AccessTokenService manages personal access tokens: long-lived, scoped
+// tokens for scripts and CI that cannot log in interactively
+type AccessTokenService struct {
+	repo     *repository.AccessTokenRepository
+	userRepo *repository.UserRepository
+}
+
+// NewAccessTokenService creates a new AccessTokenService
+func NewAccessTokenService(repo *repository.AccessTokenRepository, userRepo *repository.UserRepository) *AccessTokenService {
+	return &AccessTokenService{
+		repo:     repo,
+		userRepo: userRepo,
+	}
+}
+
+// CreateToken creates a token for the user. The returned token carries its
+// plain value, which is not stored and cannot be shown again. expiresInDays
+// of 0 uses DefaultAccessTokenDays.
+func (s *AccessTokenService) CreateToken(userID, name string, scopes []models.TokenScope, expiresInDays int) (*models.PersonalAccessToken, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, fmt.Errorf("%w: name is required", ErrInvalidAccessTokenRequest)
+	}
+	if len(name) > 100 {
+		return nil, fmt.Errorf("%w: name must be 100 characters or less", ErrInvalidAccessTokenRequest)
+	}
+
+	scopes, err := normalizeTokenScopes(scopes)
+	if err != nil {
+		return nil, err
+	}
+
+	if expiresInDays == 0 {
+		expiresInDays = DefaultAccessTokenDays
+	}
+	if expiresInDays < 1 || expiresInDays > MaxAccessTokenDays {
+		return nil, fmt.Errorf("%w: expires_in_days must be between 1 and %d", ErrInvalidAccessTokenRequest, MaxAccessTokenDays)
+	}
+
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return nil, fmt.Errorf("failed to generate personal access token: %v", err)
+	}
+	value := models.PersonalAccessTokenPrefix + base64.RawURLEncoding.EncodeToString(b)
+
+	now := time.Now().UTC()
+	token := &models.PersonalAccessToken{
+		ID:        uuid.New().String(),
+		UserID:    userID,
+		Name:      name,
+		Prefix:    value[:len(models.PersonalAccessTokenPrefix)+6],
+		Scopes:    scopes,
+		ExpiresAt: now.AddDate(0, 0, expiresInDays),
+		CreatedAt: now,
+	}
+	if err := s.repo.Create(token, hashToken(value)); err != nil {
+		return nil, err
+	}
+
+	token.Token = value
+	return token, nil
+}
+
+// ListTokens lists the user's tokens that have not been revoked
+func (s *AccessTokenService) ListTokens(userID string) ([]models.PersonalAccessToken, error) {
+	return s.repo.ListByUser(userID)
+}
+
+// RevokeToken revokes one of the user's tokens
+func (s *AccessTokenService) RevokeToken(userID, id string) error {
+	revoked, err := s.repo.Revoke(userID, id, time.Now())
+	if err != nil {
+		return err
+	}
+	if !revoked {
+		return ErrAccessTokenNotFound
+	}
+	return nil
+}
+
+// RevokeAllTokens revokes every token of the user
+func (s *AccessTokenService) RevokeAllTokens(userID string) error {
+	return s.repo.RevokeAllByUser(userID, time.Now())
+}
+
+// AuthenticatePersonalToken implements PersonalTokenAuthenticator. It records
+// the use and returns claims carrying the token's scopes.
+func (s *AccessTokenService) AuthenticatePersonalToken(value string) (*Claims, error) {
+	token, err := s.repo.GetActiveByHash(hashToken(value))
+	if err != nil {
+		return nil, err
+	}
+	if token == nil {
+		return nil, ErrInvalidToken
+	}
+
+	now := time.Now()
+	if token.IsExpired(now) {
+		return nil, ErrExpiredToken
+	}
+
+	user, err := s.userRepo.GetUserByID(token.UserID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user: %v", err)
+	}
+	if user == nil {
+		return nil, ErrInvalidToken
+	}
+	if !user.IsActive {
+		return nil, ErrUserInactive
+	}
+
+	if err := s.repo.TouchLastUsed(token.ID, now, accessTokenUseResolution); err != nil {
+		// A missed last-used update must not fail the request
+		log.Printf("Failed to record use of personal access token %s: %v", token.ID, err)
+	}
+
+	return &Claims{
+		UserID: user.ID,
+		Email:  user.Email,
+		Scopes: token.Scopes,
+	}, nil
+}
+
+// normalizeTokenScopes checks the requested scopes and drops duplicates
+func normalizeTokenScopes(scopes []models.TokenScope) ([]models.TokenScope, error) {
+	if len(scopes) == 0 {
+		return nil, fmt.Errorf("%w: at least one scope is required", ErrInvalidAccessTokenRequest)
+	}
+
+	seen := make(map[models.TokenScope]bool, len(scopes))
+	normalized := make([]models.TokenScope, 0, len(scopes))
+	for _, scope := range scopes {
+		if !scope.IsValid() {
+			return nil, fmt.Errorf("%w: unknown scope %q", ErrInvalidAccessTokenRequest, scope)
+		}
+		if !seen[scope] {
+			seen[scope] = true
+			normalized = append(normalized, scope)
+		}
+	}
+	return normalized, nil
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"log"
 	"regexp"
 	"strings"
 	"time"
@@ -32,6 +33,10 @@ type AuthService struct {
 	emailService      *EmailService
 	googleService     *GoogleAuthService
 	oauthStateService *OAuthStateService
+	sessionService    *SessionService
+	twoFactorService  *TwoFactorService
+	accessTokens      *AccessTokenService
+	verification      *EmailVerificationService
 }
 
 // NewAuthService creates a new AuthService
@@ -55,23 +60,60 @@ func NewAuthService(
 	}
 }
 
+// SetSessionService makes sign-ins start a session with a refresh token
+// instead of issuing a lone access token
+func (s *AuthService) SetSessionService(sessionService *SessionService) {
+	s.sessionService = sessionService
+}
+
+// SetTwoFactorService makes sign-ins of users with two-factor authentication
+// stop at a challenge that needs a TOTP or recovery code
+func (s *AuthService) SetTwoFactorService(twoFactorService *TwoFactorService) {
+	s.twoFactorService = twoFactorService
+}
+
+// SetAccessTokenService lets Google sign-in revoke the personal access tokens
+// of an unverified account it takes over
+func (s *AuthService) SetAccessTokenService(accessTokens *AccessTokenService) {
+	s.accessTokens = accessTokens
+}
+
+// SetEmailVerificationService makes registration mail the new user a link
+// that verifies their email address
+func (s *AuthService) SetEmailVerificationService(verification *EmailVerificationService) {
+	s.verification = verification
+}
+
 // RegisterRequest represents the registration request body
 type RegisterRequest struct {
 	Name     string `json:"name"`
 	Email    string `json:"email"`
 	Password string `json:"password"`
+
+	// Client is filled in by the controller, not the request body
+	Client ClientInfo `json:"-"`
 }
 
 // LoginRequest represents the login request body
 type LoginRequest struct {
 	Email    string `json:"email"`
 	Password string `json:"password"`
+
+	// Client is filled in by the controller, not the request body
+	Client ClientInfo `json:"-"`
 }
 
-// AuthResponse represents the authentication response
+// AuthResponse represents the authentication response. RefreshToken and
+// ExpiresIn (the access token's lifetime in seconds) are set when sessions
+// are enabled. When the user has two-factor authentication, a sign-in only
+// returns TwoFactorRequired and a ChallengeToken to redeem with a code.
 type AuthResponse struct {
-	User  models.UserResponse `json:"user"`
-	Token string              `json:"token"`
+	User              *models.UserResponse `json:"user,omitempty"`
+	Token             string               `json:"token,omitempty"`
+	RefreshToken      string               `json:"refresh_token,omitempty"`
+	ExpiresIn         int64                `json:"expires_in,omitempty"`
+	TwoFactorRequired bool                 `json:"two_factor_required,omitempty"`
+	ChallengeToken    string               `json:"challenge_token,omitempty"`
 }
 
 // Register creates a new user account
@@ -117,16 +159,14 @@ func (s *AuthService) Register(req RegisterRequest) (*AuthResponse, error) {
 		return nil, fmt.Errorf("failed to create user: %v", err)
 	}
 
-	// Generate JWT token
-	token, err := s.jwtService.GenerateToken(user.ID, user.Email)
-	if err != nil {
-		return nil, fmt.Errorf("failed to generate token: %v", err)
+	// The account exists either way; the user can ask for another link
+	if s.verification != nil {
+		if err := s.verification.SendVerification(user); err != nil {
+			log.Printf("Failed to send verification email to %s: %v", user.Email, err)
+		}
 	}
 
-	return &AuthResponse{
-		User:  user.ToResponse(),
-		Token: token,
-	}, nil
+	return s.issueTokens(user, req.Client)
 }
 
 // Login authenticates a user and returns a JWT token
@@ -158,16 +198,7 @@ func (s *AuthService) Login(req LoginRequest) (*AuthResponse, error) {
 		return nil, ErrUserInactive
 	}
 
-	// Generate JWT token
-	token, err := s.jwtService.GenerateToken(user.ID, user.Email)
-	if err != nil {
-		return nil, fmt.Errorf("failed to generate token: %v", err)
-	}
-
-	return &AuthResponse{
-		User:  user.ToResponse(),
-		Token: token,
-	}, nil
+	return s.issueTokens(user, req.Client)
 }
 
 // GetUserByID retrieves a user by their ID
@@ -203,7 +234,7 @@ func (s *AuthService) ForgotPassword(email string) error {
 	}
 
 	// Send OTP via email
-	if err := s.emailService.SendOTP(email, otp); err != nil {
+	if err := s.emailService.SendOTP(email, user.Language, otp); err != nil {
 		return fmt.Errorf("failed to send OTP email: %v", err)
 	}
 
@@ -245,6 +276,19 @@ func (s *AuthService) ResetPassword(resetToken, newPassword string) error {
 		return fmt.Errorf("failed to update password: %v", err)
 	}
 
+	// Whoever knew the old password may still hold a session
+	if s.sessionService != nil {
+		user, err := s.userRepo.GetUserByEmail(email)
+		if err != nil {
+			return fmt.Errorf("failed to get user: %v", err)
+		}
+		if user != nil {
+			if err := s.sessionService.RevokeAllSessions(user.ID); err != nil {
+				return fmt.Errorf("failed to revoke sessions: %v", err)
+			}
+		}
+	}
+
 	return nil
 }
 
@@ -273,7 +317,7 @@ func (s *AuthService) GoogleLoginWithIDToken(ctx context.Context, idToken string
 		return nil, err
 	}
 
-	return s.completeGoogleSignIn(identity, "")
+	return s.completeGoogleSignIn(identity, "", ClientInfoFromContext(ctx))
 }
 
 // GoogleLoginWithCode authenticates a user from a Google OAuth code callback.
@@ -301,10 +345,10 @@ func (s *AuthService) GoogleLoginWithCode(ctx context.Context, state, code strin
 		return nil, err
 	}
 
-	return s.completeGoogleSignIn(identity, tokens.RefreshToken)
+	return s.completeGoogleSignIn(identity, tokens.RefreshToken, ClientInfoFromContext(ctx))
 }
 
-func (s *AuthService) completeGoogleSignIn(identity *GoogleIdentityPayload, refreshToken string) (*AuthResponse, error) {
+func (s *AuthService) completeGoogleSignIn(identity *GoogleIdentityPayload, refreshToken string, client ClientInfo) (*AuthResponse, error) {
 	if identity == nil || !identity.EmailVerified || identity.Subject == "" {
 		return nil, ErrInvalidGoogleToken
 	}
@@ -334,14 +378,15 @@ func (s *AuthService) completeGoogleSignIn(identity *GoogleIdentityPayload, refr
 		if user == nil {
 			now := time.Now()
 			user = &models.User{
-				ID:           uuid.New().String(),
-				Name:         firstNonEmpty(strings.TrimSpace(identity.Name), deriveNameFromEmail(email)),
-				Email:        email,
-				PasswordHash: "",
-				Role:         models.RoleUser,
-				IsActive:     true,
-				CreatedAt:    now,
-				UpdatedAt:    now,
+				ID:            uuid.New().String(),
+				Name:          firstNonEmpty(strings.TrimSpace(identity.Name), deriveNameFromEmail(email)),
+				Email:         email,
+				PasswordHash:  "",
+				Role:          models.RoleUser,
+				IsActive:      true,
+				EmailVerified: true,
+				CreatedAt:     now,
+				UpdatedAt:     now,
 			}
 			if err := s.userRepo.CreateUser(user); err != nil {
 				return nil, fmt.Errorf("failed to create google user: %v", err)
@@ -356,17 +401,106 @@ func (s *AuthService) completeGoogleSignIn(identity *GoogleIdentityPayload, refr
 		user.IsActive = true
 	}
 
+	// Google only signs in addresses it has verified
+	if !user.EmailVerified {
+		if err := s.reclaimUnverifiedAccount(user); err != nil {
+			return nil, err
+		}
+	}
+
 	if err := s.identityRepo.UpsertGoogleIdentity(user.ID, identity.Subject, email, identity.PictureURL, refreshToken); err != nil {
 		return nil, fmt.Errorf("failed to store google auth identity: %v", err)
 	}
 
+	return s.issueTokens(user, client)
+}
+
+// reclaimUnverifiedAccount hands an account whose address was never verified
+// to the Google user who just proved they own it. Whoever registered it may
+// not be that person, so the password, sessions, personal access tokens and
+// two-factor authentication they set up are all removed before the address is
+// marked verified.
+func (s *AuthService) reclaimUnverifiedAccount(user *models.User) error {
+	if err := s.userRepo.ClearPassword(user.ID); err != nil {
+		return err
+	}
+	user.PasswordHash = ""
+
+	if s.sessionService != nil {
+		if err := s.sessionService.RevokeAllSessions(user.ID); err != nil {
+			return fmt.Errorf("failed to revoke sessions: %v", err)
+		}
+	}
+	if s.accessTokens != nil {
+		if err := s.accessTokens.RevokeAllTokens(user.ID); err != nil {
+			return err
+		}
+	}
+	if s.twoFactorService != nil {
+		if err := s.twoFactorService.Reset(user.ID); err != nil {
+			return err
+		}
+	}
+
+	if err := s.userRepo.SetEmailVerified(user.ID); err != nil {
+		return fmt.Errorf("failed to mark email verified: %v", err)
+	}
+	user.EmailVerified = true
+	return nil
+}
+
+// VerifyTwoFactor completes a sign-in that stopped at a two-factor challenge
+func (s *AuthService) VerifyTwoFactor(challengeToken, code string, client ClientInfo) (*AuthResponse, error) {
+	if s.twoFactorService == nil {
+		return nil, ErrInvalidChallenge
+	}
+
+	user, err := s.twoFactorService.VerifyChallenge(challengeToken, code)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.startSession(user, client)
+}
+
+// issueTokens signs the user in, or returns a two-factor challenge when the
+// user has two-factor authentication
+func (s *AuthService) issueTokens(user *models.User, client ClientInfo) (*AuthResponse, error) {
+	if s.twoFactorService != nil {
+		enabled, err := s.twoFactorService.IsEnabled(user.ID)
+		if err != nil {
+			return nil, err
+		}
+		if enabled {
+			challenge, err := s.twoFactorService.CreateChallenge(user.ID)
+			if err != nil {
+				return nil, err
+			}
+			return &AuthResponse{
+				TwoFactorRequired: true,
+				ChallengeToken:    challenge,
+			}, nil
+		}
+	}
+
+	return s.startSession(user, client)
+}
+
+// startSession issues the user's tokens, starting a session when sessions
+// are enabled
+func (s *AuthService) startSession(user *models.User, client ClientInfo) (*AuthResponse, error) {
+	if s.sessionService != nil {
+		return s.sessionService.StartSession(user, client)
+	}
+
 	token, err := s.jwtService.GenerateToken(user.ID, user.Email)
 	if err != nil {
 		return nil, fmt.Errorf("failed to generate token: %v", err)
 	}
 
+	userResponse := user.ToResponse()
 	return &AuthResponse{
-		User:  user.ToResponse(),
+		User:  &userResponse,
 		Token: token,
 	}, nil
 }
@@ -408,6 +542,27 @@ func (s *AuthService) UpdateUserName(userID, name string) (*models.UserResponse,
 	return &resp, nil
 }
 
+// UpdateUserLanguage changes the language the user's emails are written in.
+// An empty language falls back to the default templates.
+func (s *AuthService) UpdateUserLanguage(userID, language string) (*models.UserResponse, error) {
+	language = strings.TrimSpace(language)
+	if !IsValidLanguage(language) {
+		return nil, fmt.Errorf("language must be a language tag such as en or pt-BR")
+	}
+
+	if err := s.userRepo.UpdateLanguage(userID, language); err != nil {
+		return nil, fmt.Errorf("failed to update language: %v", err)
+	}
+
+	user, err := s.userRepo.GetUserByID(userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get updated user: %v", err)
+	}
+
+	resp := user.ToResponse()
+	return &resp, nil
+}
+
 // validateRegisterInput validates the registration input
 func (s *AuthService) validateRegisterInput(req RegisterRequest) error {
 	if req.Name == "" {
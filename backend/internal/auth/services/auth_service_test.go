@@ -7,6 +7,7 @@ import (
 	"testing"
 	"time"
 
+	"backend/internal/auth/models"
 	"backend/internal/auth/repository"
 	_ "github.com/mattn/go-sqlite3"
 )
@@ -30,11 +31,16 @@ func newAuthServiceForTest(t *testing.T) (*AuthService, *repository.AuthIdentity
 		t.Fatalf("failed to init identity table: %v", err)
 	}
 
+	otpRepo := repository.NewOTPRepository(db)
+	if err := otpRepo.InitTable(); err != nil {
+		t.Fatalf("failed to init otp table: %v", err)
+	}
+
 	service := NewAuthService(
 		userRepo,
 		identityRepo,
 		NewJWTService("test-secret", 24),
-		NewOTPService(),
+		NewOTPService(otpRepo),
 		&EmailService{},
 		&GoogleAuthService{},
 		NewOAuthStateService(10*time.Minute),
@@ -60,7 +66,7 @@ func TestGoogleLoginLinksExistingEmailUser(t *testing.T) {
 		Name:          "Google Name",
 		PictureURL:    "https://img.example.com/profile.png",
 		EmailVerified: true,
-	}, "")
+	}, "", ClientInfo{})
 	if err != nil {
 		t.Fatalf("expected google sign in to succeed: %v", err)
 	}
@@ -75,6 +81,119 @@ func TestGoogleLoginLinksExistingEmailUser(t *testing.T) {
 	if identity.UserID != resp.User.ID {
 		t.Fatalf("expected identity to link to existing user %q, got %q", resp.User.ID, identity.UserID)
 	}
+	if !resp.User.EmailVerified {
+		t.Fatal("expected google sign in to verify the existing user's email")
+	}
+	if _, err := service.Login(LoginRequest{Email: "existing@example.com", Password: "password123"}); !errors.Is(err, ErrInvalidCredentials) {
+		t.Fatalf("expected the unverified account's password to stop working, got %v", err)
+	}
+}
+
+func TestGoogleLoginReclaimsUnverifiedAccount(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("failed to open test db: %v", err)
+	}
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { db.Close() })
+
+	userRepo := repository.NewUserRepository(db)
+	identityRepo := repository.NewAuthIdentityRepository(db)
+	otpRepo := repository.NewOTPRepository(db)
+	sessionRepo := repository.NewSessionRepository(db)
+	accessTokenRepo := repository.NewAccessTokenRepository(db)
+	twoFactorRepo := repository.NewTwoFactorRepository(db)
+	for _, init := range []func() error{userRepo.InitTable, identityRepo.InitTable, otpRepo.InitTable,
+		sessionRepo.InitTable, accessTokenRepo.InitTable, twoFactorRepo.InitTable} {
+		if err := init(); err != nil {
+			t.Fatalf("failed to init tables: %v", err)
+		}
+	}
+
+	jwtService := NewJWTService("test-secret", 24)
+	sessionService := NewSessionService(sessionRepo, userRepo, jwtService, 24*time.Hour)
+	jwtService.SetSessionValidator(sessionService)
+	accessTokens := NewAccessTokenService(accessTokenRepo, userRepo)
+	jwtService.SetPersonalTokenAuthenticator(accessTokens)
+	twoFactor := NewTwoFactorService(twoFactorRepo, userRepo)
+	service := NewAuthService(userRepo, identityRepo, jwtService, NewOTPService(otpRepo), &EmailService{}, &GoogleAuthService{}, NewOAuthStateService(10*time.Minute))
+	service.SetSessionService(sessionService)
+	service.SetAccessTokenService(accessTokens)
+	service.SetTwoFactorService(twoFactor)
+
+	// Someone registers an address they do not own and makes themselves at
+	// home: a session, a personal access token and two-factor authentication
+	squatter, err := service.Register(RegisterRequest{Name: "Squatter", Email: "victim@example.com", Password: "password123"})
+	if err != nil {
+		t.Fatalf("failed to register: %v", err)
+	}
+	pat, err := accessTokens.CreateToken(squatter.User.ID, "ci", []models.TokenScope{models.ScopeReadProjects}, 30)
+	if err != nil {
+		t.Fatalf("failed to create personal access token: %v", err)
+	}
+	setup, err := twoFactor.Setup(squatter.User.ID)
+	if err != nil {
+		t.Fatalf("failed to set up 2FA: %v", err)
+	}
+	code, _ := GenerateTOTPCode(setup.Secret, time.Now())
+	if _, err := twoFactor.Enable(squatter.User.ID, code); err != nil {
+		t.Fatalf("failed to enable 2FA: %v", err)
+	}
+
+	// The owner of the address signs in with Google
+	resp, err := service.completeGoogleSignIn(&GoogleIdentityPayload{
+		Subject:       "google-sub-owner",
+		Email:         "victim@example.com",
+		Name:          "Owner",
+		EmailVerified: true,
+	}, "", ClientInfo{})
+	if err != nil {
+		t.Fatalf("expected google sign in to succeed: %v", err)
+	}
+	if resp.TwoFactorRequired || resp.Token == "" || resp.User.ID != squatter.User.ID || !resp.User.EmailVerified {
+		t.Fatalf("expected the owner to be signed in to the verified account, got %+v", resp)
+	}
+
+	user, _ := userRepo.GetUserByID(squatter.User.ID)
+	if user.PasswordHash != "" {
+		t.Error("expected the squatter's password to be cleared")
+	}
+	if _, err := service.Login(LoginRequest{Email: "victim@example.com", Password: "password123"}); !errors.Is(err, ErrInvalidCredentials) {
+		t.Errorf("expected the squatter's password to stop working, got %v", err)
+	}
+	if _, err := jwtService.AuthenticateBearer(squatter.Token); err == nil {
+		t.Error("expected the squatter's access token to be revoked")
+	}
+	if _, err := sessionService.Refresh(squatter.RefreshToken, ClientInfo{}); err == nil {
+		t.Error("expected the squatter's refresh token to be revoked")
+	}
+	if _, err := jwtService.AuthenticateBearer(pat.Token); err == nil {
+		t.Error("expected the squatter's personal access token to be revoked")
+	}
+	if enabled, _ := twoFactor.IsEnabled(squatter.User.ID); enabled {
+		t.Error("expected the squatter's two-factor authentication to be removed")
+	}
+	if _, err := jwtService.AuthenticateBearer(resp.Token); err != nil {
+		t.Errorf("expected the owner's access token to be valid: %v", err)
+	}
+
+	// Signing in again leaves an account that was already verified alone
+	if err := userRepo.UpdatePassword("victim@example.com", "owner-password-hash"); err != nil {
+		t.Fatalf("failed to set password: %v", err)
+	}
+	if _, err := service.completeGoogleSignIn(&GoogleIdentityPayload{
+		Subject:       "google-sub-owner",
+		Email:         "victim@example.com",
+		EmailVerified: true,
+	}, "", ClientInfo{}); err != nil {
+		t.Fatalf("expected second google sign in to succeed: %v", err)
+	}
+	if _, err := jwtService.AuthenticateBearer(resp.Token); err != nil {
+		t.Errorf("expected a verified account's sessions to survive google sign in: %v", err)
+	}
+	if user, _ := userRepo.GetUserByID(squatter.User.ID); user.PasswordHash != "owner-password-hash" {
+		t.Errorf("expected a verified account's password to be kept, got %q", user.PasswordHash)
+	}
 }
 
 func TestGoogleLoginCreatesNewUser(t *testing.T) {
@@ -86,7 +205,7 @@ func TestGoogleLoginCreatesNewUser(t *testing.T) {
 		Name:          "New Google User",
 		PictureURL:    "https://img.example.com/new-user.png",
 		EmailVerified: true,
-	}, "refresh-1")
+	}, "refresh-1", ClientInfo{})
 	if err != nil {
 		t.Fatalf("expected google sign in to create a new user: %v", err)
 	}
@@ -101,6 +220,9 @@ func TestGoogleLoginCreatesNewUser(t *testing.T) {
 	if user.PasswordHash != "" {
 		t.Fatalf("expected google-created user to have empty password hash, got %q", user.PasswordHash)
 	}
+	if !user.EmailVerified {
+		t.Fatal("expected google-created user to have a verified email")
+	}
 
 	identity, err := identityRepo.GetByProviderUserID("google", "google-sub-2")
 	if err != nil {
@@ -132,7 +254,7 @@ func TestGoogleLoginReactivatesInactiveUser(t *testing.T) {
 		Email:         "inactive@example.com",
 		Name:          "Inactive User",
 		EmailVerified: true,
-	}, "")
+	}, "", ClientInfo{})
 	if err != nil {
 		t.Fatalf("expected google sign in to reactivate user: %v", err)
 	}
@@ -154,7 +276,7 @@ func TestGoogleLoginRejectsUnverifiedEmail(t *testing.T) {
 		Email:         "unverified@example.com",
 		Name:          "Unverified",
 		EmailVerified: false,
-	}, "")
+	}, "", ClientInfo{})
 	if !errors.Is(err, ErrInvalidGoogleToken) {
 		t.Fatalf("expected ErrInvalidGoogleToken, got %v", err)
 	}
@@ -0,0 +1,202 @@
+package services
+
+import (
+	"errors"
+	"log"
+	"time"
+
+	"backend/internal/auth/models"
+	"backend/internal/auth/repository"
+)
+
+const (
+	// DefaultOutboxMaxAttempts is how many times an email is tried before it
+	// is dead-lettered
+	DefaultOutboxMaxAttempts = 6
+	// DefaultOutboxBaseDelay is the wait after the first failure; it doubles
+	// with every further failure
+	DefaultOutboxBaseDelay = time.Minute
+	// DefaultOutboxMaxDelay caps the wait between attempts
+	DefaultOutboxMaxDelay = time.Hour
+
+	// outboxLease is how long a claimed email is held before another worker
+	// may retry it, in case this one dies mid-send
+	outboxLease = 5 * time.Minute
+	// outboxBatchSize is how many emails one pass of the worker sends
+	outboxBatchSize = 50
+)
+
+var (
+	ErrOutboxEmailNotFound = errors.New("email not found")
+	ErrOutboxEmailNotDead  = errors.New("only dead emails can be retried")
+)
+
+// EmailOutbox stores outbound emails and delivers them in the background,
+// retrying failures with exponential backoff
+type EmailOutbox struct {
+	repo        *repository.EmailOutboxRepository
+	mailer      Mailer
+	maxAttempts int
+	baseDelay   time.Duration
+	maxDelay    time.Duration
+}
+
+// NewEmailOutbox creates a new EmailOutbox delivering through mailer
+func NewEmailOutbox(repo *repository.EmailOutboxRepository, mailer Mailer) *EmailOutbox {
+	return &EmailOutbox{
+		repo:        repo,
+		mailer:      mailer,
+		maxAttempts: DefaultOutboxMaxAttempts,
+		baseDelay:   DefaultOutboxBaseDelay,
+		maxDelay:    DefaultOutboxMaxDelay,
+	}
+}
+
+// SetRetryPolicy changes how often and how far apart failed emails are retried
+func (o *EmailOutbox) SetRetryPolicy(maxAttempts int, baseDelay, maxDelay time.Duration) {
+	o.maxAttempts = maxAttempts
+	o.baseDelay = baseDelay
+	o.maxDelay = maxDelay
+}
+
+// Enqueue stores the message for delivery by the worker
+func (o *EmailOutbox) Enqueue(msg EmailMessage) (int64, error) {
+	return o.repo.Enqueue(msg.To, msg.Subject, msg.Body, msg.HTMLBody)
+}
+
+// ProcessDue sends every email that is due and returns how many were
+// delivered
+func (o *EmailOutbox) ProcessDue() (int, error) {
+	now := time.Now().UTC()
+	ids, err := o.repo.DueIDs(now, outboxBatchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	sent := 0
+	for _, id := range ids {
+		claimed, err := o.repo.Claim(id, now, now.Add(outboxLease))
+		if err != nil {
+			log.Printf("Failed to claim email %d: %v", id, err)
+			continue
+		}
+		if !claimed {
+			continue
+		}
+
+		email, err := o.repo.GetByID(id)
+		if err != nil || email == nil {
+			log.Printf("Failed to load email %d: %v", id, err)
+			continue
+		}
+
+		if err := o.mailer.Send(EmailMessage{To: email.To, Subject: email.Subject, Body: email.Body, HTMLBody: email.HTMLBody}); err != nil {
+			dead := email.Attempts >= o.maxAttempts
+			if err := o.repo.MarkFailed(id, err.Error(), time.Now().UTC().Add(o.backoff(email.Attempts)), dead); err != nil {
+				log.Printf("Failed to record failure of email %d: %v", id, err)
+			}
+			if dead {
+				log.Printf("Email %d to %s dead-lettered after %d attempts: %v", id, email.To, email.Attempts, err)
+			}
+			continue
+		}
+
+		if err := o.repo.MarkSent(id, time.Now()); err != nil {
+			log.Printf("Failed to mark email %d sent: %v", id, err)
+			continue
+		}
+		sent++
+	}
+
+	return sent, nil
+}
+
+// backoff is the wait after the given number of failed attempts
+func (o *EmailOutbox) backoff(attempts int) time.Duration {
+	delay := o.baseDelay
+	for i := 1; i < attempts && delay < o.maxDelay; i++ {
+		delay *= 2
+	}
+	if delay > o.maxDelay {
+		delay = o.maxDelay
+	}
+	return delay
+}
+
+// Start starts a background goroutine that delivers due emails periodically
+func (o *EmailOutbox) Start(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			if _, err := o.ProcessDue(); err != nil {
+				log.Printf("Email outbox error: %v", err)
+			}
+		}
+	}()
+}
+
+// PurgeSent deletes emails sent more than maxAge ago and returns how many
+// were deleted
+func (o *EmailOutbox) PurgeSent(maxAge time.Duration) (int64, error) {
+	return o.repo.PurgeSentBefore(time.Now().UTC().Add(-maxAge))
+}
+
+// StartRetention starts a background goroutine that purges emails sent more
+// than maxAge ago periodically. A maxAge of 0 keeps them forever.
+func (o *EmailOutbox) StartRetention(maxAge, interval time.Duration) {
+	if maxAge <= 0 {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			purged, err := o.PurgeSent(maxAge)
+			if err != nil {
+				log.Printf("Email outbox retention error: %v", err)
+				continue
+			}
+			if purged > 0 {
+				log.Printf("Purged %d emails sent more than %s ago", purged, maxAge)
+			}
+		}
+	}()
+}
+
+// ListEmails returns a page of emails with the given status, most recently
+// updated first
+func (o *EmailOutbox) ListEmails(status models.EmailStatus, page, limit int) ([]models.OutboxEmail, int64, error) {
+	return o.repo.ListByStatus(status, limit, (page-1)*limit)
+}
+
+// GetEmail returns one outbox email
+func (o *EmailOutbox) GetEmail(id int64) (*models.OutboxEmail, error) {
+	email, err := o.repo.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if email == nil {
+		return nil, ErrOutboxEmailNotFound
+	}
+	return email, nil
+}
+
+// Retry gives a dead email a fresh set of attempts, starting with the
+// worker's next pass
+func (o *EmailOutbox) Retry(id int64) (*models.OutboxEmail, error) {
+	requeued, err := o.repo.Requeue(id)
+	if err != nil {
+		return nil, err
+	}
+	if !requeued {
+		if _, err := o.GetEmail(id); err != nil {
+			return nil, err
+		}
+		return nil, ErrOutboxEmailNotDead
+	}
+	return o.GetEmail(id)
+}
@@ -1,80 +1,100 @@
 package services
 
-import (
-	"fmt"
-	"net/smtp"
-	"os"
-)
+import "fmt"
 
-// EmailService handles sending emails via SMTP
+// EmailService composes Taskify's emails and hands them to the outbox, or
+// straight to the mailer when no outbox is set
 type EmailService struct {
-	host     string
-	port     string
-	email    string
-	password string
+	mailer    Mailer
+	outbox    *EmailOutbox
+	templates *EmailTemplates
 }
 
-// NewEmailService creates a new EmailService with SMTP configuration
+// NewEmailService creates a new EmailService using the transport and
+// template overrides configured in the environment (see NewMailerFromEnv and
+// NewEmailTemplatesFromEnv)
 func NewEmailService() *EmailService {
-	host := os.Getenv("SMTP_HOST")
-	if host == "" {
-		host = "smtp.gmail.com"
-	}
-	port := os.Getenv("SMTP_PORT")
-	if port == "" {
-		port = "587"
-	}
+	service := NewEmailServiceWithMailer(NewMailerFromEnv())
+	service.SetTemplates(NewEmailTemplatesFromEnv())
+	return service
+}
 
-	return &EmailService{
-		host:     host,
-		port:     port,
-		email:    os.Getenv("SMTP_EMAIL"),
-		password: os.Getenv("SMTP_PASSWORD"),
-	}
+// NewEmailServiceWithMailer creates a new EmailService that sends through
+// mailer using the built-in templates
+func NewEmailServiceWithMailer(mailer Mailer) *EmailService {
+	return &EmailService{mailer: mailer, templates: NewEmailTemplates("")}
 }
 
-// SendOTP sends a verification OTP code to the specified email address
-func (s *EmailService) SendOTP(toEmail, otp string) error {
-	auth := smtp.PlainAuth("", s.email, s.password, s.host)
+// Mailer returns the transport emails are delivered with
+func (s *EmailService) Mailer() Mailer {
+	return s.mailer
+}
 
-	subject := "Taskify - Password Reset Code"
-	body := fmt.Sprintf(
-		"Hello,\n\nYour password reset verification code is: %s\n\nThis code will expire in 10 minutes.\n\nIf you did not request this, please ignore this email.\n\n- Taskify Team",
-		otp,
-	)
+// SetOutbox queues emails in the outbox so failed sends are retried
+func (s *EmailService) SetOutbox(outbox *EmailOutbox) {
+	s.outbox = outbox
+}
 
-	msg := fmt.Sprintf(
-		"From: Taskify <%s>\r\nTo: %s\r\nSubject: %s\r\nMIME-Version: 1.0\r\nContent-Type: text/plain; charset=\"UTF-8\"\r\n\r\n%s",
-		s.email, toEmail, subject, body,
-	)
+// SetTemplates changes where email templates are loaded from
+func (s *EmailService) SetTemplates(templates *EmailTemplates) {
+	s.templates = templates
+}
 
-	addr := fmt.Sprintf("%s:%s", s.host, s.port)
-	err := smtp.SendMail(addr, auth, s.email, []string{toEmail}, []byte(msg))
-	if err != nil {
-		return fmt.Errorf("failed to send email: %v", err)
+// Send queues the message, or sends it right away when there is no outbox
+func (s *EmailService) Send(msg EmailMessage) error {
+	if s.outbox != nil {
+		_, err := s.outbox.Enqueue(msg)
+		return err
 	}
+	return s.mailer.Send(msg)
+}
 
-	return nil
+// SendOTP sends a password reset OTP code to the specified email address
+func (s *EmailService) SendOTP(toEmail, locale, otp string) error {
+	return s.sendTemplate(toEmail, locale, EmailOTP, OTPEmail{Code: otp, ExpiresInMinutes: int(OTPTTL.Minutes())})
 }
 
-// SendNotification sends a generic notification email
-func (s *EmailService) SendNotification(toEmail, subject, body string) error {
-	auth := smtp.PlainAuth("", s.email, s.password, s.host)
+// SendInvite sends a project invitation link
+func (s *EmailService) SendInvite(toEmail, locale string, data InviteEmail) error {
+	return s.sendTemplate(toEmail, locale, EmailInvite, data)
+}
 
-	fullBody := fmt.Sprintf(
-		"Hello,\n\n%s\n\nThis is an automated notification from Taskify.\n\nBest regards,\nTaskify Team",
-		body,
-	)
+// SendTaskAssigned tells a user they were assigned a task
+func (s *EmailService) SendTaskAssigned(toEmail, locale string, data TaskAssignedEmail) error {
+	return s.sendTemplate(toEmail, locale, EmailTaskAssigned, data)
+}
+
+// SendDeadlineReminder tells a user a task is due soon or overdue
+func (s *EmailService) SendDeadlineReminder(toEmail, locale string, data DeadlineEmail) error {
+	return s.sendTemplate(toEmail, locale, EmailDeadline, data)
+}
 
-	msg := fmt.Sprintf(
-		"From: Taskify <%s>\r\nTo: %s\r\nSubject: %s\r\nMIME-Version: 1.0\r\nContent-Type: text/plain; charset=\"UTF-8\"\r\n\r\n%s",
-		s.email, toEmail, subject, fullBody,
-	)
+// SendDigest sends a daily or weekly notification digest
+func (s *EmailService) SendDigest(toEmail, locale string, data DigestEmail) error {
+	return s.sendTemplate(toEmail, locale, EmailDigest, data)
+}
 
-	addr := fmt.Sprintf("%s:%s", s.host, s.port)
-	err := smtp.SendMail(addr, auth, s.email, []string{toEmail}, []byte(msg))
+// SendNotification sends a generic notification email
+func (s *EmailService) SendNotification(toEmail, locale, subject, body string) error {
+	return s.sendTemplate(toEmail, locale, EmailNotification, NotificationEmail{Subject: subject, Message: body})
+}
+
+// SendEmailVerification sends the link that confirms a user's email address
+func (s *EmailService) SendEmailVerification(toEmail, locale string, data VerifyEmail) error {
+	return s.sendTemplate(toEmail, locale, EmailVerifyEmail, data)
+}
+
+// sendTemplate renders the template of the given kind in the recipient's
+// language and sends the result
+func (s *EmailService) sendTemplate(toEmail, locale string, kind EmailKind, data interface{}) error {
+	msg, err := s.templates.Render(kind, locale, data)
 	if err != nil {
-		return fmt.Errorf("failed to send notification email: %v", err)
+		return fmt.Errorf("failed to render %s email: %v", kind, err)
+	}
+	msg.To = toEmail
+
+	if err := s.Send(msg); err != nil {
+		return fmt.Errorf("failed to send %s email: %v", kind, err)
 	}
 
 	return nil
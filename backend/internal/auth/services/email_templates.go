@@ -0,0 +1,254 @@
+package services
+
+import (
+	"bytes"
+	"embed"
+	"errors"
+	htmltemplate "html/template"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	texttemplate "text/template"
+	"time"
+)
+
+// EmailKind names the template an email is rendered from
+type EmailKind string
+
+const (
+	EmailOTP          EmailKind = "otp"
+	EmailInvite       EmailKind = "invite"
+	EmailTaskAssigned EmailKind = "task_assigned"
+	EmailDeadline     EmailKind = "deadline"
+	EmailDigest       EmailKind = "digest"
+	EmailNotification EmailKind = "notification"
+	EmailVerifyEmail  EmailKind = "verify_email"
+)
+
+//go:embed email_templates
+var defaultEmailTemplates embed.FS
+
+// OTPEmail is the data for the otp template
+type OTPEmail struct {
+	Code             string
+	ExpiresInMinutes int
+}
+
+// InviteEmail is the data for the invite template
+type InviteEmail struct {
+	InviterName string
+	ProjectName string
+	AcceptURL   string
+	ExpiresAt   time.Time
+}
+
+// TaskAssignedEmail is the data for the task_assigned template
+type TaskAssignedEmail struct {
+	ActorName   string
+	TaskTitle   string
+	ProjectName string
+}
+
+// DeadlineEmail is the data for the deadline template. DueIn is only used
+// when the task is not yet overdue.
+type DeadlineEmail struct {
+	TaskTitle string
+	DueIn     string
+	Overdue   bool
+}
+
+// DigestEmail is the data for the digest template
+type DigestEmail struct {
+	Frequency string
+	Projects  []DigestEmailProject
+}
+
+// DigestEmailProject is one project's section of a digest email
+type DigestEmailProject struct {
+	Name          string
+	Notifications []string
+	Activity      []string
+	MoreActivity  int
+}
+
+// NotificationEmail is the data for the generic notification template
+type NotificationEmail struct {
+	Subject string
+	Message string
+}
+
+// VerifyEmail is the data for the verify_email template
+type VerifyEmail struct {
+	Name           string
+	VerifyURL      string
+	ExpiresInHours int
+}
+
+// EmailTemplates renders emails from templates. Each kind has a text
+// template, kind.txt, which defines the "subject" block and renders the
+// plain-text body, and optionally kind.html, which defines the "content"
+// block placed inside layout.html. Locale variants such as otp.pt-br.txt
+// are preferred over the defaults when the recipient's language matches.
+//
+// Templates found in the override directory replace the built-in ones file
+// by file; they are read on every render, so edits apply without a restart.
+type EmailTemplates struct {
+	dir string
+}
+
+// NewEmailTemplates creates an EmailTemplates that looks for overrides in
+// dir before falling back to the built-in templates. dir may be empty.
+func NewEmailTemplates(dir string) *EmailTemplates {
+	return &EmailTemplates{dir: dir}
+}
+
+// NewEmailTemplatesFromEnv creates an EmailTemplates with overrides from
+// EMAIL_TEMPLATE_DIR
+func NewEmailTemplatesFromEnv() *EmailTemplates {
+	return NewEmailTemplates(os.Getenv("EMAIL_TEMPLATE_DIR"))
+}
+
+// Render renders the subject and bodies of an email of the given kind in the
+// closest available locale. The text and HTML templates are looked up
+// separately, so a translated kind.fr.txt still gets the default HTML if
+// there is no kind.fr.html. The returned message has no recipient.
+func (t *EmailTemplates) Render(kind EmailKind, locale string, data interface{}) (EmailMessage, error) {
+	var msg EmailMessage
+	candidates := localeCandidates(locale)
+
+	name, text, err := t.find(kind, candidates, ".txt")
+	if err != nil {
+		return msg, err
+	}
+	if name == "" {
+		return msg, errors.New("no email template for " + string(kind))
+	}
+	msg.Subject, msg.Body, err = renderText(name, text, data)
+	if err != nil {
+		return msg, err
+	}
+
+	name, html, err := t.find(kind, candidates, ".html")
+	if err != nil || name == "" {
+		return msg, err
+	}
+	layout, err := t.readFile("layout.html")
+	if err != nil {
+		return msg, err
+	}
+	msg.HTMLBody, err = renderHTML(name, layout, html, data)
+	return msg, err
+}
+
+// find returns the name and source of the first template of the kind that
+// exists for one of the locales, or an empty name when there is none
+func (t *EmailTemplates) find(kind EmailKind, locales []string, ext string) (string, string, error) {
+	for _, locale := range locales {
+		name := string(kind)
+		if locale != "" {
+			name += "." + locale
+		}
+		name += ext
+
+		source, err := t.readFile(name)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return "", "", err
+		}
+		return name, source, nil
+	}
+	return "", "", nil
+}
+
+// readFile reads a template from the override directory, or from the
+// built-in templates when it is not overridden
+func (t *EmailTemplates) readFile(name string) (string, error) {
+	if t.dir != "" {
+		data, err := os.ReadFile(filepath.Join(t.dir, name))
+		if err == nil {
+			return string(data), nil
+		}
+		if !errors.Is(err, fs.ErrNotExist) {
+			return "", err
+		}
+	}
+	data, err := defaultEmailTemplates.ReadFile("email_templates/" + name)
+	return string(data), err
+}
+
+func renderText(name, source string, data interface{}) (string, string, error) {
+	tmpl, err := texttemplate.New(name).Parse(source)
+	if err != nil {
+		return "", "", err
+	}
+	var subject, body bytes.Buffer
+	if tmpl.Lookup("subject") != nil {
+		if err := tmpl.ExecuteTemplate(&subject, "subject", data); err != nil {
+			return "", "", err
+		}
+	}
+	if err := tmpl.Execute(&body, data); err != nil {
+		return "", "", err
+	}
+	return strings.TrimSpace(subject.String()), body.String(), nil
+}
+
+func renderHTML(name, layout, source string, data interface{}) (string, error) {
+	tmpl, err := htmltemplate.New(name).Parse(layout)
+	if err != nil {
+		return "", err
+	}
+	if _, err := tmpl.Parse(source); err != nil {
+		return "", err
+	}
+	var body bytes.Buffer
+	if err := tmpl.ExecuteTemplate(&body, "layout", data); err != nil {
+		return "", err
+	}
+	return body.String(), nil
+}
+
+// localeCandidates lists the template locales to try for a language tag,
+// most specific first and ending with the default: "pt-BR" gives "pt-br",
+// "pt" and ""
+func localeCandidates(locale string) []string {
+	locale = strings.ToLower(strings.ReplaceAll(strings.TrimSpace(locale), "_", "-"))
+	if !IsValidLanguage(locale) {
+		return []string{""}
+	}
+	var candidates []string
+	for locale != "" {
+		candidates = append(candidates, locale)
+		i := strings.LastIndex(locale, "-")
+		if i < 0 {
+			break
+		}
+		locale = locale[:i]
+	}
+	return append(candidates, "")
+}
+
+// IsValidLanguage reports whether a language preference looks like a BCP 47
+// tag such as "en" or "pt-BR". The empty string means no preference.
+func IsValidLanguage(language string) bool {
+	if len(language) > 35 {
+		return false
+	}
+	if language == "" {
+		return true
+	}
+	for i, part := range strings.Split(language, "-") {
+		if len(part) == 0 || len(part) > 8 || (i == 0 && (len(part) < 2 || len(part) > 3)) {
+			return false
+		}
+		for _, r := range part {
+			if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9') {
+				return false
+			}
+		}
+	}
+	return true
+}
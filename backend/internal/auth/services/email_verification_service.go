@@ -0,0 +1,143 @@
+package services
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"backend/internal/auth/models"
+	"backend/internal/auth/repository"
+)
+
+// EmailVerificationResendInterval is how long a user waits before another
+// verification email can be sent
+const EmailVerificationResendInterval = time.Minute
+
+var (
+	ErrInvalidVerificationToken  = errors.New("invalid or expired verification link")
+	ErrEmailAlreadyVerified      = errors.New("email address is already verified")
+	ErrVerificationResendTooSoon = errors.New("a verification email was sent recently, please wait before asking for another")
+)
+
+// EmailVerificationService confirms that users own the email address they
+// registered with by mailing them a single-use link
+type EmailVerificationService struct {
+	repo         *repository.EmailVerificationRepository
+	userRepo     *repository.UserRepository
+	emailService *EmailService
+	ttl          time.Duration
+}
+
+// NewEmailVerificationService creates a new EmailVerificationService whose
+// links are valid for ttl
+func NewEmailVerificationService(repo *repository.EmailVerificationRepository, userRepo *repository.UserRepository, emailService *EmailService, ttl time.Duration) *EmailVerificationService {
+	return &EmailVerificationService{
+		repo:         repo,
+		userRepo:     userRepo,
+		emailService: emailService,
+		ttl:          ttl,
+	}
+}
+
+// SendVerification mails the user a new verification link, replacing any
+// earlier one. It refuses when the last link went out less than
+// EmailVerificationResendInterval ago.
+func (s *EmailVerificationService) SendVerification(user *models.User) error {
+	if user.EmailVerified {
+		return ErrEmailAlreadyVerified
+	}
+
+	pending, err := s.repo.GetByUser(user.ID)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	if pending != nil && now.Sub(pending.SentAt) < EmailVerificationResendInterval {
+		return ErrVerificationResendTooSoon
+	}
+
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return fmt.Errorf("failed to generate verification token: %v", err)
+	}
+	token := base64.RawURLEncoding.EncodeToString(b)
+
+	if err := s.repo.Save(&models.EmailVerification{
+		UserID:    user.ID,
+		ExpiresAt: now.Add(s.ttl),
+		SentAt:    now,
+	}, hashToken(token)); err != nil {
+		return err
+	}
+
+	return s.emailService.SendEmailVerification(user.Email, user.Language, VerifyEmail{
+		Name:           user.Name,
+		VerifyURL:      verifyEmailURL(token),
+		ExpiresInHours: int(s.ttl.Hours()),
+	})
+}
+
+// Resend mails the user another verification link
+func (s *EmailVerificationService) Resend(userID string) error {
+	user, err := s.userRepo.GetUserByID(userID)
+	if err != nil {
+		return fmt.Errorf("failed to get user: %v", err)
+	}
+	if user == nil {
+		return errors.New("user not found")
+	}
+	return s.SendVerification(user)
+}
+
+// Verify redeems a verification token and marks the address of the user it
+// was sent to as verified
+func (s *EmailVerificationService) Verify(token string) (*models.User, error) {
+	if token == "" {
+		return nil, ErrInvalidVerificationToken
+	}
+
+	tokenHash := hashToken(token)
+	verification, err := s.repo.GetByTokenHash(tokenHash)
+	if err != nil {
+		return nil, err
+	}
+	if verification == nil || !time.Now().Before(verification.ExpiresAt) {
+		return nil, ErrInvalidVerificationToken
+	}
+
+	// Deleting the verification claims it, so a link works only once
+	redeemed, err := s.repo.DeleteByTokenHash(tokenHash)
+	if err != nil {
+		return nil, err
+	}
+	if !redeemed {
+		return nil, ErrInvalidVerificationToken
+	}
+
+	if err := s.userRepo.SetEmailVerified(verification.UserID); err != nil {
+		return nil, err
+	}
+
+	user, err := s.userRepo.GetUserByID(verification.UserID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user: %v", err)
+	}
+	if user == nil {
+		return nil, ErrInvalidVerificationToken
+	}
+	return user, nil
+}
+
+// verifyEmailURL is the frontend page that redeems a verification token
+func verifyEmailURL(token string) string {
+	frontendURL := os.Getenv("FRONTEND_URL")
+	if frontendURL == "" {
+		frontendURL = "http://localhost:4200"
+	}
+	return strings.TrimRight(frontendURL, "/") + "/verify-email?token=" + url.QueryEscape(token)
+}
@@ -4,26 +4,48 @@ import (
 	"errors"
 	"fmt"
 	"os"
+	"strings"
 	"time"
 
+	"backend/internal/auth/models"
+
 	"github.com/golang-jwt/jwt/v5"
 )
 
 var (
-	ErrInvalidToken = errors.New("invalid token")
-	ErrExpiredToken = errors.New("token has expired")
+	ErrInvalidToken   = errors.New("invalid token")
+	ErrExpiredToken   = errors.New("token has expired")
+	ErrSessionRevoked = errors.New("session has been revoked")
 )
 
+// SessionValidator checks that the session an access token was issued for
+// is still usable and that its user is still active
+type SessionValidator interface {
+	ValidateSession(sessionID, userID string) error
+}
+
+// PersonalTokenAuthenticator validates personal access tokens, which are
+// opaque tokens starting with models.PersonalAccessTokenPrefix
+type PersonalTokenAuthenticator interface {
+	AuthenticatePersonalToken(token string) (*Claims, error)
+}
+
 // JWTService handles JWT token generation and validation
 type JWTService struct {
 	secretKey      string
 	expirationTime time.Duration
+	sessions       SessionValidator
+	personalTokens PersonalTokenAuthenticator
 }
 
 // Claims represents the JWT claims structure
 type Claims struct {
-	UserID string `json:"user_id"`
-	Email  string `json:"email"`
+	UserID    string `json:"user_id"`
+	Email     string `json:"email"`
+	SessionID string `json:"sid,omitempty"`
+
+	// Scopes is set for personal access tokens only; JWTs are unrestricted
+	Scopes []models.TokenScope `json:"-"`
 	jwt.RegisteredClaims
 }
 
@@ -35,11 +57,38 @@ func NewJWTService(secretKey string, expirationHours int) *JWTService {
 	}
 }
 
+// SetAccessTokenTTL sets how long newly issued tokens are valid
+func (s *JWTService) SetAccessTokenTTL(ttl time.Duration) {
+	s.expirationTime = ttl
+}
+
+// AccessTokenTTL returns how long newly issued tokens are valid
+func (s *JWTService) AccessTokenTTL() time.Duration {
+	return s.expirationTime
+}
+
+// SetSessionValidator makes Authenticate check every token's session
+func (s *JWTService) SetSessionValidator(sessions SessionValidator) {
+	s.sessions = sessions
+}
+
+// SetPersonalTokenAuthenticator makes AuthenticateBearer accept personal
+// access tokens
+func (s *JWTService) SetPersonalTokenAuthenticator(personalTokens PersonalTokenAuthenticator) {
+	s.personalTokens = personalTokens
+}
+
 // GenerateToken creates a new JWT token for a user
 func (s *JWTService) GenerateToken(userID, email string) (string, error) {
+	return s.GenerateSessionToken(userID, email, "")
+}
+
+// GenerateSessionToken creates a new JWT access token for a user's session
+func (s *JWTService) GenerateSessionToken(userID, email, sessionID string) (string, error) {
 	claims := Claims{
-		UserID: userID,
-		Email:  email,
+		UserID:    userID,
+		Email:     email,
+		SessionID: sessionID,
 		RegisteredClaims: jwt.RegisteredClaims{
 			ExpiresAt: jwt.NewNumericDate(time.Now().Add(s.expirationTime)),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
@@ -80,6 +129,36 @@ func (s *JWTService) ValidateToken(tokenString string) (*Claims, error) {
 	return claims, nil
 }
 
+// Authenticate validates a token like ValidateToken and then, when a session
+// validator is set, rejects tokens whose session was revoked or whose user
+// was deactivated
+func (s *JWTService) Authenticate(tokenString string) (*Claims, error) {
+	claims, err := s.ValidateToken(tokenString)
+	if err != nil {
+		return nil, err
+	}
+
+	if s.sessions != nil {
+		if err := s.sessions.ValidateSession(claims.SessionID, claims.UserID); err != nil {
+			return nil, err
+		}
+	}
+
+	return claims, nil
+}
+
+// AuthenticateBearer authenticates the bearer token of an API request, which
+// is either a JWT access token or a personal access token
+func (s *JWTService) AuthenticateBearer(tokenString string) (*Claims, error) {
+	if strings.HasPrefix(tokenString, models.PersonalAccessTokenPrefix) {
+		if s.personalTokens == nil {
+			return nil, ErrInvalidToken
+		}
+		return s.personalTokens.AuthenticatePersonalToken(tokenString)
+	}
+	return s.Authenticate(tokenString)
+}
+
 // GetSecretKey returns the secret key (for testing)
 func (s *JWTService) GetSecretKey() string {
 	return s.secretKey
@@ -0,0 +1,192 @@
+package services
+
+import (
+	"bytes"
+	"fmt"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/smtp"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// EmailMessage is one outbound email. Body is the plain-text version; when
+// HTMLBody is set too, both are sent as multipart/alternative.
+type EmailMessage struct {
+	To       string
+	Subject  string
+	Body     string
+	HTMLBody string
+}
+
+// Mailer delivers email messages. Implementations must be safe for
+// concurrent use.
+type Mailer interface {
+	Send(msg EmailMessage) error
+}
+
+// NewMailerFromEnv picks the transport from MAIL_TRANSPORT: "smtp" (the
+// default), "file" to write messages into the maildir at MAIL_DIR, or
+// "memory" to keep them in memory
+func NewMailerFromEnv() Mailer {
+	from := os.Getenv("SMTP_EMAIL")
+
+	switch strings.ToLower(os.Getenv("MAIL_TRANSPORT")) {
+	case "file":
+		dir := os.Getenv("MAIL_DIR")
+		if dir == "" {
+			dir = "mail"
+		}
+		return NewFileMailer(dir, from)
+	case "memory":
+		return NewMemoryMailer()
+	}
+
+	host := os.Getenv("SMTP_HOST")
+	if host == "" {
+		host = "smtp.gmail.com"
+	}
+	port := os.Getenv("SMTP_PORT")
+	if port == "" {
+		port = "587"
+	}
+	return NewSMTPMailer(host, port, from, os.Getenv("SMTP_PASSWORD"))
+}
+
+// formatMessage renders the message with its headers
+func formatMessage(from string, msg EmailMessage) []byte {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "From: Taskify <%s>\r\nTo: %s\r\nSubject: %s\r\nDate: %s\r\nMIME-Version: 1.0\r\n",
+		from, msg.To, mime.QEncoding.Encode("utf-8", msg.Subject), time.Now().Format(time.RFC1123Z))
+
+	if msg.HTMLBody == "" {
+		fmt.Fprintf(&b, "Content-Type: text/plain; charset=\"UTF-8\"\r\n\r\n%s", msg.Body)
+		return b.Bytes()
+	}
+
+	parts := multipart.NewWriter(&b)
+	fmt.Fprintf(&b, "Content-Type: multipart/alternative; boundary=%q\r\n\r\n", parts.Boundary())
+	// Clients show the last part they understand, so HTML goes after the text
+	writeQuotedPrintablePart(parts, "text/plain", msg.Body)
+	writeQuotedPrintablePart(parts, "text/html", msg.HTMLBody)
+	parts.Close()
+	return b.Bytes()
+}
+
+// writeQuotedPrintablePart adds one UTF-8 body part to a multipart message
+func writeQuotedPrintablePart(parts *multipart.Writer, contentType, body string) {
+	header := textproto.MIMEHeader{}
+	header.Set("Content-Type", contentType+"; charset=\"UTF-8\"")
+	header.Set("Content-Transfer-Encoding", "quoted-printable")
+	part, err := parts.CreatePart(header)
+	if err != nil {
+		return
+	}
+	qp := quotedprintable.NewWriter(part)
+	qp.Write([]byte(body))
+	qp.Close()
+}
+
+// SMTPMailer sends messages through an SMTP server
+type SMTPMailer struct {
+	host     string
+	port     string
+	from     string
+	password string
+}
+
+// NewSMTPMailer creates a new SMTPMailer that authenticates as from
+func NewSMTPMailer(host, port, from, password string) *SMTPMailer {
+	return &SMTPMailer{host: host, port: port, from: from, password: password}
+}
+
+// Send delivers the message to the SMTP server
+func (m *SMTPMailer) Send(msg EmailMessage) error {
+	auth := smtp.PlainAuth("", m.from, m.password, m.host)
+	addr := fmt.Sprintf("%s:%s", m.host, m.port)
+	if err := smtp.SendMail(addr, auth, m.from, []string{msg.To}, formatMessage(m.from, msg)); err != nil {
+		return fmt.Errorf("failed to send email: %v", err)
+	}
+	return nil
+}
+
+// FileMailer writes each message as a file into a maildir, for offline
+// development. Mail clients can open the directory directly.
+type FileMailer struct {
+	dir  string
+	from string
+
+	mu  sync.Mutex
+	seq int
+}
+
+// NewFileMailer creates a new FileMailer writing into dir
+func NewFileMailer(dir, from string) *FileMailer {
+	return &FileMailer{dir: dir, from: from}
+}
+
+// Send writes the message into the maildir's tmp folder and moves it into
+// new once complete
+func (m *FileMailer) Send(msg EmailMessage) error {
+	for _, sub := range []string{"tmp", "new", "cur"} {
+		if err := os.MkdirAll(filepath.Join(m.dir, sub), 0o755); err != nil {
+			return fmt.Errorf("failed to create maildir: %v", err)
+		}
+	}
+
+	m.mu.Lock()
+	m.seq++
+	name := fmt.Sprintf("%d.%d_%d.taskify", time.Now().UnixNano(), os.Getpid(), m.seq)
+	m.mu.Unlock()
+
+	tmpPath := filepath.Join(m.dir, "tmp", name)
+	if err := os.WriteFile(tmpPath, formatMessage(m.from, msg), 0o644); err != nil {
+		return fmt.Errorf("failed to write email: %v", err)
+	}
+	if err := os.Rename(tmpPath, filepath.Join(m.dir, "new", name)); err != nil {
+		return fmt.Errorf("failed to deliver email: %v", err)
+	}
+	return nil
+}
+
+// MemoryMailer keeps sent messages in memory, for tests
+type MemoryMailer struct {
+	mu       sync.Mutex
+	messages []EmailMessage
+	err      error
+}
+
+// NewMemoryMailer creates a new MemoryMailer
+func NewMemoryMailer() *MemoryMailer {
+	return &MemoryMailer{}
+}
+
+// Send records the message, or fails with the error set by SetError
+func (m *MemoryMailer) Send(msg EmailMessage) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.err != nil {
+		return m.err
+	}
+	m.messages = append(m.messages, msg)
+	return nil
+}
+
+// SetError makes every following Send fail with err; nil restores delivery
+func (m *MemoryMailer) SetError(err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.err = err
+}
+
+// Messages returns the messages sent so far
+func (m *MemoryMailer) Messages() []EmailMessage {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]EmailMessage(nil), m.messages...)
+}
@@ -2,97 +2,169 @@ package services
 
 import (
 	"crypto/rand"
+	"encoding/base64"
+	"errors"
 	"fmt"
 	"math/big"
-	"sync"
 	"time"
 
-	"github.com/google/uuid"
+	"backend/internal/auth/models"
+	"backend/internal/auth/repository"
+
+	"golang.org/x/crypto/bcrypt"
 )
 
-// OTPEntry stores an OTP and its metadata
-type OTPEntry struct {
-	Code       string
-	ExpiresAt  time.Time
-	ResetToken string // set after OTP is verified
-}
+const (
+	// OTPTTL is how long a password reset code, and the reset token it is
+	// exchanged for, stay valid
+	OTPTTL = 10 * time.Minute
+	// OTPResendInterval is how long an address waits before another code
+	// can be sent to it
+	OTPResendInterval = time.Minute
+	// OTPLockout is how long an address is locked after too many wrong codes
+	OTPLockout = 15 * time.Minute
+	// maxOTPAttempts is how many codes can be tried against one OTP
+	maxOTPAttempts = 5
+)
+
+var (
+	ErrOTPNotFound       = errors.New("no OTP found for this email")
+	ErrOTPExpired        = errors.New("OTP has expired")
+	ErrInvalidOTP        = errors.New("invalid OTP code")
+	ErrOTPLocked         = errors.New("too many incorrect codes, please try again later")
+	ErrOTPResendTooSoon  = errors.New("a code was sent recently, please wait before requesting another")
+	ErrInvalidResetToken = errors.New("invalid reset token")
+	ErrResetTokenExpired = errors.New("reset token has expired")
+)
 
-// OTPService handles OTP generation, storage, and verification
+// OTPService handles password reset codes: generation, verification with an
+// attempt limit, and the reset tokens verified codes are exchanged for
 type OTPService struct {
-	store map[string]*OTPEntry // keyed by email
-	mu    sync.Mutex
+	repo *repository.OTPRepository
 }
 
 // NewOTPService creates a new OTPService
-func NewOTPService() *OTPService {
-	return &OTPService{
-		store: make(map[string]*OTPEntry),
-	}
+func NewOTPService(repo *repository.OTPRepository) *OTPService {
+	return &OTPService{repo: repo}
 }
 
-// GenerateOTP creates a random 6-digit OTP for the given email (10-minute expiry)
+// GenerateOTP creates a random 6-digit OTP for the given email, replacing any
+// earlier one. It refuses while the address is locked out or when the last
+// code went out less than OTPResendInterval ago.
 func (s *OTPService) GenerateOTP(email string) (string, error) {
+	existing, err := s.repo.Get(email)
+	if err != nil {
+		return "", err
+	}
+	now := time.Now()
+	if existing != nil {
+		if existing.LockedUntil != nil && now.Before(*existing.LockedUntil) {
+			return "", ErrOTPLocked
+		}
+		if now.Sub(existing.SentAt) < OTPResendInterval {
+			return "", ErrOTPResendTooSoon
+		}
+	}
+
 	code, err := generateRandomCode(6)
 	if err != nil {
 		return "", fmt.Errorf("failed to generate OTP: %v", err)
 	}
 
-	s.mu.Lock()
-	defer s.mu.Unlock()
+	// A 6-digit code has few enough values that a plain hash of it is easily
+	// reversed, so it is hashed with bcrypt like a password
+	codeHash, err := bcrypt.GenerateFromPassword([]byte(code), bcrypt.DefaultCost)
+	if err != nil {
+		return "", fmt.Errorf("failed to hash OTP: %v", err)
+	}
 
-	s.store[email] = &OTPEntry{
-		Code:      code,
-		ExpiresAt: time.Now().Add(10 * time.Minute),
+	if err := s.repo.Save(&models.PasswordResetOTP{
+		Email:     email,
+		CodeHash:  string(codeHash),
+		ExpiresAt: now.Add(OTPTTL),
+		SentAt:    now,
+	}); err != nil {
+		return "", err
 	}
 
 	return code, nil
 }
 
-// VerifyOTP checks the OTP code for an email and returns a reset token if valid
+// VerifyOTP checks the OTP code for an email and returns a reset token if
+// valid. Every attempt counts towards the limit; the one that reaches it
+// locks the address for OTPLockout.
 func (s *OTPService) VerifyOTP(email, code string) (string, error) {
-	s.mu.Lock()
-	defer s.mu.Unlock()
+	otp, err := s.repo.Get(email)
+	if err != nil {
+		return "", err
+	}
+	if otp == nil || otp.CodeHash == "" {
+		return "", ErrOTPNotFound
+	}
 
-	entry, exists := s.store[email]
-	if !exists {
-		return "", fmt.Errorf("no OTP found for this email")
+	now := time.Now()
+	if otp.LockedUntil != nil && now.Before(*otp.LockedUntil) {
+		return "", ErrOTPLocked
+	}
+	if !now.Before(otp.ExpiresAt) {
+		if err := s.repo.Delete(email); err != nil {
+			return "", err
+		}
+		return "", ErrOTPExpired
+	}
+
+	// The attempt is counted before the code is checked, so parallel
+	// guesses cannot get past the limit
+	allowed, err := s.repo.CountAttempt(email, maxOTPAttempts, now.Add(OTPLockout))
+	if err != nil {
+		return "", err
+	}
+	if !allowed {
+		return "", ErrOTPLocked
 	}
 
-	if time.Now().After(entry.ExpiresAt) {
-		delete(s.store, email)
-		return "", fmt.Errorf("OTP has expired")
+	if err := bcrypt.CompareHashAndPassword([]byte(otp.CodeHash), []byte(code)); err != nil {
+		if otp.Attempts+1 >= maxOTPAttempts {
+			return "", ErrOTPLocked
+		}
+		return "", ErrInvalidOTP
 	}
 
-	if entry.Code != code {
-		return "", fmt.Errorf("invalid OTP code")
+	// OTP is valid — exchange it for a one-time reset token
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate reset token: %v", err)
 	}
+	resetToken := base64.RawURLEncoding.EncodeToString(b)
 
-	// OTP is valid — generate a one-time reset token
-	resetToken := uuid.New().String()
-	entry.ResetToken = resetToken
-	entry.ExpiresAt = time.Now().Add(10 * time.Minute) // extend for password reset
+	exchanged, err := s.repo.SetResetToken(email, otp.CodeHash, hashToken(resetToken), now.Add(OTPTTL))
+	if err != nil {
+		return "", err
+	}
+	if !exchanged {
+		return "", ErrOTPNotFound
+	}
 
 	return resetToken, nil
 }
 
-// ValidateResetToken checks if a reset token is valid and returns the associated email
+// ValidateResetToken consumes a reset token and returns the associated email
 func (s *OTPService) ValidateResetToken(token string) (string, error) {
-	s.mu.Lock()
-	defer s.mu.Unlock()
-
-	for email, entry := range s.store {
-		if entry.ResetToken == token {
-			if time.Now().After(entry.ExpiresAt) {
-				delete(s.store, email)
-				return "", fmt.Errorf("reset token has expired")
-			}
-			// Consume the token (one-time use)
-			delete(s.store, email)
-			return email, nil
-		}
+	if token == "" {
+		return "", ErrInvalidResetToken
 	}
 
-	return "", fmt.Errorf("invalid reset token")
+	email, expiresAt, err := s.repo.ConsumeResetToken(hashToken(token))
+	if err != nil {
+		return "", err
+	}
+	if email == "" {
+		return "", ErrInvalidResetToken
+	}
+	if !time.Now().Before(expiresAt) {
+		return "", ErrResetTokenExpired
+	}
+	return email, nil
 }
 
 // generateRandomCode generates a cryptographically random numeric code of the given length
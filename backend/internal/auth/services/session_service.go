@@ -0,0 +1,299 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"backend/internal/auth/models"
+	"backend/internal/auth/repository"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrInvalidRefreshToken = errors.New("invalid or expired refresh token")
+	ErrRefreshTokenReused  = errors.New("refresh token was already used")
+	ErrSessionNotFound     = errors.New("session not found")
+)
+
+// ClientInfo describes the device a session is started or refreshed from
+type ClientInfo struct {
+	UserAgent string
+	IPAddress string
+}
+
+// SessionService issues access and refresh tokens and manages the sessions
+// they belong to. Refresh tokens rotate on every use; presenting one that was
+// already rotated revokes its whole session, since either the client or an
+// attacker holds a stolen copy.
+type SessionService struct {
+	repo       *repository.SessionRepository
+	userRepo   *repository.UserRepository
+	jwtService *JWTService
+	refreshTTL time.Duration
+}
+
+// NewSessionService creates a new SessionService whose refresh tokens are
+// valid for refreshTTL after their last use
+func NewSessionService(repo *repository.SessionRepository, userRepo *repository.UserRepository, jwtService *JWTService, refreshTTL time.Duration) *SessionService {
+	return &SessionService{
+		repo:       repo,
+		userRepo:   userRepo,
+		jwtService: jwtService,
+		refreshTTL: refreshTTL,
+	}
+}
+
+// StartSession starts a new session for the user and returns its tokens
+func (s *SessionService) StartSession(user *models.User, client ClientInfo) (*AuthResponse, error) {
+	now := time.Now().UTC()
+	familyID := uuid.New().String()
+
+	refreshToken, tokenHash, err := generateRefreshToken()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.repo.CreateRefreshToken(&models.RefreshToken{
+		ID:        uuid.New().String(),
+		FamilyID:  familyID,
+		UserID:    user.ID,
+		TokenHash: tokenHash,
+		UserAgent: client.UserAgent,
+		IPAddress: client.IPAddress,
+		StartedAt: now,
+		CreatedAt: now,
+		ExpiresAt: now.Add(s.refreshTTL),
+	}); err != nil {
+		return nil, err
+	}
+
+	return s.authResponse(user, familyID, refreshToken)
+}
+
+// Refresh exchanges a refresh token for a new access token and a new refresh
+// token of the same session
+func (s *SessionService) Refresh(refreshToken string, client ClientInfo) (*AuthResponse, error) {
+	if refreshToken == "" {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	current, err := s.repo.GetRefreshTokenByHash(hashToken(refreshToken))
+	if err != nil {
+		return nil, err
+	}
+	if current == nil || current.RevokedAt != nil {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	now := time.Now().UTC()
+	if current.RotatedAt != nil {
+		return nil, s.revokeReusedFamily(current.FamilyID, now)
+	}
+	if !now.Before(current.ExpiresAt) {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	user, err := s.userRepo.GetUserByID(current.UserID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user: %v", err)
+	}
+	if user == nil || !user.IsActive {
+		if err := s.repo.RevokeFamily(current.FamilyID, now); err != nil {
+			return nil, err
+		}
+		return nil, ErrUserInactive
+	}
+
+	nextToken, nextHash, err := generateRefreshToken()
+	if err != nil {
+		return nil, err
+	}
+
+	rotated, err := s.repo.RotateRefreshToken(current.ID, &models.RefreshToken{
+		ID:        uuid.New().String(),
+		FamilyID:  current.FamilyID,
+		UserID:    current.UserID,
+		TokenHash: nextHash,
+		UserAgent: firstNonEmpty(client.UserAgent, current.UserAgent),
+		IPAddress: firstNonEmpty(client.IPAddress, current.IPAddress),
+		StartedAt: current.StartedAt,
+		CreatedAt: now,
+		ExpiresAt: now.Add(s.refreshTTL),
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !rotated {
+		// Another request rotated the token between our read and write
+		return nil, s.revokeReusedFamily(current.FamilyID, now)
+	}
+
+	return s.authResponse(user, current.FamilyID, nextToken)
+}
+
+// Logout revokes the session a refresh token belongs to. Unknown tokens are
+// ignored so that logging out twice is harmless.
+func (s *SessionService) Logout(refreshToken string) error {
+	if refreshToken == "" {
+		return ErrInvalidRefreshToken
+	}
+
+	token, err := s.repo.GetRefreshTokenByHash(hashToken(refreshToken))
+	if err != nil {
+		return err
+	}
+	if token == nil {
+		return nil
+	}
+
+	return s.repo.RevokeFamily(token.FamilyID, time.Now())
+}
+
+// ListSessions lists the user's active sessions, flagging currentSessionID
+func (s *SessionService) ListSessions(userID, currentSessionID string) ([]models.Session, error) {
+	sessions, err := s.repo.ListActiveSessions(userID, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range sessions {
+		sessions[i].Current = sessions[i].ID == currentSessionID
+	}
+	return sessions, nil
+}
+
+// RevokeSession signs the user out of one of their sessions
+func (s *SessionService) RevokeSession(userID, sessionID string) error {
+	revoked, err := s.repo.RevokeUserSession(userID, sessionID, time.Now())
+	if err != nil {
+		return err
+	}
+	if !revoked {
+		return ErrSessionNotFound
+	}
+	return nil
+}
+
+// RevokeOtherSessions signs the user out everywhere except currentSessionID
+// and returns how many sessions were revoked
+func (s *SessionService) RevokeOtherSessions(userID, currentSessionID string) (int64, error) {
+	return s.repo.RevokeUserSessions(userID, currentSessionID, time.Now())
+}
+
+// RevokeAllSessions signs the user out everywhere
+func (s *SessionService) RevokeAllSessions(userID string) error {
+	_, err := s.repo.RevokeUserSessions(userID, "", time.Now())
+	return err
+}
+
+// ValidateSession implements SessionValidator. Tokens without a session, such
+// as those issued before sessions existed, are refused, since logging out or
+// revoking sessions could never end them.
+func (s *SessionService) ValidateSession(sessionID, userID string) error {
+	user, err := s.userRepo.GetUserByID(userID)
+	if err != nil {
+		return fmt.Errorf("failed to get user: %v", err)
+	}
+	if user == nil {
+		return ErrInvalidToken
+	}
+	if !user.IsActive {
+		return ErrUserInactive
+	}
+
+	if sessionID == "" {
+		return ErrInvalidToken
+	}
+
+	active, err := s.repo.IsSessionActive(sessionID, userID, time.Now())
+	if err != nil {
+		return err
+	}
+	if !active {
+		return ErrSessionRevoked
+	}
+	return nil
+}
+
+// PurgeExpired deletes sessions that can no longer be refreshed
+func (s *SessionService) PurgeExpired() (int64, error) {
+	return s.repo.DeleteExpired(time.Now())
+}
+
+// StartCleanup purges expired sessions on the given interval
+func (s *SessionService) StartCleanup(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			if n, err := s.PurgeExpired(); err != nil {
+				log.Printf("Failed to purge expired sessions: %v", err)
+			} else if n > 0 {
+				log.Printf("Purged %d expired refresh tokens", n)
+			}
+		}
+	}()
+}
+
+func (s *SessionService) revokeReusedFamily(familyID string, now time.Time) error {
+	if err := s.repo.RevokeFamily(familyID, now); err != nil {
+		return err
+	}
+	log.Printf("Refresh token reuse detected, revoked session %s", familyID)
+	return ErrRefreshTokenReused
+}
+
+func (s *SessionService) authResponse(user *models.User, sessionID, refreshToken string) (*AuthResponse, error) {
+	token, err := s.jwtService.GenerateSessionToken(user.ID, user.Email, sessionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate token: %v", err)
+	}
+
+	userResponse := user.ToResponse()
+	return &AuthResponse{
+		User:         &userResponse,
+		Token:        token,
+		RefreshToken: refreshToken,
+		ExpiresIn:    int64(s.jwtService.AccessTokenTTL().Seconds()),
+	}, nil
+}
+
+// generateRefreshToken returns a random refresh token and the hash it is
+// stored under
+func generateRefreshToken() (string, string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", fmt.Errorf("failed to generate refresh token: %v", err)
+	}
+	token := base64.RawURLEncoding.EncodeToString(b)
+	return token, hashToken(token), nil
+}
+
+// hashToken returns the SHA-256 hash secret tokens are stored and looked up by
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+type clientInfoKey struct{}
+
+// WithClientInfo attaches the requesting device to a context, for sign-in
+// flows that take a context rather than a request struct
+func WithClientInfo(ctx context.Context, client ClientInfo) context.Context {
+	return context.WithValue(ctx, clientInfoKey{}, client)
+}
+
+// ClientInfoFromContext returns the device attached by WithClientInfo
+func ClientInfoFromContext(ctx context.Context) ClientInfo {
+	client, _ := ctx.Value(clientInfoKey{}).(ClientInfo)
+	return client
+}
@@ -0,0 +1,354 @@
+package services
+
+import (
+	"crypto/rand"
+	"encoding/base32"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"backend/internal/auth/models"
+	"backend/internal/auth/repository"
+
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	// TwoFactorChallengeTTL is how long a sign-in waits for its second factor
+	TwoFactorChallengeTTL = 5 * time.Minute
+	// maxChallengeAttempts is how many wrong codes a challenge takes before
+	// the sign-in has to start over
+	maxChallengeAttempts = 5
+	// recoveryCodeCount is how many recovery codes a user gets at a time
+	recoveryCodeCount = 10
+
+	totpIssuer = "Taskify"
+)
+
+var (
+	ErrTwoFactorAlreadyEnabled = errors.New("two-factor authentication is already enabled")
+	ErrTwoFactorNotEnabled     = errors.New("two-factor authentication is not enabled")
+	ErrTwoFactorSetupRequired  = errors.New("two-factor setup has not been started")
+	ErrInvalidTwoFactorCode    = errors.New("invalid two-factor code")
+	ErrInvalidChallenge        = errors.New("invalid or expired two-factor challenge")
+	ErrIncorrectPassword       = errors.New("password is incorrect")
+)
+
+// recoveryCodeEncoding spells recovery codes in lowercase base32, which has
+// no easily confused characters
+var recoveryCodeEncoding = base32.NewEncoding("abcdefghijklmnopqrstuvwxyz234567").WithPadding(base32.NoPadding)
+
+// TwoFactorService manages TOTP two-factor authentication: enrollment,
+// recovery codes and the second step of sign-in
+type TwoFactorService struct {
+	repo     *repository.TwoFactorRepository
+	userRepo *repository.UserRepository
+}
+
+// NewTwoFactorService creates a new TwoFactorService
+func NewTwoFactorService(repo *repository.TwoFactorRepository, userRepo *repository.UserRepository) *TwoFactorService {
+	return &TwoFactorService{
+		repo:     repo,
+		userRepo: userRepo,
+	}
+}
+
+// Status reports whether the user has two-factor authentication turned on
+func (s *TwoFactorService) Status(userID string) (*models.TwoFactorStatus, error) {
+	secret, err := s.repo.GetTOTP(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	status := &models.TwoFactorStatus{}
+	if secret == nil || secret.EnabledAt == nil {
+		return status, nil
+	}
+
+	remaining, err := s.repo.CountUnusedRecoveryCodes(userID)
+	if err != nil {
+		return nil, err
+	}
+	status.Enabled = true
+	status.EnabledAt = secret.EnabledAt
+	status.RecoveryCodesRemaining = remaining
+	return status, nil
+}
+
+// IsEnabled reports whether sign-ins of the user need a second factor
+func (s *TwoFactorService) IsEnabled(userID string) (bool, error) {
+	secret, err := s.repo.GetTOTP(userID)
+	if err != nil {
+		return false, err
+	}
+	return secret != nil && secret.EnabledAt != nil, nil
+}
+
+// Setup starts enrollment with a new secret. Two-factor authentication stays
+// off until Enable verifies a code from it.
+func (s *TwoFactorService) Setup(userID string) (*models.TwoFactorSetup, error) {
+	user, err := s.getUser(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	enabled, err := s.IsEnabled(userID)
+	if err != nil {
+		return nil, err
+	}
+	if enabled {
+		return nil, ErrTwoFactorAlreadyEnabled
+	}
+
+	secret, err := generateTOTPSecret()
+	if err != nil {
+		return nil, err
+	}
+	if err := s.repo.SavePendingTOTP(userID, secret); err != nil {
+		return nil, err
+	}
+
+	return &models.TwoFactorSetup{
+		Secret:     secret,
+		OTPAuthURI: totpURI(totpIssuer, user.Email, secret),
+	}, nil
+}
+
+// Enable verifies the first code from the user's authenticator, turns on
+// two-factor authentication and returns the user's recovery codes. They are
+// stored hashed and cannot be shown again.
+func (s *TwoFactorService) Enable(userID, code string) ([]string, error) {
+	secret, err := s.repo.GetTOTP(userID)
+	if err != nil {
+		return nil, err
+	}
+	if secret == nil {
+		return nil, ErrTwoFactorSetupRequired
+	}
+	if secret.EnabledAt != nil {
+		return nil, ErrTwoFactorAlreadyEnabled
+	}
+
+	step, ok := matchTOTP(secret.Secret, normalizeTwoFactorCode(code), time.Now())
+	if !ok {
+		return nil, ErrInvalidTwoFactorCode
+	}
+
+	codes, hashes, err := generateRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	if err := s.repo.EnableTOTP(userID, step, hashes); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// Disable turns off two-factor authentication. The user re-authenticates
+// with their password (unless the account has none, as with Google-only
+// accounts) and a current TOTP or recovery code.
+func (s *TwoFactorService) Disable(userID, password, code string) error {
+	user, err := s.getUser(userID)
+	if err != nil {
+		return err
+	}
+
+	enabled, err := s.IsEnabled(userID)
+	if err != nil {
+		return err
+	}
+	if !enabled {
+		return ErrTwoFactorNotEnabled
+	}
+
+	if user.PasswordHash != "" {
+		if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password)); err != nil {
+			return ErrIncorrectPassword
+		}
+	}
+
+	if err := s.verifyCode(userID, code); err != nil {
+		return err
+	}
+
+	return s.repo.DeleteTOTP(userID)
+}
+
+// Reset turns off two-factor authentication without re-authentication, for
+// when the account is taken back from whoever set it up
+func (s *TwoFactorService) Reset(userID string) error {
+	return s.repo.DeleteTOTP(userID)
+}
+
+// RegenerateRecoveryCodes replaces the user's recovery codes, after checking
+// a current TOTP code
+func (s *TwoFactorService) RegenerateRecoveryCodes(userID, code string) ([]string, error) {
+	enabled, err := s.IsEnabled(userID)
+	if err != nil {
+		return nil, err
+	}
+	if !enabled {
+		return nil, ErrTwoFactorNotEnabled
+	}
+
+	if err := s.verifyTOTP(userID, normalizeTwoFactorCode(code)); err != nil {
+		return nil, err
+	}
+
+	codes, hashes, err := generateRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	if err := s.repo.ReplaceRecoveryCodes(userID, hashes); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// CreateChallenge starts the second step of the user's sign-in and returns
+// the token the client redeems with a code
+func (s *TwoFactorService) CreateChallenge(userID string) (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate challenge token: %v", err)
+	}
+	token := base64.RawURLEncoding.EncodeToString(b)
+
+	if err := s.repo.CreateChallenge(&models.TwoFactorChallenge{
+		ID:        uuid.New().String(),
+		UserID:    userID,
+		ExpiresAt: time.Now().Add(TwoFactorChallengeTTL),
+	}, hashToken(token)); err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// VerifyChallenge redeems a challenge token with a TOTP or recovery code and
+// returns the user signing in. A challenge can be redeemed once, and is
+// refused after too many wrong codes.
+func (s *TwoFactorService) VerifyChallenge(challengeToken, code string) (*models.User, error) {
+	if challengeToken == "" {
+		return nil, ErrInvalidChallenge
+	}
+
+	challenge, err := s.repo.GetChallengeByHash(hashToken(challengeToken))
+	if err != nil {
+		return nil, err
+	}
+	if challenge == nil {
+		return nil, ErrInvalidChallenge
+	}
+
+	// The attempt is counted before the code is checked, so parallel
+	// guesses cannot get past the limit
+	allowed, err := s.repo.ClaimChallengeAttempt(challenge.ID, maxChallengeAttempts, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	if !allowed {
+		return nil, ErrInvalidChallenge
+	}
+
+	if err := s.verifyCode(challenge.UserID, code); err != nil {
+		return nil, err
+	}
+
+	// Deleting the challenge claims it, so two requests cannot both redeem it
+	redeemed, err := s.repo.DeleteChallenge(challenge.ID)
+	if err != nil {
+		return nil, err
+	}
+	if !redeemed {
+		return nil, ErrInvalidChallenge
+	}
+
+	user, err := s.getUser(challenge.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if !user.IsActive {
+		return nil, ErrUserInactive
+	}
+	return user, nil
+}
+
+// verifyCode accepts a current TOTP code or an unused recovery code, which
+// is used up
+func (s *TwoFactorService) verifyCode(userID, code string) error {
+	code = normalizeTwoFactorCode(code)
+	if len(code) == totpDigits {
+		return s.verifyTOTP(userID, code)
+	}
+
+	used, err := s.repo.UseRecoveryCode(userID, hashToken(code))
+	if err != nil {
+		return err
+	}
+	if !used {
+		return ErrInvalidTwoFactorCode
+	}
+	return nil
+}
+
+// verifyTOTP accepts a current TOTP code that has not been used before
+func (s *TwoFactorService) verifyTOTP(userID, code string) error {
+	secret, err := s.repo.GetTOTP(userID)
+	if err != nil {
+		return err
+	}
+	if secret == nil || secret.EnabledAt == nil {
+		return ErrTwoFactorNotEnabled
+	}
+
+	step, ok := matchTOTP(secret.Secret, code, time.Now())
+	if !ok || step <= secret.LastUsedStep {
+		return ErrInvalidTwoFactorCode
+	}
+
+	fresh, err := s.repo.UseTOTPStep(userID, step)
+	if err != nil {
+		return err
+	}
+	if !fresh {
+		return ErrInvalidTwoFactorCode
+	}
+	return nil
+}
+
+func (s *TwoFactorService) getUser(userID string) (*models.User, error) {
+	user, err := s.userRepo.GetUserByID(userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user: %v", err)
+	}
+	if user == nil {
+		return nil, errors.New("user not found")
+	}
+	return user, nil
+}
+
+// generateRecoveryCodes returns new recovery codes, formatted "xxxxx-xxxxx",
+// and the hashes they are stored under
+func generateRecoveryCodes() ([]string, []string, error) {
+	codes := make([]string, recoveryCodeCount)
+	hashes := make([]string, recoveryCodeCount)
+	for i := range codes {
+		b := make([]byte, 7)
+		if _, err := rand.Read(b); err != nil {
+			return nil, nil, fmt.Errorf("failed to generate recovery code: %v", err)
+		}
+		code := recoveryCodeEncoding.EncodeToString(b)[:10]
+		codes[i] = code[:5] + "-" + code[5:]
+		hashes[i] = hashToken(code)
+	}
+	return codes, hashes, nil
+}
+
+// normalizeTwoFactorCode drops the spaces and dashes people type into codes
+// and lowercases recovery codes
+func normalizeTwoFactorCode(code string) string {
+	return strings.ToLower(strings.NewReplacer(" ", "", "-", "").Replace(strings.TrimSpace(code)))
+}
@@ -0,0 +1,133 @@
+package chat
+
+import (
+	"errors"
+	"sync"
+)
+
+// Backplane message kinds
+const (
+	// BackplaneBroadcast delivers Payload to every local client in the project
+	BackplaneBroadcast = "broadcast"
+
+	// BackplaneDisconnect closes every local connection UserID holds in the project
+	BackplaneDisconnect = "disconnect"
+
+	// BackplanePresence reports that UserID's first connection to the project
+	// opened on the publishing instance, or that its last one closed
+	BackplanePresence = "presence"
+
+	// BackplanePresenceSnapshot lists every user connected to the publishing
+	// instance. Instances publish one periodically, so presence recovers from
+	// lost messages and from instances that stop without saying goodbye.
+	BackplanePresenceSnapshot = "presence.snapshot"
+)
+
+// ErrBackplaneClosed is returned when publishing on a closed backplane
+var ErrBackplaneClosed = errors.New("backplane is closed")
+
+// BackplaneMessage is a hub operation relayed between backend instances
+type BackplaneMessage struct {
+	Kind      string `json:"kind"`
+	ProjectID int64  `json:"project_id"`
+	UserID    string `json:"user_id,omitempty"`
+	Payload   []byte `json:"payload,omitempty"`
+}
+
+// Backplane relays hub operations between backend instances so clients
+// connected to different instances see the same chat and board updates.
+// Implementations never hand a message back to the instance that published it.
+type Backplane interface {
+	// Publish sends a message to every other instance
+	Publish(msg BackplaneMessage) error
+
+	// Subscribe registers the handler for messages from other instances.
+	// It is called once, before the hub starts serving clients.
+	Subscribe(handler func(BackplaneMessage)) error
+
+	// Close stops delivery and releases resources
+	Close() error
+}
+
+// MemoryBus connects hubs running in the same process. It is mainly useful
+// for tests and for running several hubs behind one listener.
+type MemoryBus struct {
+	mutex     sync.RWMutex
+	endpoints map[*memoryBackplane]bool
+}
+
+// NewMemoryBus creates an empty in-memory bus
+func NewMemoryBus() *MemoryBus {
+	return &MemoryBus{endpoints: make(map[*memoryBackplane]bool)}
+}
+
+// Backplane returns a new endpoint on the bus for one hub
+func (b *MemoryBus) Backplane() Backplane {
+	endpoint := &memoryBackplane{bus: b}
+	b.mutex.Lock()
+	b.endpoints[endpoint] = true
+	b.mutex.Unlock()
+	return endpoint
+}
+
+type memoryBackplane struct {
+	bus     *MemoryBus
+	mutex   sync.RWMutex
+	handler func(BackplaneMessage)
+	closed  bool
+}
+
+func (m *memoryBackplane) Publish(msg BackplaneMessage) error {
+	m.mutex.RLock()
+	closed := m.closed
+	m.mutex.RUnlock()
+	if closed {
+		return ErrBackplaneClosed
+	}
+
+	m.bus.mutex.RLock()
+	peers := make([]*memoryBackplane, 0, len(m.bus.endpoints))
+	for endpoint := range m.bus.endpoints {
+		if endpoint != m {
+			peers = append(peers, endpoint)
+		}
+	}
+	m.bus.mutex.RUnlock()
+
+	for _, peer := range peers {
+		peer.deliver(msg)
+	}
+	return nil
+}
+
+func (m *memoryBackplane) deliver(msg BackplaneMessage) {
+	m.mutex.RLock()
+	handler := m.handler
+	closed := m.closed
+	m.mutex.RUnlock()
+
+	if handler != nil && !closed {
+		handler(msg)
+	}
+}
+
+func (m *memoryBackplane) Subscribe(handler func(BackplaneMessage)) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if m.closed {
+		return ErrBackplaneClosed
+	}
+	m.handler = handler
+	return nil
+}
+
+func (m *memoryBackplane) Close() error {
+	m.mutex.Lock()
+	m.closed = true
+	m.mutex.Unlock()
+
+	m.bus.mutex.Lock()
+	delete(m.bus.endpoints, m)
+	m.bus.mutex.Unlock()
+	return nil
+}
@@ -0,0 +1,165 @@
+package chat
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// hubEventRetention is how long relayed messages stay in hub_events. Instances
+// that fall further behind than this miss messages, like a dropped socket would.
+const hubEventRetention = time.Minute
+
+// SQLiteBackplane relays hub operations through a hub_events table in the
+// shared database. Every instance polls for rows written by the others.
+type SQLiteBackplane struct {
+	db           *sql.DB
+	origin       string
+	pollInterval time.Duration
+
+	mutex  sync.Mutex
+	stop   chan struct{}
+	done   chan struct{}
+	closed bool
+}
+
+// DefaultBackplanePollInterval is used when no positive poll interval is given
+const DefaultBackplanePollInterval = 200 * time.Millisecond
+
+// NewSQLiteBackplane returns a backplane that checks the hub_events table,
+// created with the rest of the schema, for new messages every pollInterval
+func NewSQLiteBackplane(db *sql.DB, pollInterval time.Duration) *SQLiteBackplane {
+	if pollInterval <= 0 {
+		pollInterval = DefaultBackplanePollInterval
+	}
+
+	return &SQLiteBackplane{
+		db:           db,
+		origin:       uuid.New().String(),
+		pollInterval: pollInterval,
+	}
+}
+
+// Publish stores the message for the other instances to pick up
+func (b *SQLiteBackplane) Publish(msg BackplaneMessage) error {
+	b.mutex.Lock()
+	closed := b.closed
+	b.mutex.Unlock()
+	if closed {
+		return ErrBackplaneClosed
+	}
+
+	_, err := b.db.Exec(
+		"INSERT INTO hub_events (origin, kind, project_id, user_id, payload, created_at) VALUES (?, ?, ?, ?, ?, ?)",
+		b.origin, msg.Kind, msg.ProjectID, msg.UserID, msg.Payload, time.Now().UTC(),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to publish hub event: %v", err)
+	}
+	return nil
+}
+
+// Subscribe starts polling for messages written after this call
+func (b *SQLiteBackplane) Subscribe(handler func(BackplaneMessage)) error {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	if b.closed {
+		return ErrBackplaneClosed
+	}
+	if b.stop != nil {
+		return fmt.Errorf("backplane already has a subscriber")
+	}
+
+	var lastID int64
+	if err := b.db.QueryRow("SELECT COALESCE(MAX(id), 0) FROM hub_events").Scan(&lastID); err != nil {
+		return fmt.Errorf("failed to read hub_events position: %v", err)
+	}
+
+	b.stop = make(chan struct{})
+	b.done = make(chan struct{})
+	go b.poll(lastID, handler)
+	return nil
+}
+
+func (b *SQLiteBackplane) poll(lastID int64, handler func(BackplaneMessage)) {
+	defer close(b.done)
+
+	ticker := time.NewTicker(b.pollInterval)
+	defer ticker.Stop()
+
+	lastPrune := time.Now()
+	for {
+		select {
+		case <-b.stop:
+			return
+		case <-ticker.C:
+		}
+
+		messages, newLastID, err := b.fetchSince(lastID)
+		if err != nil {
+			log.Printf("Backplane poll failed: %v", err)
+			continue
+		}
+		lastID = newLastID
+		for _, msg := range messages {
+			handler(msg)
+		}
+
+		if time.Since(lastPrune) >= hubEventRetention {
+			lastPrune = time.Now()
+			cutoff := time.Now().UTC().Add(-hubEventRetention)
+			if _, err := b.db.Exec("DELETE FROM hub_events WHERE created_at < ?", cutoff); err != nil {
+				log.Printf("Backplane prune failed: %v", err)
+			}
+		}
+	}
+}
+
+// fetchSince returns messages from other instances with id > lastID and the new high-water mark
+func (b *SQLiteBackplane) fetchSince(lastID int64) ([]BackplaneMessage, int64, error) {
+	rows, err := b.db.Query(
+		"SELECT id, origin, kind, project_id, COALESCE(user_id, ''), payload FROM hub_events WHERE id > ? ORDER BY id ASC",
+		lastID,
+	)
+	if err != nil {
+		return nil, lastID, err
+	}
+	defer rows.Close()
+
+	var messages []BackplaneMessage
+	for rows.Next() {
+		var id int64
+		var origin string
+		var msg BackplaneMessage
+		if err := rows.Scan(&id, &origin, &msg.Kind, &msg.ProjectID, &msg.UserID, &msg.Payload); err != nil {
+			return nil, lastID, err
+		}
+		lastID = id
+		if origin != b.origin {
+			messages = append(messages, msg)
+		}
+	}
+	return messages, lastID, rows.Err()
+}
+
+// Close stops polling. Messages already stored are left for other instances.
+func (b *SQLiteBackplane) Close() error {
+	b.mutex.Lock()
+	if b.closed {
+		b.mutex.Unlock()
+		return nil
+	}
+	b.closed = true
+	stop, done := b.stop, b.done
+	b.mutex.Unlock()
+
+	if stop != nil {
+		close(stop)
+		<-done
+	}
+	return nil
+}
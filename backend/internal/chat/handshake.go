@@ -0,0 +1,148 @@
+package chat
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"backend/internal/auth/services"
+
+	"github.com/gorilla/mux"
+	"github.com/gorilla/websocket"
+)
+
+// tokenSubprotocol is the Sec-WebSocket-Protocol entry that precedes the JWT
+// when a browser client sends its token as a subprotocol: ["bearer", "<token>"]
+const tokenSubprotocol = "bearer"
+
+// TokenValidator validates the JWT presented during the WebSocket handshake,
+// including the session it was issued for
+type TokenValidator interface {
+	Authenticate(tokenString string) (*services.Claims, error)
+}
+
+// AccessChecker reports whether a user may join a project's hub
+type AccessChecker interface {
+	HasAccess(projectID int64, userID string) (bool, error)
+}
+
+// WsHandler authenticates WebSocket handshakes and attaches clients to the hub
+type WsHandler struct {
+	hub      *Hub
+	tokens   TokenValidator
+	access   AccessChecker
+	messages MessageStore
+	upgrader websocket.Upgrader
+}
+
+// NewWsHandler creates a new WsHandler. Only browser origins listed in
+// allowedOrigins may open a connection; requests without an Origin header
+// (non-browser clients) are accepted.
+func NewWsHandler(hub *Hub, tokens TokenValidator, access AccessChecker, messages MessageStore, allowedOrigins []string) *WsHandler {
+	allowed := make(map[string]bool, len(allowedOrigins))
+	for _, origin := range allowedOrigins {
+		allowed[strings.TrimRight(origin, "/")] = true
+	}
+
+	return &WsHandler{
+		hub:      hub,
+		tokens:   tokens,
+		access:   access,
+		messages: messages,
+		upgrader: websocket.Upgrader{
+			ReadBufferSize:  1024,
+			WriteBufferSize: 1024,
+			Subprotocols:    []string{tokenSubprotocol},
+			CheckOrigin: func(r *http.Request) bool {
+				origin := r.Header.Get("Origin")
+				return origin == "" || allowed[origin]
+			},
+		},
+	}
+}
+
+// ServeWs handles WebSocket requests from clients on /ws/{projectId}
+func (h *WsHandler) ServeWs(w http.ResponseWriter, r *http.Request) {
+	// Get project ID from URL
+	vars := mux.Vars(r)
+	projectID, err := strconv.ParseInt(vars["projectId"], 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid project ID", http.StatusBadRequest)
+		return
+	}
+
+	if !h.upgrader.CheckOrigin(r) {
+		http.Error(w, "Origin not allowed", http.StatusForbidden)
+		return
+	}
+
+	// Browsers cannot set headers on a WebSocket handshake, so the token
+	// arrives as a query parameter or a subprotocol entry instead
+	tokenString := handshakeToken(r)
+	if tokenString == "" {
+		http.Error(w, "Authentication required", http.StatusUnauthorized)
+		return
+	}
+
+	claims, err := h.tokens.Authenticate(tokenString)
+	if err != nil {
+		http.Error(w, "Invalid token", http.StatusUnauthorized)
+		return
+	}
+
+	// Reconnecting clients pass the last message they saw to get what they missed
+	var lastMessageID int64
+	replay := r.URL.Query().Has("last_message_id")
+	if replay {
+		lastMessageID, err = strconv.ParseInt(r.URL.Query().Get("last_message_id"), 10, 64)
+		if err != nil || lastMessageID < 0 {
+			http.Error(w, "Invalid last message ID", http.StatusBadRequest)
+			return
+		}
+	}
+
+	hasAccess, err := h.access.HasAccess(projectID, claims.UserID)
+	if err != nil {
+		http.Error(w, "Failed to check project access", http.StatusInternalServerError)
+		return
+	}
+	if !hasAccess {
+		http.Error(w, "Access denied to this project", http.StatusForbidden)
+		return
+	}
+
+	conn, err := h.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		log.Println("Error upgrading connection:", err)
+		return
+	}
+
+	client := newClient(h.hub, conn, projectID, claims.UserID, h.messages)
+	client.replaying = replay
+	h.hub.register <- client
+
+	// Start goroutines for reading and writing
+	go client.writePump()
+	go client.readPump()
+
+	if replay {
+		client.replay(lastMessageID)
+	}
+}
+
+// handshakeToken extracts the JWT from the ?token= query parameter or from
+// the "bearer, <token>" subprotocol pair
+func handshakeToken(r *http.Request) string {
+	if token := r.URL.Query().Get("token"); token != "" {
+		return token
+	}
+
+	protocols := websocket.Subprotocols(r)
+	for i := 0; i < len(protocols)-1; i++ {
+		if protocols[i] == tokenSubprotocol {
+			return protocols[i+1]
+		}
+	}
+	return ""
+}
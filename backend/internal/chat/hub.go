@@ -3,14 +3,32 @@ package chat
 import (
 	"encoding/json"
 	"log"
-	"net/http"
-	"strconv"
+	"sort"
 	"sync"
+	"time"
 
-	"github.com/gorilla/mux"
+	"backend/internal/models"
+
+	"github.com/google/uuid"
 	"github.com/gorilla/websocket"
 )
 
+// Connection lifecycle defaults
+const (
+	// Time allowed to write a frame to the peer
+	defaultWriteWait = 10 * time.Second
+
+	// Time allowed to read the next pong from the peer
+	defaultPongWait = 60 * time.Second
+
+	// Maximum frame size accepted from a client. Chat content is capped at
+	// 2000 characters, which is at most 8000 bytes of UTF-8 plus the envelope.
+	maxFrameSize = 16 * 1024
+
+	// Frames buffered per client before it is treated as a slow consumer
+	sendBufferSize = 256
+)
+
 // Hub maintains the set of active clients and broadcasts messages to clients
 type Hub struct {
 	// Registered clients by project ID
@@ -22,8 +40,34 @@ type Hub struct {
 	// Unregister requests from clients
 	unregister chan *Client
 
+	// Loaded replays for reconnecting clients; handled after their register
+	replayed chan replayResult
+
 	// Mutex for thread-safe operations
 	mutex sync.Mutex
+
+	// Heartbeat settings; pings are sent every 9/10 of pongWait
+	writeWait time.Duration
+	pongWait  time.Duration
+
+	// Relays broadcasts, disconnects and presence to other backend instances (optional)
+	backplane Backplane
+
+	// instanceID tells this hub's presence messages apart from other instances'
+	instanceID string
+
+	// Users connected to other instances by project, user and instance, with
+	// when each entry expires unless a snapshot refreshes it
+	remotePresence map[int64]map[string]map[string]time.Time
+
+	// Instances heard from, with when they are forgotten
+	peers map[string]time.Time
+
+	// How often this hub publishes a presence snapshot
+	presenceRefresh time.Duration
+
+	// Presence messages waiting to be published outside the mutex
+	presenceOut chan BackplaneMessage
 }
 
 // NewHub creates a new Hub instance
@@ -32,11 +76,117 @@ func NewHub() *Hub {
 		clients:    make(map[int64]map[*Client]bool),
 		register:   make(chan *Client),
 		unregister: make(chan *Client),
+		replayed:   make(chan replayResult),
+		writeWait:  defaultWriteWait,
+		pongWait:   defaultPongWait,
+
+		instanceID:      uuid.New().String(),
+		remotePresence:  make(map[int64]map[string]map[string]time.Time),
+		peers:           make(map[string]time.Time),
+		presenceRefresh: defaultPresenceRefresh,
 	}
 }
 
+// SetTimeouts overrides the write deadline and pong timeout for connections
+// opened afterwards. It must be called before the hub starts serving clients.
+func (h *Hub) SetTimeouts(writeWait, pongWait time.Duration) {
+	h.writeWait = writeWait
+	h.pongWait = pongWait
+}
+
+// SetBackplane connects the hub to other backend instances. Broadcasts,
+// disconnects and presence changes are published to the backplane and those
+// from other instances are applied to local clients. It must be called before
+// the hub starts serving clients.
+func (h *Hub) SetBackplane(backplane Backplane) error {
+	h.presenceOut = make(chan BackplaneMessage, presenceQueueSize)
+	if err := backplane.Subscribe(h.applyRemote); err != nil {
+		h.presenceOut = nil
+		return err
+	}
+	h.backplane = backplane
+	go h.publishPresence()
+
+	// Other instances answer the first snapshot with theirs
+	h.mutex.Lock()
+	h.queuePresenceLocked(h.snapshotLocked())
+	h.mutex.Unlock()
+	return nil
+}
+
+// publish relays an operation to other instances; failures only affect remote clients
+func (h *Hub) publish(msg BackplaneMessage) {
+	if h.backplane == nil {
+		return
+	}
+	if err := h.backplane.Publish(msg); err != nil {
+		log.Printf("Failed to publish %s for project %d: %v", msg.Kind, msg.ProjectID, err)
+	}
+}
+
+// applyRemote applies an operation published by another instance to local clients only
+func (h *Hub) applyRemote(msg BackplaneMessage) {
+	switch msg.Kind {
+	case BackplaneBroadcast:
+		h.mutex.Lock()
+		h.removeClientsLocked(h.sendLocked(msg.ProjectID, msg.Payload, nil))
+		h.mutex.Unlock()
+	case BackplaneDisconnect:
+		h.disconnectLocal(msg.ProjectID, msg.UserID)
+	case BackplanePresence:
+		h.applyRemotePresence(msg)
+	case BackplanePresenceSnapshot:
+		h.applyPresenceSnapshot(msg)
+	}
+}
+
+// pingPeriod is how often pings are sent; it must be less than pongWait
+func (h *Hub) pingPeriod() time.Duration {
+	return h.pongWait * 9 / 10
+}
+
+// Presence and typing frame types pushed to clients
+const (
+	FramePresenceJoin  = "presence.join"
+	FramePresenceLeave = "presence.leave"
+	FrameTyping        = "typing"
+	FrameRead          = "read"
+)
+
+// TypingTTL is how long clients should show a typing indicator after the
+// last typing frame. Clients resend typing frames while the user keeps typing.
+const TypingTTL = 5 * time.Second
+
+// typingThrottle drops typing frames a client sends faster than this
+const typingThrottle = time.Second
+
+// PresenceFrame announces that a user came online or went offline in a project.
+// A user with several tabs open, on any instances, joins on the first
+// connection and leaves on the last.
+type PresenceFrame struct {
+	Type      string `json:"type"`
+	ProjectID int64  `json:"project_id"`
+	UserID    string `json:"user_id"`
+}
+
+// TypingFrame is relayed to the other clients in a project while a user types
+type TypingFrame struct {
+	Type        string `json:"type"`
+	ProjectID   int64  `json:"project_id"`
+	UserID      string `json:"user_id"`
+	ExpiresInMs int64  `json:"expires_in_ms"`
+}
+
 // Run starts the hub's main loop
 func (h *Hub) Run() {
+	// Presence snapshots are only published with a backplane
+	var refresh <-chan time.Time
+	if h.backplane != nil {
+		ticker := time.NewTicker(h.presenceRefresh)
+		defer ticker.Stop()
+		refresh = ticker.C
+	}
+
 	for {
 		select {
 		case client := <-h.register:
@@ -44,43 +194,184 @@ func (h *Hub) Run() {
 			if h.clients[client.projectID] == nil {
 				h.clients[client.projectID] = make(map[*Client]bool)
 			}
+			wasOnline := h.isOnlineAnywhereLocked(client.projectID, client.userID)
+			firstConnection := !h.isOnlineLocked(client.projectID, client.userID)
 			h.clients[client.projectID][client] = true
+			if firstConnection {
+				h.localPresenceChangedLocked(client.projectID, client.userID, true)
+			}
+			if !wasOnline {
+				h.announcePresenceLocked(client.projectID, client.userID, FramePresenceJoin)
+			}
 			h.mutex.Unlock()
 
 		case client := <-h.unregister:
 			h.mutex.Lock()
-			if clients, ok := h.clients[client.projectID]; ok {
-				if _, exists := clients[client]; exists {
-					delete(clients, client)
-					close(client.send)
-				}
-				if len(h.clients[client.projectID]) == 0 {
-					delete(h.clients, client.projectID)
-				}
-			}
+			h.removeClientsLocked([]*Client{client})
 			h.mutex.Unlock()
+
+		case result := <-h.replayed:
+			h.mutex.Lock()
+			h.finishReplayLocked(result)
+			h.mutex.Unlock()
+
+		case <-refresh:
+			h.refreshPresence()
 		}
 	}
 }
 
-// Broadcast sends a message to all clients in a project
+// Broadcast sends a message to all clients in a project, on this instance
+// and, through the backplane, on every other instance
 func (h *Hub) Broadcast(projectID int64, message []byte) {
 	h.mutex.Lock()
-	defer h.mutex.Unlock()
+	h.removeClientsLocked(h.sendLocked(projectID, message, nil))
+	h.mutex.Unlock()
 
-	if clients, ok := h.clients[projectID]; ok {
-		for client := range clients {
-			select {
-			case client.send <- message:
-			default:
-				// Client's send buffer is full, close the connection
-				close(client.send)
-				delete(clients, client)
+	h.publish(BackplaneMessage{Kind: BackplaneBroadcast, ProjectID: projectID, Payload: message})
+}
+
+// sendLocked queues a message for every client in a project except skip and
+// returns the clients whose send buffer was full. Callers must hold the mutex.
+func (h *Hub) sendLocked(projectID int64, message []byte, skip *Client) []*Client {
+	var slow []*Client
+	for client := range h.clients[projectID] {
+		if client == skip {
+			continue
+		}
+		if client.replaying {
+			// Held back until the client's missed messages are queued
+			if len(client.pending) >= maxPendingFrames {
+				slow = append(slow, client)
+			} else {
+				client.pending = append(client.pending, message)
 			}
+			continue
+		}
+		select {
+		case client.send <- message:
+		default:
+			slow = append(slow, client)
+		}
+	}
+	return slow
+}
+
+// removeClientsLocked drops clients from the hub, closing their send channels,
+// and announces users whose last connection went away, unless they are still
+// connected to another instance. Clients that are no longer registered are
+// ignored. Callers must hold the mutex.
+func (h *Hub) removeClientsLocked(pending []*Client) {
+	for len(pending) > 0 {
+		client := pending[0]
+		pending = pending[1:]
+
+		clients, ok := h.clients[client.projectID]
+		if !ok || !clients[client] {
+			continue
+		}
+		delete(clients, client)
+		client.closeSend()
+		if len(clients) == 0 {
+			delete(h.clients, client.projectID)
+		}
+
+		if h.isOnlineLocked(client.projectID, client.userID) {
+			continue
+		}
+		h.localPresenceChangedLocked(client.projectID, client.userID, false)
+		if !h.isOnlineAnywhereLocked(client.projectID, client.userID) {
+			frame, _ := json.Marshal(PresenceFrame{Type: FramePresenceLeave, ProjectID: client.projectID, UserID: client.userID})
+			// Clients too slow for the leave frame are dropped as well
+			pending = append(pending, h.sendLocked(client.projectID, frame, nil)...)
 		}
 	}
 }
 
+// announcePresenceLocked sends a presence frame to the project. Callers must hold the mutex.
+func (h *Hub) announcePresenceLocked(projectID int64, userID string, frameType string) {
+	frame, _ := json.Marshal(PresenceFrame{Type: frameType, ProjectID: projectID, UserID: userID})
+	h.removeClientsLocked(h.sendLocked(projectID, frame, nil))
+}
+
+// isOnlineLocked reports whether the user has any connection to the project
+// on this instance. Callers must hold the mutex.
+func (h *Hub) isOnlineLocked(projectID int64, userID string) bool {
+	for client := range h.clients[projectID] {
+		if client.userID == userID {
+			return true
+		}
+	}
+	return false
+}
+
+// OnlineUsers returns the IDs of users connected to a project on any
+// instance, sorted
+func (h *Hub) OnlineUsers(projectID int64) []string {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	users := h.localUsersLocked(projectID)
+	for userID := range h.remotePresence[projectID] {
+		if !h.isOnlineLocked(projectID, userID) {
+			users = append(users, userID)
+		}
+	}
+	sort.Strings(users)
+	return users
+}
+
+// relayTyping forwards a typing indicator from one client to the rest of its project
+func (h *Hub) relayTyping(from *Client) {
+	frame, _ := json.Marshal(TypingFrame{
+		Type:        FrameTyping,
+		ProjectID:   from.projectID,
+		UserID:      from.userID,
+		ExpiresInMs: TypingTTL.Milliseconds(),
+	})
+
+	h.mutex.Lock()
+	if !h.clients[from.projectID][from] {
+		h.mutex.Unlock()
+		return
+	}
+	h.removeClientsLocked(h.sendLocked(from.projectID, frame, from))
+	h.mutex.Unlock()
+
+	h.publish(BackplaneMessage{Kind: BackplaneBroadcast, ProjectID: from.projectID, Payload: frame})
+}
+
+// BroadcastJSON marshals a payload and sends it to all clients in a project
+func (h *Hub) BroadcastJSON(projectID int64, payload interface{}) {
+	message, err := json.Marshal(payload)
+	if err != nil {
+		log.Printf("Failed to marshal broadcast for project %d: %v", projectID, err)
+		return
+	}
+	h.Broadcast(projectID, message)
+}
+
+// DisconnectUser closes every connection a user holds for a project on any
+// instance. It is called when the user loses access, e.g. after being removed
+// as a member.
+func (h *Hub) DisconnectUser(projectID int64, userID string) {
+	h.disconnectLocal(projectID, userID)
+	h.publish(BackplaneMessage{Kind: BackplaneDisconnect, ProjectID: projectID, UserID: userID})
+}
+
+func (h *Hub) disconnectLocal(projectID int64, userID string) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	var dropped []*Client
+	for client := range h.clients[projectID] {
+		if client.userID == userID {
+			dropped = append(dropped, client)
+		}
+	}
+	h.removeClientsLocked(dropped)
+}
+
 // GetClientCount returns the number of connected clients for a project
 func (h *Hub) GetClientCount(projectID int64) int {
 	h.mutex.Lock()
@@ -92,60 +383,75 @@ func (h *Hub) GetClientCount(projectID int64) int {
 	return 0
 }
 
-var upgrader = websocket.Upgrader{
-	ReadBufferSize:  1024,
-	WriteBufferSize: 1024,
-	// Allow all origins for development
-	CheckOrigin: func(r *http.Request) bool {
-		return true
-	},
+// MessageStore persists chat messages sent over the socket. The store is
+// responsible for broadcasting the stored message to the project.
+type MessageStore interface {
+	CreateMessage(userID string, projectID int64, content string) (*models.Message, error)
+
+	// CreateReply stores a message in the thread of parentID
+	CreateReply(userID string, projectID int64, parentID int64, content string) (*models.Message, error)
+
+	// MarkRead advances the user's read position and broadcasts the receipt
+	MarkRead(userID string, projectID int64, messageID int64) (*models.MessageRead, error)
+
+	// GetMessagesSince returns the newest messages after afterID, up to limit,
+	// in chronological order, and whether older ones were left out
+	GetMessagesSince(userID string, projectID int64, afterID int64, limit int) ([]models.Message, bool, error)
 }
 
 // Client represents a WebSocket client
 type Client struct {
-	hub      *Hub
-	conn     *websocket.Conn
-	send     chan []byte
+	hub       *Hub
+	conn      *websocket.Conn
+	send      chan []byte
 	projectID int64
-}
+	userID    string
+	messages  MessageStore
 
-// Message represents a chat message
-type Message struct {
-	Type       string `json:"type"`
-	SenderName string `json:"sender_name"`
-	Content    string `json:"content"`
-	CreatedAt  string `json:"created_at"`
-}
+	// lastTypingAt is only touched by readPump
+	lastTypingAt time.Time
 
-// ServeWs handles WebSocket requests from clients
-func ServeWs(hub *Hub, w http.ResponseWriter, r *http.Request) {
-	conn, err := upgrader.Upgrade(w, r, nil)
-	if err != nil {
-		log.Println("Error upgrading connection:", err)
-		return
-	}
+	// sendClosed guards close(send) so teardown is idempotent
+	sendClosed sync.Once
 
-	// Get project ID from URL
-	vars := mux.Vars(r)
-	projectID, err := strconv.ParseInt(vars["projectId"], 10, 64)
-	if err != nil {
-		log.Println("Invalid project ID:", err)
-		conn.Close()
-		return
-	}
+	// While replaying, live frames are collected in pending instead of send.
+	// Both are guarded by the hub mutex.
+	replaying bool
+	pending   [][]byte
+}
 
-	client := &Client{
-		hub:      hub,
-		conn:     conn,
-		send:     make(chan []byte, 256),
+// newClient creates a client for an upgraded connection
+func newClient(hub *Hub, conn *websocket.Conn, projectID int64, userID string, messages MessageStore) *Client {
+	return &Client{
+		hub:       hub,
+		conn:      conn,
+		send:      make(chan []byte, sendBufferSize),
 		projectID: projectID,
+		userID:    userID,
+		messages:  messages,
 	}
+}
+
+// closeSend closes the send channel once, which makes writePump close the connection
+func (c *Client) closeSend() {
+	c.sendClosed.Do(func() { close(c.send) })
+}
 
-	hub.register <- client
+// IncomingFrame is a frame sent by a client. Sender and timestamp are
+// always assigned by the server.
+type IncomingFrame struct {
+	Type            string `json:"type"`
+	Content         string `json:"content"`
+	ParentMessageID int64  `json:"parent_message_id"`
 
-	// Start goroutines for reading and writing
-	go client.writePump()
-	go client.readPump()
+	// MessageID is the newest message the user has seen, for "read" frames
+	MessageID int64 `json:"message_id"`
+}
+
+// ErrorFrame reports a rejected frame back to the client that sent it
+type ErrorFrame struct {
+	Type  string `json:"type"`
+	Error string `json:"error"`
 }
 
 // readPump pumps messages from the WebSocket connection to the hub
@@ -155,9 +461,17 @@ func (c *Client) readPump() {
 		c.conn.Close()
 	}()
 
+	c.conn.SetReadLimit(maxFrameSize)
+	c.conn.SetReadDeadline(time.Now().Add(c.hub.pongWait))
+	c.conn.SetPongHandler(func(string) error {
+		// Every pong proves the peer is alive; extend the read deadline
+		c.conn.SetReadDeadline(time.Now().Add(c.hub.pongWait))
+		return nil
+	})
+
 	for {
-		var message Message
-		err := c.conn.ReadJSON(&message)
+		var frame IncomingFrame
+		err := c.conn.ReadJSON(&frame)
 		if err != nil {
 			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
 				log.Printf("WebSocket error: %v", err)
@@ -165,40 +479,95 @@ func (c *Client) readPump() {
 			break
 		}
 
-		// Broadcast the message to all clients in the same project
-		messageJSON, _ := json.Marshal(message)
-		c.hub.Broadcast(c.projectID, messageJSON)
+		switch frame.Type {
+		case "", FrameMessage:
+			// Persist as the authenticated user; the store broadcasts the saved message
+			var err error
+			if frame.ParentMessageID > 0 {
+				_, err = c.messages.CreateReply(c.userID, c.projectID, frame.ParentMessageID, frame.Content)
+			} else {
+				_, err = c.messages.CreateMessage(c.userID, c.projectID, frame.Content)
+			}
+			if err != nil {
+				c.sendError(err.Error())
+			}
+		case FrameRead:
+			if _, err := c.messages.MarkRead(c.userID, c.projectID, frame.MessageID); err != nil {
+				c.sendError(err.Error())
+			}
+		case FrameTyping:
+			if time.Since(c.lastTypingAt) >= typingThrottle {
+				c.lastTypingAt = time.Now()
+				c.hub.relayTyping(c)
+			}
+		default:
+			c.sendError("unsupported frame type")
+		}
+	}
+}
+
+// sendError queues an error frame for this client only
+func (c *Client) sendError(message string) {
+	frame, _ := json.Marshal(ErrorFrame{Type: "error", Error: message})
+
+	c.hub.mutex.Lock()
+	defer c.hub.mutex.Unlock()
+
+	// The hub closes send when the client is dropped; only write while registered
+	if clients, ok := c.hub.clients[c.projectID]; ok && clients[c] {
+		select {
+		case c.send <- frame:
+		default:
+		}
 	}
 }
 
-// writePump pumps messages from the hub to the WebSocket connection
+// writePump pumps messages from the hub to the WebSocket connection and
+// pings the peer so half-open connections are detected by readPump
 func (c *Client) writePump() {
-	defer c.conn.Close()
+	ticker := time.NewTicker(c.hub.pingPeriod())
+	defer func() {
+		ticker.Stop()
+		c.conn.Close()
+	}()
 
 	for {
-		message, ok := <-c.send
-		if !ok {
-			// Hub closed the channel
-			c.conn.WriteMessage(websocket.CloseMessage, []byte{})
-			return
-		}
+		select {
+		case message, ok := <-c.send:
+			c.conn.SetWriteDeadline(time.Now().Add(c.hub.writeWait))
+			if !ok {
+				// Hub closed the channel
+				c.conn.WriteMessage(websocket.CloseMessage, []byte{})
+				return
+			}
 
-		w, err := c.conn.NextWriter(websocket.TextMessage)
-		if err != nil {
-			return
-		}
+			w, err := c.conn.NextWriter(websocket.TextMessage)
+			if err != nil {
+				return
+			}
 
-		w.Write(message)
+			w.Write(message)
 
-		// Add queued messages to the current WebSocket message
-		n := len(c.send)
-		for i := 0; i < n; i++ {
-			w.Write([]byte{'\n'})
-			w.Write(<-c.send)
-		}
+			// Add queued messages to the current WebSocket message
+			n := len(c.send)
+			for i := 0; i < n; i++ {
+				next, ok := <-c.send
+				if !ok {
+					break
+				}
+				w.Write([]byte{'\n'})
+				w.Write(next)
+			}
 
-		if err := w.Close(); err != nil {
-			return
+			if err := w.Close(); err != nil {
+				return
+			}
+
+		case <-ticker.C:
+			c.conn.SetWriteDeadline(time.Now().Add(c.hub.writeWait))
+			if err := c.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				return
+			}
 		}
 	}
-}
\ No newline at end of file
+}
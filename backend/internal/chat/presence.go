@@ -0,0 +1,255 @@
+package chat
+
+import (
+	"encoding/json"
+	"log"
+	"sort"
+	"time"
+)
+
+// Presence relay defaults
+const (
+	// defaultPresenceRefresh is how often a hub publishes a snapshot of who
+	// is connected to it
+	defaultPresenceRefresh = 15 * time.Second
+
+	// presenceExpiryRefreshes is how many snapshots an instance may miss
+	// before the users connected to it count as offline
+	presenceExpiryRefreshes = 3
+
+	// presenceQueueSize bounds presence messages waiting to be published.
+	// When it is full messages are dropped; the next snapshot corrects them.
+	presenceQueueSize = 256
+)
+
+// presenceUpdate is the payload of a BackplanePresence message
+type presenceUpdate struct {
+	Instance string `json:"instance"`
+	Online   bool   `json:"online"`
+}
+
+// presenceSnapshot is the payload of a BackplanePresenceSnapshot message
+type presenceSnapshot struct {
+	Instance string             `json:"instance"`
+	Projects map[int64][]string `json:"projects"`
+}
+
+// presenceKey identifies a user in a project
+type presenceKey struct {
+	projectID int64
+	userID    string
+}
+
+// SetPresenceRefresh changes how often the hub publishes a snapshot of its
+// presence to other instances. Users connected to an instance that misses
+// three snapshots count as offline. It must be called before the hub starts
+// serving clients.
+func (h *Hub) SetPresenceRefresh(interval time.Duration) {
+	h.presenceRefresh = interval
+}
+
+// presenceTTL is how long presence heard from another instance lasts without
+// being refreshed
+func (h *Hub) presenceTTL() time.Duration {
+	return h.presenceRefresh * presenceExpiryRefreshes
+}
+
+// publishPresence publishes queued presence messages in order. It runs for
+// the life of the hub, outside the mutex, because publishing on an in-process
+// bus calls straight into other hubs.
+func (h *Hub) publishPresence() {
+	for msg := range h.presenceOut {
+		if err := h.backplane.Publish(msg); err != nil && err != ErrBackplaneClosed {
+			log.Printf("Failed to publish %s for project %d: %v", msg.Kind, msg.ProjectID, err)
+		}
+	}
+}
+
+// queuePresenceLocked queues a presence message for other instances. Callers
+// must hold the mutex.
+func (h *Hub) queuePresenceLocked(msg BackplaneMessage) {
+	if h.presenceOut == nil {
+		return
+	}
+	select {
+	case h.presenceOut <- msg:
+	default:
+		log.Printf("Presence queue full, dropped %s for project %d", msg.Kind, msg.ProjectID)
+	}
+}
+
+// localPresenceChangedLocked tells other instances that the user's first
+// connection to the project on this instance opened or its last one closed.
+// Callers must hold the mutex.
+func (h *Hub) localPresenceChangedLocked(projectID int64, userID string, online bool) {
+	if h.presenceOut == nil {
+		return
+	}
+	payload, _ := json.Marshal(presenceUpdate{Instance: h.instanceID, Online: online})
+	h.queuePresenceLocked(BackplaneMessage{Kind: BackplanePresence, ProjectID: projectID, UserID: userID, Payload: payload})
+}
+
+// snapshotLocked builds a snapshot of the users connected to this instance.
+// Callers must hold the mutex.
+func (h *Hub) snapshotLocked() BackplaneMessage {
+	snapshot := presenceSnapshot{Instance: h.instanceID, Projects: make(map[int64][]string)}
+	for projectID := range h.clients {
+		snapshot.Projects[projectID] = h.localUsersLocked(projectID)
+	}
+	payload, _ := json.Marshal(snapshot)
+	return BackplaneMessage{Kind: BackplanePresenceSnapshot, Payload: payload}
+}
+
+// refreshPresence publishes this instance's snapshot and forgets presence of
+// instances that stopped publishing theirs
+func (h *Hub) refreshPresence() {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	now := time.Now()
+	for projectID, users := range h.remotePresence {
+		for userID, instances := range users {
+			for instance, expiresAt := range instances {
+				if now.After(expiresAt) {
+					h.dropRemotePresenceLocked(projectID, userID, instance)
+					h.announceIfChangedLocked(projectID, userID, true)
+				}
+			}
+		}
+	}
+	for instance, expiresAt := range h.peers {
+		if now.After(expiresAt) {
+			delete(h.peers, instance)
+		}
+	}
+
+	h.queuePresenceLocked(h.snapshotLocked())
+}
+
+// applyRemotePresence records a user coming online or going offline on
+// another instance and tells local clients when that changes whether the
+// user is online at all
+func (h *Hub) applyRemotePresence(msg BackplaneMessage) {
+	var update presenceUpdate
+	if err := json.Unmarshal(msg.Payload, &update); err != nil || update.Instance == "" {
+		log.Printf("Ignoring invalid presence message: %v", err)
+		return
+	}
+
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	wasOnline := h.isOnlineAnywhereLocked(msg.ProjectID, msg.UserID)
+	if update.Online {
+		h.setRemotePresenceLocked(msg.ProjectID, msg.UserID, update.Instance, time.Now().Add(h.presenceTTL()))
+	} else {
+		h.dropRemotePresenceLocked(msg.ProjectID, msg.UserID, update.Instance)
+	}
+	h.announceIfChangedLocked(msg.ProjectID, msg.UserID, wasOnline)
+}
+
+// applyPresenceSnapshot replaces what is known about another instance's
+// users with its snapshot. A snapshot from an instance not heard from before
+// is answered with this instance's own, so a starting instance learns who is
+// online without waiting for the next refresh.
+func (h *Hub) applyPresenceSnapshot(msg BackplaneMessage) {
+	var snapshot presenceSnapshot
+	if err := json.Unmarshal(msg.Payload, &snapshot); err != nil || snapshot.Instance == "" {
+		log.Printf("Ignoring invalid presence snapshot: %v", err)
+		return
+	}
+
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	now := time.Now()
+	_, known := h.peers[snapshot.Instance]
+	h.peers[snapshot.Instance] = now.Add(h.presenceTTL())
+
+	// Whether each affected user was online before the snapshot is applied
+	wasOnline := make(map[presenceKey]bool)
+	for projectID, users := range h.remotePresence {
+		for userID, instances := range users {
+			if _, ok := instances[snapshot.Instance]; ok {
+				wasOnline[presenceKey{projectID, userID}] = true
+				h.dropRemotePresenceLocked(projectID, userID, snapshot.Instance)
+			}
+		}
+	}
+	for projectID, users := range snapshot.Projects {
+		for _, userID := range users {
+			key := presenceKey{projectID, userID}
+			if _, ok := wasOnline[key]; !ok {
+				wasOnline[key] = h.isOnlineAnywhereLocked(projectID, userID)
+			}
+			h.setRemotePresenceLocked(projectID, userID, snapshot.Instance, now.Add(h.presenceTTL()))
+		}
+	}
+	for key, online := range wasOnline {
+		h.announceIfChangedLocked(key.projectID, key.userID, online)
+	}
+
+	if !known {
+		h.queuePresenceLocked(h.snapshotLocked())
+	}
+}
+
+// announceIfChangedLocked sends a join or leave frame to local clients when
+// the user's presence differs from wasOnline. Callers must hold the mutex.
+func (h *Hub) announceIfChangedLocked(projectID int64, userID string, wasOnline bool) {
+	online := h.isOnlineAnywhereLocked(projectID, userID)
+	switch {
+	case online && !wasOnline:
+		h.announcePresenceLocked(projectID, userID, FramePresenceJoin)
+	case !online && wasOnline:
+		h.announcePresenceLocked(projectID, userID, FramePresenceLeave)
+	}
+}
+
+// setRemotePresenceLocked records the user as connected to the project on
+// another instance until expiresAt. Callers must hold the mutex.
+func (h *Hub) setRemotePresenceLocked(projectID int64, userID, instance string, expiresAt time.Time) {
+	users := h.remotePresence[projectID]
+	if users == nil {
+		users = make(map[string]map[string]time.Time)
+		h.remotePresence[projectID] = users
+	}
+	if users[userID] == nil {
+		users[userID] = make(map[string]time.Time)
+	}
+	users[userID][instance] = expiresAt
+}
+
+// dropRemotePresenceLocked forgets the user's connection to the project on
+// another instance. Callers must hold the mutex.
+func (h *Hub) dropRemotePresenceLocked(projectID int64, userID, instance string) {
+	users := h.remotePresence[projectID]
+	delete(users[userID], instance)
+	if len(users[userID]) == 0 {
+		delete(users, userID)
+	}
+	if len(users) == 0 {
+		delete(h.remotePresence, projectID)
+	}
+}
+
+// isOnlineAnywhereLocked reports whether the user has a connection to the
+// project on this or another instance. Callers must hold the mutex.
+func (h *Hub) isOnlineAnywhereLocked(projectID int64, userID string) bool {
+	return h.isOnlineLocked(projectID, userID) || len(h.remotePresence[projectID][userID]) > 0
+}
+
+// localUsersLocked returns the IDs of users connected to the project on this
+// instance, sorted. Callers must hold the mutex.
+func (h *Hub) localUsersLocked(projectID int64) []string {
+	seen := make(map[string]bool)
+	users := []string{}
+	for client := range h.clients[projectID] {
+		if !seen[client.userID] {
+			seen[client.userID] = true
+			users = append(users, client.userID)
+		}
+	}
+	sort.Strings(users)
+	return users
+}
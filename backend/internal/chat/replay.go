@@ -0,0 +1,126 @@
+package chat
+
+import (
+	"encoding/json"
+	"log"
+
+	"backend/internal/models"
+)
+
+// Replay frame types pushed to reconnecting clients
+const (
+	FrameMessage        = "message"
+	FrameReplayComplete = "replay.complete"
+)
+
+// MaxReplayMessages is the most missed messages replayed on reconnect. When
+// more were missed the newest are replayed and the client pages back through
+// the rest over REST.
+const MaxReplayMessages = 100
+
+// maxPendingFrames caps the live frames held back while a replay is loading,
+// so replay plus pending frames always fit in the send buffer
+const maxPendingFrames = sendBufferSize - MaxReplayMessages - 1
+
+// MessageFrame carries a stored chat message; it matches the frames the
+// message store broadcasts
+type MessageFrame struct {
+	Type string `json:"type"`
+	*models.Message
+}
+
+// ReplayCompleteFrame marks the switch from replayed to live delivery.
+// HasMore reports that older missed messages were not replayed.
+type ReplayCompleteFrame struct {
+	Type      string `json:"type"`
+	ProjectID int64  `json:"project_id"`
+	Count     int    `json:"count"`
+	HasMore   bool   `json:"has_more"`
+}
+
+// replayResult is handed to the hub loop once a client's missed messages are loaded
+type replayResult struct {
+	client   *Client
+	messages []models.Message
+	hasMore  bool
+	err      error
+}
+
+// replay loads the messages a client missed after lastMessageID. The client
+// must have been registered with replaying set, so live frames are held back
+// until the hub has queued the replay ahead of them.
+func (c *Client) replay(lastMessageID int64) {
+	messages, hasMore, err := c.messages.GetMessagesSince(c.userID, c.projectID, lastMessageID, MaxReplayMessages)
+	c.hub.replayed <- replayResult{client: c, messages: messages, hasMore: hasMore, err: err}
+}
+
+// finishReplayLocked queues the replayed messages, then the live frames held
+// back meanwhile, skipping messages that were already replayed. Callers must
+// hold the mutex.
+func (h *Hub) finishReplayLocked(result replayResult) {
+	client := result.client
+	pending := client.pending
+	client.replaying = false
+	client.pending = nil
+
+	// The client may have been dropped while its replay was loading
+	if !h.clients[client.projectID][client] {
+		return
+	}
+
+	var frames [][]byte
+	var lastReplayed int64
+	if result.err != nil {
+		log.Printf("Failed to replay messages for project %d: %v", client.projectID, result.err)
+		frame, _ := json.Marshal(ErrorFrame{Type: "error", Error: "failed to replay missed messages"})
+		frames = append(frames, frame)
+	} else {
+		for i := range result.messages {
+			message := &result.messages[i]
+			frame, err := json.Marshal(MessageFrame{Type: FrameMessage, Message: message})
+			if err != nil {
+				continue
+			}
+			frames = append(frames, frame)
+			lastReplayed = message.ID
+		}
+		frame, _ := json.Marshal(ReplayCompleteFrame{
+			Type:      FrameReplayComplete,
+			ProjectID: client.projectID,
+			Count:     len(result.messages),
+			HasMore:   result.hasMore,
+		})
+		frames = append(frames, frame)
+	}
+
+	for _, frame := range pending {
+		if !isReplayedMessage(frame, lastReplayed) {
+			frames = append(frames, frame)
+		}
+	}
+
+	for _, frame := range frames {
+		select {
+		case client.send <- frame:
+		default:
+			h.removeClientsLocked([]*Client{client})
+			return
+		}
+	}
+}
+
+// isReplayedMessage reports whether a held-back frame is a message that the
+// replay already delivered
+func isReplayedMessage(frame []byte, lastReplayed int64) bool {
+	if lastReplayed == 0 {
+		return false
+	}
+	var header struct {
+		Type string `json:"type"`
+		ID   int64  `json:"id"`
+	}
+	if err := json.Unmarshal(frame, &header); err != nil {
+		return false
+	}
+	return header.Type == FrameMessage && header.ID <= lastReplayed
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"os"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/joho/godotenv"
@@ -21,6 +22,22 @@ type Config struct {
 	JWTSecret          string
 	JWTExpirationHours int
 
+	// Sessions: access tokens are short-lived and renewed with a refresh
+	// token that is valid for RefreshTokenTTL after its last use
+	AccessTokenTTL  time.Duration
+	RefreshTokenTTL time.Duration
+
+	// Login and password reset endpoints accept AuthRateLimit requests per
+	// AuthRateLimitWindow from each IP and for each email address
+	AuthRateLimit       int
+	AuthRateLimitWindow time.Duration
+
+	// Email verification: new accounts get a link valid for
+	// EmailVerificationTTL. With RequireEmailVerification, unverified users
+	// cannot accept project invites or receive notification emails.
+	RequireEmailVerification bool
+	EmailVerificationTTL     time.Duration
+
 	// Email/SMTP
 	SMTPHost     string
 	SMTPPort     int
@@ -32,6 +49,26 @@ type Config struct {
 	GoogleClientID     string
 	GoogleClientSecret string
 	GoogleRedirectURL  string
+
+	// WebSocket
+	WSAllowedOrigins []string
+
+	// WSBackplane selects how hubs on several instances share broadcasts:
+	// "" (single instance) or "sqlite" (through the shared database). A poll
+	// interval of 0 uses the backplane's default.
+	WSBackplane             string
+	WSBackplanePollInterval time.Duration
+
+	// NotificationRetention is how long read notifications are kept; 0
+	// keeps them forever
+	NotificationRetention time.Duration
+	// WebhookAllowedHosts may be webhook hosts even though they are internal
+	// addresses, such as localhost during development
+	WebhookAllowedHosts []string
+
+	// EmailOutboxRetention is how long sent emails are kept in the outbox; 0
+	// keeps them forever
+	EmailOutboxRetention time.Duration
 }
 
 // Load loads configuration from environment variables
@@ -44,18 +81,30 @@ func Load() (*Config, error) {
 	}
 
 	cfg := &Config{
-		Port:               getEnv("PORT", "8080"),
-		DBPath:             getEnv("DB_PATH", "taskify.db"),
-		JWTSecret:          getEnv("JWT_SECRET", "your-secret-key-change-in-production"),
-		JWTExpirationHours: getEnvAsInt("JWT_EXPIRATION_HOURS", 24),
-		SMTPHost:           getEnv("SMTP_HOST", "smtp.gmail.com"),
-		SMTPPort:           getEnvAsInt("SMTP_PORT", 587),
-		SMTPUsername:       getEnv("SMTP_USERNAME", ""),
-		SMTPPassword:       getEnv("SMTP_PASSWORD", ""),
-		SMTPFrom:           getEnv("SMTP_FROM", ""),
-		GoogleClientID:     getEnv("GOOGLE_CLIENT_ID", ""),
-		GoogleClientSecret: getEnv("GOOGLE_CLIENT_SECRET", ""),
-		GoogleRedirectURL:  getEnv("GOOGLE_REDIRECT_URL", "http://localhost:4200/google-callback"),
+		Port:                     getEnv("PORT", "8080"),
+		DBPath:                   getEnv("DB_PATH", "taskify.db"),
+		JWTSecret:                getEnv("JWT_SECRET", "your-secret-key-change-in-production"),
+		JWTExpirationHours:       getEnvAsInt("JWT_EXPIRATION_HOURS", 24),
+		AccessTokenTTL:           time.Duration(getEnvAsInt("ACCESS_TOKEN_TTL_MINUTES", 15)) * time.Minute,
+		RefreshTokenTTL:          time.Duration(getEnvAsInt("REFRESH_TOKEN_TTL_DAYS", 30)) * 24 * time.Hour,
+		AuthRateLimit:            getEnvAsInt("AUTH_RATE_LIMIT", 10),
+		AuthRateLimitWindow:      time.Duration(getEnvAsInt("AUTH_RATE_LIMIT_WINDOW_MINUTES", 15)) * time.Minute,
+		RequireEmailVerification: getEnvAsBool("REQUIRE_EMAIL_VERIFICATION", false),
+		EmailVerificationTTL:     getEnvAsDuration("EMAIL_VERIFICATION_TTL_HOURS", 24*time.Hour),
+		SMTPHost:                 getEnv("SMTP_HOST", "smtp.gmail.com"),
+		SMTPPort:                 getEnvAsInt("SMTP_PORT", 587),
+		SMTPUsername:             getEnv("SMTP_USERNAME", ""),
+		SMTPPassword:             getEnv("SMTP_PASSWORD", ""),
+		SMTPFrom:                 getEnv("SMTP_FROM", ""),
+		GoogleClientID:           getEnv("GOOGLE_CLIENT_ID", ""),
+		GoogleClientSecret:       getEnv("GOOGLE_CLIENT_SECRET", ""),
+		GoogleRedirectURL:        getEnv("GOOGLE_REDIRECT_URL", "http://localhost:4200/google-callback"),
+		WSAllowedOrigins:         getEnvAsList("WS_ALLOWED_ORIGINS", []string{"http://localhost:4200"}),
+		WSBackplane:              getEnv("WS_BACKPLANE", ""),
+		WSBackplanePollInterval:  time.Duration(getEnvAsInt("WS_BACKPLANE_POLL_MS", 0)) * time.Millisecond,
+		NotificationRetention:    time.Duration(getEnvAsInt("NOTIFICATION_RETENTION_DAYS", 90)) * 24 * time.Hour,
+		WebhookAllowedHosts:      getEnvAsList("WEBHOOK_ALLOWED_HOSTS", nil),
+		EmailOutboxRetention:     time.Duration(getEnvAsInt("EMAIL_OUTBOX_RETENTION_DAYS", 7)) * 24 * time.Hour,
 	}
 
 	// Validate required fields in production
@@ -84,6 +133,35 @@ func getEnvAsInt(key string, defaultValue int) int {
 	return defaultValue
 }
 
+// getEnvAsBool returns environment variable as bool or default value
+func getEnvAsBool(key string, defaultValue bool) bool {
+	if value := os.Getenv(key); value != "" {
+		if boolVal, err := strconv.ParseBool(value); err == nil {
+			return boolVal
+		}
+	}
+	return defaultValue
+}
+
+// getEnvAsList returns a comma-separated environment variable as a slice or default value
+func getEnvAsList(key string, defaultValue []string) []string {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	if len(items) == 0 {
+		return defaultValue
+	}
+	return items
+}
+
 // getEnvAsDuration returns environment variable as duration (in hours) or default value
 func getEnvAsDuration(key string, defaultValue time.Duration) time.Duration {
 	hours := getEnvAsInt(key, int(defaultValue.Hours()))
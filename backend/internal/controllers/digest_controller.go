@@ -0,0 +1,64 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"backend/internal/helpers"
+	"backend/internal/models"
+	"backend/internal/services"
+)
+
+// DigestController handles the current user's email digest settings
+type DigestController struct {
+	service *services.NotificationService
+}
+
+// NewDigestController creates a new DigestController
+func NewDigestController(service *services.NotificationService) *DigestController {
+	return &DigestController{service: service}
+}
+
+type updateDigestSettingsRequest struct {
+	Frequency models.DigestFrequency `json:"frequency"`
+}
+
+// GetSettings handles GET /api/me/digest-settings
+func (c *DigestController) GetSettings(w http.ResponseWriter, r *http.Request) {
+	userID := helpers.GetUserID(r)
+	if userID == "" {
+		helpers.WriteError(w, http.StatusUnauthorized, "Authentication required", helpers.ErrCodeUnauthorized)
+		return
+	}
+
+	settings, err := c.service.GetDigestSettings(userID)
+	if err != nil {
+		handleServiceError(w, err)
+		return
+	}
+
+	helpers.WriteSuccess(w, http.StatusOK, settings, "")
+}
+
+// UpdateSettings handles PUT /api/me/digest-settings
+func (c *DigestController) UpdateSettings(w http.ResponseWriter, r *http.Request) {
+	userID := helpers.GetUserID(r)
+	if userID == "" {
+		helpers.WriteError(w, http.StatusUnauthorized, "Authentication required", helpers.ErrCodeUnauthorized)
+		return
+	}
+
+	var req updateDigestSettingsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		helpers.WriteError(w, http.StatusBadRequest, "Invalid request body", helpers.ErrCodeBadRequest)
+		return
+	}
+
+	settings, err := c.service.UpdateDigestSettings(userID, req.Frequency)
+	if err != nil {
+		handleServiceError(w, err)
+		return
+	}
+
+	helpers.WriteSuccess(w, http.StatusOK, settings, "Digest settings updated")
+}
@@ -0,0 +1,106 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	authmodels "backend/internal/auth/models"
+	authservices "backend/internal/auth/services"
+	"backend/internal/helpers"
+)
+
+// EmailOutboxController lets admins inspect queued emails and retry failed ones
+type EmailOutboxController struct {
+	outbox *authservices.EmailOutbox
+}
+
+// NewEmailOutboxController creates a new EmailOutboxController
+func NewEmailOutboxController(outbox *authservices.EmailOutbox) *EmailOutboxController {
+	return &EmailOutboxController{outbox: outbox}
+}
+
+// ListEmails handles GET /api/admin/emails?status=&page=&limit=. Only
+// undelivered emails are listed; sent ones are not for admins to read.
+func (c *EmailOutboxController) ListEmails(w http.ResponseWriter, r *http.Request) {
+	status := authmodels.EmailStatus(r.URL.Query().Get("status"))
+	if status == "" {
+		status = authmodels.EmailDead
+	}
+	if status != authmodels.EmailPending && status != authmodels.EmailDead {
+		helpers.WriteError(w, http.StatusBadRequest, "status must be pending or dead", helpers.ErrCodeBadRequest)
+		return
+	}
+
+	page, limit := 1, 20
+	if p := r.URL.Query().Get("page"); p != "" {
+		parsed, err := strconv.Atoi(p)
+		if err != nil {
+			helpers.WriteError(w, http.StatusBadRequest, "Invalid page parameter", helpers.ErrCodeBadRequest)
+			return
+		}
+		page = parsed
+	}
+	if l := r.URL.Query().Get("limit"); l != "" {
+		parsed, err := strconv.Atoi(l)
+		if err != nil {
+			helpers.WriteError(w, http.StatusBadRequest, "Invalid limit parameter", helpers.ErrCodeBadRequest)
+			return
+		}
+		limit = parsed
+	}
+	page, limit = normalizeActivityPagination(page, limit)
+
+	emails, total, err := c.outbox.ListEmails(status, page, limit)
+	if err != nil {
+		helpers.WriteError(w, http.StatusInternalServerError, "Failed to list emails", helpers.ErrCodeInternalError)
+		return
+	}
+
+	helpers.WritePaginated(w, http.StatusOK, emails, page, limit, total)
+}
+
+// GetEmail handles GET /api/admin/emails/:id
+func (c *EmailOutboxController) GetEmail(w http.ResponseWriter, r *http.Request) {
+	id, err := parseInt64RouteParam(r, "id")
+	if err != nil {
+		helpers.WriteError(w, http.StatusBadRequest, "Invalid email ID", helpers.ErrCodeBadRequest)
+		return
+	}
+
+	email, err := c.outbox.GetEmail(id)
+	if err != nil {
+		handleOutboxError(w, err)
+		return
+	}
+
+	helpers.WriteSuccess(w, http.StatusOK, email, "")
+}
+
+// RetryEmail handles POST /api/admin/emails/:id/retry
+func (c *EmailOutboxController) RetryEmail(w http.ResponseWriter, r *http.Request) {
+	id, err := parseInt64RouteParam(r, "id")
+	if err != nil {
+		helpers.WriteError(w, http.StatusBadRequest, "Invalid email ID", helpers.ErrCodeBadRequest)
+		return
+	}
+
+	email, err := c.outbox.Retry(id)
+	if err != nil {
+		handleOutboxError(w, err)
+		return
+	}
+
+	helpers.WriteSuccess(w, http.StatusOK, email, "Email queued for retry")
+}
+
+func handleOutboxError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, authservices.ErrOutboxEmailNotFound):
+		helpers.WriteError(w, http.StatusNotFound, err.Error(), helpers.ErrCodeNotFound)
+	case errors.Is(err, authservices.ErrOutboxEmailNotDead):
+		helpers.WriteError(w, http.StatusConflict, err.Error(), helpers.ErrCodeConflict)
+	default:
+		helpers.WriteError(w, http.StatusInternalServerError, "Internal server error", helpers.ErrCodeInternalError)
+	}
+}
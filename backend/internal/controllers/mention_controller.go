@@ -0,0 +1,55 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+
+	"backend/internal/helpers"
+	"backend/internal/services"
+)
+
+// MentionController handles the current user's @mentions
+type MentionController struct {
+	service *services.MentionService
+}
+
+// NewMentionController creates a new MentionController
+func NewMentionController(service *services.MentionService) *MentionController {
+	return &MentionController{service: service}
+}
+
+// GetMyMentions handles GET /api/me/mentions?page=&limit=
+func (c *MentionController) GetMyMentions(w http.ResponseWriter, r *http.Request) {
+	userID := helpers.GetUserID(r)
+	if userID == "" {
+		helpers.WriteError(w, http.StatusUnauthorized, "Authentication required", helpers.ErrCodeUnauthorized)
+		return
+	}
+
+	page, limit := 1, 20
+	if p := r.URL.Query().Get("page"); p != "" {
+		parsed, err := strconv.Atoi(p)
+		if err != nil {
+			helpers.WriteError(w, http.StatusBadRequest, "Invalid page parameter", helpers.ErrCodeBadRequest)
+			return
+		}
+		page = parsed
+	}
+	if l := r.URL.Query().Get("limit"); l != "" {
+		parsed, err := strconv.Atoi(l)
+		if err != nil {
+			helpers.WriteError(w, http.StatusBadRequest, "Invalid limit parameter", helpers.ErrCodeBadRequest)
+			return
+		}
+		limit = parsed
+	}
+	page, limit = normalizeActivityPagination(page, limit)
+
+	mentions, total, err := c.service.GetUserMentions(userID, page, limit)
+	if err != nil {
+		helpers.WriteError(w, http.StatusInternalServerError, "Failed to get mentions", helpers.ErrCodeInternalError)
+		return
+	}
+
+	helpers.WritePaginated(w, http.StatusOK, mentions, page, limit, total)
+}
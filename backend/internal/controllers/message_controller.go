@@ -2,10 +2,13 @@ package controllers
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
 	"strconv"
 
 	"backend/internal/helpers"
+	"backend/internal/models"
 	"backend/internal/services"
 
 	"github.com/gorilla/mux"
@@ -34,9 +37,10 @@ func (c *MessageController) CreateMessage(w http.ResponseWriter, r *http.Request
 		return
 	}
 
+	// The sender name is resolved from the authenticated user, not the request
 	var req struct {
-		SenderName string `json:"sender_name"`
-		Content    string `json:"content"`
+		Content         string `json:"content"`
+		ParentMessageID *int64 `json:"parent_message_id"`
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -44,14 +48,14 @@ func (c *MessageController) CreateMessage(w http.ResponseWriter, r *http.Request
 		return
 	}
 
-	if req.SenderName == "" || req.Content == "" {
-		http.Error(w, "Sender name and content are required", http.StatusBadRequest)
-		return
+	var message *models.Message
+	if req.ParentMessageID != nil {
+		message, err = c.service.CreateReply(userID, projectID, *req.ParentMessageID, req.Content)
+	} else {
+		message, err = c.service.CreateMessage(userID, projectID, req.Content)
 	}
-
-	message, err := c.service.CreateMessage(userID, projectID, req.SenderName, req.Content)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		c.handleMessageError(w, err)
 		return
 	}
 
@@ -60,7 +64,7 @@ func (c *MessageController) CreateMessage(w http.ResponseWriter, r *http.Request
 	json.NewEncoder(w).Encode(message)
 }
 
-// GetMessagesByProject handles GET /api/projects/:projectId/messages
+// GetMessagesByProject handles GET /api/projects/:projectId/messages?before=&after=&limit=
 func (c *MessageController) GetMessagesByProject(w http.ResponseWriter, r *http.Request) {
 	userID := helpers.GetUserID(r)
 	if userID == "" {
@@ -75,9 +79,15 @@ func (c *MessageController) GetMessagesByProject(w http.ResponseWriter, r *http.
 		return
 	}
 
-	messages, err := c.service.GetMessagesByProject(userID, projectID)
+	cursor, err := parseMessageCursor(r)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	messages, err := c.service.GetMessagesByProject(userID, projectID, cursor)
+	if err != nil {
+		c.handleMessageError(w, err)
 		return
 	}
 
@@ -102,7 +112,7 @@ func (c *MessageController) GetRecentMessages(w http.ResponseWriter, r *http.Req
 
 	messages, err := c.service.GetRecentMessages(userID, projectID)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		c.handleMessageError(w, err)
 		return
 	}
 
@@ -110,6 +120,188 @@ func (c *MessageController) GetRecentMessages(w http.ResponseWriter, r *http.Req
 	json.NewEncoder(w).Encode(messages)
 }
 
+// UpdateMessage handles PATCH /api/messages/:id
+func (c *MessageController) UpdateMessage(w http.ResponseWriter, r *http.Request) {
+	userID := helpers.GetUserID(r)
+	if userID == "" {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid message ID", http.StatusBadRequest)
+		return
+	}
+
+	var req struct {
+		Content string `json:"content"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	message, err := c.service.UpdateMessage(userID, id, req.Content)
+	if err != nil {
+		c.handleMessageError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(message)
+}
+
+// GetReplies handles GET /api/messages/:id/replies?before=&after=&limit=
+func (c *MessageController) GetReplies(w http.ResponseWriter, r *http.Request) {
+	userID := helpers.GetUserID(r)
+	if userID == "" {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid message ID", http.StatusBadRequest)
+		return
+	}
+
+	cursor, err := parseMessageCursor(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	replies, err := c.service.GetReplies(userID, id, cursor)
+	if err != nil {
+		c.handleMessageError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(replies)
+}
+
+// AddReaction handles POST /api/messages/:id/reactions
+func (c *MessageController) AddReaction(w http.ResponseWriter, r *http.Request) {
+	userID := helpers.GetUserID(r)
+	if userID == "" {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid message ID", http.StatusBadRequest)
+		return
+	}
+
+	var req struct {
+		Emoji string `json:"emoji"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	reactions, err := c.service.AddReaction(userID, id, req.Emoji)
+	if err != nil {
+		c.handleMessageError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(reactions)
+}
+
+// RemoveReaction handles DELETE /api/messages/:id/reactions/:emoji
+func (c *MessageController) RemoveReaction(w http.ResponseWriter, r *http.Request) {
+	userID := helpers.GetUserID(r)
+	if userID == "" {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	vars := mux.Vars(r)
+	id, err := strconv.ParseInt(vars["id"], 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid message ID", http.StatusBadRequest)
+		return
+	}
+
+	reactions, err := c.service.RemoveReaction(userID, id, vars["emoji"])
+	if err != nil {
+		c.handleMessageError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(reactions)
+}
+
+// MarkRead handles POST /api/projects/:projectId/messages/read
+func (c *MessageController) MarkRead(w http.ResponseWriter, r *http.Request) {
+	userID := helpers.GetUserID(r)
+	if userID == "" {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	projectID, err := strconv.ParseInt(mux.Vars(r)["projectId"], 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid project ID", http.StatusBadRequest)
+		return
+	}
+
+	// An empty body marks everything read
+	var req struct {
+		MessageID int64 `json:"message_id"`
+	}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+	}
+	if req.MessageID < 0 {
+		http.Error(w, "Invalid message ID", http.StatusBadRequest)
+		return
+	}
+
+	read, err := c.service.MarkRead(userID, projectID, req.MessageID)
+	if err != nil {
+		c.handleMessageError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(read)
+}
+
+// GetReads handles GET /api/projects/:projectId/messages/reads
+func (c *MessageController) GetReads(w http.ResponseWriter, r *http.Request) {
+	userID := helpers.GetUserID(r)
+	if userID == "" {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	projectID, err := strconv.ParseInt(mux.Vars(r)["projectId"], 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid project ID", http.StatusBadRequest)
+		return
+	}
+
+	reads, err := c.service.GetReads(userID, projectID)
+	if err != nil {
+		c.handleMessageError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(reads)
+}
+
 // DeleteMessage handles DELETE /api/messages/:id
 func (c *MessageController) DeleteMessage(w http.ResponseWriter, r *http.Request) {
 	userID := helpers.GetUserID(r)
@@ -126,9 +318,60 @@ func (c *MessageController) DeleteMessage(w http.ResponseWriter, r *http.Request
 	}
 
 	if err := c.service.DeleteMessage(userID, id); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		c.handleMessageError(w, err)
 		return
 	}
 
 	w.WriteHeader(http.StatusNoContent)
 }
+
+func (c *MessageController) handleMessageError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, services.ErrMessageContentRequired),
+		errors.Is(err, services.ErrMessageTooLong),
+		errors.Is(err, services.ErrInvalidMessageCursor),
+		errors.Is(err, services.ErrInvalidParentMessage),
+		errors.Is(err, services.ErrInvalidReaction):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, services.ErrProjectNotFoundOrAccessDenied),
+		errors.Is(err, services.ErrMessageNotFoundOrAccessDenied):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// parseMessageCursor reads the optional before, after and limit query parameters
+func parseMessageCursor(r *http.Request) (services.MessageCursor, error) {
+	var cursor services.MessageCursor
+	query := r.URL.Query()
+
+	fields := []struct {
+		name  string
+		value *int64
+	}{
+		{"before", &cursor.Before},
+		{"after", &cursor.After},
+	}
+	for _, field := range fields {
+		raw := query.Get(field.name)
+		if raw == "" {
+			continue
+		}
+		value, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil || value <= 0 {
+			return cursor, fmt.Errorf("invalid %s message ID", field.name)
+		}
+		*field.value = value
+	}
+
+	if raw := query.Get("limit"); raw != "" {
+		limit, err := strconv.Atoi(raw)
+		if err != nil || limit <= 0 {
+			return cursor, errors.New("invalid limit")
+		}
+		cursor.Limit = limit
+	}
+
+	return cursor, nil
+}
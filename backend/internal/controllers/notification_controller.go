@@ -7,6 +7,7 @@ import (
 
 	"backend/internal/helpers"
 	"backend/internal/models"
+	"backend/internal/repository"
 	"backend/internal/services"
 
 	"github.com/gorilla/mux"
@@ -41,10 +42,30 @@ func (c *NotificationController) GetNotifications(w http.ResponseWriter, r *http
 		limit = 20
 	}
 
+	// Parse filters
+	query := r.URL.Query()
+	filter := repository.NotificationFilter{Type: models.NotificationType(query.Get("type"))}
+	if value := query.Get("is_read"); value != "" {
+		isRead, err := strconv.ParseBool(value)
+		if err != nil {
+			helpers.WriteError(w, http.StatusBadRequest, "is_read must be true or false", helpers.ErrCodeBadRequest)
+			return
+		}
+		filter.IsRead = &isRead
+	}
+	if value := query.Get("project_id"); value != "" {
+		projectID, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || projectID < 1 {
+			helpers.WriteError(w, http.StatusBadRequest, "Invalid project ID", helpers.ErrCodeBadRequest)
+			return
+		}
+		filter.ProjectID = projectID
+	}
+
 	// Get notifications
-	notifications, total, err := c.notificationService.GetUserNotifications(userID, page, limit)
+	notifications, total, err := c.notificationService.ListNotifications(userID, filter, page, limit)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		handleServiceError(w, err)
 		return
 	}
 
@@ -54,16 +75,7 @@ func (c *NotificationController) GetNotifications(w http.ResponseWriter, r *http
 	// Convert to response format
 	responses := make([]models.NotificationResponse, len(notifications))
 	for i, n := range notifications {
-		responses[i] = models.NotificationResponse{
-			ID:                n.ID,
-			UserID:            n.UserID,
-			Type:              string(n.Type),
-			Message:           n.Message,
-			IsRead:            n.IsRead,
-			RelatedEntityType: n.RelatedEntityType,
-			RelatedEntityID:   n.RelatedEntityID,
-			CreatedAt:         n.CreatedAt.Format("2006-01-02T15:04:05Z07:00"),
-		}
+		responses[i] = toNotificationResponse(n)
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -126,3 +138,88 @@ func (c *NotificationController) MarkAllAsRead(w http.ResponseWriter, r *http.Re
 		"marked_count": count,
 	})
 }
+
+// MarkAsUnread handles PATCH /api/notifications/:id/unread
+func (c *NotificationController) MarkAsUnread(w http.ResponseWriter, r *http.Request) {
+	userID := helpers.GetUserID(r)
+	if userID == "" {
+		helpers.WriteError(w, http.StatusUnauthorized, "Authentication required", helpers.ErrCodeUnauthorized)
+		return
+	}
+
+	notificationID, err := parseInt64RouteParam(r, "id")
+	if err != nil {
+		helpers.WriteError(w, http.StatusBadRequest, "Invalid notification ID", helpers.ErrCodeBadRequest)
+		return
+	}
+
+	if err := c.notificationService.MarkAsUnread(notificationID, userID); err != nil {
+		handleServiceError(w, err)
+		return
+	}
+
+	helpers.WriteSuccess(w, http.StatusOK, nil, "Notification marked as unread")
+}
+
+// DeleteNotification handles DELETE /api/notifications/:id
+func (c *NotificationController) DeleteNotification(w http.ResponseWriter, r *http.Request) {
+	userID := helpers.GetUserID(r)
+	if userID == "" {
+		helpers.WriteError(w, http.StatusUnauthorized, "Authentication required", helpers.ErrCodeUnauthorized)
+		return
+	}
+
+	notificationID, err := parseInt64RouteParam(r, "id")
+	if err != nil {
+		helpers.WriteError(w, http.StatusBadRequest, "Invalid notification ID", helpers.ErrCodeBadRequest)
+		return
+	}
+
+	if err := c.notificationService.DeleteNotification(notificationID, userID); err != nil {
+		handleServiceError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// BulkUpdate handles POST /api/notifications/bulk, which marks several
+// notifications read or unread, or deletes them
+func (c *NotificationController) BulkUpdate(w http.ResponseWriter, r *http.Request) {
+	userID := helpers.GetUserID(r)
+	if userID == "" {
+		helpers.WriteError(w, http.StatusUnauthorized, "Authentication required", helpers.ErrCodeUnauthorized)
+		return
+	}
+
+	var req struct {
+		Action string  `json:"action"`
+		IDs    []int64 `json:"ids"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		helpers.WriteError(w, http.StatusBadRequest, "Invalid request body", helpers.ErrCodeBadRequest)
+		return
+	}
+
+	count, err := c.notificationService.BulkUpdateNotifications(userID, req.Action, req.IDs)
+	if err != nil {
+		handleServiceError(w, err)
+		return
+	}
+
+	helpers.WriteSuccess(w, http.StatusOK, map[string]int64{"affected_count": count}, "Notifications updated")
+}
+
+// toNotificationResponse converts a notification to its JSON form
+func toNotificationResponse(n models.Notification) models.NotificationResponse {
+	return models.NotificationResponse{
+		ID:                n.ID,
+		UserID:            n.UserID,
+		Type:              string(n.Type),
+		Message:           n.Message,
+		IsRead:            n.IsRead,
+		RelatedEntityType: n.RelatedEntityType,
+		RelatedEntityID:   n.RelatedEntityID,
+		CreatedAt:         n.CreatedAt.Format("2006-01-02T15:04:05Z07:00"),
+	}
+}
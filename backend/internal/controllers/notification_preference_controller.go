@@ -0,0 +1,112 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"backend/internal/helpers"
+	"backend/internal/models"
+	"backend/internal/services"
+)
+
+// NotificationPreferenceController handles the current user's notification
+// preferences
+type NotificationPreferenceController struct {
+	service *services.NotificationService
+}
+
+// NewNotificationPreferenceController creates a new NotificationPreferenceController
+func NewNotificationPreferenceController(service *services.NotificationService) *NotificationPreferenceController {
+	return &NotificationPreferenceController{service: service}
+}
+
+type updateNotificationPreferencesRequest struct {
+	ProjectID   int64                                 `json:"project_id"`
+	Preferences []models.NotificationPreferenceUpdate `json:"preferences"`
+}
+
+// parsePreferenceProjectID reads the optional project_id query parameter
+func parsePreferenceProjectID(r *http.Request) (int64, error) {
+	raw := r.URL.Query().Get("project_id")
+	if raw == "" {
+		return 0, nil
+	}
+	return strconv.ParseInt(raw, 10, 64)
+}
+
+// GetPreferences handles GET /api/me/notification-preferences?project_id=
+func (c *NotificationPreferenceController) GetPreferences(w http.ResponseWriter, r *http.Request) {
+	userID := helpers.GetUserID(r)
+	if userID == "" {
+		helpers.WriteError(w, http.StatusUnauthorized, "Authentication required", helpers.ErrCodeUnauthorized)
+		return
+	}
+
+	projectID, err := parsePreferenceProjectID(r)
+	if err != nil || projectID < 0 {
+		helpers.WriteError(w, http.StatusBadRequest, "Invalid project_id parameter", helpers.ErrCodeBadRequest)
+		return
+	}
+
+	preferences, err := c.service.GetNotificationPreferences(userID, projectID)
+	if err != nil {
+		handleServiceError(w, err)
+		return
+	}
+
+	helpers.WriteSuccess(w, http.StatusOK, preferences, "")
+}
+
+// UpdatePreferences handles PUT /api/me/notification-preferences
+func (c *NotificationPreferenceController) UpdatePreferences(w http.ResponseWriter, r *http.Request) {
+	userID := helpers.GetUserID(r)
+	if userID == "" {
+		helpers.WriteError(w, http.StatusUnauthorized, "Authentication required", helpers.ErrCodeUnauthorized)
+		return
+	}
+
+	var req updateNotificationPreferencesRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		helpers.WriteError(w, http.StatusBadRequest, "Invalid request body", helpers.ErrCodeBadRequest)
+		return
+	}
+	if req.ProjectID < 0 {
+		helpers.WriteError(w, http.StatusBadRequest, "Invalid project_id", helpers.ErrCodeBadRequest)
+		return
+	}
+	if len(req.Preferences) == 0 {
+		helpers.WriteError(w, http.StatusBadRequest, "preferences are required", helpers.ErrCodeBadRequest)
+		return
+	}
+
+	preferences, err := c.service.UpdateNotificationPreferences(userID, req.ProjectID, req.Preferences)
+	if err != nil {
+		handleServiceError(w, err)
+		return
+	}
+
+	helpers.WriteSuccess(w, http.StatusOK, preferences, "Notification preferences updated")
+}
+
+// ResetProjectPreferences handles DELETE /api/me/notification-preferences?project_id=
+func (c *NotificationPreferenceController) ResetProjectPreferences(w http.ResponseWriter, r *http.Request) {
+	userID := helpers.GetUserID(r)
+	if userID == "" {
+		helpers.WriteError(w, http.StatusUnauthorized, "Authentication required", helpers.ErrCodeUnauthorized)
+		return
+	}
+
+	projectID, err := parsePreferenceProjectID(r)
+	if err != nil || projectID < 0 {
+		helpers.WriteError(w, http.StatusBadRequest, "Invalid project_id parameter", helpers.ErrCodeBadRequest)
+		return
+	}
+
+	if err := c.service.ResetProjectNotificationPreferences(userID, projectID); err != nil {
+		handleServiceError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
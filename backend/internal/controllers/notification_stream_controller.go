@@ -0,0 +1,200 @@
+package controllers
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"backend/internal/helpers"
+	"backend/internal/models"
+	"backend/internal/services"
+)
+
+const (
+	// DefaultStreamKeepAlive is how often an idle notification stream gets a
+	// comment line, so proxies do not close it
+	DefaultStreamKeepAlive = 25 * time.Second
+
+	// streamRetryMillis is how long browsers wait before reconnecting
+	streamRetryMillis = 5000
+	// streamBatchSize is how many notifications are read per query when
+	// catching up
+	streamBatchSize = 100
+)
+
+// NotificationStreamController serves notifications as Server-Sent Events
+type NotificationStreamController struct {
+	notificationService *services.NotificationService
+	stream              *services.NotificationStream
+	keepAlive           time.Duration
+}
+
+// NewNotificationStreamController creates a new NotificationStreamController
+func NewNotificationStreamController(notificationService *services.NotificationService, stream *services.NotificationStream) *NotificationStreamController {
+	return &NotificationStreamController{
+		notificationService: notificationService,
+		stream:              stream,
+		keepAlive:           DefaultStreamKeepAlive,
+	}
+}
+
+// SetKeepAlive changes how often idle streams get a keep-alive
+func (c *NotificationStreamController) SetKeepAlive(interval time.Duration) {
+	c.keepAlive = interval
+}
+
+// Stream handles GET /api/notifications/stream. Every new notification is
+// sent as a "notification" event whose id is the notification ID, together
+// with the unread count; read-state changes send an "unread_count" event.
+// A reconnecting client sends Last-Event-ID (or ?last_event_id=) and first
+// gets every notification it missed.
+func (c *NotificationStreamController) Stream(w http.ResponseWriter, r *http.Request) {
+	userID := helpers.GetUserID(r)
+	if userID == "" {
+		helpers.WriteError(w, http.StatusUnauthorized, "Authentication required", helpers.ErrCodeUnauthorized)
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		helpers.WriteError(w, http.StatusInternalServerError, "Streaming is not supported", helpers.ErrCodeInternalError)
+		return
+	}
+
+	lastEventID := r.Header.Get("Last-Event-ID")
+	if lastEventID == "" {
+		lastEventID = r.URL.Query().Get("last_event_id")
+	}
+	var cursor int64
+	resume := lastEventID != ""
+	if resume {
+		id, err := strconv.ParseInt(lastEventID, 10, 64)
+		if err != nil || id < 0 {
+			helpers.WriteError(w, http.StatusBadRequest, "Invalid Last-Event-ID", helpers.ErrCodeBadRequest)
+			return
+		}
+		cursor = id
+	}
+
+	// Subscribe before reading the database so nothing inserted in between
+	// is missed
+	changes, unsubscribe := c.stream.Subscribe(userID)
+	defer unsubscribe()
+
+	if !resume {
+		latest, err := c.notificationService.GetLatestNotificationID(userID)
+		if err != nil {
+			helpers.WriteError(w, http.StatusInternalServerError, "Failed to open notification stream", helpers.ErrCodeInternalError)
+			return
+		}
+		cursor = latest
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprintf(w, "retry: %d\n\n", streamRetryMillis)
+
+	s := &notificationStreamWriter{w: w, service: c.notificationService, userID: userID, cursor: cursor, unreadCount: -1}
+	if err := s.sendChanges(); err != nil {
+		return
+	}
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(c.keepAlive)
+	defer keepAlive.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-changes:
+		case <-keepAlive.C:
+			if _, err := io.WriteString(w, ": keep-alive\n\n"); err != nil {
+				return
+			}
+			// Notifications created by another server instance never signal
+			// this one, so look for them on every keep-alive
+		}
+		if err := s.sendChanges(); err != nil {
+			return
+		}
+		flusher.Flush()
+	}
+}
+
+// notificationStreamWriter remembers what one stream has already sent
+type notificationStreamWriter struct {
+	w           io.Writer
+	service     *services.NotificationService
+	userID      string
+	cursor      int64
+	unreadCount int64
+}
+
+// sendChanges sends the notifications after the cursor, or an unread_count
+// event when there are none but the count changed
+func (s *notificationStreamWriter) sendChanges() error {
+	sent := false
+	for {
+		notifications, err := s.service.GetNotificationsAfter(s.userID, s.cursor, streamBatchSize)
+		if err != nil {
+			return err
+		}
+		if len(notifications) == 0 {
+			break
+		}
+
+		count, err := s.service.GetUnreadCount(s.userID)
+		if err != nil {
+			return err
+		}
+		for _, n := range notifications {
+			response := toNotificationResponse(n)
+			event := models.NotificationStreamEvent{Notification: &response, UnreadCount: count}
+			if err := writeStreamEvent(s.w, strconv.FormatInt(n.ID, 10), "notification", event); err != nil {
+				return err
+			}
+			s.cursor = n.ID
+		}
+		s.unreadCount = count
+		sent = true
+
+		if len(notifications) < streamBatchSize {
+			break
+		}
+	}
+	if sent {
+		return nil
+	}
+
+	count, err := s.service.GetUnreadCount(s.userID)
+	if err != nil {
+		return err
+	}
+	if count == s.unreadCount {
+		return nil
+	}
+	s.unreadCount = count
+	return writeStreamEvent(s.w, "", "unread_count", models.NotificationStreamEvent{UnreadCount: count})
+}
+
+// writeStreamEvent writes one Server-Sent Event with JSON data
+func writeStreamEvent(w io.Writer, id, event string, data interface{}) error {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	if id != "" {
+		if _, err := fmt.Fprintf(w, "id: %s\n", id); err != nil {
+			return err
+		}
+	}
+	_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, payload)
+	return err
+}
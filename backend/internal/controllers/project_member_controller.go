@@ -7,6 +7,7 @@ import (
 	"strings"
 
 	"backend/internal/helpers"
+	"backend/internal/models"
 	"backend/internal/services"
 
 	"github.com/gorilla/mux"
@@ -37,7 +38,8 @@ func (c *ProjectMemberController) CreateInvite(w http.ResponseWriter, r *http.Re
 	}
 
 	var req struct {
-		ExpiresInHours int `json:"expires_in_hours"`
+		ExpiresInHours int    `json:"expires_in_hours"`
+		Email          string `json:"email"`
 	}
 	json.NewDecoder(r.Body).Decode(&req)
 
@@ -46,7 +48,13 @@ func (c *ProjectMemberController) CreateInvite(w http.ResponseWriter, r *http.Re
 		req.ExpiresInHours = 168 // 7 days
 	}
 
-	invite, err := c.service.CreateInvite(projectID, currentUserID, req.ExpiresInHours)
+	// With an email address the invite link is also sent there
+	var invite *models.ProjectInvite
+	if req.Email != "" {
+		invite, err = c.service.InviteByEmail(projectID, currentUserID, req.ExpiresInHours, req.Email)
+	} else {
+		invite, err = c.service.CreateInvite(projectID, currentUserID, req.ExpiresInHours)
+	}
 	if err != nil {
 		handleServiceError(w, err)
 		return
@@ -189,6 +197,30 @@ func (c *ProjectMemberController) GetMembers(w http.ResponseWriter, r *http.Requ
 	helpers.WriteSuccess(w, http.StatusOK, members, "")
 }
 
+// GetPresence handles GET /api/projects/{id}/presence
+// Returns the members currently connected to the project's chat/board socket
+func (c *ProjectMemberController) GetPresence(w http.ResponseWriter, r *http.Request) {
+	currentUserID := getUserIDFromContext(r)
+	if currentUserID == "" {
+		helpers.WriteError(w, http.StatusUnauthorized, "Authentication required", helpers.ErrCodeUnauthorized)
+		return
+	}
+
+	projectID, err := getProjectID(r)
+	if err != nil {
+		helpers.WriteError(w, http.StatusBadRequest, "Invalid project ID", helpers.ErrCodeBadRequest)
+		return
+	}
+
+	online, err := c.service.GetOnlineMembers(projectID, currentUserID)
+	if err != nil {
+		handleServiceError(w, err)
+		return
+	}
+
+	helpers.WriteSuccess(w, http.StatusOK, online, "")
+}
+
 // -------------------- Helpers --------------------
 
 // getProjectID extracts and validates project ID
@@ -203,7 +235,7 @@ func handleServiceError(w http.ResponseWriter, err error) {
 		switch se.Code {
 		case "PROJECT_NOT_FOUND", "USER_NOT_FOUND":
 			helpers.WriteError(w, http.StatusNotFound, se.Message, helpers.ErrCodeNotFound)
-		case "ACCESS_DENIED":
+		case "ACCESS_DENIED", "EMAIL_NOT_VERIFIED":
 			helpers.WriteError(w, http.StatusForbidden, se.Message, helpers.ErrCodeForbidden)
 		case "INVALID_REQUEST":
 			helpers.WriteError(w, http.StatusBadRequest, se.Message, helpers.ErrCodeBadRequest)
@@ -0,0 +1,167 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"backend/internal/helpers"
+	"backend/internal/services"
+)
+
+// ReminderController handles deadline reminder settings for the current user
+// and for projects
+type ReminderController struct {
+	service *services.NotificationService
+}
+
+// NewReminderController creates a new ReminderController
+func NewReminderController(service *services.NotificationService) *ReminderController {
+	return &ReminderController{service: service}
+}
+
+type reminderSettingsRequest struct {
+	OffsetsMinutes *[]int `json:"offsets_minutes"`
+}
+
+// decodeReminderOffsets reads the offsets from a PUT body; an empty list
+// turns reminders off
+func decodeReminderOffsets(w http.ResponseWriter, r *http.Request) ([]int, bool) {
+	var req reminderSettingsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		helpers.WriteError(w, http.StatusBadRequest, "Invalid request body", helpers.ErrCodeBadRequest)
+		return nil, false
+	}
+	if req.OffsetsMinutes == nil {
+		helpers.WriteError(w, http.StatusBadRequest, "offsets_minutes is required", helpers.ErrCodeBadRequest)
+		return nil, false
+	}
+	return *req.OffsetsMinutes, true
+}
+
+// GetMySettings handles GET /api/me/reminder-settings
+func (c *ReminderController) GetMySettings(w http.ResponseWriter, r *http.Request) {
+	userID := helpers.GetUserID(r)
+	if userID == "" {
+		helpers.WriteError(w, http.StatusUnauthorized, "Authentication required", helpers.ErrCodeUnauthorized)
+		return
+	}
+
+	settings, err := c.service.GetUserReminderSettings(userID)
+	if err != nil {
+		handleServiceError(w, err)
+		return
+	}
+
+	helpers.WriteSuccess(w, http.StatusOK, settings, "")
+}
+
+// UpdateMySettings handles PUT /api/me/reminder-settings
+func (c *ReminderController) UpdateMySettings(w http.ResponseWriter, r *http.Request) {
+	userID := helpers.GetUserID(r)
+	if userID == "" {
+		helpers.WriteError(w, http.StatusUnauthorized, "Authentication required", helpers.ErrCodeUnauthorized)
+		return
+	}
+
+	offsets, ok := decodeReminderOffsets(w, r)
+	if !ok {
+		return
+	}
+
+	settings, err := c.service.SetUserReminderOffsets(userID, offsets)
+	if err != nil {
+		handleServiceError(w, err)
+		return
+	}
+
+	helpers.WriteSuccess(w, http.StatusOK, settings, "Reminder settings updated")
+}
+
+// ResetMySettings handles DELETE /api/me/reminder-settings
+func (c *ReminderController) ResetMySettings(w http.ResponseWriter, r *http.Request) {
+	userID := helpers.GetUserID(r)
+	if userID == "" {
+		helpers.WriteError(w, http.StatusUnauthorized, "Authentication required", helpers.ErrCodeUnauthorized)
+		return
+	}
+
+	if err := c.service.ResetUserReminderOffsets(userID); err != nil {
+		handleServiceError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetProjectSettings handles GET /api/projects/:id/reminder-settings
+func (c *ReminderController) GetProjectSettings(w http.ResponseWriter, r *http.Request) {
+	userID := helpers.GetUserID(r)
+	if userID == "" {
+		helpers.WriteError(w, http.StatusUnauthorized, "Authentication required", helpers.ErrCodeUnauthorized)
+		return
+	}
+
+	projectID, err := getProjectID(r)
+	if err != nil {
+		helpers.WriteError(w, http.StatusBadRequest, "Invalid project ID", helpers.ErrCodeBadRequest)
+		return
+	}
+
+	settings, err := c.service.GetProjectReminderSettings(projectID, userID)
+	if err != nil {
+		handleServiceError(w, err)
+		return
+	}
+
+	helpers.WriteSuccess(w, http.StatusOK, settings, "")
+}
+
+// UpdateProjectSettings handles PUT /api/projects/:id/reminder-settings
+func (c *ReminderController) UpdateProjectSettings(w http.ResponseWriter, r *http.Request) {
+	userID := helpers.GetUserID(r)
+	if userID == "" {
+		helpers.WriteError(w, http.StatusUnauthorized, "Authentication required", helpers.ErrCodeUnauthorized)
+		return
+	}
+
+	projectID, err := getProjectID(r)
+	if err != nil {
+		helpers.WriteError(w, http.StatusBadRequest, "Invalid project ID", helpers.ErrCodeBadRequest)
+		return
+	}
+
+	offsets, ok := decodeReminderOffsets(w, r)
+	if !ok {
+		return
+	}
+
+	settings, err := c.service.SetProjectReminderOffsets(projectID, userID, offsets)
+	if err != nil {
+		handleServiceError(w, err)
+		return
+	}
+
+	helpers.WriteSuccess(w, http.StatusOK, settings, "Reminder settings updated")
+}
+
+// ResetProjectSettings handles DELETE /api/projects/:id/reminder-settings
+func (c *ReminderController) ResetProjectSettings(w http.ResponseWriter, r *http.Request) {
+	userID := helpers.GetUserID(r)
+	if userID == "" {
+		helpers.WriteError(w, http.StatusUnauthorized, "Authentication required", helpers.ErrCodeUnauthorized)
+		return
+	}
+
+	projectID, err := getProjectID(r)
+	if err != nil {
+		helpers.WriteError(w, http.StatusBadRequest, "Invalid project ID", helpers.ErrCodeBadRequest)
+		return
+	}
+
+	if err := c.service.ResetProjectReminderOffsets(projectID, userID); err != nil {
+		handleServiceError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
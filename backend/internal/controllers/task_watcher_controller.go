@@ -0,0 +1,95 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+
+	"backend/internal/helpers"
+	"backend/internal/services"
+)
+
+// TaskWatcherController handles watching and unwatching tasks
+type TaskWatcherController struct {
+	service *services.TaskWatcherService
+}
+
+// NewTaskWatcherController creates a new TaskWatcherController
+func NewTaskWatcherController(service *services.TaskWatcherService) *TaskWatcherController {
+	return &TaskWatcherController{service: service}
+}
+
+// WatchTask handles POST /api/tasks/:id/watchers
+func (c *TaskWatcherController) WatchTask(w http.ResponseWriter, r *http.Request) {
+	userID := helpers.GetUserID(r)
+	if userID == "" {
+		helpers.WriteError(w, http.StatusUnauthorized, "Authentication required", helpers.ErrCodeUnauthorized)
+		return
+	}
+
+	taskID, err := parseInt64RouteParam(r, "id")
+	if err != nil {
+		helpers.WriteError(w, http.StatusBadRequest, "Invalid task ID", helpers.ErrCodeBadRequest)
+		return
+	}
+
+	watchers, err := c.service.WatchTask(userID, taskID)
+	if err != nil {
+		handleWatcherError(w, err)
+		return
+	}
+
+	helpers.WriteSuccess(w, http.StatusOK, watchers, "Watching task")
+}
+
+// UnwatchTask handles DELETE /api/tasks/:id/watchers
+func (c *TaskWatcherController) UnwatchTask(w http.ResponseWriter, r *http.Request) {
+	userID := helpers.GetUserID(r)
+	if userID == "" {
+		helpers.WriteError(w, http.StatusUnauthorized, "Authentication required", helpers.ErrCodeUnauthorized)
+		return
+	}
+
+	taskID, err := parseInt64RouteParam(r, "id")
+	if err != nil {
+		helpers.WriteError(w, http.StatusBadRequest, "Invalid task ID", helpers.ErrCodeBadRequest)
+		return
+	}
+
+	if err := c.service.UnwatchTask(userID, taskID); err != nil {
+		handleWatcherError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetWatchers handles GET /api/tasks/:id/watchers
+func (c *TaskWatcherController) GetWatchers(w http.ResponseWriter, r *http.Request) {
+	userID := helpers.GetUserID(r)
+	if userID == "" {
+		helpers.WriteError(w, http.StatusUnauthorized, "Authentication required", helpers.ErrCodeUnauthorized)
+		return
+	}
+
+	taskID, err := parseInt64RouteParam(r, "id")
+	if err != nil {
+		helpers.WriteError(w, http.StatusBadRequest, "Invalid task ID", helpers.ErrCodeBadRequest)
+		return
+	}
+
+	watchers, err := c.service.GetWatchers(userID, taskID)
+	if err != nil {
+		handleWatcherError(w, err)
+		return
+	}
+
+	helpers.WriteSuccess(w, http.StatusOK, watchers, "")
+}
+
+func handleWatcherError(w http.ResponseWriter, err error) {
+	if errors.Is(err, services.ErrTaskNotFoundOrAccessDenied) {
+		helpers.WriteError(w, http.StatusNotFound, err.Error(), helpers.ErrCodeNotFound)
+		return
+	}
+	helpers.WriteError(w, http.StatusInternalServerError, "Internal server error", helpers.ErrCodeInternalError)
+}
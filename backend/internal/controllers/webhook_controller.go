@@ -0,0 +1,230 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"backend/internal/helpers"
+	"backend/internal/models"
+	"backend/internal/services"
+)
+
+// WebhookController handles a project's outgoing webhooks and their
+// delivery log
+type WebhookController struct {
+	service *services.WebhookService
+}
+
+// NewWebhookController creates a new WebhookController
+func NewWebhookController(service *services.WebhookService) *WebhookController {
+	return &WebhookController{service: service}
+}
+
+type createWebhookRequest struct {
+	URL    string                  `json:"url"`
+	Events []models.ActivityAction `json:"events"`
+	Secret string                  `json:"secret"`
+	Active *bool                   `json:"active"`
+}
+
+type updateWebhookRequest struct {
+	URL    *string                 `json:"url"`
+	Events []models.ActivityAction `json:"events"`
+	Active *bool                   `json:"active"`
+}
+
+// webhookRouteParams reads the current user and the project and webhook IDs
+// of the request, writing an error response when one is missing or invalid
+func webhookRouteParams(w http.ResponseWriter, r *http.Request, withWebhook bool) (string, int64, int64, bool) {
+	userID := helpers.GetUserID(r)
+	if userID == "" {
+		helpers.WriteError(w, http.StatusUnauthorized, "Authentication required", helpers.ErrCodeUnauthorized)
+		return "", 0, 0, false
+	}
+
+	projectID, err := getProjectID(r)
+	if err != nil {
+		helpers.WriteError(w, http.StatusBadRequest, "Invalid project ID", helpers.ErrCodeBadRequest)
+		return "", 0, 0, false
+	}
+
+	var webhookID int64
+	if withWebhook {
+		webhookID, err = parseInt64RouteParam(r, "webhookId")
+		if err != nil {
+			helpers.WriteError(w, http.StatusBadRequest, "Invalid webhook ID", helpers.ErrCodeBadRequest)
+			return "", 0, 0, false
+		}
+	}
+	return userID, projectID, webhookID, true
+}
+
+// ListWebhooks handles GET /api/projects/:id/webhooks
+func (c *WebhookController) ListWebhooks(w http.ResponseWriter, r *http.Request) {
+	userID, projectID, _, ok := webhookRouteParams(w, r, false)
+	if !ok {
+		return
+	}
+
+	webhooks, err := c.service.ListWebhooks(projectID, userID)
+	if err != nil {
+		handleServiceError(w, err)
+		return
+	}
+
+	helpers.WriteSuccess(w, http.StatusOK, webhooks, "")
+}
+
+// CreateWebhook handles POST /api/projects/:id/webhooks
+func (c *WebhookController) CreateWebhook(w http.ResponseWriter, r *http.Request) {
+	userID, projectID, _, ok := webhookRouteParams(w, r, false)
+	if !ok {
+		return
+	}
+
+	var req createWebhookRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		helpers.WriteError(w, http.StatusBadRequest, "Invalid request body", helpers.ErrCodeBadRequest)
+		return
+	}
+	active := req.Active == nil || *req.Active
+
+	webhook, err := c.service.CreateWebhook(projectID, userID, req.URL, req.Secret, req.Events, active)
+	if err != nil {
+		handleServiceError(w, err)
+		return
+	}
+
+	helpers.WriteSuccess(w, http.StatusCreated, webhook, "Webhook created")
+}
+
+// GetWebhook handles GET /api/projects/:id/webhooks/:webhookId
+func (c *WebhookController) GetWebhook(w http.ResponseWriter, r *http.Request) {
+	userID, projectID, webhookID, ok := webhookRouteParams(w, r, true)
+	if !ok {
+		return
+	}
+
+	webhook, err := c.service.GetWebhook(projectID, webhookID, userID)
+	if err != nil {
+		handleServiceError(w, err)
+		return
+	}
+
+	helpers.WriteSuccess(w, http.StatusOK, webhook, "")
+}
+
+// UpdateWebhook handles PUT /api/projects/:id/webhooks/:webhookId
+func (c *WebhookController) UpdateWebhook(w http.ResponseWriter, r *http.Request) {
+	userID, projectID, webhookID, ok := webhookRouteParams(w, r, true)
+	if !ok {
+		return
+	}
+
+	var req updateWebhookRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		helpers.WriteError(w, http.StatusBadRequest, "Invalid request body", helpers.ErrCodeBadRequest)
+		return
+	}
+
+	webhook, err := c.service.UpdateWebhook(projectID, webhookID, userID, services.WebhookUpdate{
+		URL:    req.URL,
+		Events: req.Events,
+		Active: req.Active,
+	})
+	if err != nil {
+		handleServiceError(w, err)
+		return
+	}
+
+	helpers.WriteSuccess(w, http.StatusOK, webhook, "Webhook updated")
+}
+
+// DeleteWebhook handles DELETE /api/projects/:id/webhooks/:webhookId
+func (c *WebhookController) DeleteWebhook(w http.ResponseWriter, r *http.Request) {
+	userID, projectID, webhookID, ok := webhookRouteParams(w, r, true)
+	if !ok {
+		return
+	}
+
+	if err := c.service.DeleteWebhook(projectID, webhookID, userID); err != nil {
+		handleServiceError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// SendTestEvent handles POST /api/projects/:id/webhooks/:webhookId/test
+func (c *WebhookController) SendTestEvent(w http.ResponseWriter, r *http.Request) {
+	userID, projectID, webhookID, ok := webhookRouteParams(w, r, true)
+	if !ok {
+		return
+	}
+
+	delivery, err := c.service.SendTestEvent(projectID, webhookID, userID)
+	if err != nil {
+		handleServiceError(w, err)
+		return
+	}
+
+	helpers.WriteSuccess(w, http.StatusOK, delivery, "Test event sent")
+}
+
+// ListDeliveries handles GET /api/projects/:id/webhooks/:webhookId/deliveries?page=&limit=
+func (c *WebhookController) ListDeliveries(w http.ResponseWriter, r *http.Request) {
+	userID, projectID, webhookID, ok := webhookRouteParams(w, r, true)
+	if !ok {
+		return
+	}
+
+	page, limit := 1, 20
+	if p := r.URL.Query().Get("page"); p != "" {
+		parsed, err := strconv.Atoi(p)
+		if err != nil {
+			helpers.WriteError(w, http.StatusBadRequest, "Invalid page parameter", helpers.ErrCodeBadRequest)
+			return
+		}
+		page = parsed
+	}
+	if l := r.URL.Query().Get("limit"); l != "" {
+		parsed, err := strconv.Atoi(l)
+		if err != nil {
+			helpers.WriteError(w, http.StatusBadRequest, "Invalid limit parameter", helpers.ErrCodeBadRequest)
+			return
+		}
+		limit = parsed
+	}
+	page, limit = normalizeActivityPagination(page, limit)
+
+	deliveries, total, err := c.service.ListDeliveries(projectID, webhookID, userID, page, limit)
+	if err != nil {
+		handleServiceError(w, err)
+		return
+	}
+
+	helpers.WritePaginated(w, http.StatusOK, deliveries, page, limit, total)
+}
+
+// GetDelivery handles GET /api/projects/:id/webhooks/:webhookId/deliveries/:deliveryId
+func (c *WebhookController) GetDelivery(w http.ResponseWriter, r *http.Request) {
+	userID, projectID, webhookID, ok := webhookRouteParams(w, r, true)
+	if !ok {
+		return
+	}
+
+	deliveryID, err := parseInt64RouteParam(r, "deliveryId")
+	if err != nil {
+		helpers.WriteError(w, http.StatusBadRequest, "Invalid delivery ID", helpers.ErrCodeBadRequest)
+		return
+	}
+
+	delivery, err := c.service.GetDelivery(projectID, webhookID, deliveryID, userID)
+	if err != nil {
+		handleServiceError(w, err)
+		return
+	}
+
+	helpers.WriteSuccess(w, http.StatusOK, delivery, "")
+}
@@ -24,22 +24,35 @@ func NewDB() (*DB, error) {
 
 	log.Printf("Database path: %s", dbPath)
 
-	db, err := sql.Open("sqlite3", dbPath)
+	database, err := Open(dbPath)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Println("Database connected successfully")
+	return database, nil
+}
+
+// Open opens the SQLite database at path and creates or migrates the schema,
+// the same way NewDB does for the server's database
+func Open(path string) (*DB, error) {
+	db, err := sql.Open("sqlite3", path)
 	if err != nil {
 		return nil, fmt.Errorf("failed to open database: %v", err)
 	}
 
 	if err := db.Ping(); err != nil {
+		db.Close()
 		return nil, fmt.Errorf("failed to ping database: %v", err)
 	}
 
 	database := &DB{db}
 
 	if err := database.createTables(); err != nil {
+		db.Close()
 		return nil, fmt.Errorf("failed to create tables: %v", err)
 	}
 
-	log.Println("Database connected successfully")
 	return database, nil
 }
 
@@ -78,6 +91,7 @@ func (db *DB) createTables() error {
 		project_id INTEGER NOT NULL,
 		name TEXT NOT NULL,
 		position INTEGER DEFAULT 0,
+		is_final INTEGER DEFAULT 0,
 		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
 		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
 		FOREIGN KEY (project_id) REFERENCES projects(id) ON DELETE CASCADE
@@ -109,9 +123,37 @@ func (db *DB) createTables() error {
 		id INTEGER PRIMARY KEY AUTOINCREMENT,
 		user_id TEXT,
 		project_id INTEGER NOT NULL,
+		parent_message_id INTEGER,
 		sender_name TEXT NOT NULL,
 		content TEXT NOT NULL,
 		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		edited_at DATETIME,
+		FOREIGN KEY (project_id) REFERENCES projects(id) ON DELETE CASCADE,
+		FOREIGN KEY (parent_message_id) REFERENCES messages(id) ON DELETE CASCADE
+	)
+	`
+
+	// Create message reactions table (one row per user, message and emoji)
+	messageReactionsTable := `
+	CREATE TABLE IF NOT EXISTS message_reactions (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		message_id INTEGER NOT NULL,
+		user_id TEXT NOT NULL,
+		emoji TEXT NOT NULL,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY (message_id) REFERENCES messages(id) ON DELETE CASCADE,
+		UNIQUE(message_id, user_id, emoji)
+	)
+	`
+
+	// Create message reads table (chat read position per user and project)
+	messageReadsTable := `
+	CREATE TABLE IF NOT EXISTS message_reads (
+		user_id TEXT NOT NULL,
+		project_id INTEGER NOT NULL,
+		last_read_message_id INTEGER NOT NULL,
+		read_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		PRIMARY KEY (user_id, project_id),
 		FOREIGN KEY (project_id) REFERENCES projects(id) ON DELETE CASCADE
 	)
 	`
@@ -211,16 +253,165 @@ func (db *DB) createTables() error {
 		is_read INTEGER DEFAULT 0,
 		related_entity_type TEXT,
 		related_entity_id INTEGER,
+		project_id INTEGER,
 		created_at DATETIME DEFAULT CURRENT_TIMESTAMP
 	)
 	`
 
+	// Create mentions table (one row per mentioned user per comment or message)
+	mentionsTable := `
+	CREATE TABLE IF NOT EXISTS mentions (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		project_id INTEGER NOT NULL,
+		user_id TEXT NOT NULL,
+		author_id TEXT NOT NULL,
+		entity_type TEXT NOT NULL,
+		entity_id INTEGER NOT NULL,
+		task_id INTEGER,
+		excerpt TEXT NOT NULL,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY (project_id) REFERENCES projects(id) ON DELETE CASCADE,
+		UNIQUE(entity_type, entity_id, user_id)
+	)
+	`
+
+	// Create reminder settings table. A row holds either a user's own offsets
+	// (project_id 0) or a project's offsets (user_id '')
+	reminderSettingsTable := `
+	CREATE TABLE IF NOT EXISTS reminder_settings (
+		user_id TEXT NOT NULL DEFAULT '',
+		project_id INTEGER NOT NULL DEFAULT 0,
+		offsets TEXT NOT NULL,
+		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		PRIMARY KEY (user_id, project_id)
+	)
+	`
+
+	// Create deadline reminders table (one row per reminder sent for a task's
+	// current deadline; offset_minutes 0 is the overdue notice)
+	deadlineRemindersTable := `
+	CREATE TABLE IF NOT EXISTS deadline_reminders (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		task_id INTEGER NOT NULL,
+		user_id TEXT NOT NULL,
+		deadline DATETIME NOT NULL,
+		offset_minutes INTEGER NOT NULL,
+		sent_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY (task_id) REFERENCES tasks(id) ON DELETE CASCADE,
+		UNIQUE(task_id, user_id, deadline, offset_minutes)
+	)
+	`
+
+	// Create notification preferences table. project_id 0 holds the user's
+	// defaults for all projects; a missing row means both channels are on
+	notificationPreferencesTable := `
+	CREATE TABLE IF NOT EXISTS notification_preferences (
+		user_id TEXT NOT NULL,
+		project_id INTEGER NOT NULL DEFAULT 0,
+		type TEXT NOT NULL,
+		in_app INTEGER NOT NULL DEFAULT 1,
+		email INTEGER NOT NULL DEFAULT 1,
+		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		PRIMARY KEY (user_id, project_id, type)
+	)
+	`
+
+	// Create task watchers table
+	taskWatchersTable := `
+	CREATE TABLE IF NOT EXISTS task_watchers (
+		task_id INTEGER NOT NULL,
+		user_id TEXT NOT NULL,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		PRIMARY KEY (task_id, user_id),
+		FOREIGN KEY (task_id) REFERENCES tasks(id) ON DELETE CASCADE
+	)
+	`
+
+	// Create digest settings table. Users without a row get immediate emails;
+	// last_sent_at marks the end of the period the last digest covered
+	digestSettingsTable := `
+	CREATE TABLE IF NOT EXISTS digest_settings (
+		user_id TEXT PRIMARY KEY,
+		frequency TEXT NOT NULL DEFAULT 'immediate',
+		last_sent_at DATETIME,
+		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	)
+	`
+
+	// Create webhooks table. events is a JSON array of activity actions the
+	// webhook is subscribed to
+	webhooksTable := `
+	CREATE TABLE IF NOT EXISTS webhooks (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		project_id INTEGER NOT NULL,
+		url TEXT NOT NULL,
+		secret TEXT NOT NULL,
+		events TEXT NOT NULL,
+		active INTEGER NOT NULL DEFAULT 1,
+		created_by TEXT NOT NULL,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY (project_id) REFERENCES projects(id) ON DELETE CASCADE
+	)
+	`
+
+	// Create webhook deliveries table (one row per event sent to a webhook,
+	// retried by the delivery worker until it succeeds or runs out of attempts)
+	webhookDeliveriesTable := `
+	CREATE TABLE IF NOT EXISTS webhook_deliveries (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		webhook_id INTEGER NOT NULL,
+		event TEXT NOT NULL,
+		payload TEXT NOT NULL,
+		status TEXT NOT NULL DEFAULT 'pending',
+		attempts INTEGER NOT NULL DEFAULT 0,
+		max_attempts INTEGER NOT NULL,
+		next_attempt_at DATETIME NOT NULL,
+		delivered_at DATETIME,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY (webhook_id) REFERENCES webhooks(id) ON DELETE CASCADE
+	)
+	`
+
+	// Create webhook delivery attempts table (the log of every HTTP request
+	// made for a delivery)
+	webhookDeliveryAttemptsTable := `
+	CREATE TABLE IF NOT EXISTS webhook_delivery_attempts (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		delivery_id INTEGER NOT NULL,
+		attempt INTEGER NOT NULL,
+		response_status INTEGER NOT NULL DEFAULT 0,
+		response_body TEXT NOT NULL DEFAULT '',
+		error TEXT NOT NULL DEFAULT '',
+		duration_ms INTEGER NOT NULL DEFAULT 0,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY (delivery_id) REFERENCES webhook_deliveries(id) ON DELETE CASCADE
+	)
+	`
+
+	// Create hub events table (chat hub operations relayed between instances
+	// by the SQLite WebSocket backplane)
+	hubEventsTable := `
+	CREATE TABLE IF NOT EXISTS hub_events (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		origin TEXT NOT NULL,
+		kind TEXT NOT NULL,
+		project_id INTEGER NOT NULL,
+		user_id TEXT,
+		payload BLOB,
+		created_at DATETIME NOT NULL
+	)
+	`
+
 	tables := []string{
 		projectsTable,
 		projectMembersTable,
 		stagesTable,
 		tasksTable,
 		messagesTable,
+		messageReactionsTable,
+		messageReadsTable,
 		commentsTable,
 		subtasksTable,
 		activityLogsTable,
@@ -228,6 +419,16 @@ func (db *DB) createTables() error {
 		labelsTable,
 		taskLabelsTable,
 		notificationsTable,
+		mentionsTable,
+		reminderSettingsTable,
+		deadlineRemindersTable,
+		notificationPreferencesTable,
+		taskWatchersTable,
+		digestSettingsTable,
+		webhooksTable,
+		webhookDeliveriesTable,
+		webhookDeliveryAttemptsTable,
+		hubEventsTable,
 	}
 
 	for _, table := range tables {
@@ -245,10 +446,14 @@ func (db *DB) createTables() error {
 		"CREATE INDEX IF NOT EXISTS idx_projects_owner ON projects(owner_id)",
 		"CREATE INDEX IF NOT EXISTS idx_stages_user ON stages(user_id)",
 		"CREATE INDEX IF NOT EXISTS idx_stages_project ON stages(project_id)",
+		"CREATE INDEX IF NOT EXISTS idx_stages_project_final ON stages(project_id, is_final)",
 		"CREATE INDEX IF NOT EXISTS idx_tasks_user ON tasks(user_id)",
 		"CREATE INDEX IF NOT EXISTS idx_tasks_stage ON tasks(stage_id)",
 		"CREATE INDEX IF NOT EXISTS idx_messages_user ON messages(user_id)",
 		"CREATE INDEX IF NOT EXISTS idx_messages_project ON messages(project_id)",
+		"CREATE INDEX IF NOT EXISTS idx_messages_parent ON messages(parent_message_id)",
+		"CREATE INDEX IF NOT EXISTS idx_message_reactions_message ON message_reactions(message_id)",
+		"CREATE INDEX IF NOT EXISTS idx_message_reads_project ON message_reads(project_id)",
 		"CREATE INDEX IF NOT EXISTS idx_comments_task ON comments(task_id)",
 		"CREATE INDEX IF NOT EXISTS idx_comments_user ON comments(user_id)",
 		"CREATE INDEX IF NOT EXISTS idx_subtasks_task ON subtasks(task_id)",
@@ -272,8 +477,18 @@ func (db *DB) createTables() error {
 		"CREATE INDEX IF NOT EXISTS idx_task_labels_label ON task_labels(label_id)",
 		// Notification indexes
 		"CREATE INDEX IF NOT EXISTS idx_notifications_user ON notifications(user_id)",
+		"CREATE INDEX IF NOT EXISTS idx_mentions_user_created ON mentions(user_id, created_at DESC)",
+		"CREATE INDEX IF NOT EXISTS idx_task_watchers_user ON task_watchers(user_id)",
 		"CREATE INDEX IF NOT EXISTS idx_notifications_user_read ON notifications(user_id, is_read)",
 		"CREATE INDEX IF NOT EXISTS idx_notifications_user_created ON notifications(user_id, created_at DESC)",
+		"CREATE INDEX IF NOT EXISTS idx_notifications_user_type ON notifications(user_id, type, created_at DESC)",
+		"CREATE INDEX IF NOT EXISTS idx_notifications_user_project ON notifications(user_id, project_id, created_at DESC)",
+		"CREATE INDEX IF NOT EXISTS idx_notifications_read_created ON notifications(is_read, created_at)",
+		"CREATE INDEX IF NOT EXISTS idx_webhooks_project ON webhooks(project_id)",
+		"CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_webhook ON webhook_deliveries(webhook_id, created_at DESC)",
+		"CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_status_next ON webhook_deliveries(status, next_attempt_at)",
+		"CREATE INDEX IF NOT EXISTS idx_webhook_delivery_attempts_delivery ON webhook_delivery_attempts(delivery_id)",
+		"CREATE INDEX IF NOT EXISTS idx_hub_events_created ON hub_events(created_at)",
 	}
 
 	for _, index := range indexes {
@@ -287,12 +502,18 @@ func (db *DB) createTables() error {
 }
 
 func (db *DB) migrateLegacySchema() error {
+	hadNotificationProject, err := db.columnExists("notifications", "project_id")
+	if err != nil {
+		return err
+	}
+
 	requiredColumns := map[string]map[string]string{
 		"projects": {
 			"owner_id": "TEXT",
 		},
 		"stages": {
-			"user_id": "TEXT",
+			"user_id":  "TEXT",
+			"is_final": "INTEGER DEFAULT 0",
 		},
 		"tasks": {
 			"user_id":     "TEXT",
@@ -302,7 +523,12 @@ func (db *DB) migrateLegacySchema() error {
 			"assigned_to": "TEXT",
 		},
 		"messages": {
-			"user_id": "TEXT",
+			"user_id":           "TEXT",
+			"parent_message_id": "INTEGER",
+			"edited_at":         "DATETIME",
+		},
+		"notifications": {
+			"project_id": "INTEGER",
 		},
 	}
 
@@ -334,6 +560,13 @@ func (db *DB) migrateLegacySchema() error {
 		return fmt.Errorf("failed to backfill project_members: %v", err)
 	}
 
+	// Notifications created before project_id existed get it from their entity
+	if !hadNotificationProject {
+		if err := db.backfillNotificationProjects(); err != nil {
+			return fmt.Errorf("failed to backfill notification projects: %v", err)
+		}
+	}
+
 	return nil
 }
 
@@ -439,6 +672,27 @@ func (db *DB) backfillProjectMembers() error {
 	return nil
 }
 
+// backfillNotificationProjects sets project_id on existing notifications from
+// the project, task, comment or chat message they point to
+func (db *DB) backfillNotificationProjects() error {
+	result, err := db.Exec(`
+		UPDATE notifications SET project_id = CASE related_entity_type
+			WHEN 'project' THEN related_entity_id
+			WHEN 'task' THEN (SELECT st.project_id FROM tasks t JOIN stages st ON st.id = t.stage_id WHERE t.id = notifications.related_entity_id)
+			WHEN 'comment' THEN (SELECT st.project_id FROM comments c JOIN tasks t ON t.id = c.task_id JOIN stages st ON st.id = t.stage_id WHERE c.id = notifications.related_entity_id)
+			WHEN 'message' THEN (SELECT m.project_id FROM messages m WHERE m.id = notifications.related_entity_id)
+		END
+		WHERE project_id IS NULL
+	`)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, _ := result.RowsAffected()
+	log.Printf("Database migration: backfilled project_id on %d notifications", rowsAffected)
+	return nil
+}
+
 func (db *DB) columnExists(tableName, columnName string) (bool, error) {
 	rows, err := db.Query(fmt.Sprintf("PRAGMA table_info(%s)", tableName))
 	if err != nil {
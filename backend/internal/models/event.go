@@ -0,0 +1,99 @@
+package models
+
+import "time"
+
+// BoardEventVersion is bumped whenever the shape of an event payload changes
+// in a way clients must handle differently
+const BoardEventVersion = 1
+
+// BoardEventType identifies a real-time board change
+type BoardEventType string
+
+// Board event types pushed over the project hub
+const (
+	BoardTaskCreated  BoardEventType = "task.created"
+	BoardTaskUpdated  BoardEventType = "task.updated"
+	BoardTaskMoved    BoardEventType = "task.moved"
+	BoardTaskAssigned BoardEventType = "task.assigned"
+	BoardTaskDeleted  BoardEventType = "task.deleted"
+
+	BoardStagesReordered BoardEventType = "stages.reordered"
+
+	BoardTaskLabelAdded   BoardEventType = "task.label_added"
+	BoardTaskLabelRemoved BoardEventType = "task.label_removed"
+
+	BoardCommentCreated BoardEventType = "comment.created"
+	BoardCommentUpdated BoardEventType = "comment.updated"
+	BoardCommentDeleted BoardEventType = "comment.deleted"
+
+	BoardSubtaskCreated BoardEventType = "subtask.created"
+	BoardSubtaskUpdated BoardEventType = "subtask.updated"
+	BoardSubtaskDeleted BoardEventType = "subtask.deleted"
+)
+
+// BoardEvent is the versioned envelope for every board change sent to
+// WebSocket clients. Data holds one of the *EventData types below.
+type BoardEvent struct {
+	Type       BoardEventType `json:"type"`
+	Version    int            `json:"version"`
+	ProjectID  int64          `json:"project_id"`
+	ActorID    string         `json:"actor_id"`
+	Data       interface{}    `json:"data"`
+	OccurredAt time.Time      `json:"occurred_at"`
+}
+
+// TaskEventData is the payload of task.created, task.updated and task.assigned
+type TaskEventData struct {
+	Task *Task `json:"task"`
+}
+
+// TaskMovedEventData is the payload of task.moved
+type TaskMovedEventData struct {
+	TaskID       int64 `json:"task_id"`
+	FromStageID  int64 `json:"from_stage_id"`
+	ToStageID    int64 `json:"to_stage_id"`
+	FromPosition int   `json:"from_position"`
+	ToPosition   int   `json:"to_position"`
+	Task         *Task `json:"task"`
+}
+
+// TaskDeletedEventData is the payload of task.deleted
+type TaskDeletedEventData struct {
+	TaskID  int64 `json:"task_id"`
+	StageID int64 `json:"stage_id"`
+}
+
+// StagesReorderedEventData is the payload of stages.reordered
+type StagesReorderedEventData struct {
+	StageIDs []int64 `json:"stage_ids"`
+	Stages   []Stage `json:"stages"`
+}
+
+// TaskLabelEventData is the payload of task.label_added and task.label_removed
+type TaskLabelEventData struct {
+	TaskID  int64  `json:"task_id"`
+	LabelID int64  `json:"label_id"`
+	Label   *Label `json:"label,omitempty"`
+}
+
+// CommentEventData is the payload of comment.created and comment.updated
+type CommentEventData struct {
+	Comment *Comment `json:"comment"`
+}
+
+// CommentDeletedEventData is the payload of comment.deleted
+type CommentDeletedEventData struct {
+	CommentID int64 `json:"comment_id"`
+	TaskID    int64 `json:"task_id"`
+}
+
+// SubtaskEventData is the payload of subtask.created and subtask.updated
+type SubtaskEventData struct {
+	Subtask *Subtask `json:"subtask"`
+}
+
+// SubtaskDeletedEventData is the payload of subtask.deleted
+type SubtaskDeletedEventData struct {
+	SubtaskID int64 `json:"subtask_id"`
+	TaskID    int64 `json:"task_id"`
+}
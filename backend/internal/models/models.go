@@ -41,6 +41,13 @@ type ProjectMemberAPIResponse struct {
 	Role   string `json:"role"`
 }
 
+// ProjectPresence is an online member returned by GET /api/projects/:id/presence
+type ProjectPresence struct {
+	UserID string `json:"user_id"`
+	Name   string `json:"name"`
+	Role   string `json:"role"`
+}
+
 // StageTaskCount represents tasks count for a single stage
 type StageTaskCount struct {
 	StageID   int64  `json:"stage_id"`
@@ -93,6 +100,25 @@ const (
 	ActivityCommentDeleted ActivityAction = "comment_deleted"
 )
 
+// ActivityActions lists every activity action, in the order above
+var ActivityActions = []ActivityAction{
+	ActivityProjectCreated, ActivityProjectUpdated, ActivityProjectDeleted,
+	ActivityMemberAdded, ActivityMemberRemoved, ActivityMemberJoined,
+	ActivityTaskCreated, ActivityTaskUpdated, ActivityTaskDeleted, ActivityTaskAssigned, ActivityTaskMoved,
+	ActivityLabelCreated, ActivityLabelDeleted, ActivityLabelAssigned, ActivityLabelRemoved,
+	ActivityCommentAdded, ActivityCommentDeleted,
+}
+
+// IsValid reports whether the action is one of ActivityActions
+func (a ActivityAction) IsValid() bool {
+	for _, action := range ActivityActions {
+		if a == action {
+			return true
+		}
+	}
+	return false
+}
+
 // Entity types
 const (
 	EntityProject EntityType = "project"
@@ -101,6 +127,7 @@ const (
 	EntityLabel   EntityType = "label"
 	EntityComment EntityType = "comment"
 	EntityStage   EntityType = "stage"
+	EntityMessage EntityType = "message"
 )
 
 // ActivityLog represents an activity log entry
@@ -180,6 +207,10 @@ type Project struct {
 	Description string    `json:"description"`
 	CreatedAt   time.Time `json:"created_at"`
 	UpdatedAt   time.Time `json:"updated_at"`
+
+	// UnreadCount is the number of chat messages from others the requesting
+	// user has not read; only set when listing the user's projects
+	UnreadCount *int `json:"unread_count,omitempty"`
 }
 
 // Stage represents a stage/column in a project board
@@ -260,12 +291,32 @@ type Subtask struct {
 
 // Message represents a chat message in a project
 type Message struct {
-	ID         int64     `json:"id"`
-	UserID     string    `json:"user_id"`
-	ProjectID  int64     `json:"project_id"`
-	SenderName string    `json:"sender_name"`
-	Content    string    `json:"content"`
-	CreatedAt  time.Time `json:"created_at"`
+	ID              int64                    `json:"id"`
+	UserID          string                   `json:"user_id"`
+	ProjectID       int64                    `json:"project_id"`
+	ParentMessageID *int64                   `json:"parent_message_id"`
+	SenderName      string                   `json:"sender_name"`
+	Content         string                   `json:"content"`
+	CreatedAt       time.Time                `json:"created_at"`
+	EditedAt        *time.Time               `json:"edited_at"`
+	ReplyCount      int                      `json:"reply_count"`
+	Reactions       []MessageReactionSummary `json:"reactions"`
+}
+
+// MessageRead is a member's read position in a project's chat: every message
+// up to and including LastReadMessageID has been read
+type MessageRead struct {
+	UserID            string    `json:"user_id"`
+	ProjectID         int64     `json:"project_id"`
+	LastReadMessageID int64     `json:"last_read_message_id"`
+	ReadAt            time.Time `json:"read_at"`
+}
+
+// MessageReactionSummary groups the reactions on a message by emoji
+type MessageReactionSummary struct {
+	Emoji   string   `json:"emoji"`
+	Count   int      `json:"count"`
+	UserIDs []string `json:"user_ids"`
 }
 
 // Label represents a label/tag for tasks within a project
@@ -310,8 +361,50 @@ const (
 	NotificationDeadlineNear  NotificationType = "deadline_near"
 	NotificationTaskCompleted NotificationType = "task_completed"
 	NotificationCommentAdded  NotificationType = "comment_added"
+	NotificationMentioned     NotificationType = "mentioned"
+	NotificationOverdue       NotificationType = "overdue"
+	NotificationTaskUpdated   NotificationType = "task_updated"
 )
 
+// NotificationTypes lists every notification type users can set preferences for
+var NotificationTypes = []NotificationType{
+	NotificationMemberAdded,
+	NotificationTaskAssigned,
+	NotificationDeadlineNear,
+	NotificationOverdue,
+	NotificationTaskCompleted,
+	NotificationTaskUpdated,
+	NotificationCommentAdded,
+	NotificationMentioned,
+}
+
+// IsValidNotificationType reports whether t is a known notification type
+func IsValidNotificationType(t NotificationType) bool {
+	for _, known := range NotificationTypes {
+		if t == known {
+			return true
+		}
+	}
+	return false
+}
+
+// NotificationPreference turns one notification type on or off for the
+// in-app and email channels, for all projects or for one project
+type NotificationPreference struct {
+	Type      NotificationType `json:"type"`
+	ProjectID *int64           `json:"project_id,omitempty"`
+	InApp     bool             `json:"in_app"`
+	Email     bool             `json:"email"`
+}
+
+// NotificationPreferenceUpdate changes one notification type's channels; a
+// channel left nil keeps its current value
+type NotificationPreferenceUpdate struct {
+	Type  NotificationType `json:"type"`
+	InApp *bool            `json:"in_app"`
+	Email *bool            `json:"email"`
+}
+
 // Notification represents a user notification
 type Notification struct {
 	ID                int64            `json:"id"`
@@ -324,6 +417,87 @@ type Notification struct {
 	CreatedAt         time.Time        `json:"created_at"`
 }
 
+// TaskWatcher is a user who follows a task's changes
+type TaskWatcher struct {
+	TaskID    int64     `json:"task_id"`
+	UserID    string    `json:"user_id"`
+	Name      string    `json:"name"`
+	Email     string    `json:"email"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Mention records that a user was referenced with @name or @email in a
+// task comment or a chat message
+type Mention struct {
+	ID          int64      `json:"id"`
+	ProjectID   int64      `json:"project_id"`
+	ProjectName string     `json:"project_name"`
+	UserID      string     `json:"user_id"`
+	AuthorID    string     `json:"author_id"`
+	AuthorName  string     `json:"author_name"`
+	EntityType  EntityType `json:"entity_type"`
+	EntityID    int64      `json:"entity_id"`
+	TaskID      *int64     `json:"task_id"`
+	Excerpt     string     `json:"excerpt"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
+
+// Reminder setting sources, from most to least specific
+const (
+	ReminderSourceUser    = "user"
+	ReminderSourceProject = "project"
+	ReminderSourceDefault = "default"
+)
+
+// ReminderSettings lists how long before a task's deadline its assignee is
+// reminded. An empty list turns reminders off.
+type ReminderSettings struct {
+	OffsetsMinutes []int  `json:"offsets_minutes"`
+	Source         string `json:"source"`
+}
+
+// DigestFrequency is how often a user's notification emails go out
+type DigestFrequency string
+
+// Digest frequencies
+const (
+	DigestImmediate DigestFrequency = "immediate"
+	DigestDaily     DigestFrequency = "daily"
+	DigestWeekly    DigestFrequency = "weekly"
+)
+
+// IsValidDigestFrequency reports whether f is a known digest frequency
+func IsValidDigestFrequency(f DigestFrequency) bool {
+	return f == DigestImmediate || f == DigestDaily || f == DigestWeekly
+}
+
+// DigestSettings is the user's choice between one email per notification
+// and a daily or weekly summary
+type DigestSettings struct {
+	Frequency    DigestFrequency `json:"frequency"`
+	LastSentAt   *time.Time      `json:"last_sent_at"`
+	NextDigestAt *time.Time      `json:"next_digest_at,omitempty"`
+}
+
+// Digest summarises a user's unread notifications and their projects'
+// activity over a period
+type Digest struct {
+	UserID   string          `json:"user_id"`
+	Since    time.Time       `json:"since"`
+	Until    time.Time       `json:"until"`
+	Projects []DigestProject `json:"projects"`
+}
+
+// DigestProject is one project's part of a digest. Notifications that do
+// not belong to a project are listed under ProjectID 0.
+type DigestProject struct {
+	ProjectID     int64          `json:"project_id"`
+	ProjectName   string         `json:"project_name"`
+	Notifications []Notification `json:"notifications"`
+	Activity      []ActivityLog  `json:"activity"`
+	MoreActivity  int            `json:"more_activity"`
+}
+
 // NotificationResponse is the paginated response for notifications
 type NotificationResponse struct {
 	ID                int64  `json:"id"`
@@ -336,6 +510,13 @@ type NotificationResponse struct {
 	CreatedAt         string `json:"created_at,omitempty"`
 }
 
+// NotificationStreamEvent is the data of a notification stream event: a new
+// notification (absent for unread_count events) and the user's unread count
+type NotificationStreamEvent struct {
+	Notification *NotificationResponse `json:"notification,omitempty"`
+	UnreadCount  int64                 `json:"unread_count"`
+}
+
 // NotificationListResponse is the paginated list response
 type NotificationListResponse struct {
 	Success     bool                   `json:"success"`
@@ -0,0 +1,83 @@
+package models
+
+import "time"
+
+// WebhookPingEvent is the event sent by the "send test event" endpoint
+const WebhookPingEvent = "ping"
+
+// WebhookDeliveryStatus is where a webhook delivery is in its retries
+type WebhookDeliveryStatus string
+
+const (
+	// WebhookDeliveryPending is waiting for its first or next attempt
+	WebhookDeliveryPending WebhookDeliveryStatus = "pending"
+	// WebhookDeliverySucceeded got a 2xx response
+	WebhookDeliverySucceeded WebhookDeliveryStatus = "succeeded"
+	// WebhookDeliveryFailed failed every attempt and is not retried again
+	WebhookDeliveryFailed WebhookDeliveryStatus = "failed"
+)
+
+// Webhook is a URL that receives a project's activity as signed JSON POSTs
+type Webhook struct {
+	ID        int64            `json:"id"`
+	ProjectID int64            `json:"project_id"`
+	URL       string           `json:"url"`
+	Events    []ActivityAction `json:"events"`
+	Active    bool             `json:"active"`
+	CreatedBy string           `json:"created_by"`
+	CreatedAt time.Time        `json:"created_at"`
+	UpdatedAt time.Time        `json:"updated_at"`
+
+	// Secret signs the payloads; it is only returned when the webhook is
+	// created
+	Secret string `json:"secret,omitempty"`
+}
+
+// Subscribes reports whether the webhook wants events for the action
+func (w *Webhook) Subscribes(action ActivityAction) bool {
+	for _, event := range w.Events {
+		if event == action {
+			return true
+		}
+	}
+	return false
+}
+
+// WebhookDelivery is one event sent to a webhook, with every attempt made
+// to deliver it
+type WebhookDelivery struct {
+	ID            int64                    `json:"id"`
+	WebhookID     int64                    `json:"webhook_id"`
+	Event         string                   `json:"event"`
+	Payload       string                   `json:"payload"`
+	Status        WebhookDeliveryStatus    `json:"status"`
+	Attempts      int                      `json:"attempts"`
+	MaxAttempts   int                      `json:"max_attempts"`
+	NextAttemptAt *time.Time               `json:"next_attempt_at,omitempty"`
+	DeliveredAt   *time.Time               `json:"delivered_at,omitempty"`
+	CreatedAt     time.Time                `json:"created_at"`
+	UpdatedAt     time.Time                `json:"updated_at"`
+	AttemptLog    []WebhookDeliveryAttempt `json:"attempt_log,omitempty"`
+}
+
+// WebhookDeliveryAttempt is the outcome of one HTTP request for a delivery.
+// ResponseStatus is 0 when no response was received.
+type WebhookDeliveryAttempt struct {
+	ID             int64     `json:"id"`
+	DeliveryID     int64     `json:"delivery_id"`
+	Attempt        int       `json:"attempt"`
+	ResponseStatus int       `json:"response_status"`
+	ResponseBody   string    `json:"response_body,omitempty"`
+	Error          string    `json:"error,omitempty"`
+	DurationMs     int64     `json:"duration_ms"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+// WebhookPayload is the JSON body POSTed to a webhook. It is the same on
+// every attempt; receivers can tell retries apart by the delivery ID header.
+type WebhookPayload struct {
+	Event     string               `json:"event"`
+	ProjectID int64                `json:"project_id"`
+	CreatedAt time.Time            `json:"created_at"`
+	Activity  *ActivityLogResponse `json:"activity,omitempty"`
+}
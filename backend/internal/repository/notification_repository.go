@@ -3,6 +3,7 @@ package repository
 import (
 	"database/sql"
 	"fmt"
+	"strings"
 	"time"
 
 	"backend/internal/models"
@@ -18,12 +19,31 @@ func NewNotificationRepository(db *sql.DB) *NotificationRepository {
 	return &NotificationRepository{db: db}
 }
 
+// NotificationFilter narrows a user's notification list. Zero fields match
+// everything.
+type NotificationFilter struct {
+	Type      models.NotificationType
+	IsRead    *bool
+	ProjectID int64
+}
+
 // CreateNotification creates a new notification
 func (r *NotificationRepository) CreateNotification(userID string, notificationType models.NotificationType, message, entityType string, entityID int64) (*models.Notification, error) {
+	return r.CreateProjectNotification(userID, notificationType, message, entityType, entityID, 0)
+}
+
+// CreateProjectNotification creates a new notification that belongs to a
+// project, so it can be filtered by project; projectID 0 means none
+func (r *NotificationRepository) CreateProjectNotification(userID string, notificationType models.NotificationType, message, entityType string, entityID, projectID int64) (*models.Notification, error) {
+	var project interface{}
+	if projectID > 0 {
+		project = projectID
+	}
+
 	result, err := r.db.Exec(
-		`INSERT INTO notifications (user_id, type, message, related_entity_type, related_entity_id, created_at) 
-		VALUES (?, ?, ?, ?, ?, ?)`,
-		userID, notificationType, message, entityType, entityID, time.Now(),
+		`INSERT INTO notifications (user_id, type, message, related_entity_type, related_entity_id, project_id, created_at) 
+		VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		userID, notificationType, message, entityType, entityID, project, time.Now(),
 	)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create notification: %v", err)
@@ -48,9 +68,30 @@ func (r *NotificationRepository) CreateNotification(userID string, notificationT
 
 // GetUserNotifications retrieves paginated notifications for a user
 func (r *NotificationRepository) GetUserNotifications(userID string, page, limit int) ([]models.Notification, int64, error) {
+	return r.ListNotifications(userID, NotificationFilter{}, page, limit)
+}
+
+// ListNotifications retrieves a user's notifications matching the filter,
+// newest first, with the total number of matches
+func (r *NotificationRepository) ListNotifications(userID string, filter NotificationFilter, page, limit int) ([]models.Notification, int64, error) {
+	where := "WHERE user_id = ?"
+	args := []interface{}{userID}
+	if filter.Type != "" {
+		where += " AND type = ?"
+		args = append(args, filter.Type)
+	}
+	if filter.IsRead != nil {
+		where += " AND is_read = ?"
+		args = append(args, *filter.IsRead)
+	}
+	if filter.ProjectID > 0 {
+		where += " AND project_id = ?"
+		args = append(args, filter.ProjectID)
+	}
+
 	// Get total count
 	var total int64
-	err := r.db.QueryRow("SELECT COUNT(*) FROM notifications WHERE user_id = ?", userID).Scan(&total)
+	err := r.db.QueryRow("SELECT COUNT(*) FROM notifications "+where, args...).Scan(&total)
 	if err != nil {
 		return nil, 0, fmt.Errorf("failed to count notifications: %v", err)
 	}
@@ -60,10 +101,10 @@ func (r *NotificationRepository) GetUserNotifications(userID string, page, limit
 	rows, err := r.db.Query(`
 		SELECT id, user_id, type, message, is_read, related_entity_type, related_entity_id, created_at
 		FROM notifications
-		WHERE user_id = ?
-		ORDER BY created_at DESC
+		`+where+`
+		ORDER BY created_at DESC, id DESC
 		LIMIT ? OFFSET ?
-	`, userID, limit, offset)
+	`, append(args, limit, offset)...)
 	if err != nil {
 		return nil, 0, fmt.Errorf("failed to get notifications: %v", err)
 	}
@@ -131,6 +172,91 @@ func (r *NotificationRepository) MarkAllAsRead(userID string) (int64, error) {
 	return rowsAffected, nil
 }
 
+// MarkAsUnread marks a notification as unread and reports whether the user
+// has a notification with that ID
+func (r *NotificationRepository) MarkAsUnread(notificationID int64, userID string) (bool, error) {
+	result, err := r.db.Exec(
+		"UPDATE notifications SET is_read = 0 WHERE id = ? AND user_id = ?",
+		notificationID, userID,
+	)
+	if err != nil {
+		return false, fmt.Errorf("failed to mark notification as unread: %v", err)
+	}
+
+	rowsAffected, _ := result.RowsAffected()
+	return rowsAffected > 0, nil
+}
+
+// SetReadForIDs marks the user's notifications with the given IDs read or
+// unread and returns how many were changed
+func (r *NotificationRepository) SetReadForIDs(userID string, ids []int64, isRead bool) (int64, error) {
+	placeholders, args := idPlaceholders(ids)
+	result, err := r.db.Exec(
+		"UPDATE notifications SET is_read = ? WHERE user_id = ? AND is_read != ? AND id IN ("+placeholders+")",
+		append([]interface{}{isRead, userID, isRead}, args...)...,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("failed to update notifications: %v", err)
+	}
+
+	rowsAffected, _ := result.RowsAffected()
+	return rowsAffected, nil
+}
+
+// DeleteNotification deletes one of the user's notifications and reports
+// whether it existed
+func (r *NotificationRepository) DeleteNotification(notificationID int64, userID string) (bool, error) {
+	result, err := r.db.Exec("DELETE FROM notifications WHERE id = ? AND user_id = ?", notificationID, userID)
+	if err != nil {
+		return false, fmt.Errorf("failed to delete notification: %v", err)
+	}
+
+	rowsAffected, _ := result.RowsAffected()
+	return rowsAffected > 0, nil
+}
+
+// DeleteNotifications deletes the user's notifications with the given IDs
+// and returns how many were deleted
+func (r *NotificationRepository) DeleteNotifications(userID string, ids []int64) (int64, error) {
+	placeholders, args := idPlaceholders(ids)
+	result, err := r.db.Exec(
+		"DELETE FROM notifications WHERE user_id = ? AND id IN ("+placeholders+")",
+		append([]interface{}{userID}, args...)...,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete notifications: %v", err)
+	}
+
+	rowsAffected, _ := result.RowsAffected()
+	return rowsAffected, nil
+}
+
+// PurgeReadBefore deletes every read notification created before cutoff and
+// returns how many were deleted
+func (r *NotificationRepository) PurgeReadBefore(cutoff time.Time) (int64, error) {
+	result, err := r.db.Exec(
+		"DELETE FROM notifications WHERE is_read = 1 AND julianday(created_at) < julianday(?)",
+		cutoff.UTC(),
+	)
+	if err != nil {
+		return 0, fmt.Errorf("failed to purge notifications: %v", err)
+	}
+
+	rowsAffected, _ := result.RowsAffected()
+	return rowsAffected, nil
+}
+
+// idPlaceholders returns "?, ?, ..." for the IDs and the IDs as query args
+func idPlaceholders(ids []int64) (string, []interface{}) {
+	placeholders := make([]string, len(ids))
+	args := make([]interface{}, len(ids))
+	for i, id := range ids {
+		placeholders[i] = "?"
+		args[i] = id
+	}
+	return strings.Join(placeholders, ", "), args
+}
+
 // GetUnreadCount returns the count of unread notifications for a user
 func (r *NotificationRepository) GetUnreadCount(userID string) (int64, error) {
 	var count int64
@@ -187,3 +313,54 @@ func (r *NotificationRepository) GetNotificationByID(notificationID int64) (*mod
 
 	return &n, nil
 }
+
+// GetNotificationsAfter retrieves up to limit of a user's notifications with
+// an ID above afterID, oldest first
+func (r *NotificationRepository) GetNotificationsAfter(userID string, afterID int64, limit int) ([]models.Notification, error) {
+	rows, err := r.db.Query(`
+		SELECT id, user_id, type, message, is_read, related_entity_type, related_entity_id, created_at
+		FROM notifications
+		WHERE user_id = ? AND id > ?
+		ORDER BY id ASC
+		LIMIT ?
+	`, userID, afterID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get notifications: %v", err)
+	}
+	defer rows.Close()
+
+	var notifications []models.Notification
+	for rows.Next() {
+		var n models.Notification
+		var isRead int
+		var entityType sql.NullString
+		var entityID sql.NullInt64
+
+		if err := rows.Scan(&n.ID, &n.UserID, &n.Type, &n.Message, &isRead, &entityType, &entityID, &n.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan notification: %v", err)
+		}
+
+		n.IsRead = isRead == 1
+		if entityType.Valid {
+			n.RelatedEntityType = entityType.String
+		}
+		if entityID.Valid {
+			n.RelatedEntityID = entityID.Int64
+		}
+
+		notifications = append(notifications, n)
+	}
+
+	return notifications, rows.Err()
+}
+
+// GetLatestNotificationID returns the ID of the user's newest notification,
+// or 0 when they have none
+func (r *NotificationRepository) GetLatestNotificationID(userID string) (int64, error) {
+	var id int64
+	err := r.db.QueryRow("SELECT COALESCE(MAX(id), 0) FROM notifications WHERE user_id = ?", userID).Scan(&id)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get latest notification: %v", err)
+	}
+	return id, nil
+}
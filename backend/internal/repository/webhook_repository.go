@@ -0,0 +1,315 @@
+package repository
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"backend/internal/models"
+)
+
+// WebhookRepository handles database operations for webhooks and their
+// deliveries
+type WebhookRepository struct {
+	db *sql.DB
+}
+
+// NewWebhookRepository creates a new WebhookRepository
+func NewWebhookRepository(db *sql.DB) *WebhookRepository {
+	return &WebhookRepository{db: db}
+}
+
+const webhookColumns = `id, project_id, url, secret, events, active, created_by, created_at, updated_at`
+
+// CreateWebhook stores a new webhook
+func (r *WebhookRepository) CreateWebhook(projectID int64, url, secret string, events []models.ActivityAction, active bool, createdBy string) (*models.Webhook, error) {
+	encoded, err := json.Marshal(events)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode webhook events: %v", err)
+	}
+
+	now := time.Now().UTC()
+	result, err := r.db.Exec(`
+		INSERT INTO webhooks (project_id, url, secret, events, active, created_by, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+	`, projectID, url, secret, string(encoded), active, createdBy, now, now)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create webhook: %v", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get last insert id: %v", err)
+	}
+	return r.GetWebhook(id)
+}
+
+// GetWebhook retrieves a webhook, or nil when it does not exist
+func (r *WebhookRepository) GetWebhook(id int64) (*models.Webhook, error) {
+	webhook, err := scanWebhook(r.db.QueryRow("SELECT "+webhookColumns+" FROM webhooks WHERE id = ?", id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get webhook: %v", err)
+	}
+	return webhook, nil
+}
+
+// GetProjectWebhooks retrieves a project's webhooks, oldest first. With
+// activeOnly, disabled webhooks are left out.
+func (r *WebhookRepository) GetProjectWebhooks(projectID int64, activeOnly bool) ([]models.Webhook, error) {
+	query := "SELECT " + webhookColumns + " FROM webhooks WHERE project_id = ?"
+	if activeOnly {
+		query += " AND active = 1"
+	}
+	rows, err := r.db.Query(query+" ORDER BY id ASC", projectID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get webhooks: %v", err)
+	}
+	defer rows.Close()
+
+	webhooks := []models.Webhook{}
+	for rows.Next() {
+		webhook, err := scanWebhook(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan webhook: %v", err)
+		}
+		webhooks = append(webhooks, *webhook)
+	}
+	return webhooks, rows.Err()
+}
+
+// UpdateWebhook saves a webhook's URL, events and active flag
+func (r *WebhookRepository) UpdateWebhook(webhook *models.Webhook) error {
+	encoded, err := json.Marshal(webhook.Events)
+	if err != nil {
+		return fmt.Errorf("failed to encode webhook events: %v", err)
+	}
+	webhook.UpdatedAt = time.Now().UTC()
+	_, err = r.db.Exec(`
+		UPDATE webhooks SET url = ?, events = ?, active = ?, updated_at = ? WHERE id = ?
+	`, webhook.URL, string(encoded), webhook.Active, webhook.UpdatedAt, webhook.ID)
+	if err != nil {
+		return fmt.Errorf("failed to update webhook: %v", err)
+	}
+	return nil
+}
+
+// DeleteWebhook removes a webhook together with its delivery log
+func (r *WebhookRepository) DeleteWebhook(id int64) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to delete webhook: %v", err)
+	}
+	defer tx.Rollback()
+
+	statements := []string{
+		"DELETE FROM webhook_delivery_attempts WHERE delivery_id IN (SELECT id FROM webhook_deliveries WHERE webhook_id = ?)",
+		"DELETE FROM webhook_deliveries WHERE webhook_id = ?",
+		"DELETE FROM webhooks WHERE id = ?",
+	}
+	for _, statement := range statements {
+		if _, err := tx.Exec(statement, id); err != nil {
+			return fmt.Errorf("failed to delete webhook: %v", err)
+		}
+	}
+	return tx.Commit()
+}
+
+type webhookScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanWebhook(row webhookScanner) (*models.Webhook, error) {
+	var webhook models.Webhook
+	var events string
+	err := row.Scan(&webhook.ID, &webhook.ProjectID, &webhook.URL, &webhook.Secret, &events, &webhook.Active,
+		&webhook.CreatedBy, &webhook.CreatedAt, &webhook.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal([]byte(events), &webhook.Events); err != nil {
+		return nil, fmt.Errorf("invalid events on webhook %d: %v", webhook.ID, err)
+	}
+	return &webhook, nil
+}
+
+// CreateDelivery queues an event for a webhook, due right away
+func (r *WebhookRepository) CreateDelivery(webhookID int64, event, payload string, maxAttempts int) (*models.WebhookDelivery, error) {
+	now := time.Now().UTC()
+	result, err := r.db.Exec(`
+		INSERT INTO webhook_deliveries (webhook_id, event, payload, status, max_attempts, next_attempt_at, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+	`, webhookID, event, payload, models.WebhookDeliveryPending, maxAttempts, now, now, now)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create webhook delivery: %v", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get last insert id: %v", err)
+	}
+	return r.GetDelivery(id)
+}
+
+// DueDeliveryIDs lists pending deliveries of active webhooks whose next
+// attempt is due, oldest first
+func (r *WebhookRepository) DueDeliveryIDs(now time.Time, limit int) ([]int64, error) {
+	rows, err := r.db.Query(`
+		SELECT id FROM webhook_deliveries
+		WHERE status = ? AND julianday(next_attempt_at) <= julianday(?)
+		  AND webhook_id IN (SELECT id FROM webhooks WHERE active = 1)
+		ORDER BY next_attempt_at ASC, id ASC
+		LIMIT ?
+	`, models.WebhookDeliveryPending, now.UTC(), limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list due webhook deliveries: %v", err)
+	}
+	defer rows.Close()
+
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("failed to scan webhook delivery id: %v", err)
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// ClaimDelivery counts an attempt and holds the delivery until leaseUntil,
+// so no other worker sends it at the same time. It reports false when the
+// delivery is no longer due or its webhook was disabled.
+func (r *WebhookRepository) ClaimDelivery(id int64, now, leaseUntil time.Time) (bool, error) {
+	result, err := r.db.Exec(`
+		UPDATE webhook_deliveries
+		SET attempts = attempts + 1, next_attempt_at = ?, updated_at = ?
+		WHERE id = ? AND status = ? AND julianday(next_attempt_at) <= julianday(?)
+		  AND webhook_id IN (SELECT id FROM webhooks WHERE active = 1)
+	`, leaseUntil.UTC(), now.UTC(), id, models.WebhookDeliveryPending, now.UTC())
+	if err != nil {
+		return false, fmt.Errorf("failed to claim webhook delivery: %v", err)
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to claim webhook delivery: %v", err)
+	}
+	return n > 0, nil
+}
+
+// RecordAttempt logs the outcome of one attempt and moves the delivery to
+// status. nextAttemptAt is only used while the delivery stays pending.
+func (r *WebhookRepository) RecordAttempt(attempt *models.WebhookDeliveryAttempt, status models.WebhookDeliveryStatus, nextAttemptAt time.Time) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to record webhook attempt: %v", err)
+	}
+	defer tx.Rollback()
+
+	attempt.CreatedAt = time.Now().UTC()
+	result, err := tx.Exec(`
+		INSERT INTO webhook_delivery_attempts (delivery_id, attempt, response_status, response_body, error, duration_ms, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+	`, attempt.DeliveryID, attempt.Attempt, attempt.ResponseStatus, attempt.ResponseBody, attempt.Error, attempt.DurationMs, attempt.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to record webhook attempt: %v", err)
+	}
+	attempt.ID, _ = result.LastInsertId()
+
+	var deliveredAt interface{}
+	if status == models.WebhookDeliverySucceeded {
+		deliveredAt = attempt.CreatedAt
+	}
+	_, err = tx.Exec(`
+		UPDATE webhook_deliveries SET status = ?, next_attempt_at = ?, delivered_at = ?, updated_at = ? WHERE id = ?
+	`, status, nextAttemptAt.UTC(), deliveredAt, attempt.CreatedAt, attempt.DeliveryID)
+	if err != nil {
+		return fmt.Errorf("failed to update webhook delivery: %v", err)
+	}
+	return tx.Commit()
+}
+
+const webhookDeliveryColumns = `id, webhook_id, event, payload, status, attempts, max_attempts, next_attempt_at, delivered_at, created_at, updated_at`
+
+// GetDelivery retrieves a delivery without its attempts, or nil when it does
+// not exist
+func (r *WebhookRepository) GetDelivery(id int64) (*models.WebhookDelivery, error) {
+	delivery, err := scanWebhookDelivery(r.db.QueryRow("SELECT "+webhookDeliveryColumns+" FROM webhook_deliveries WHERE id = ?", id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get webhook delivery: %v", err)
+	}
+	return delivery, nil
+}
+
+// GetDeliveries lists a webhook's deliveries, newest first
+func (r *WebhookRepository) GetDeliveries(webhookID int64, limit, offset int) ([]models.WebhookDelivery, int64, error) {
+	var total int64
+	if err := r.db.QueryRow("SELECT COUNT(*) FROM webhook_deliveries WHERE webhook_id = ?", webhookID).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("failed to count webhook deliveries: %v", err)
+	}
+
+	rows, err := r.db.Query("SELECT "+webhookDeliveryColumns+" FROM webhook_deliveries WHERE webhook_id = ? ORDER BY id DESC LIMIT ? OFFSET ?",
+		webhookID, limit, offset)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to list webhook deliveries: %v", err)
+	}
+	defer rows.Close()
+
+	deliveries := []models.WebhookDelivery{}
+	for rows.Next() {
+		delivery, err := scanWebhookDelivery(rows)
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to scan webhook delivery: %v", err)
+		}
+		deliveries = append(deliveries, *delivery)
+	}
+	return deliveries, total, rows.Err()
+}
+
+// GetAttempts lists a delivery's attempts in the order they were made
+func (r *WebhookRepository) GetAttempts(deliveryID int64) ([]models.WebhookDeliveryAttempt, error) {
+	rows, err := r.db.Query(`
+		SELECT id, delivery_id, attempt, response_status, response_body, error, duration_ms, created_at
+		FROM webhook_delivery_attempts
+		WHERE delivery_id = ?
+		ORDER BY id ASC
+	`, deliveryID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list webhook attempts: %v", err)
+	}
+	defer rows.Close()
+
+	attempts := []models.WebhookDeliveryAttempt{}
+	for rows.Next() {
+		var a models.WebhookDeliveryAttempt
+		if err := rows.Scan(&a.ID, &a.DeliveryID, &a.Attempt, &a.ResponseStatus, &a.ResponseBody, &a.Error, &a.DurationMs, &a.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan webhook attempt: %v", err)
+		}
+		attempts = append(attempts, a)
+	}
+	return attempts, rows.Err()
+}
+
+func scanWebhookDelivery(row webhookScanner) (*models.WebhookDelivery, error) {
+	var d models.WebhookDelivery
+	var nextAttemptAt time.Time
+	var deliveredAt sql.NullTime
+	err := row.Scan(&d.ID, &d.WebhookID, &d.Event, &d.Payload, &d.Status, &d.Attempts, &d.MaxAttempts,
+		&nextAttemptAt, &deliveredAt, &d.CreatedAt, &d.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	if d.Status == models.WebhookDeliveryPending {
+		d.NextAttemptAt = &nextAttemptAt
+	}
+	if deliveredAt.Valid {
+		d.DeliveredAt = &deliveredAt.Time
+	}
+	return &d, nil
+}
@@ -10,6 +10,8 @@ import (
 	authmiddleware "backend/internal/auth/middleware"
 	"backend/internal/auth/repository"
 	"backend/internal/auth/services"
+	"backend/internal/chat"
+	"backend/internal/config"
 	"backend/internal/controllers"
 	"backend/internal/database"
 	"backend/internal/middleware"
@@ -19,7 +21,7 @@ import (
 )
 
 // SetupRoutes configures all API routes
-func SetupRoutes(router *mux.Router, db *database.DB) {
+func SetupRoutes(router *mux.Router, db *database.DB, hub *chat.Hub, cfg *config.Config) {
 	// Health check endpoint
 	router.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "application/json")
@@ -35,29 +37,97 @@ func SetupRoutes(router *mux.Router, db *database.DB) {
 	if err := identityRepo.InitTable(); err != nil {
 		log.Printf("Warning: failed to initialize auth identities table: %v", err)
 	}
+	sessionRepo := repository.NewSessionRepository(db.DB)
+	if err := sessionRepo.InitTable(); err != nil {
+		log.Printf("Warning: failed to initialize sessions table: %v", err)
+	}
+	twoFactorRepo := repository.NewTwoFactorRepository(db.DB)
+	if err := twoFactorRepo.InitTable(); err != nil {
+		log.Printf("Warning: failed to initialize two-factor tables: %v", err)
+	}
+	verificationRepo := repository.NewEmailVerificationRepository(db.DB)
+	if err := verificationRepo.InitTable(); err != nil {
+		log.Printf("Warning: failed to initialize email verifications table: %v", err)
+	}
+	otpRepo := repository.NewOTPRepository(db.DB)
+	if err := otpRepo.InitTable(); err != nil {
+		log.Printf("Warning: failed to initialize password reset OTPs table: %v", err)
+	}
+	accessTokenRepo := repository.NewAccessTokenRepository(db.DB)
+	if err := accessTokenRepo.InitTable(); err != nil {
+		log.Printf("Warning: failed to initialize personal access tokens table: %v", err)
+	}
 
 	// Initialize auth services
 	jwtSecret := services.GetEnvJWTSecret()
-	jwtService := services.NewJWTService(jwtSecret, 24) // 24 hour expiration
-	otpService := services.NewOTPService()
+	jwtService := services.NewJWTService(jwtSecret, 24)
+	jwtService.SetAccessTokenTTL(cfg.AccessTokenTTL)
+	sessionService := services.NewSessionService(sessionRepo, userRepo, jwtService, cfg.RefreshTokenTTL)
+	jwtService.SetSessionValidator(sessionService)
+	sessionService.StartCleanup(6 * time.Hour)
+	accessTokenService := services.NewAccessTokenService(accessTokenRepo, userRepo)
+	jwtService.SetPersonalTokenAuthenticator(accessTokenService)
+	otpService := services.NewOTPService(otpRepo)
 	emailService := services.NewEmailService()
+	outboxRepo := repository.NewEmailOutboxRepository(db.DB)
+	if err := outboxRepo.InitTable(); err != nil {
+		log.Printf("Warning: failed to initialize email outbox table: %v", err)
+	}
+	emailOutbox := services.NewEmailOutbox(outboxRepo, emailService.Mailer())
+	emailService.SetOutbox(emailOutbox)
+	emailOutbox.Start(30 * time.Second)
+	emailOutbox.StartRetention(cfg.EmailOutboxRetention, 6*time.Hour)
 	googleService := services.NewGoogleAuthService()
 	oauthStateService := services.NewOAuthStateService(10 * time.Minute)
 	authService := services.NewAuthService(userRepo, identityRepo, jwtService, otpService, emailService, googleService, oauthStateService)
+	authService.SetSessionService(sessionService)
+	twoFactorService := services.NewTwoFactorService(twoFactorRepo, userRepo)
+	authService.SetTwoFactorService(twoFactorService)
+	authService.SetAccessTokenService(accessTokenService)
+	verificationService := services.NewEmailVerificationService(verificationRepo, userRepo, emailService, cfg.EmailVerificationTTL)
+	authService.SetEmailVerificationService(verificationService)
 	authController := controller.NewAuthController(authService)
+	authController.SetSessionService(sessionService)
+	authController.SetAccessTokenService(accessTokenService)
+	authController.SetTwoFactorService(twoFactorService)
+	authController.SetEmailVerificationService(verificationService)
 
 	// Initialize business services
 	projectService := projectServices.NewProjectService(db.DB)
 	stageService := projectServices.NewStageService(db.DB)
+	stageService.SetBroadcaster(hub)
 	messageService := projectServices.NewMessageService(db.DB)
+	messageService.SetBroadcaster(hub)
 	projectMemberService := projectServices.NewProjectMemberService(db.DB)
+	projectMemberService.SetConnectionManager(hub)
+	projectMemberService.SetEmailService(emailService)
+	projectMemberService.SetRequireVerifiedEmail(cfg.RequireEmailVerification)
 	activityService := projectServices.NewActivityService(db.DB, projectMemberService)
 	taskService := projectServices.NewTaskService(db.DB, activityService)
+	taskService.SetBroadcaster(hub)
 	commentService := projectServices.NewCommentService(db.DB)
+	commentService.SetBroadcaster(hub)
 	subtaskService := projectServices.NewSubtaskService(db.DB)
+	subtaskService.SetBroadcaster(hub)
 	labelService := projectServices.NewLabelService(db.DB, projectMemberService, activityService)
 	taskLabelService := projectServices.NewTaskLabelService(db.DB, projectMemberService, activityService)
+	taskLabelService.SetBroadcaster(hub)
 	notificationService := projectServices.NewNotificationService(db.DB, emailService)
+	notificationStream := projectServices.NewNotificationStream()
+	notificationService.SetStream(notificationStream)
+	notificationService.SetRequireVerifiedEmail(cfg.RequireEmailVerification)
+	mentionService := projectServices.NewMentionService(db.DB, notificationService)
+	commentService.SetMentionService(mentionService)
+	commentService.SetNotificationService(notificationService)
+	taskService.SetNotificationService(notificationService)
+	activityService.AddObserver(notificationService)
+	taskWatcherService := projectServices.NewTaskWatcherService(db.DB)
+	taskService.SetWatcherService(taskWatcherService)
+	commentService.SetWatcherService(taskWatcherService)
+	messageService.SetMentionService(mentionService)
+	webhookService := projectServices.NewWebhookService(db.DB, projectMemberService)
+	webhookService.SetAllowedHosts(cfg.WebhookAllowedHosts)
+	activityService.AddObserver(webhookService)
 
 	// Initialize controllers
 	projectController := controllers.NewProjectController(projectService)
@@ -71,9 +141,25 @@ func SetupRoutes(router *mux.Router, db *database.DB) {
 	labelController := controllers.NewLabelController(labelService)
 	taskLabelController := controllers.NewTaskLabelController(taskLabelService)
 	notificationController := controllers.NewNotificationController(notificationService)
+	notificationStreamController := controllers.NewNotificationStreamController(notificationService, notificationStream)
+	mentionController := controllers.NewMentionController(mentionService)
+	reminderController := controllers.NewReminderController(notificationService)
+	notificationPreferenceController := controllers.NewNotificationPreferenceController(notificationService)
+	taskWatcherController := controllers.NewTaskWatcherController(taskWatcherService)
+	digestController := controllers.NewDigestController(notificationService)
+	emailOutboxController := controllers.NewEmailOutboxController(emailOutbox)
+	webhookController := controllers.NewWebhookController(webhookService)
 
-	// Start deadline checker background job (runs every 15 minutes)
+	// Start the deadline checker and digest sender background jobs (run every 15 minutes)
 	notificationService.StartDeadlineChecker(15 * time.Minute)
+	notificationService.StartDigestSender(15 * time.Minute)
+	notificationService.StartNotificationRetention(cfg.NotificationRetention, 6*time.Hour)
+
+	// Deliver webhook events as they are queued, and retry failed ones every minute
+	webhookService.Start(time.Minute)
+
+	// Limit guessing at passwords, reset codes and reset tokens
+	authRateLimit := authmiddleware.RateLimitMiddleware(cfg.AuthRateLimit, cfg.AuthRateLimitWindow)
 
 	// Create JWT middleware
 	jwtMiddleware := authmiddleware.JWTAuthMiddleware(jwtService)
@@ -81,19 +167,27 @@ func SetupRoutes(router *mux.Router, db *database.DB) {
 	// Create project access middleware
 	projectAccessMiddleware := middleware.ProjectAccessMiddleware(projectMemberService)
 
+	// WebSocket endpoint (authenticated during the handshake, not by jwtMiddleware)
+	wsHandler := chat.NewWsHandler(hub, jwtService, projectMemberService, messageService, cfg.WSAllowedOrigins)
+	router.HandleFunc("/ws/{projectId}", wsHandler.ServeWs)
+
 	// API Routes
 	api := router.PathPrefix("/api").Subrouter()
 
 	// Auth routes (public - no authentication required)
 	auth := api.PathPrefix("/auth").Subrouter()
 	auth.HandleFunc("/register", authController.Register).Methods("POST")
-	auth.HandleFunc("/login", authController.Login).Methods("POST")
+	auth.Handle("/login", authRateLimit(http.HandlerFunc(authController.Login))).Methods("POST")
 	auth.HandleFunc("/google/id-token", authController.GoogleLoginWithIDToken).Methods("POST")
 	auth.HandleFunc("/google/login", authController.GoogleLoginRedirect).Methods("GET")
 	auth.HandleFunc("/google/callback", authController.GoogleCallback).Methods("GET")
-	auth.HandleFunc("/forgot-password", authController.ForgotPassword).Methods("POST")
-	auth.HandleFunc("/verify-otp", authController.VerifyOTP).Methods("POST")
-	auth.HandleFunc("/reset-password", authController.ResetPassword).Methods("POST")
+	auth.Handle("/forgot-password", authRateLimit(http.HandlerFunc(authController.ForgotPassword))).Methods("POST")
+	auth.Handle("/verify-otp", authRateLimit(http.HandlerFunc(authController.VerifyOTP))).Methods("POST")
+	auth.Handle("/reset-password", authRateLimit(http.HandlerFunc(authController.ResetPassword))).Methods("POST")
+	auth.Handle("/verify-email", authRateLimit(http.HandlerFunc(authController.VerifyEmail))).Methods("POST")
+	auth.HandleFunc("/refresh", authController.Refresh).Methods("POST")
+	auth.HandleFunc("/logout", authController.Logout).Methods("POST")
+	auth.Handle("/2fa/verify", authRateLimit(http.HandlerFunc(authController.VerifyTwoFactor))).Methods("POST")
 
 	// Protected routes - require JWT authentication
 	protected := api.PathPrefix("").Subrouter()
@@ -102,6 +196,40 @@ func SetupRoutes(router *mux.Router, db *database.DB) {
 	// Protected auth route (GET /me, PUT /me)
 	protected.HandleFunc("/auth/me", authController.GetMe).Methods("GET")
 	protected.HandleFunc("/auth/me", authController.UpdateMe).Methods("PUT")
+	protected.HandleFunc("/auth/sessions", authController.ListSessions).Methods("GET")
+	protected.HandleFunc("/auth/sessions", authController.RevokeOtherSessions).Methods("DELETE")
+	protected.HandleFunc("/auth/sessions/{id}", authController.RevokeSession).Methods("DELETE")
+	protected.HandleFunc("/auth/verify-email/resend", authController.ResendVerification).Methods("POST")
+
+	// Two-factor authentication
+	protected.HandleFunc("/auth/2fa", authController.GetTwoFactorStatus).Methods("GET")
+	protected.HandleFunc("/auth/2fa/setup", authController.SetupTwoFactor).Methods("POST")
+	protected.HandleFunc("/auth/2fa/enable", authController.EnableTwoFactor).Methods("POST")
+	protected.HandleFunc("/auth/2fa/disable", authController.DisableTwoFactor).Methods("POST")
+	protected.HandleFunc("/auth/2fa/recovery-codes", authController.RegenerateRecoveryCodes).Methods("POST")
+
+	// Personal access tokens for scripts and CI
+	protected.HandleFunc("/me/tokens", authController.ListAccessTokens).Methods("GET")
+	protected.HandleFunc("/me/tokens", authController.CreateAccessToken).Methods("POST")
+	protected.HandleFunc("/me/tokens/{id}", authController.RevokeAccessToken).Methods("DELETE")
+
+	// Current user's mentions (protected)
+	protected.HandleFunc("/me/mentions", mentionController.GetMyMentions).Methods("GET")
+
+	// Notification preferences (protected)
+	protected.HandleFunc("/me/notification-preferences", notificationPreferenceController.GetPreferences).Methods("GET")
+	protected.HandleFunc("/me/notification-preferences", notificationPreferenceController.UpdatePreferences).Methods("PUT")
+	protected.HandleFunc("/me/notification-preferences", notificationPreferenceController.ResetProjectPreferences).Methods("DELETE")
+	protected.HandleFunc("/me/digest-settings", digestController.GetSettings).Methods("GET")
+	protected.HandleFunc("/me/digest-settings", digestController.UpdateSettings).Methods("PUT")
+
+	// Deadline reminder settings (protected)
+	protected.HandleFunc("/me/reminder-settings", reminderController.GetMySettings).Methods("GET")
+	protected.HandleFunc("/me/reminder-settings", reminderController.UpdateMySettings).Methods("PUT")
+	protected.HandleFunc("/me/reminder-settings", reminderController.ResetMySettings).Methods("DELETE")
+	protected.HandleFunc("/projects/{id}/reminder-settings", reminderController.GetProjectSettings).Methods("GET")
+	protected.HandleFunc("/projects/{id}/reminder-settings", reminderController.UpdateProjectSettings).Methods("PUT")
+	protected.HandleFunc("/projects/{id}/reminder-settings", reminderController.ResetProjectSettings).Methods("DELETE")
 
 	// Project routes (protected)
 	protected.HandleFunc("/projects", projectController.CreateProject).Methods("POST")
@@ -131,6 +259,12 @@ func SetupRoutes(router *mux.Router, db *database.DB) {
 	projectMemberRoutes.HandleFunc("", projectMemberController.GetMembers).Methods("GET")
 	projectMemberRoutes.HandleFunc("/{userId}", projectMemberController.RemoveMember).Methods("DELETE")
 
+	// Presence routes (protected with project access check)
+	presenceRoutes := api.PathPrefix("/projects/{id}/presence").Subrouter()
+	presenceRoutes.Use(jwtMiddleware)
+	presenceRoutes.Use(projectAccessMiddleware)
+	presenceRoutes.HandleFunc("", projectMemberController.GetPresence).Methods("GET")
+
 	// Invite routes (protected)
 	inviteRoutes := api.PathPrefix("/projects/{id}/invites").Subrouter()
 	inviteRoutes.Use(jwtMiddleware)
@@ -167,6 +301,9 @@ func SetupRoutes(router *mux.Router, db *database.DB) {
 	protected.HandleFunc("/tasks/{id}", taskController.DeleteTask).Methods("DELETE")
 	protected.HandleFunc("/tasks/{id}/comments", commentController.CreateComment).Methods("POST")
 	protected.HandleFunc("/tasks/{id}/comments", commentController.GetCommentsByTask).Methods("GET")
+	protected.HandleFunc("/tasks/{id}/watchers", taskWatcherController.GetWatchers).Methods("GET")
+	protected.HandleFunc("/tasks/{id}/watchers", taskWatcherController.WatchTask).Methods("POST")
+	protected.HandleFunc("/tasks/{id}/watchers", taskWatcherController.UnwatchTask).Methods("DELETE")
 	protected.HandleFunc("/tasks/{id}/subtasks", subtaskController.CreateSubtask).Methods("POST")
 	protected.HandleFunc("/tasks/{id}/subtasks", subtaskController.GetSubtasksByTask).Methods("GET")
 	protected.HandleFunc("/comments/{id}", commentController.UpdateComment).Methods("PATCH")
@@ -178,7 +315,13 @@ func SetupRoutes(router *mux.Router, db *database.DB) {
 	protected.HandleFunc("/projects/{projectId}/messages", messageController.CreateMessage).Methods("POST")
 	protected.HandleFunc("/projects/{projectId}/messages", messageController.GetMessagesByProject).Methods("GET")
 	protected.HandleFunc("/projects/{projectId}/messages/recent", messageController.GetRecentMessages).Methods("GET")
+	protected.HandleFunc("/projects/{projectId}/messages/read", messageController.MarkRead).Methods("POST")
+	protected.HandleFunc("/projects/{projectId}/messages/reads", messageController.GetReads).Methods("GET")
+	protected.HandleFunc("/messages/{id}", messageController.UpdateMessage).Methods("PATCH")
 	protected.HandleFunc("/messages/{id}", messageController.DeleteMessage).Methods("DELETE")
+	protected.HandleFunc("/messages/{id}/replies", messageController.GetReplies).Methods("GET")
+	protected.HandleFunc("/messages/{id}/reactions", messageController.AddReaction).Methods("POST")
+	protected.HandleFunc("/messages/{id}/reactions/{emoji}", messageController.RemoveReaction).Methods("DELETE")
 
 	// Activity routes (protected with project access check)
 	activityRoutes := api.PathPrefix("/projects/{id}/activity").Subrouter()
@@ -194,6 +337,19 @@ func SetupRoutes(router *mux.Router, db *database.DB) {
 	labelRoutes.HandleFunc("", labelController.CreateLabel).Methods("POST")
 	labelRoutes.HandleFunc("", labelController.GetLabels).Methods("GET")
 
+	// Webhook routes (protected with project access check; owners only)
+	webhookRoutes := api.PathPrefix("/projects/{id}/webhooks").Subrouter()
+	webhookRoutes.Use(jwtMiddleware)
+	webhookRoutes.Use(projectAccessMiddleware)
+	webhookRoutes.HandleFunc("", webhookController.ListWebhooks).Methods("GET")
+	webhookRoutes.HandleFunc("", webhookController.CreateWebhook).Methods("POST")
+	webhookRoutes.HandleFunc("/{webhookId}", webhookController.GetWebhook).Methods("GET")
+	webhookRoutes.HandleFunc("/{webhookId}", webhookController.UpdateWebhook).Methods("PUT")
+	webhookRoutes.HandleFunc("/{webhookId}", webhookController.DeleteWebhook).Methods("DELETE")
+	webhookRoutes.HandleFunc("/{webhookId}/test", webhookController.SendTestEvent).Methods("POST")
+	webhookRoutes.HandleFunc("/{webhookId}/deliveries", webhookController.ListDeliveries).Methods("GET")
+	webhookRoutes.HandleFunc("/{webhookId}/deliveries/{deliveryId}", webhookController.GetDelivery).Methods("GET")
+
 	// Single label route (protected)
 	protected.HandleFunc("/labels/{id}", labelController.DeleteLabel).Methods("DELETE")
 
@@ -205,7 +361,24 @@ func SetupRoutes(router *mux.Router, db *database.DB) {
 	// Notification routes (protected)
 	protected.HandleFunc("/notifications", notificationController.GetNotifications).Methods("GET")
 	protected.HandleFunc("/notifications/read-all", notificationController.MarkAllAsRead).Methods("PATCH")
+	protected.HandleFunc("/notifications/bulk", notificationController.BulkUpdate).Methods("POST")
 	protected.HandleFunc("/notifications/{id}/read", notificationController.MarkAsRead).Methods("PATCH")
+	protected.HandleFunc("/notifications/{id}/unread", notificationController.MarkAsUnread).Methods("PATCH")
+	protected.HandleFunc("/notifications/{id}", notificationController.DeleteNotification).Methods("DELETE")
+
+	// Notification stream (protected; EventSource cannot set headers, so the
+	// token may also be sent as ?token=)
+	notificationStreamRoutes := api.PathPrefix("/notifications/stream").Subrouter()
+	notificationStreamRoutes.Use(authmiddleware.JWTQueryAuthMiddleware(jwtService))
+	notificationStreamRoutes.HandleFunc("", notificationStreamController.Stream).Methods("GET")
+
+	// Admin routes (protected, admins only)
+	adminRoutes := api.PathPrefix("/admin").Subrouter()
+	adminRoutes.Use(jwtMiddleware)
+	adminRoutes.Use(authmiddleware.RequireAdmin(userRepo))
+	adminRoutes.HandleFunc("/emails", emailOutboxController.ListEmails).Methods("GET")
+	adminRoutes.HandleFunc("/emails/{id}", emailOutboxController.GetEmail).Methods("GET")
+	adminRoutes.HandleFunc("/emails/{id}/retry", emailOutboxController.RetryEmail).Methods("POST")
 
 	// Health check endpoint (public)
 	router.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
@@ -9,11 +9,17 @@ import (
 	"backend/internal/repository"
 )
 
+// ActivityObserver is told about each activity after it is logged
+type ActivityObserver interface {
+	ActivityLogged(entry *models.ActivityLog)
+}
+
 // ActivityService handles business logic for activity logs
 type ActivityService struct {
 	db            *sql.DB
 	activityRepo  *repository.ActivityRepository
 	memberService *ProjectMemberService
+	observers     []ActivityObserver
 }
 
 // NewActivityService creates a new ActivityService
@@ -25,6 +31,11 @@ func NewActivityService(db *sql.DB, memberService *ProjectMemberService) *Activi
 	}
 }
 
+// AddObserver registers an observer for every activity logged from now on
+func (s *ActivityService) AddObserver(observer ActivityObserver) {
+	s.observers = append(s.observers, observer)
+}
+
 // LogActivity is the central logging function - all activity logging goes through here
 func (s *ActivityService) LogActivity(
 	projectID int64,
@@ -52,6 +63,11 @@ func (s *ActivityService) LogActivity(
 	if err != nil {
 		// Log error but don't fail the main operation
 		fmt.Printf("Warning: failed to log activity: %v\n", err)
+		return nil
+	}
+
+	for _, observer := range s.observers {
+		observer.ActivityLogged(log)
 	}
 
 	return nil
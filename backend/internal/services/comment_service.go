@@ -4,6 +4,7 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"log"
 	"strings"
 	"time"
 
@@ -17,13 +18,37 @@ var (
 )
 
 type CommentService struct {
-	db *sql.DB
+	db            *sql.DB
+	broadcaster   Broadcaster
+	mentions      *MentionService
+	notifications *NotificationService
+	watchers      *TaskWatcherService
 }
 
 func NewCommentService(db *sql.DB) *CommentService {
 	return &CommentService{db: db}
 }
 
+// SetBroadcaster registers the hub that receives board events for comment changes
+func (s *CommentService) SetBroadcaster(broadcaster Broadcaster) {
+	s.broadcaster = broadcaster
+}
+
+// SetMentionService enables @mentions in comments
+func (s *CommentService) SetMentionService(mentions *MentionService) {
+	s.mentions = mentions
+}
+
+// SetNotificationService enables comment_added notifications
+func (s *CommentService) SetNotificationService(notifications *NotificationService) {
+	s.notifications = notifications
+}
+
+// SetWatcherService subscribes commenters to the tasks they comment on
+func (s *CommentService) SetWatcherService(watchers *TaskWatcherService) {
+	s.watchers = watchers
+}
+
 func (s *CommentService) CreateComment(userID string, taskID int64, content string) (*models.Comment, error) {
 	if _, err := s.verifyTaskOwnership(userID, taskID); err != nil {
 		return nil, err
@@ -53,7 +78,7 @@ func (s *CommentService) CreateComment(userID string, taskID int64, content stri
 	}
 
 	now := time.Now()
-	return &models.Comment{
+	comment := &models.Comment{
 		ID:         id,
 		TaskID:     taskID,
 		UserID:     userID,
@@ -61,7 +86,16 @@ func (s *CommentService) CreateComment(userID string, taskID int64, content stri
 		Content:    normalizedContent,
 		CreatedAt:  now,
 		UpdatedAt:  now,
-	}, nil
+	}
+
+	s.publishCommentEvent(taskID, models.BoardCommentCreated, userID, models.CommentEventData{Comment: comment})
+	s.recordMentions(comment)
+	s.notifyCommentAdded(comment)
+	if s.watchers != nil {
+		s.watchers.autoWatch(taskID, userID)
+	}
+
+	return comment, nil
 }
 
 func (s *CommentService) GetCommentsByTask(userID string, taskID int64) ([]models.Comment, error) {
@@ -116,7 +150,15 @@ func (s *CommentService) UpdateComment(userID string, commentID int64, content s
 		return nil, ErrCommentNotFoundOrAccessDenied
 	}
 
-	return s.GetCommentByID(userID, commentID)
+	comment, err := s.GetCommentByID(userID, commentID)
+	if err != nil {
+		return nil, err
+	}
+
+	s.publishCommentEvent(comment.TaskID, models.BoardCommentUpdated, userID, models.CommentEventData{Comment: comment})
+	s.recordMentions(comment)
+
+	return comment, nil
 }
 
 func (s *CommentService) GetCommentByID(userID string, commentID int64) (*models.Comment, error) {
@@ -136,6 +178,16 @@ func (s *CommentService) GetCommentByID(userID string, commentID int64) (*models
 }
 
 func (s *CommentService) DeleteComment(userID string, commentID int64) error {
+	// Remember the task for the board event before the row is gone
+	var taskID int64
+	err := s.db.QueryRow("SELECT task_id FROM comments WHERE id = ? AND user_id = ?", commentID, userID).Scan(&taskID)
+	if err == sql.ErrNoRows {
+		return ErrCommentNotFoundOrAccessDenied
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get comment: %v", err)
+	}
+
 	result, err := s.db.Exec("DELETE FROM comments WHERE id = ? AND user_id = ?", commentID, userID)
 	if err != nil {
 		return fmt.Errorf("failed to delete comment: %v", err)
@@ -149,9 +201,48 @@ func (s *CommentService) DeleteComment(userID string, commentID int64) error {
 		return ErrCommentNotFoundOrAccessDenied
 	}
 
+	s.publishCommentEvent(taskID, models.BoardCommentDeleted, userID, models.CommentDeletedEventData{
+		CommentID: commentID,
+		TaskID:    taskID,
+	})
+
 	return nil
 }
 
+// publishCommentEvent broadcasts a comment event to the project that owns the task
+func (s *CommentService) publishCommentEvent(taskID int64, eventType models.BoardEventType, actorID string, data interface{}) {
+	if s.broadcaster == nil {
+		return
+	}
+	projectID, err := lookupTaskProjectID(s.db, taskID)
+	if err != nil {
+		log.Printf("Failed to publish comment event for task %d: %v", taskID, err)
+		return
+	}
+	publishBoardEvent(s.broadcaster, projectID, eventType, actorID, data)
+}
+
+// recordMentions stores and notifies @mentions; the comment is saved either way
+func (s *CommentService) recordMentions(comment *models.Comment) {
+	if s.mentions == nil {
+		return
+	}
+	if _, err := s.mentions.RecordCommentMentions(comment); err != nil {
+		log.Printf("Failed to record mentions for comment %d: %v", comment.ID, err)
+	}
+}
+
+// notifyCommentAdded runs after recordMentions so mentioned users are not
+// notified twice
+func (s *CommentService) notifyCommentAdded(comment *models.Comment) {
+	if s.notifications == nil {
+		return
+	}
+	if err := s.notifications.NotifyCommentAdded(comment); err != nil {
+		log.Printf("Failed to notify about comment %d: %v", comment.ID, err)
+	}
+}
+
 func (s *CommentService) verifyTaskOwnership(userID string, taskID int64) (int64, error) {
 	var taskIDFound int64
 	err := s.db.QueryRow(`
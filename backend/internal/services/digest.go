@@ -0,0 +1,324 @@
+package services
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"backend/internal/auth/services"
+	"backend/internal/models"
+)
+
+// maxDigestActivity caps how many activity entries each project lists in a
+// digest; the rest are only counted
+const maxDigestActivity = 20
+
+// digestPeriod is how long a digest covers
+func digestPeriod(frequency models.DigestFrequency) time.Duration {
+	if frequency == models.DigestWeekly {
+		return 7 * 24 * time.Hour
+	}
+	return 24 * time.Hour
+}
+
+// GetDigestSettings returns how often the user's notification emails go out.
+// Users who never chose get immediate emails.
+func (s *NotificationService) GetDigestSettings(userID string) (*models.DigestSettings, error) {
+	var frequency string
+	var lastSent sql.NullTime
+	err := s.db.QueryRow("SELECT frequency, last_sent_at FROM digest_settings WHERE user_id = ?", userID).Scan(&frequency, &lastSent)
+	if err == sql.ErrNoRows {
+		return &models.DigestSettings{Frequency: models.DigestImmediate}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get digest settings: %v", err)
+	}
+
+	settings := &models.DigestSettings{Frequency: models.DigestFrequency(frequency)}
+	if lastSent.Valid {
+		settings.LastSentAt = &lastSent.Time
+		if settings.Frequency != models.DigestImmediate {
+			next := lastSent.Time.Add(digestPeriod(settings.Frequency))
+			settings.NextDigestAt = &next
+		}
+	}
+	return settings, nil
+}
+
+// UpdateDigestSettings changes how often the user's notification emails go
+// out. Switching frequency starts a new period, so the first digest covers
+// only what happened after the switch.
+func (s *NotificationService) UpdateDigestSettings(userID string, frequency models.DigestFrequency) (*models.DigestSettings, error) {
+	if !models.IsValidDigestFrequency(frequency) {
+		return nil, &ServiceError{Code: "INVALID_REQUEST", Message: "frequency must be immediate, daily or weekly"}
+	}
+
+	current, err := s.GetDigestSettings(userID)
+	if err != nil {
+		return nil, err
+	}
+	if current.Frequency == frequency && current.LastSentAt != nil {
+		return current, nil
+	}
+
+	_, err = s.db.Exec(`
+		INSERT INTO digest_settings (user_id, frequency, last_sent_at, updated_at)
+		VALUES (?, ?, ?, CURRENT_TIMESTAMP)
+		ON CONFLICT(user_id) DO UPDATE SET
+			frequency = excluded.frequency, last_sent_at = excluded.last_sent_at, updated_at = excluded.updated_at
+	`, userID, frequency, time.Now().UTC())
+	if err != nil {
+		return nil, fmt.Errorf("failed to save digest settings: %v", err)
+	}
+
+	return s.GetDigestSettings(userID)
+}
+
+// emailsImmediately reports whether the user gets one email per notification
+// rather than a digest
+func (s *NotificationService) emailsImmediately(userID string) bool {
+	var frequency string
+	err := s.db.QueryRow("SELECT frequency FROM digest_settings WHERE user_id = ?", userID).Scan(&frequency)
+	return err != nil || models.DigestFrequency(frequency) == models.DigestImmediate
+}
+
+// BuildDigest collects the user's unread notifications whose type they get
+// emails for, and what other members did in their projects, between since
+// and until, grouped by project
+func (s *NotificationService) BuildDigest(userID string, since, until time.Time) (*models.Digest, error) {
+	digest := &models.Digest{UserID: userID, Since: since, Until: until, Projects: []models.DigestProject{}}
+	byProject := make(map[int64]*models.DigestProject)
+	var order []int64
+	project := func(projectID int64) *models.DigestProject {
+		if p, ok := byProject[projectID]; ok {
+			return p
+		}
+		p := &models.DigestProject{ProjectID: projectID, Notifications: []models.Notification{}, Activity: []models.ActivityLog{}}
+		byProject[projectID] = p
+		order = append(order, projectID)
+		return p
+	}
+
+	notifications, err := s.digestNotifications(userID, since, until)
+	if err != nil {
+		return nil, err
+	}
+	for _, n := range notifications {
+		projectID := s.projectForEntity(models.EntityType(n.RelatedEntityType), n.RelatedEntityID)
+		pref, err := s.preferenceFor(userID, n.Type, projectID)
+		if err != nil {
+			return nil, err
+		}
+		if !pref.Email {
+			continue
+		}
+		p := project(projectID)
+		p.Notifications = append(p.Notifications, n)
+	}
+
+	activity, err := s.digestActivity(userID, since, until)
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range activity {
+		p := project(entry.ProjectID)
+		if len(p.Activity) >= maxDigestActivity {
+			p.MoreActivity++
+			continue
+		}
+		p.Activity = append(p.Activity, entry)
+	}
+
+	for _, projectID := range order {
+		p := byProject[projectID]
+		if projectID > 0 {
+			p.ProjectName = s.projectName(projectID)
+		}
+		digest.Projects = append(digest.Projects, *p)
+	}
+	return digest, nil
+}
+
+// digestNotifications lists the user's unread notifications created in the
+// period, oldest first
+func (s *NotificationService) digestNotifications(userID string, since, until time.Time) ([]models.Notification, error) {
+	rows, err := s.db.Query(`
+		SELECT id, user_id, type, message, is_read, COALESCE(related_entity_type, ''), COALESCE(related_entity_id, 0), created_at
+		FROM notifications
+		WHERE user_id = ? AND is_read = 0
+			AND julianday(created_at) > julianday(?) AND julianday(created_at) <= julianday(?)
+		ORDER BY created_at ASC, id ASC
+	`, userID, since.UTC(), until.UTC())
+	if err != nil {
+		return nil, fmt.Errorf("failed to get digest notifications: %v", err)
+	}
+	defer rows.Close()
+
+	var notifications []models.Notification
+	for rows.Next() {
+		var n models.Notification
+		if err := rows.Scan(&n.ID, &n.UserID, &n.Type, &n.Message, &n.IsRead, &n.RelatedEntityType, &n.RelatedEntityID, &n.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan notification: %v", err)
+		}
+		notifications = append(notifications, n)
+	}
+	return notifications, rows.Err()
+}
+
+// digestActivity lists what other members did in the user's projects during
+// the period, oldest first
+func (s *NotificationService) digestActivity(userID string, since, until time.Time) ([]models.ActivityLog, error) {
+	rows, err := s.db.Query(`
+		SELECT a.id, a.project_id, a.user_id, COALESCE(a.user_name, ''), a.action,
+			COALESCE(a.entity_type, ''), COALESCE(a.entity_id, 0), COALESCE(a.description, ''), a.created_at
+		FROM activity_logs a
+		JOIN project_members pm ON pm.project_id = a.project_id AND pm.user_id = ?
+		WHERE a.user_id != ?
+			AND julianday(a.created_at) > julianday(?) AND julianday(a.created_at) <= julianday(?)
+		ORDER BY a.created_at ASC, a.id ASC
+	`, userID, userID, since.UTC(), until.UTC())
+	if err != nil {
+		return nil, fmt.Errorf("failed to get digest activity: %v", err)
+	}
+	defer rows.Close()
+
+	var activity []models.ActivityLog
+	for rows.Next() {
+		var entry models.ActivityLog
+		if err := rows.Scan(&entry.ID, &entry.ProjectID, &entry.UserID, &entry.UserName, &entry.Action,
+			&entry.EntityType, &entry.EntityID, &entry.Description, &entry.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan activity: %v", err)
+		}
+		activity = append(activity, entry)
+	}
+	return activity, rows.Err()
+}
+
+// SendDigests is a background job that emails every daily and weekly digest
+// that is due. Each user's period is claimed in digest_settings before the
+// email goes out, so a restart or a second instance never sends it twice.
+func (s *NotificationService) SendDigests() error {
+	return s.sendDigestsAt(time.Now().UTC())
+}
+
+func (s *NotificationService) sendDigestsAt(now time.Time) error {
+	rows, err := s.db.Query("SELECT user_id, frequency, last_sent_at FROM digest_settings WHERE frequency IN (?, ?)",
+		models.DigestDaily, models.DigestWeekly)
+	if err != nil {
+		return fmt.Errorf("failed to get digest settings: %v", err)
+	}
+
+	type dueDigest struct {
+		userID    string
+		frequency models.DigestFrequency
+		since     time.Time
+	}
+	var due []dueDigest
+	for rows.Next() {
+		var userID, frequency string
+		var lastSent sql.NullTime
+		if err := rows.Scan(&userID, &frequency, &lastSent); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan digest settings: %v", err)
+		}
+		period := digestPeriod(models.DigestFrequency(frequency))
+		since := now.Add(-period)
+		if lastSent.Valid {
+			if now.Sub(lastSent.Time) < period {
+				continue
+			}
+			since = lastSent.Time
+		}
+		due = append(due, dueDigest{userID: userID, frequency: models.DigestFrequency(frequency), since: since})
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to read digest settings: %v", err)
+	}
+
+	for _, d := range due {
+		claimed, err := s.claimDigest(d.userID, d.frequency, now)
+		if err != nil {
+			log.Printf("Failed to claim digest for user %s: %v", d.userID, err)
+			continue
+		}
+		if !claimed {
+			continue
+		}
+
+		digest, err := s.BuildDigest(d.userID, d.since, now)
+		if err != nil {
+			log.Printf("Failed to build digest for user %s: %v", d.userID, err)
+			continue
+		}
+		if len(digest.Projects) == 0 {
+			continue
+		}
+		email := s.digestEmail(d.frequency, digest)
+		s.sendEmailNotification(d.userID, func(to, locale string) error {
+			return s.emailService.SendDigest(to, locale, email)
+		})
+	}
+
+	return nil
+}
+
+// claimDigest moves the user's last_sent_at to now if their digest is still
+// due, and reports whether this call did so
+func (s *NotificationService) claimDigest(userID string, frequency models.DigestFrequency, now time.Time) (bool, error) {
+	result, err := s.db.Exec(`
+		UPDATE digest_settings SET last_sent_at = ?
+		WHERE user_id = ? AND frequency = ?
+			AND (last_sent_at IS NULL OR julianday(last_sent_at) <= julianday(?))
+	`, now, userID, frequency, now.Add(-digestPeriod(frequency)))
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
+
+// digestEmail turns a digest into the data for the digest email template
+func (s *NotificationService) digestEmail(frequency models.DigestFrequency, digest *models.Digest) services.DigestEmail {
+	email := services.DigestEmail{Frequency: string(frequency)}
+	for _, p := range digest.Projects {
+		name := p.ProjectName
+		if p.ProjectID == 0 || name == "" {
+			name = "Other"
+		}
+		project := services.DigestEmailProject{Name: name, MoreActivity: p.MoreActivity}
+		for _, n := range p.Notifications {
+			project.Notifications = append(project.Notifications, n.Message)
+		}
+		for _, entry := range p.Activity {
+			actor := entry.UserName
+			if actor == "" {
+				actor = s.userName(entry.UserID)
+			}
+			description := strings.TrimSpace(strings.TrimPrefix(entry.Description, entry.UserName))
+			project.Activity = append(project.Activity, fmt.Sprintf("%s: %s", actor, description))
+		}
+		email.Projects = append(email.Projects, project)
+	}
+	return email
+}
+
+// StartDigestSender starts a background goroutine that sends due digests
+// periodically
+func (s *NotificationService) StartDigestSender(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			if err := s.SendDigests(); err != nil {
+				log.Printf("Digest send error: %v", err)
+			}
+		}
+	}()
+}
@@ -0,0 +1,246 @@
+package services
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"backend/internal/models"
+)
+
+// mentionExcerptLength caps the text stored with a mention, in runes
+const mentionExcerptLength = 140
+
+// MentionService finds @name and @email references to project members in
+// comments and chat messages, stores them and notifies the mentioned users
+type MentionService struct {
+	db            *sql.DB
+	notifications *NotificationService
+}
+
+// NewMentionService creates a MentionService; notifications may be nil
+func NewMentionService(db *sql.DB, notifications *NotificationService) *MentionService {
+	return &MentionService{db: db, notifications: notifications}
+}
+
+// mentionCandidate is a project member that can be mentioned
+type mentionCandidate struct {
+	userID string
+	name   string
+	email  string
+}
+
+// RecordCommentMentions stores the mentions in a task comment and notifies
+// each newly mentioned member. Returns the IDs of the newly mentioned users.
+func (s *MentionService) RecordCommentMentions(comment *models.Comment) ([]string, error) {
+	var projectID int64
+	var taskTitle string
+	err := s.db.QueryRow(`
+		SELECT stages.project_id, tasks.title
+		FROM tasks
+		JOIN stages ON tasks.stage_id = stages.id
+		WHERE tasks.id = ?`,
+		comment.TaskID,
+	).Scan(&projectID, &taskTitle)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get comment task: %v", err)
+	}
+
+	taskID := comment.TaskID
+	return s.record(projectID, comment.UserID, comment.AuthorName, models.EntityComment, comment.ID, &taskID,
+		comment.Content, fmt.Sprintf("a comment on '%s'", taskTitle))
+}
+
+// RecordMessageMentions stores the mentions in a chat message and notifies
+// each newly mentioned member. Returns the IDs of the newly mentioned users.
+func (s *MentionService) RecordMessageMentions(message *models.Message) ([]string, error) {
+	var projectName string
+	if err := s.db.QueryRow("SELECT name FROM projects WHERE id = ?", message.ProjectID).Scan(&projectName); err != nil {
+		return nil, fmt.Errorf("failed to get message project: %v", err)
+	}
+
+	return s.record(message.ProjectID, message.UserID, message.SenderName, models.EntityMessage, message.ID, nil,
+		message.Content, fmt.Sprintf("the '%s' chat", projectName))
+}
+
+// record stores one mention per mentioned member. Mentions already stored for
+// the entity (e.g. before an edit) are kept and not notified again.
+func (s *MentionService) record(projectID int64, authorID, authorName string, entityType models.EntityType, entityID int64, taskID *int64, content, where string) ([]string, error) {
+	if !strings.Contains(content, "@") {
+		return nil, nil
+	}
+
+	candidates, err := s.projectMembers(projectID)
+	if err != nil {
+		return nil, err
+	}
+
+	excerpt := mentionExcerpt(content)
+	var mentioned []string
+	for _, userID := range parseMentions(content, candidates) {
+		if userID == authorID {
+			continue
+		}
+
+		result, err := s.db.Exec(
+			`INSERT OR IGNORE INTO mentions (project_id, user_id, author_id, entity_type, entity_id, task_id, excerpt, created_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+			projectID, userID, authorID, entityType, entityID, taskID, excerpt, time.Now().UTC(),
+		)
+		if err != nil {
+			return mentioned, fmt.Errorf("failed to store mention: %v", err)
+		}
+		if rowsAffected, _ := result.RowsAffected(); rowsAffected == 0 {
+			continue
+		}
+		mentioned = append(mentioned, userID)
+
+		if s.notifications != nil {
+			if err := s.notifications.NotifyMentioned(userID, authorID, authorName, where, entityType, entityID); err != nil {
+				log.Printf("Failed to notify mention of %s: %v", userID, err)
+			}
+		}
+	}
+	return mentioned, nil
+}
+
+// GetUserMentions returns where a user was mentioned, newest first. Mentions
+// whose comment or message was deleted, or in projects the user has left,
+// are not listed.
+func (s *MentionService) GetUserMentions(userID string, page, limit int) ([]models.Mention, int64, error) {
+	const visible = `
+		FROM mentions m
+		JOIN projects p ON p.id = m.project_id
+		JOIN project_members pm ON pm.project_id = m.project_id AND pm.user_id = m.user_id
+		LEFT JOIN users u ON u.id = m.author_id
+		WHERE m.user_id = ?
+			AND (
+				(m.entity_type = 'comment' AND EXISTS (SELECT 1 FROM comments c WHERE c.id = m.entity_id))
+				OR (m.entity_type = 'message' AND EXISTS (SELECT 1 FROM messages msg WHERE msg.id = m.entity_id))
+			)`
+
+	var total int64
+	if err := s.db.QueryRow("SELECT COUNT(*)"+visible, userID).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("failed to count mentions: %v", err)
+	}
+
+	rows, err := s.db.Query(`
+		SELECT m.id, m.project_id, p.name, m.user_id, m.author_id, COALESCE(u.name, ''),
+			m.entity_type, m.entity_id, m.task_id, m.excerpt, m.created_at`+visible+`
+		ORDER BY m.created_at DESC, m.id DESC
+		LIMIT ? OFFSET ?`,
+		userID, limit, (page-1)*limit,
+	)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to query mentions: %v", err)
+	}
+	defer rows.Close()
+
+	mentions := []models.Mention{}
+	for rows.Next() {
+		var mention models.Mention
+		var taskID sql.NullInt64
+		err := rows.Scan(&mention.ID, &mention.ProjectID, &mention.ProjectName, &mention.UserID, &mention.AuthorID, &mention.AuthorName,
+			&mention.EntityType, &mention.EntityID, &taskID, &mention.Excerpt, &mention.CreatedAt)
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to scan mention: %v", err)
+		}
+		if taskID.Valid {
+			mention.TaskID = &taskID.Int64
+		}
+		mentions = append(mentions, mention)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, fmt.Errorf("failed while reading mentions: %v", err)
+	}
+
+	return mentions, total, nil
+}
+
+// projectMembers lists the users that can be mentioned in a project
+func (s *MentionService) projectMembers(projectID int64) ([]mentionCandidate, error) {
+	rows, err := s.db.Query(`
+		SELECT u.id, COALESCE(u.name, ''), COALESCE(u.email, '')
+		FROM project_members pm
+		JOIN users u ON u.id = pm.user_id
+		WHERE pm.project_id = ?`,
+		projectID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query project members: %v", err)
+	}
+	defer rows.Close()
+
+	var candidates []mentionCandidate
+	for rows.Next() {
+		var candidate mentionCandidate
+		if err := rows.Scan(&candidate.userID, &candidate.name, &candidate.email); err != nil {
+			return nil, fmt.Errorf("failed to scan project member: %v", err)
+		}
+		candidate.name = strings.ToLower(strings.TrimSpace(candidate.name))
+		candidate.email = strings.ToLower(strings.TrimSpace(candidate.email))
+		candidates = append(candidates, candidate)
+	}
+	return candidates, rows.Err()
+}
+
+// parseMentions returns the IDs of the candidates referenced in content, in
+// order of first appearance. A mention is "@" followed by a member's email or
+// full name (case-insensitive) and then a non-word character or the end.
+// When several members match, the longest handle wins, so "@Ann Lee" beats
+// "@Ann". An "@" inside a word, as in an email address, does not start a mention.
+func parseMentions(content string, candidates []mentionCandidate) []string {
+	lower := strings.ToLower(content)
+	seen := make(map[string]bool)
+	var userIDs []string
+
+	for i := 0; i < len(lower); i++ {
+		if lower[i] != '@' {
+			continue
+		}
+		if previous, _ := utf8.DecodeLastRuneInString(lower[:i]); i > 0 && isMentionWordRune(previous) {
+			continue
+		}
+
+		rest := lower[i+1:]
+		matchedID, matchedLen := "", 0
+		for _, candidate := range candidates {
+			for _, handle := range []string{candidate.email, candidate.name} {
+				if handle == "" || len(handle) <= matchedLen || !strings.HasPrefix(rest, handle) {
+					continue
+				}
+				if next, _ := utf8.DecodeRuneInString(rest[len(handle):]); len(rest) > len(handle) && isMentionWordRune(next) {
+					continue
+				}
+				matchedID, matchedLen = candidate.userID, len(handle)
+			}
+		}
+
+		if matchedID == "" {
+			continue
+		}
+		if !seen[matchedID] {
+			seen[matchedID] = true
+			userIDs = append(userIDs, matchedID)
+		}
+		i += matchedLen
+	}
+	return userIDs
+}
+
+func isMentionWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_' || r == '@'
+}
+
+func mentionExcerpt(content string) string {
+	content = strings.Join(strings.Fields(content), " ")
+	if utf8.RuneCountInString(content) <= mentionExcerptLength {
+		return content
+	}
+	runes := []rune(content)
+	return string(runes[:mentionExcerptLength-1]) + "…"
+}
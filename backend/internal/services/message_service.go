@@ -2,44 +2,199 @@ package services
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
+	"log"
+	"strings"
 	"time"
+	"unicode/utf8"
 
 	"backend/internal/models"
 )
 
+// MaxMessageLength caps the size of a single chat message, in characters
+// (runes), not bytes
+const MaxMessageLength = 2000
+
+// MaxReactionLength caps the size of a reaction; enough for any emoji
+// sequence or a :shortcode:
+const MaxReactionLength = 32
+
+var (
+	ErrMessageContentRequired        = errors.New("message content is required")
+	ErrMessageTooLong                = fmt.Errorf("message content is too long (max %d characters)", MaxMessageLength)
+	ErrMessageNotFoundOrAccessDenied = errors.New("message not found or access denied")
+	ErrProjectNotFoundOrAccessDenied = errors.New("project not found or access denied")
+	ErrInvalidMessageCursor          = errors.New("before, after and limit must not be negative")
+	ErrInvalidParentMessage          = errors.New("parent message not found or is itself a reply")
+	ErrInvalidReaction               = fmt.Errorf("reaction must be a single emoji or shortcode (max %d characters)", MaxReactionLength)
+)
+
+// Chat history page sizes
+const (
+	DefaultMessagePageSize = 50
+	MaxMessagePageSize     = 200
+)
+
+// MessageCursor selects a page of chat history by message id. Before and
+// After are exclusive bounds and zero means unbounded:
+//   - no cursor: the latest messages
+//   - Before: the messages just before it, for scrolling back
+//   - After: the messages just after it, for catching up
+//   - both: the newest messages between them, for filling a gap
+//
+// Limit defaults to DefaultMessagePageSize and is capped at MaxMessagePageSize.
+type MessageCursor struct {
+	Before int64
+	After  int64
+	Limit  int
+}
+
+func (c MessageCursor) pageSize() (int, error) {
+	switch {
+	case c.Limit < 0 || c.Before < 0 || c.After < 0:
+		return 0, ErrInvalidMessageCursor
+	case c.Limit == 0:
+		return DefaultMessagePageSize, nil
+	case c.Limit > MaxMessagePageSize:
+		return MaxMessagePageSize, nil
+	}
+	return c.Limit, nil
+}
+
+// Chat frame types pushed to WebSocket clients
+const (
+	ChatFrameMessage         = "message"
+	ChatFrameMessageUpdated  = "message_updated"
+	ChatFrameMessageDeleted  = "message_deleted"
+	ChatFrameReactionAdded   = "reaction_added"
+	ChatFrameReactionRemoved = "reaction_removed"
+	ChatFrameMessageRead     = "message_read"
+)
+
+// ChatMessageFrame is the frame broadcast when a message is stored or edited
+type ChatMessageFrame struct {
+	Type string `json:"type"`
+	*models.Message
+}
+
+// ChatMessageDeletedFrame is the frame broadcast when a message is deleted
+type ChatMessageDeletedFrame struct {
+	Type            string `json:"type"`
+	ID              int64  `json:"id"`
+	ProjectID       int64  `json:"project_id"`
+	ParentMessageID *int64 `json:"parent_message_id,omitempty"`
+}
+
+// ChatReactionFrame is the frame broadcast when a reaction is added or removed
+type ChatReactionFrame struct {
+	Type      string `json:"type"`
+	ProjectID int64  `json:"project_id"`
+	MessageID int64  `json:"message_id"`
+	UserID    string `json:"user_id"`
+	Emoji     string `json:"emoji"`
+}
+
+// ChatReadFrame is the read receipt broadcast when a member's read position advances
+type ChatReadFrame struct {
+	Type string `json:"type"`
+	*models.MessageRead
+}
+
+// messageColumns are the columns read by scanMessages
+const messageColumns = "id, COALESCE(user_id, ''), project_id, parent_message_id, sender_name, content, created_at, edited_at"
+
 type MessageService struct {
-	db *sql.DB
+	db          *sql.DB
+	broadcaster Broadcaster
+	mentions    *MentionService
 }
 
 func NewMessageService(db *sql.DB) *MessageService {
 	return &MessageService{db: db}
 }
 
-// verifyProjectOwnership checks if project belongs to user
-func (s *MessageService) verifyProjectOwnership(userID string, projectID int64) (bool, error) {
+// SetBroadcaster registers the hub that receives chat frames for connected clients
+func (s *MessageService) SetBroadcaster(broadcaster Broadcaster) {
+	s.broadcaster = broadcaster
+}
+
+// SetMentionService enables @mentions in chat messages
+func (s *MessageService) SetMentionService(mentions *MentionService) {
+	s.mentions = mentions
+}
+
+// hasProjectAccess checks if user is the project owner or a member
+func (s *MessageService) hasProjectAccess(userID string, projectID int64) (bool, error) {
 	var count int
-	err := s.db.QueryRow("SELECT COUNT(*) FROM projects WHERE id = ? AND user_id = ?", projectID, userID).Scan(&count)
+	err := s.db.QueryRow(
+		`SELECT COUNT(*)
+		FROM projects
+		LEFT JOIN project_members
+			ON project_members.project_id = projects.id
+			AND project_members.user_id = ?
+		WHERE projects.id = ?
+			AND (projects.owner_id = ? OR project_members.user_id IS NOT NULL)`,
+		userID,
+		projectID,
+		userID,
+	).Scan(&count)
 	if err != nil {
 		return false, err
 	}
 	return count > 0, nil
 }
 
-// CreateMessage creates a new chat message (validates ownership)
-func (s *MessageService) CreateMessage(userID string, projectID int64, senderName, content string) (*models.Message, error) {
-	// Verify project belongs to user
-	owned, err := s.verifyProjectOwnership(userID, projectID)
+// verifyProjectAccess returns ErrProjectNotFoundOrAccessDenied unless the user can access the project
+func (s *MessageService) verifyProjectAccess(userID string, projectID int64) error {
+	hasAccess, err := s.hasProjectAccess(userID, projectID)
 	if err != nil {
-		return nil, fmt.Errorf("failed to verify project ownership: %v", err)
+		return fmt.Errorf("failed to verify project access: %v", err)
+	}
+	if !hasAccess {
+		return ErrProjectNotFoundOrAccessDenied
 	}
-	if !owned {
-		return nil, fmt.Errorf("project not found or access denied")
+	return nil
+}
+
+// CreateMessage stores a chat message from the authenticated user and
+// broadcasts it to the project's connected clients. The sender name is taken
+// from the user's profile, never from the client.
+func (s *MessageService) CreateMessage(userID string, projectID int64, content string) (*models.Message, error) {
+	return s.createMessage(userID, projectID, nil, content)
+}
+
+// CreateReply stores a reply in the thread of a top-level message in the
+// same project and broadcasts it like any other message
+func (s *MessageService) CreateReply(userID string, projectID int64, parentID int64, content string) (*models.Message, error) {
+	return s.createMessage(userID, projectID, &parentID, content)
+}
+
+func (s *MessageService) createMessage(userID string, projectID int64, parentID *int64, content string) (*models.Message, error) {
+	normalizedContent, err := normalizeMessageContent(content)
+	if err != nil {
+		return nil, err
 	}
 
+	if err := s.verifyProjectAccess(userID, projectID); err != nil {
+		return nil, err
+	}
+
+	if parentID != nil {
+		if err := s.verifyThreadParent(projectID, *parentID); err != nil {
+			return nil, err
+		}
+	}
+
+	senderName, err := s.getSenderName(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	createdAt := time.Now().UTC()
 	result, err := s.db.Exec(
-		"INSERT INTO messages (user_id, project_id, sender_name, content) VALUES (?, ?, ?, ?)",
-		userID, projectID, senderName, content,
+		"INSERT INTO messages (user_id, project_id, parent_message_id, sender_name, content, created_at) VALUES (?, ?, ?, ?, ?, ?)",
+		userID, projectID, parentID, senderName, normalizedContent, createdAt,
 	)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create message: %v", err)
@@ -50,90 +205,517 @@ func (s *MessageService) CreateMessage(userID string, projectID int64, senderNam
 		return nil, fmt.Errorf("failed to get last insert id: %v", err)
 	}
 
-	return &models.Message{
-		ID:         id,
-		UserID:     userID,
-		ProjectID:  projectID,
-		SenderName: senderName,
-		Content:    content,
-		CreatedAt:  time.Now(),
-	}, nil
+	message := &models.Message{
+		ID:              id,
+		UserID:          userID,
+		ProjectID:       projectID,
+		ParentMessageID: parentID,
+		SenderName:      senderName,
+		Content:         normalizedContent,
+		CreatedAt:       createdAt,
+		Reactions:       []models.MessageReactionSummary{},
+	}
+
+	if s.broadcaster != nil {
+		s.broadcaster.BroadcastJSON(projectID, ChatMessageFrame{Type: ChatFrameMessage, Message: message})
+	}
+	s.recordMentions(message)
+
+	return message, nil
+}
+
+// recordMentions stores and notifies @mentions; the message is saved either way
+func (s *MessageService) recordMentions(message *models.Message) {
+	if s.mentions == nil {
+		return
+	}
+	if _, err := s.mentions.RecordMessageMentions(message); err != nil {
+		log.Printf("Failed to record mentions for message %d: %v", message.ID, err)
+	}
+}
+
+// verifyThreadParent checks that a reply's parent is a top-level message in
+// the project; threads are one level deep
+func (s *MessageService) verifyThreadParent(projectID, parentID int64) error {
+	var count int
+	err := s.db.QueryRow(
+		"SELECT COUNT(*) FROM messages WHERE id = ? AND project_id = ? AND parent_message_id IS NULL",
+		parentID, projectID,
+	).Scan(&count)
+	if err != nil {
+		return fmt.Errorf("failed to get parent message: %v", err)
+	}
+	if count == 0 {
+		return ErrInvalidParentMessage
+	}
+	return nil
+}
+
+// GetMessagesByProject retrieves one page of a project's top-level chat
+// history in chronological order (validates access). Thread replies are
+// counted in ReplyCount and read with GetReplies. See MessageCursor for paging.
+func (s *MessageService) GetMessagesByProject(userID string, projectID int64, cursor MessageCursor) ([]models.Message, error) {
+	limit, err := cursor.pageSize()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.verifyProjectAccess(userID, projectID); err != nil {
+		return nil, err
+	}
+
+	// Paging forward from After reads oldest-first; every other page is the
+	// newest messages before the cursor
+	newestFirst := cursor.Before > 0 || cursor.After == 0
+	return s.listMessages("project_id = ? AND parent_message_id IS NULL", []interface{}{projectID}, cursor.Before, cursor.After, limit, newestFirst)
+}
+
+// GetReplies retrieves one page of a message's thread, oldest first unless
+// the cursor pages back with Before (validates access)
+func (s *MessageService) GetReplies(userID string, messageID int64, cursor MessageCursor) ([]models.Message, error) {
+	limit, err := cursor.pageSize()
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := s.accessibleMessageProject(userID, messageID); err != nil {
+		return nil, err
+	}
+
+	newestFirst := cursor.Before > 0
+	return s.listMessages("parent_message_id = ?", []interface{}{messageID}, cursor.Before, cursor.After, limit, newestFirst)
 }
 
-// GetMessagesByProject retrieves all messages for a project (validates ownership)
-func (s *MessageService) GetMessagesByProject(userID string, projectID int64) ([]models.Message, error) {
-	// Verify project belongs to user
-	owned, err := s.verifyProjectOwnership(userID, projectID)
+// GetMessagesSince returns the newest messages posted after afterID, replies
+// included, up to limit, in chronological order. hasMore reports that older
+// messages after afterID were left out. It is used to replay what a
+// reconnecting client missed.
+func (s *MessageService) GetMessagesSince(userID string, projectID int64, afterID int64, limit int) ([]models.Message, bool, error) {
+	if err := s.verifyProjectAccess(userID, projectID); err != nil {
+		return nil, false, err
+	}
+
+	messages, err := s.listMessages("project_id = ?", []interface{}{projectID}, 0, afterID, limit+1, true)
+	if err != nil {
+		return nil, false, err
+	}
+	if len(messages) > limit {
+		return messages[1:], true, nil
+	}
+	return messages, false, nil
+}
+
+// listMessages returns up to limit messages matching where with
+// after < id < before (zero bounds are ignored) in chronological order. With
+// newestFirst the page is taken from the before end of the range, otherwise
+// from the after end.
+func (s *MessageService) listMessages(where string, whereArgs []interface{}, before, after int64, limit int, newestFirst bool) ([]models.Message, error) {
+	query := "SELECT " + messageColumns + " FROM messages WHERE " + where
+	args := append([]interface{}{}, whereArgs...)
+	if before > 0 {
+		query += " AND id < ?"
+		args = append(args, before)
+	}
+	if after > 0 {
+		query += " AND id > ?"
+		args = append(args, after)
+	}
+	if newestFirst {
+		query += " ORDER BY id DESC LIMIT ?"
+	} else {
+		query += " ORDER BY id ASC LIMIT ?"
+	}
+	args = append(args, limit)
+
+	rows, err := s.db.Query(query, args...)
 	if err != nil {
-		return nil, fmt.Errorf("failed to verify project ownership: %v", err)
+		return nil, fmt.Errorf("failed to query messages: %v", err)
+	}
+	defer rows.Close()
+
+	messages, err := scanMessages(rows)
+	if err != nil {
+		return nil, err
+	}
+	rows.Close()
+
+	if newestFirst {
+		// Reverse to get chronological order
+		for i, j := 0, len(messages)-1; i < j; i, j = i+1, j-1 {
+			messages[i], messages[j] = messages[j], messages[i]
+		}
 	}
-	if !owned {
-		return nil, fmt.Errorf("project not found or access denied")
+
+	if err := s.loadMessageDetails(messages); err != nil {
+		return nil, err
+	}
+	return messages, nil
+}
+
+// loadMessageDetails fills in reply counts and reactions for a page of messages
+func (s *MessageService) loadMessageDetails(messages []models.Message) error {
+	if len(messages) == 0 {
+		return nil
+	}
+
+	byID := make(map[int64]*models.Message, len(messages))
+	placeholders := make([]string, len(messages))
+	args := make([]interface{}, len(messages))
+	for i := range messages {
+		messages[i].Reactions = []models.MessageReactionSummary{}
+		byID[messages[i].ID] = &messages[i]
+		placeholders[i] = "?"
+		args[i] = messages[i].ID
 	}
+	in := strings.Join(placeholders, ", ")
 
 	rows, err := s.db.Query(
-		"SELECT id, user_id, project_id, sender_name, content, created_at FROM messages WHERE project_id = ? AND user_id = ? ORDER BY created_at ASC",
-		projectID, userID,
+		"SELECT parent_message_id, COUNT(*) FROM messages WHERE parent_message_id IN ("+in+") GROUP BY parent_message_id",
+		args...,
 	)
 	if err != nil {
-		return nil, fmt.Errorf("failed to query messages: %v", err)
+		return fmt.Errorf("failed to count replies: %v", err)
+	}
+	for rows.Next() {
+		var parentID int64
+		var count int
+		if err := rows.Scan(&parentID, &count); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan reply count: %v", err)
+		}
+		byID[parentID].ReplyCount = count
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed while reading reply counts: %v", err)
+	}
+
+	rows, err = s.db.Query(
+		"SELECT message_id, emoji, user_id FROM message_reactions WHERE message_id IN ("+in+") ORDER BY id ASC",
+		args...,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to query reactions: %v", err)
 	}
 	defer rows.Close()
 
-	var messages []models.Message
 	for rows.Next() {
-		var message models.Message
-		err := rows.Scan(&message.ID, &message.UserID, &message.ProjectID, &message.SenderName, &message.Content, &message.CreatedAt)
-		if err != nil {
-			return nil, fmt.Errorf("failed to scan message: %v", err)
+		var messageID int64
+		var emoji, userID string
+		if err := rows.Scan(&messageID, &emoji, &userID); err != nil {
+			return fmt.Errorf("failed to scan reaction: %v", err)
 		}
-		messages = append(messages, message)
+		message := byID[messageID]
+		message.Reactions = addReaction(message.Reactions, emoji, userID)
 	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed while reading reactions: %v", err)
+	}
+	return nil
+}
 
-	return messages, nil
+// addReaction adds one user's reaction to the summaries, grouping by emoji in
+// the order each emoji was first used
+func addReaction(summaries []models.MessageReactionSummary, emoji, userID string) []models.MessageReactionSummary {
+	for i := range summaries {
+		if summaries[i].Emoji == emoji {
+			summaries[i].Count++
+			summaries[i].UserIDs = append(summaries[i].UserIDs, userID)
+			return summaries
+		}
+	}
+	return append(summaries, models.MessageReactionSummary{Emoji: emoji, Count: 1, UserIDs: []string{userID}})
 }
 
-// GetRecentMessages retrieves recent messages for a project (validates ownership)
+// GetRecentMessages retrieves recent top-level messages for a project (validates access)
 func (s *MessageService) GetRecentMessages(userID string, projectID int64) ([]models.Message, error) {
-	// Verify project belongs to user
-	owned, err := s.verifyProjectOwnership(userID, projectID)
+	return s.GetMessagesByProject(userID, projectID, MessageCursor{})
+}
+
+// UpdateMessage edits a message's content (only its sender may edit it),
+// stamps edited_at and notifies connected clients
+func (s *MessageService) UpdateMessage(userID string, id int64, content string) (*models.Message, error) {
+	normalizedContent, err := normalizeMessageContent(content)
 	if err != nil {
-		return nil, fmt.Errorf("failed to verify project ownership: %v", err)
+		return nil, err
 	}
-	if !owned {
-		return nil, fmt.Errorf("project not found or access denied")
+
+	editedAt := time.Now().UTC()
+	result, err := s.db.Exec(
+		"UPDATE messages SET content = ?, edited_at = ? WHERE id = ? AND user_id = ?",
+		normalizedContent, editedAt, id, userID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update message: %v", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get rows affected: %v", err)
+	}
+	if rowsAffected == 0 {
+		return nil, ErrMessageNotFoundOrAccessDenied
+	}
+
+	message, err := s.getMessage(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if s.broadcaster != nil {
+		s.broadcaster.BroadcastJSON(message.ProjectID, ChatMessageFrame{Type: ChatFrameMessageUpdated, Message: message})
+	}
+	s.recordMentions(message)
+
+	return message, nil
+}
+
+// AddReaction records the user's emoji reaction to a message. Reacting twice
+// with the same emoji is a no-op. Returns the message's reactions.
+func (s *MessageService) AddReaction(userID string, messageID int64, emoji string) ([]models.MessageReactionSummary, error) {
+	emoji, err := normalizeReaction(emoji)
+	if err != nil {
+		return nil, err
+	}
+
+	projectID, err := s.accessibleMessageProject(userID, messageID)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := s.db.Exec(
+		"INSERT OR IGNORE INTO message_reactions (message_id, user_id, emoji, created_at) VALUES (?, ?, ?, ?)",
+		messageID, userID, emoji, time.Now().UTC(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to add reaction: %v", err)
+	}
+
+	return s.reactionChanged(result, ChatFrameReactionAdded, projectID, messageID, userID, emoji)
+}
+
+// RemoveReaction removes the user's emoji reaction from a message. Removing a
+// reaction that does not exist is a no-op. Returns the message's reactions.
+func (s *MessageService) RemoveReaction(userID string, messageID int64, emoji string) ([]models.MessageReactionSummary, error) {
+	emoji, err := normalizeReaction(emoji)
+	if err != nil {
+		return nil, err
+	}
+
+	projectID, err := s.accessibleMessageProject(userID, messageID)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := s.db.Exec(
+		"DELETE FROM message_reactions WHERE message_id = ? AND user_id = ? AND emoji = ?",
+		messageID, userID, emoji,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to remove reaction: %v", err)
+	}
+
+	return s.reactionChanged(result, ChatFrameReactionRemoved, projectID, messageID, userID, emoji)
+}
+
+// reactionChanged broadcasts a reaction frame if the statement changed
+// anything and returns the message's current reactions
+func (s *MessageService) reactionChanged(result sql.Result, frameType string, projectID, messageID int64, userID, emoji string) ([]models.MessageReactionSummary, error) {
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get rows affected: %v", err)
+	}
+
+	if rowsAffected > 0 && s.broadcaster != nil {
+		s.broadcaster.BroadcastJSON(projectID, ChatReactionFrame{
+			Type:      frameType,
+			ProjectID: projectID,
+			MessageID: messageID,
+			UserID:    userID,
+			Emoji:     emoji,
+		})
+	}
+
+	message, err := s.getMessage(messageID)
+	if err != nil {
+		return nil, err
+	}
+	return message.Reactions, nil
+}
+
+// MarkRead advances the user's read position in a project's chat to
+// messageID, or to the latest message when messageID is 0. Positions never
+// move backwards. When the position advances a read receipt is broadcast.
+func (s *MessageService) MarkRead(userID string, projectID int64, messageID int64) (*models.MessageRead, error) {
+	if err := s.verifyProjectAccess(userID, projectID); err != nil {
+		return nil, err
+	}
+
+	var err error
+	if messageID == 0 {
+		err = s.db.QueryRow("SELECT COALESCE(MAX(id), 0) FROM messages WHERE project_id = ?", projectID).Scan(&messageID)
+	} else {
+		err = s.db.QueryRow("SELECT id FROM messages WHERE id = ? AND project_id = ?", messageID, projectID).Scan(&messageID)
+	}
+	if err == sql.ErrNoRows {
+		return nil, ErrMessageNotFoundOrAccessDenied
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get message: %v", err)
+	}
+
+	read := &models.MessageRead{
+		UserID:            userID,
+		ProjectID:         projectID,
+		LastReadMessageID: messageID,
+		ReadAt:            time.Now().UTC(),
+	}
+	result, err := s.db.Exec(
+		`INSERT INTO message_reads (user_id, project_id, last_read_message_id, read_at) VALUES (?, ?, ?, ?)
+		ON CONFLICT(user_id, project_id) DO UPDATE SET
+			last_read_message_id = excluded.last_read_message_id,
+			read_at = excluded.read_at
+		WHERE excluded.last_read_message_id > message_reads.last_read_message_id`,
+		userID, projectID, messageID, read.ReadAt,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to mark messages read: %v", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get rows affected: %v", err)
+	}
+	if rowsAffected == 0 {
+		// Already read further; report the stored position
+		return s.getRead(userID, projectID)
+	}
+
+	if s.broadcaster != nil {
+		s.broadcaster.BroadcastJSON(projectID, ChatReadFrame{Type: ChatFrameMessageRead, MessageRead: read})
+	}
+	return read, nil
+}
+
+// GetReads returns every member's read position in a project's chat (validates access)
+func (s *MessageService) GetReads(userID string, projectID int64) ([]models.MessageRead, error) {
+	if err := s.verifyProjectAccess(userID, projectID); err != nil {
+		return nil, err
 	}
 
 	rows, err := s.db.Query(
-		"SELECT id, user_id, project_id, sender_name, content, created_at FROM messages WHERE project_id = ? AND user_id = ? ORDER BY created_at DESC LIMIT 50",
-		projectID, userID,
+		"SELECT user_id, project_id, last_read_message_id, read_at FROM message_reads WHERE project_id = ? ORDER BY user_id",
+		projectID,
 	)
 	if err != nil {
-		return nil, fmt.Errorf("failed to query messages: %v", err)
+		return nil, fmt.Errorf("failed to query message reads: %v", err)
 	}
 	defer rows.Close()
 
-	var messages []models.Message
+	reads := []models.MessageRead{}
 	for rows.Next() {
-		var message models.Message
-		err := rows.Scan(&message.ID, &message.UserID, &message.ProjectID, &message.SenderName, &message.Content, &message.CreatedAt)
-		if err != nil {
-			return nil, fmt.Errorf("failed to scan message: %v", err)
+		var read models.MessageRead
+		if err := rows.Scan(&read.UserID, &read.ProjectID, &read.LastReadMessageID, &read.ReadAt); err != nil {
+			return nil, fmt.Errorf("failed to scan message read: %v", err)
 		}
-		messages = append(messages, message)
+		reads = append(reads, read)
 	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed while reading message reads: %v", err)
+	}
+	return reads, nil
+}
 
-	// Reverse to get chronological order
-	for i, j := 0, len(messages)-1; i < j; i, j = i+1, j-1 {
-		messages[i], messages[j] = messages[j], messages[i]
+func (s *MessageService) getRead(userID string, projectID int64) (*models.MessageRead, error) {
+	var read models.MessageRead
+	err := s.db.QueryRow(
+		"SELECT user_id, project_id, last_read_message_id, read_at FROM message_reads WHERE user_id = ? AND project_id = ?",
+		userID, projectID,
+	).Scan(&read.UserID, &read.ProjectID, &read.LastReadMessageID, &read.ReadAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get message read: %v", err)
 	}
+	return &read, nil
+}
 
-	return messages, nil
+// accessibleMessageProject returns the project of a message the user can see
+func (s *MessageService) accessibleMessageProject(userID string, messageID int64) (int64, error) {
+	var projectID int64
+	err := s.db.QueryRow("SELECT project_id FROM messages WHERE id = ?", messageID).Scan(&projectID)
+	if err == sql.ErrNoRows {
+		return 0, ErrMessageNotFoundOrAccessDenied
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to get message: %v", err)
+	}
+
+	hasAccess, err := s.hasProjectAccess(userID, projectID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to verify project access: %v", err)
+	}
+	if !hasAccess {
+		return 0, ErrMessageNotFoundOrAccessDenied
+	}
+	return projectID, nil
 }
 
-// DeleteMessage deletes a message (validates ownership)
+// getMessage loads a single message with its reply count and reactions
+func (s *MessageService) getMessage(id int64) (*models.Message, error) {
+	messages, err := s.listMessages("id = ?", []interface{}{id}, 0, 0, 1, false)
+	if err != nil {
+		return nil, err
+	}
+	if len(messages) == 0 {
+		return nil, ErrMessageNotFoundOrAccessDenied
+	}
+	return &messages[0], nil
+}
+
+// DeleteMessage deletes a message together with its thread replies, their
+// reactions and mentions (only its sender may delete it) and notifies
+// connected clients of every deleted message, replies first
 func (s *MessageService) DeleteMessage(userID string, id int64) error {
-	result, err := s.db.Exec("DELETE FROM messages WHERE id = ? AND user_id = ?", id, userID)
+	var projectID int64
+	err := s.db.QueryRow("SELECT project_id FROM messages WHERE id = ? AND user_id = ?", id, userID).Scan(&projectID)
+	if err == sql.ErrNoRows {
+		return ErrMessageNotFoundOrAccessDenied
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get message: %v", err)
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	replyIDs, err := threadReplyIDs(tx, id)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(
+		"DELETE FROM message_reactions WHERE message_id = ? OR message_id IN (SELECT id FROM messages WHERE parent_message_id = ?)",
+		id, id,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to delete reactions: %v", err)
+	}
+
+	_, err = tx.Exec(
+		"DELETE FROM mentions WHERE entity_type = ? AND (entity_id = ? OR entity_id IN (SELECT id FROM messages WHERE parent_message_id = ?))",
+		models.EntityMessage, id, id,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to delete mentions: %v", err)
+	}
+
+	if _, err := tx.Exec("DELETE FROM messages WHERE parent_message_id = ?", id); err != nil {
+		return fmt.Errorf("failed to delete replies: %v", err)
+	}
+
+	result, err := tx.Exec("DELETE FROM messages WHERE id = ? AND user_id = ?", id, userID)
 	if err != nil {
 		return fmt.Errorf("failed to delete message: %v", err)
 	}
@@ -144,8 +726,107 @@ func (s *MessageService) DeleteMessage(userID string, id int64) error {
 	}
 
 	if rowsAffected == 0 {
-		return fmt.Errorf("message not found or access denied")
+		return ErrMessageNotFoundOrAccessDenied
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %v", err)
+	}
+
+	if s.broadcaster != nil {
+		for _, replyID := range replyIDs {
+			s.broadcaster.BroadcastJSON(projectID, ChatMessageDeletedFrame{
+				Type:            ChatFrameMessageDeleted,
+				ID:              replyID,
+				ProjectID:       projectID,
+				ParentMessageID: &id,
+			})
+		}
+		s.broadcaster.BroadcastJSON(projectID, ChatMessageDeletedFrame{
+			Type:      ChatFrameMessageDeleted,
+			ID:        id,
+			ProjectID: projectID,
+		})
 	}
 
 	return nil
 }
+
+// threadReplyIDs returns the ids of a message's thread replies
+func threadReplyIDs(tx *sql.Tx, parentID int64) ([]int64, error) {
+	rows, err := tx.Query("SELECT id FROM messages WHERE parent_message_id = ? ORDER BY id", parentID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get replies: %v", err)
+	}
+	defer rows.Close()
+
+	var ids []int64
+	for rows.Next() {
+		var replyID int64
+		if err := rows.Scan(&replyID); err != nil {
+			return nil, fmt.Errorf("failed to scan reply: %v", err)
+		}
+		ids = append(ids, replyID)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed while reading replies: %v", err)
+	}
+	return ids, nil
+}
+
+func (s *MessageService) getSenderName(userID string) (string, error) {
+	var name string
+	err := s.db.QueryRow("SELECT name FROM users WHERE id = ?", userID).Scan(&name)
+	if err == sql.ErrNoRows {
+		return "", fmt.Errorf("user not found")
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to get user name: %v", err)
+	}
+	return strings.TrimSpace(name), nil
+}
+
+func scanMessages(rows *sql.Rows) ([]models.Message, error) {
+	var messages []models.Message
+	for rows.Next() {
+		var message models.Message
+		var parentID sql.NullInt64
+		var editedAt sql.NullTime
+		err := rows.Scan(&message.ID, &message.UserID, &message.ProjectID, &parentID, &message.SenderName, &message.Content, &message.CreatedAt, &editedAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan message: %v", err)
+		}
+		if parentID.Valid {
+			message.ParentMessageID = &parentID.Int64
+		}
+		if editedAt.Valid {
+			message.EditedAt = &editedAt.Time
+		}
+		messages = append(messages, message)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed while reading messages: %v", err)
+	}
+
+	return messages, nil
+}
+
+func normalizeMessageContent(content string) (string, error) {
+	normalized := strings.TrimSpace(content)
+	if normalized == "" {
+		return "", ErrMessageContentRequired
+	}
+	if utf8.RuneCountInString(normalized) > MaxMessageLength {
+		return "", ErrMessageTooLong
+	}
+	return normalized, nil
+}
+
+func normalizeReaction(emoji string) (string, error) {
+	normalized := strings.TrimSpace(emoji)
+	if normalized == "" || utf8.RuneCountInString(normalized) > MaxReactionLength || strings.ContainsAny(normalized, " \t\n") {
+		return "", ErrInvalidReaction
+	}
+	return normalized, nil
+}
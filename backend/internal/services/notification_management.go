@@ -0,0 +1,121 @@
+package services
+
+import (
+	"errors"
+	"log"
+	"time"
+
+	"backend/internal/models"
+	notifrepo "backend/internal/repository"
+)
+
+// maxBulkNotifications caps how many notifications one bulk action touches
+const maxBulkNotifications = 100
+
+// Bulk notification actions
+const (
+	NotificationBulkRead   = "read"
+	NotificationBulkUnread = "unread"
+	NotificationBulkDelete = "delete"
+)
+
+var ErrNotificationNotFound = errors.New("notification not found")
+
+// ListNotifications returns a page of the user's notifications matching the
+// filter, newest first, with the total number of matches
+func (s *NotificationService) ListNotifications(userID string, filter notifrepo.NotificationFilter, page, limit int) ([]models.Notification, int64, error) {
+	if filter.Type != "" && !models.IsValidNotificationType(filter.Type) {
+		return nil, 0, &ServiceError{Code: "INVALID_REQUEST", Message: "unknown notification type"}
+	}
+	return s.notifRepo.ListNotifications(userID, filter, page, limit)
+}
+
+// MarkAsUnread marks one of the user's notifications unread again
+func (s *NotificationService) MarkAsUnread(notificationID int64, userID string) error {
+	found, err := s.notifRepo.MarkAsUnread(notificationID, userID)
+	if err != nil {
+		return err
+	}
+	if !found {
+		return ErrNotificationNotFound
+	}
+	s.notifyStream(userID)
+	return nil
+}
+
+// DeleteNotification deletes one of the user's notifications
+func (s *NotificationService) DeleteNotification(notificationID int64, userID string) error {
+	found, err := s.notifRepo.DeleteNotification(notificationID, userID)
+	if err != nil {
+		return err
+	}
+	if !found {
+		return ErrNotificationNotFound
+	}
+	s.notifyStream(userID)
+	return nil
+}
+
+// BulkUpdateNotifications marks the user's notifications with the given IDs
+// read or unread, or deletes them, and returns how many changed. IDs that
+// are not the user's are ignored.
+func (s *NotificationService) BulkUpdateNotifications(userID, action string, ids []int64) (int64, error) {
+	if len(ids) == 0 {
+		return 0, &ServiceError{Code: "INVALID_REQUEST", Message: "ids are required"}
+	}
+	if len(ids) > maxBulkNotifications {
+		return 0, &ServiceError{Code: "INVALID_REQUEST", Message: "at most 100 ids per request"}
+	}
+
+	var count int64
+	var err error
+	switch action {
+	case NotificationBulkRead:
+		count, err = s.notifRepo.SetReadForIDs(userID, ids, true)
+	case NotificationBulkUnread:
+		count, err = s.notifRepo.SetReadForIDs(userID, ids, false)
+	case NotificationBulkDelete:
+		count, err = s.notifRepo.DeleteNotifications(userID, ids)
+	default:
+		return 0, &ServiceError{Code: "INVALID_REQUEST", Message: "action must be read, unread or delete"}
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	if count > 0 {
+		s.notifyStream(userID)
+	}
+	return count, nil
+}
+
+// PurgeReadNotifications deletes read notifications older than maxAge and
+// returns how many were deleted
+func (s *NotificationService) PurgeReadNotifications(maxAge time.Duration) (int64, error) {
+	return s.notifRepo.PurgeReadBefore(time.Now().UTC().Add(-maxAge))
+}
+
+// StartNotificationRetention starts a background goroutine that purges read
+// notifications older than maxAge periodically. A maxAge of 0 keeps them
+// forever.
+func (s *NotificationService) StartNotificationRetention(maxAge, interval time.Duration) {
+	if maxAge <= 0 {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			purged, err := s.PurgeReadNotifications(maxAge)
+			if err != nil {
+				log.Printf("Notification retention error: %v", err)
+				continue
+			}
+			if purged > 0 {
+				log.Printf("Purged %d read notifications older than %s", purged, maxAge)
+			}
+		}
+	}()
+}
@@ -0,0 +1,171 @@
+package services
+
+import (
+	"database/sql"
+	"fmt"
+
+	"backend/internal/models"
+)
+
+// GetNotificationPreferences returns the user's effective preference for
+// every notification type, for all projects (projectID 0) or for one project.
+// A project inherits the user's defaults for types it does not override.
+func (s *NotificationService) GetNotificationPreferences(userID string, projectID int64) ([]models.NotificationPreference, error) {
+	if err := s.checkPreferenceProject(userID, projectID); err != nil {
+		return nil, err
+	}
+
+	preferences := make([]models.NotificationPreference, 0, len(models.NotificationTypes))
+	for _, notificationType := range models.NotificationTypes {
+		pref, err := s.preferenceFor(userID, notificationType, projectID)
+		if err != nil {
+			return nil, err
+		}
+		preferences = append(preferences, pref)
+	}
+	return preferences, nil
+}
+
+// UpdateNotificationPreferences stores the given preferences for all projects
+// (projectID 0) or for one project; types not listed, and channels left out of
+// an update, keep their current values. It returns the preferences for every
+// type afterwards.
+func (s *NotificationService) UpdateNotificationPreferences(userID string, projectID int64, updates []models.NotificationPreferenceUpdate) ([]models.NotificationPreference, error) {
+	if err := s.checkPreferenceProject(userID, projectID); err != nil {
+		return nil, err
+	}
+
+	// Resolve the stored values before the transaction, which holds the
+	// connection while it is open
+	preferences := make([]models.NotificationPreference, 0, len(updates))
+	for _, update := range updates {
+		if !models.IsValidNotificationType(update.Type) {
+			return nil, &ServiceError{Code: "INVALID_REQUEST", Message: fmt.Sprintf("unknown notification type '%s'", update.Type)}
+		}
+		if update.InApp == nil && update.Email == nil {
+			return nil, &ServiceError{Code: "INVALID_REQUEST", Message: fmt.Sprintf("in_app or email is required for '%s'", update.Type)}
+		}
+
+		pref, err := s.preferenceFor(userID, update.Type, projectID)
+		if err != nil {
+			return nil, err
+		}
+		if update.InApp != nil {
+			pref.InApp = *update.InApp
+		}
+		if update.Email != nil {
+			pref.Email = *update.Email
+		}
+		preferences = append(preferences, pref)
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to start transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	for _, pref := range preferences {
+		_, err := tx.Exec(`
+			INSERT INTO notification_preferences (user_id, project_id, type, in_app, email, updated_at)
+			VALUES (?, ?, ?, ?, ?, CURRENT_TIMESTAMP)
+			ON CONFLICT(user_id, project_id, type) DO UPDATE SET
+				in_app = excluded.in_app, email = excluded.email, updated_at = excluded.updated_at
+		`, userID, projectID, pref.Type, pref.InApp, pref.Email)
+		if err != nil {
+			return nil, fmt.Errorf("failed to save notification preference: %v", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to save notification preferences: %v", err)
+	}
+
+	return s.GetNotificationPreferences(userID, projectID)
+}
+
+// ResetProjectNotificationPreferences removes the user's overrides for a
+// project so their defaults apply there again
+func (s *NotificationService) ResetProjectNotificationPreferences(userID string, projectID int64) error {
+	if projectID <= 0 {
+		return &ServiceError{Code: "INVALID_REQUEST", Message: "project_id is required"}
+	}
+	if err := s.checkPreferenceProject(userID, projectID); err != nil {
+		return err
+	}
+
+	_, err := s.db.Exec("DELETE FROM notification_preferences WHERE user_id = ? AND project_id = ?", userID, projectID)
+	if err != nil {
+		return fmt.Errorf("failed to reset notification preferences: %v", err)
+	}
+	return nil
+}
+
+// preferenceFor resolves one type's preference: the project's override, then
+// the user's default, then both channels on
+func (s *NotificationService) preferenceFor(userID string, notificationType models.NotificationType, projectID int64) (models.NotificationPreference, error) {
+	pref := models.NotificationPreference{Type: notificationType, InApp: true, Email: true}
+	if projectID > 0 {
+		pref.ProjectID = &projectID
+	}
+
+	var inApp, email bool
+	err := s.db.QueryRow(`
+		SELECT in_app, email FROM notification_preferences
+		WHERE user_id = ? AND type = ? AND project_id IN (0, ?)
+		ORDER BY project_id DESC
+		LIMIT 1
+	`, userID, notificationType, projectID).Scan(&inApp, &email)
+	if err == sql.ErrNoRows {
+		return pref, nil
+	}
+	if err != nil {
+		return pref, fmt.Errorf("failed to get notification preferences: %v", err)
+	}
+
+	pref.InApp, pref.Email = inApp, email
+	return pref, nil
+}
+
+// checkPreferenceProject makes sure project-level preferences are only set
+// for projects the user belongs to
+func (s *NotificationService) checkPreferenceProject(userID string, projectID int64) error {
+	if projectID == 0 {
+		return nil
+	}
+	isMember, err := s.pmRepo.IsMember(projectID, userID)
+	if err != nil {
+		return fmt.Errorf("failed to check access: %v", err)
+	}
+	if !isMember {
+		return &ServiceError{Code: "ACCESS_DENIED", Message: "access denied"}
+	}
+	return nil
+}
+
+// projectForEntity finds the project a notification's entity belongs to, or
+// 0 when it cannot be resolved (the user's defaults then apply)
+func (s *NotificationService) projectForEntity(entityType models.EntityType, entityID int64) int64 {
+	var query string
+	switch entityType {
+	case models.EntityProject:
+		return entityID
+	case models.EntityTask:
+		query = "SELECT st.project_id FROM tasks t JOIN stages st ON st.id = t.stage_id WHERE t.id = ?"
+	case models.EntityComment:
+		query = `SELECT st.project_id FROM comments c
+			JOIN tasks t ON t.id = c.task_id
+			JOIN stages st ON st.id = t.stage_id
+			WHERE c.id = ?`
+	case models.EntityMessage:
+		query = "SELECT project_id FROM messages WHERE id = ?"
+	default:
+		return 0
+	}
+
+	var projectID int64
+	if err := s.db.QueryRow(query, entityID).Scan(&projectID); err != nil {
+		return 0
+	}
+	return projectID
+}
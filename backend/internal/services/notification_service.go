@@ -2,8 +2,10 @@ package services
 
 import (
 	"database/sql"
+	"encoding/json"
 	"fmt"
 	"log"
+	"strings"
 	"time"
 
 	"backend/internal/auth/repository"
@@ -16,8 +18,12 @@ import (
 type NotificationService struct {
 	db           *sql.DB
 	notifRepo    *notifrepo.NotificationRepository
+	pmRepo       *notifrepo.ProjectMemberRepository
 	userRepo     *repository.UserRepository
 	emailService *services.EmailService
+	stream       *NotificationStream
+
+	requireVerifiedEmail bool
 }
 
 // NewNotificationService creates a new NotificationService
@@ -25,11 +31,24 @@ func NewNotificationService(db *sql.DB, emailService *services.EmailService) *No
 	return &NotificationService{
 		db:           db,
 		notifRepo:    notifrepo.NewNotificationRepository(db),
+		pmRepo:       notifrepo.NewProjectMemberRepository(db),
 		userRepo:     repository.NewUserRepository(db),
 		emailService: emailService,
 	}
 }
 
+// SetStream registers the stream that open notification streams listen on
+// for new notifications and read-state changes
+func (s *NotificationService) SetStream(stream *NotificationStream) {
+	s.stream = stream
+}
+
+// SetRequireVerifiedEmail stops notification and digest emails to users who
+// have not verified their email address; in-app notifications still arrive
+func (s *NotificationService) SetRequireVerifiedEmail(require bool) {
+	s.requireVerifiedEmail = require
+}
+
 // NotifyMemberAdded sends a notification when a user is added to a project
 func (s *NotificationService) NotifyMemberAdded(projectID int64, addedUserID, actorID, actorName, projectName string) error {
 	// Don't notify the actor themselves
@@ -39,22 +58,12 @@ func (s *NotificationService) NotifyMemberAdded(projectID int64, addedUserID, ac
 
 	message := fmt.Sprintf("You were added to project '%s' by %s", projectName, actorName)
 
-	// Create notification
-	_, err := s.notifRepo.CreateNotification(
-		addedUserID,
-		models.NotificationMemberAdded,
-		message,
-		string(models.EntityProject),
-		projectID,
-	)
-	if err != nil {
+	// Create the notification and email it, as the user's preferences allow
+	if err := s.deliver(addedUserID, models.NotificationMemberAdded, models.EntityProject, projectID, "Project Invitation", message); err != nil {
 		log.Printf("Failed to create member added notification: %v", err)
 		return err
 	}
 
-	// Send email asynchronously (non-blocking)
-	go s.sendEmailNotification(addedUserID, "Project Invitation", message)
-
 	return nil
 }
 
@@ -66,56 +75,299 @@ func (s *NotificationService) NotifyTaskAssigned(taskID int64, assignedUserID, a
 	}
 
 	message := fmt.Sprintf("You were assigned task '%s' by %s", taskTitle, actorName)
+	email := services.TaskAssignedEmail{
+		ActorName:   actorName,
+		TaskTitle:   taskTitle,
+		ProjectName: s.projectName(s.projectForEntity(models.EntityTask, taskID)),
+	}
 
-	// Create notification
-	_, err := s.notifRepo.CreateNotification(
-		assignedUserID,
-		models.NotificationTaskAssigned,
-		message,
-		string(models.EntityTask),
-		taskID,
-	)
+	// Create the notification and email it, as the user's preferences allow
+	err := s.deliverWithEmail(assignedUserID, models.NotificationTaskAssigned, models.EntityTask, taskID, message, func(to, locale string) error {
+		return s.emailService.SendTaskAssigned(to, locale, email)
+	})
 	if err != nil {
 		log.Printf("Failed to create task assigned notification: %v", err)
 		return err
 	}
 
-	// Send email asynchronously (non-blocking)
-	go s.sendEmailNotification(assignedUserID, "Task Assigned", message)
+	return nil
+}
+
+// NotifyDeadlineNear sends a notification when a task deadline is at most
+// offset away. CheckDeadlines makes sure each reminder is sent only once.
+func (s *NotificationService) NotifyDeadlineNear(taskID int64, userID, taskTitle string, offset time.Duration) error {
+	dueIn := formatReminderOffset(offset)
+	message := fmt.Sprintf("Task '%s' is due within %s", taskTitle, dueIn)
+	email := services.DeadlineEmail{TaskTitle: taskTitle, DueIn: dueIn}
+
+	// Create the notification and email it, as the user's preferences allow
+	err := s.deliverWithEmail(userID, models.NotificationDeadlineNear, models.EntityTask, taskID, message, func(to, locale string) error {
+		return s.emailService.SendDeadlineReminder(to, locale, email)
+	})
+	if err != nil {
+		log.Printf("Failed to create deadline notification: %v", err)
+		return err
+	}
 
 	return nil
 }
 
-// NotifyDeadlineNear sends a notification when a task deadline is approaching
-func (s *NotificationService) NotifyDeadlineNear(taskID int64, userID, taskTitle string) error {
-	// Check for duplicate notification
-	exists, err := s.notifRepo.CheckDuplicateDeadlineNotification(userID, taskID)
+// NotifyOverdue sends a notification when a task passes its deadline without
+// reaching a final stage
+func (s *NotificationService) NotifyOverdue(taskID int64, userID, taskTitle string) error {
+	message := fmt.Sprintf("Task '%s' is overdue", taskTitle)
+	email := services.DeadlineEmail{TaskTitle: taskTitle, Overdue: true}
+
+	// Create the notification and email it, as the user's preferences allow
+	err := s.deliverWithEmail(userID, models.NotificationOverdue, models.EntityTask, taskID, message, func(to, locale string) error {
+		return s.emailService.SendDeadlineReminder(to, locale, email)
+	})
 	if err != nil {
+		log.Printf("Failed to create overdue notification: %v", err)
 		return err
 	}
-	if exists {
-		return nil // Already notified
+
+	return nil
+}
+
+// NotifyMentioned sends a notification when a user is @mentioned. where
+// describes the place, e.g. "a comment on 'Fix login'".
+func (s *NotificationService) NotifyMentioned(mentionedUserID, actorID, actorName, where string, entityType models.EntityType, entityID int64) error {
+	// Don't notify the actor themselves
+	if mentionedUserID == actorID {
+		return nil
+	}
+
+	message := fmt.Sprintf("%s mentioned you in %s", actorName, where)
+
+	// Create the notification and email it, as the user's preferences allow
+	if err := s.deliver(mentionedUserID, models.NotificationMentioned, entityType, entityID, "You were mentioned", message); err != nil {
+		log.Printf("Failed to create mention notification: %v", err)
+		return err
 	}
 
-	message := fmt.Sprintf("Task '%s' is due within 24 hours", taskTitle)
+	return nil
+}
 
-	// Create notification
-	_, err = s.notifRepo.CreateNotification(
-		userID,
-		models.NotificationDeadlineNear,
-		message,
-		string(models.EntityTask),
+// NotifyTaskCompleted notifies the task's creator and watchers that it was
+// moved into a final stage by actorID
+func (s *NotificationService) NotifyTaskCompleted(taskID int64, actorID string) error {
+	var title, creatorID string
+	err := s.db.QueryRow(
+		"SELECT title, COALESCE(user_id, '') FROM tasks WHERE id = ?",
 		taskID,
-	)
+	).Scan(&title, &creatorID)
+	if err != nil {
+		return fmt.Errorf("failed to get task: %v", err)
+	}
+
+	watchers, err := s.taskWatchers(taskID)
 	if err != nil {
-		log.Printf("Failed to create deadline notification: %v", err)
 		return err
 	}
 
-	// Send email asynchronously (non-blocking)
-	go s.sendEmailNotification(userID, "Deadline Reminder", message)
+	message := fmt.Sprintf("Task '%s' was completed by %s", title, s.userName(actorID))
+	recipients := append([]string{creatorID}, watchers...)
 
-	return nil
+	return s.deliverToMembers(s.projectForEntity(models.EntityTask, taskID), recipients, actorID,
+		models.NotificationTaskCompleted, models.EntityTask, taskID, "Task Completed", message)
+}
+
+// NotifyCommentAdded notifies the task's assignee, its watchers and everyone
+// who commented on it before, except the comment's author. Users @mentioned in the
+// comment already got a mention notification and are skipped.
+func (s *NotificationService) NotifyCommentAdded(comment *models.Comment) error {
+	var title string
+	var assignee sql.NullString
+	err := s.db.QueryRow("SELECT title, assigned_to FROM tasks WHERE id = ?", comment.TaskID).Scan(&title, &assignee)
+	if err != nil {
+		return fmt.Errorf("failed to get task: %v", err)
+	}
+
+	watchers, err := s.taskWatchers(comment.TaskID)
+	if err != nil {
+		return err
+	}
+	recipients := append([]string{assignee.String}, watchers...)
+
+	rows, err := s.db.Query(`
+		SELECT DISTINCT user_id FROM comments
+		WHERE task_id = ? AND id < ? AND user_id IS NOT NULL
+	`, comment.TaskID, comment.ID)
+	if err != nil {
+		return fmt.Errorf("failed to get earlier commenters: %v", err)
+	}
+	for rows.Next() {
+		var userID string
+		if err := rows.Scan(&userID); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan commenter: %v", err)
+		}
+		recipients = append(recipients, userID)
+	}
+	rows.Close()
+
+	mentioned, err := s.mentionedUsers(models.EntityComment, comment.ID)
+	if err != nil {
+		return err
+	}
+	notMentioned := recipients[:0]
+	for _, userID := range recipients {
+		if !mentioned[userID] {
+			notMentioned = append(notMentioned, userID)
+		}
+	}
+
+	message := fmt.Sprintf("%s commented on task '%s'", comment.AuthorName, title)
+
+	return s.deliverToMembers(s.projectForEntity(models.EntityTask, comment.TaskID), notMentioned, comment.UserID,
+		models.NotificationCommentAdded, models.EntityComment, comment.ID, "New Comment", message)
+}
+
+// ActivityLogged notifies a task's watchers when the task is updated, moved,
+// assigned or relabelled. It implements ActivityObserver.
+func (s *NotificationService) ActivityLogged(entry *models.ActivityLog) {
+	if entry.EntityType != models.EntityTask || entry.EntityID == 0 {
+		return
+	}
+
+	var title, stageName string
+	var isFinal bool
+	var assignee sql.NullString
+	err := s.db.QueryRow(`
+		SELECT t.title, st.name, COALESCE(st.is_final, 0), t.assigned_to
+		FROM tasks t
+		JOIN stages st ON st.id = t.stage_id
+		WHERE t.id = ?
+	`, entry.EntityID).Scan(&title, &stageName, &isFinal, &assignee)
+	if err != nil {
+		return
+	}
+
+	actor := s.userName(entry.UserID)
+	skip := ""
+	var message string
+	switch entry.Action {
+	case models.ActivityTaskUpdated:
+		message = fmt.Sprintf("%s updated task '%s'", actor, title)
+	case models.ActivityTaskMoved:
+		// Moves into a final stage are reported as task_completed
+		if isFinal {
+			return
+		}
+		message = fmt.Sprintf("%s moved task '%s' to '%s'", actor, title, stageName)
+	case models.ActivityTaskAssigned:
+		if !assignee.Valid || assignee.String == "" {
+			message = fmt.Sprintf("%s unassigned task '%s'", actor, title)
+			break
+		}
+		// The new assignee gets task_assigned instead
+		skip = assignee.String
+		message = fmt.Sprintf("%s assigned task '%s' to %s", actor, title, s.userName(assignee.String))
+	case models.ActivityLabelAssigned:
+		message = fmt.Sprintf("%s added label '%s' to task '%s'", actor, activityDetail(entry.Details, "label"), title)
+	case models.ActivityLabelRemoved:
+		message = fmt.Sprintf("%s removed label '%s' from task '%s'", actor, activityDetail(entry.Details, "label"), title)
+	default:
+		return
+	}
+
+	watchers, err := s.taskWatchers(entry.EntityID)
+	if err != nil {
+		log.Printf("Failed to get watchers of task %d: %v", entry.EntityID, err)
+		return
+	}
+	recipients := watchers[:0]
+	for _, userID := range watchers {
+		if userID != skip {
+			recipients = append(recipients, userID)
+		}
+	}
+
+	s.deliverToMembers(entry.ProjectID, recipients, entry.UserID,
+		models.NotificationTaskUpdated, models.EntityTask, entry.EntityID, "Task Updated", message)
+}
+
+// taskWatchers returns the ids of the users watching a task
+func (s *NotificationService) taskWatchers(taskID int64) ([]string, error) {
+	rows, err := s.db.Query("SELECT user_id FROM task_watchers WHERE task_id = ? ORDER BY created_at, user_id", taskID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get watchers: %v", err)
+	}
+	defer rows.Close()
+
+	var watchers []string
+	for rows.Next() {
+		var userID string
+		if err := rows.Scan(&userID); err != nil {
+			return nil, fmt.Errorf("failed to scan watcher: %v", err)
+		}
+		watchers = append(watchers, userID)
+	}
+	return watchers, rows.Err()
+}
+
+// activityDetail reads one string field from an activity's details JSON
+func activityDetail(details, key string) string {
+	var fields map[string]interface{}
+	if err := json.Unmarshal([]byte(details), &fields); err != nil {
+		return ""
+	}
+	value, _ := fields[key].(string)
+	return value
+}
+
+// mentionedUsers returns the users @mentioned in a comment or message
+func (s *NotificationService) mentionedUsers(entityType models.EntityType, entityID int64) (map[string]bool, error) {
+	rows, err := s.db.Query("SELECT user_id FROM mentions WHERE entity_type = ? AND entity_id = ?", entityType, entityID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get mentions: %v", err)
+	}
+	defer rows.Close()
+
+	mentioned := map[string]bool{}
+	for rows.Next() {
+		var userID string
+		if err := rows.Scan(&userID); err != nil {
+			return nil, fmt.Errorf("failed to scan mention: %v", err)
+		}
+		mentioned[userID] = true
+	}
+	return mentioned, rows.Err()
+}
+
+// deliverToMembers delivers one notification to each recipient who is still a
+// member of the project, once, skipping the actor
+func (s *NotificationService) deliverToMembers(projectID int64, recipients []string, actorID string, notificationType models.NotificationType, entityType models.EntityType, entityID int64, subject, message string) error {
+	var firstErr error
+	seen := map[string]bool{"": true, actorID: true}
+	for _, userID := range recipients {
+		if seen[userID] {
+			continue
+		}
+		seen[userID] = true
+
+		isMember, err := s.pmRepo.IsMember(projectID, userID)
+		if err != nil || !isMember {
+			continue
+		}
+		if err := s.deliver(userID, notificationType, entityType, entityID, subject, message); err != nil {
+			log.Printf("Failed to create %s notification for %s: %v", notificationType, userID, err)
+			if firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+	return firstErr
+}
+
+// userName returns a user's display name for notification messages
+func (s *NotificationService) userName(userID string) string {
+	var name string
+	if err := s.db.QueryRow("SELECT name FROM users WHERE id = ?", userID).Scan(&name); err != nil || strings.TrimSpace(name) == "" {
+		return "someone"
+	}
+	return strings.TrimSpace(name)
 }
 
 // GetUserNotifications retrieves paginated notifications for a user
@@ -132,12 +384,23 @@ func (s *NotificationService) GetUserNotifications(userID string, page, limit in
 
 // MarkAsRead marks a notification as read
 func (s *NotificationService) MarkAsRead(notificationID int64, userID string) error {
-	return s.notifRepo.MarkAsRead(notificationID, userID)
+	if err := s.notifRepo.MarkAsRead(notificationID, userID); err != nil {
+		return err
+	}
+	s.notifyStream(userID)
+	return nil
 }
 
 // MarkAllAsRead marks all notifications as read for a user
 func (s *NotificationService) MarkAllAsRead(userID string) (int64, error) {
-	return s.notifRepo.MarkAllAsRead(userID)
+	count, err := s.notifRepo.MarkAllAsRead(userID)
+	if err != nil {
+		return 0, err
+	}
+	if count > 0 {
+		s.notifyStream(userID)
+	}
+	return count, nil
 }
 
 // GetUnreadCount returns the count of unread notifications
@@ -145,8 +408,62 @@ func (s *NotificationService) GetUnreadCount(userID string) (int64, error) {
 	return s.notifRepo.GetUnreadCount(userID)
 }
 
-// sendEmailNotification sends an email notification (non-blocking)
-func (s *NotificationService) sendEmailNotification(userID, subject, message string) {
+// GetNotificationsAfter returns up to limit of the user's notifications
+// newer than afterID, oldest first
+func (s *NotificationService) GetNotificationsAfter(userID string, afterID int64, limit int) ([]models.Notification, error) {
+	return s.notifRepo.GetNotificationsAfter(userID, afterID, limit)
+}
+
+// GetLatestNotificationID returns the ID of the user's newest notification,
+// or 0 when they have none
+func (s *NotificationService) GetLatestNotificationID(userID string) (int64, error) {
+	return s.notifRepo.GetLatestNotificationID(userID)
+}
+
+// notifyStream wakes the user's open notification streams
+func (s *NotificationService) notifyStream(userID string) {
+	if s.stream != nil {
+		s.stream.Notify(userID)
+	}
+}
+
+// deliver creates the in-app notification and emails it, each only when the
+// user's preferences for the type (in the entity's project) allow it
+func (s *NotificationService) deliver(userID string, notificationType models.NotificationType, entityType models.EntityType, entityID int64, subject, message string) error {
+	return s.deliverWithEmail(userID, notificationType, entityType, entityID, message, func(to, locale string) error {
+		return s.emailService.SendNotification(to, locale, subject, message)
+	})
+}
+
+// deliverWithEmail is deliver for notifications that have their own email
+// template; sendEmail is called with the user's address and language
+func (s *NotificationService) deliverWithEmail(userID string, notificationType models.NotificationType, entityType models.EntityType, entityID int64, message string, sendEmail func(to, locale string) error) error {
+	projectID := s.projectForEntity(entityType, entityID)
+	pref, err := s.preferenceFor(userID, notificationType, projectID)
+	if err != nil {
+		return err
+	}
+
+	if pref.InApp {
+		if _, err := s.notifRepo.CreateProjectNotification(userID, notificationType, message, string(entityType), entityID, projectID); err != nil {
+			return err
+		}
+		s.notifyStream(userID)
+	}
+
+	// Users on a daily or weekly digest get the notification in their next
+	// digest instead
+	if pref.Email && s.emailsImmediately(userID) {
+		// Send email asynchronously (non-blocking)
+		go s.sendEmailNotification(userID, sendEmail)
+	}
+
+	return nil
+}
+
+// sendEmailNotification looks up the user's address and language and sends
+// them an email (non-blocking)
+func (s *NotificationService) sendEmailNotification(userID string, sendEmail func(to, locale string) error) {
 	if s.emailService == nil {
 		return
 	}
@@ -163,48 +480,168 @@ func (s *NotificationService) sendEmailNotification(userID, subject, message str
 		log.Printf("User %s has no email for notification", userID)
 		return
 	}
+	if s.requireVerifiedEmail && !user.EmailVerified {
+		return
+	}
 
-	// Send email - the service handles the formatting
-	err = s.emailService.SendNotification(email, subject, message)
-	if err != nil {
+	// Send email - the templates handle the formatting
+	if err := sendEmail(email, user.Language); err != nil {
 		log.Printf("Failed to send email notification to %s: %v", email, err)
 	}
 }
 
-// CheckDeadlines is a background job that checks for approaching deadlines
-// It should be called periodically (e.g., every 15 minutes)
+// projectName returns the name of a project, or "" when it is unknown
+func (s *NotificationService) projectName(projectID int64) string {
+	var name string
+	s.db.QueryRow("SELECT name FROM projects WHERE id = ?", projectID).Scan(&name)
+	return name
+}
+
+// deadlineTask is an assigned task with a deadline in a non-final stage
+type deadlineTask struct {
+	id         int64
+	title      string
+	assignedTo string
+	projectID  int64
+	deadline   time.Time
+}
+
+// CheckDeadlines is a background job that sends deadline reminders and
+// overdue notices. It should be called periodically (e.g., every 15 minutes);
+// each reminder is recorded in deadline_reminders so it goes out only once
+// per task deadline.
 func (s *NotificationService) CheckDeadlines() error {
-	// Find tasks with deadlines in the next 24 hours that haven't been notified
+	return s.checkDeadlinesAt(time.Now())
+}
+
+func (s *NotificationService) checkDeadlinesAt(now time.Time) error {
+	// Forget reminders sent for a deadline the task no longer has, so a
+	// moved deadline is reminded about again
+	_, err := s.db.Exec(`
+		DELETE FROM deadline_reminders
+		WHERE NOT EXISTS (
+			SELECT 1 FROM tasks t
+			WHERE t.id = deadline_reminders.task_id AND t.deadline = deadline_reminders.deadline
+		)
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to clear stale deadline reminders: %v", err)
+	}
+
+	tasks, err := s.deadlineTasks()
+	if err != nil {
+		return err
+	}
+
+	for _, task := range tasks {
+		if !task.deadline.After(now) {
+			// Tasks overdue since before the lookback window are skipped so the
+			// first run after an upgrade doesn't flood inboxes
+			if now.Sub(task.deadline) > overdueLookback {
+				continue
+			}
+			if err := s.sendReminderOnce(task, 0); err != nil {
+				log.Printf("Failed to notify overdue task %d: %v", task.id, err)
+			}
+			continue
+		}
+
+		offsets, err := s.reminderOffsets(task.assignedTo, task.projectID)
+		if err != nil {
+			log.Printf("Failed to load reminder offsets for task %d: %v", task.id, err)
+			continue
+		}
+
+		// Offsets are sorted longest first. When several are due at once (the
+		// deadline was set close to now, or the checker was down) only the
+		// shortest is sent; the longer ones are recorded as sent.
+		due := -1
+		for i, offset := range offsets {
+			if task.deadline.Sub(now) <= time.Duration(offset)*time.Minute {
+				due = i
+			}
+		}
+		if due < 0 {
+			continue
+		}
+		for _, offset := range offsets[:due] {
+			if _, err := s.claimReminder(task, offset); err != nil {
+				log.Printf("Failed to record reminder for task %d: %v", task.id, err)
+			}
+		}
+		if err := s.sendReminderOnce(task, offsets[due]); err != nil {
+			log.Printf("Failed to notify deadline for task %d: %v", task.id, err)
+		}
+	}
+
+	return nil
+}
+
+// deadlineTasks lists assigned tasks with a deadline that are not yet in a
+// final stage
+func (s *NotificationService) deadlineTasks() ([]deadlineTask, error) {
 	rows, err := s.db.Query(`
-		SELECT t.id, t.title, t.assigned_to
+		SELECT t.id, t.title, t.assigned_to, st.project_id, t.deadline
 		FROM tasks t
+		JOIN stages st ON st.id = t.stage_id
 		WHERE t.deadline IS NOT NULL
-		AND t.deadline > datetime('now')
-		AND t.deadline <= datetime('now', '+24 hours')
 		AND t.assigned_to IS NOT NULL
 		AND t.assigned_to != ''
+		AND COALESCE(st.is_final, 0) != 1
 	`)
 	if err != nil {
-		return fmt.Errorf("failed to query deadline tasks: %v", err)
+		return nil, fmt.Errorf("failed to query deadline tasks: %v", err)
 	}
 	defer rows.Close()
 
+	var tasks []deadlineTask
 	for rows.Next() {
-		var taskID int64
-		var taskTitle, assignedTo string
-
-		if err := rows.Scan(&taskID, &taskTitle, &assignedTo); err != nil {
+		var task deadlineTask
+		if err := rows.Scan(&task.id, &task.title, &task.assignedTo, &task.projectID, &task.deadline); err != nil {
 			log.Printf("Failed to scan task for deadline check: %v", err)
 			continue
 		}
+		tasks = append(tasks, task)
+	}
+	return tasks, rows.Err()
+}
 
-		// Send notification
-		if err := s.NotifyDeadlineNear(taskID, assignedTo, taskTitle); err != nil {
-			log.Printf("Failed to notify deadline for task %d: %v", taskID, err)
-		}
+// sendReminderOnce sends the reminder for offset minutes before the task's
+// deadline (0 for the overdue notice) unless it was already sent
+func (s *NotificationService) sendReminderOnce(task deadlineTask, offset int) error {
+	claimed, err := s.claimReminder(task, offset)
+	if err != nil || !claimed {
+		return err
 	}
 
-	return nil
+	if offset == 0 {
+		err = s.NotifyOverdue(task.id, task.assignedTo, task.title)
+	} else {
+		err = s.NotifyDeadlineNear(task.id, task.assignedTo, task.title, time.Duration(offset)*time.Minute)
+	}
+	if err != nil {
+		// Release the claim so the next run tries again
+		s.db.Exec(
+			"DELETE FROM deadline_reminders WHERE task_id = ? AND user_id = ? AND offset_minutes = ?",
+			task.id, task.assignedTo, offset,
+		)
+	}
+	return err
+}
+
+// claimReminder records a reminder for the task's current deadline and
+// reports whether it was new. The deadline is copied from the task row so it
+// compares equal to it when stale reminders are cleared.
+func (s *NotificationService) claimReminder(task deadlineTask, offset int) (bool, error) {
+	result, err := s.db.Exec(`
+		INSERT OR IGNORE INTO deadline_reminders (task_id, user_id, deadline, offset_minutes)
+		SELECT id, ?, deadline, ? FROM tasks WHERE id = ? AND deadline IS NOT NULL
+	`, task.assignedTo, offset, task.id)
+	if err != nil {
+		return false, fmt.Errorf("failed to record deadline reminder: %v", err)
+	}
+	n, _ := result.RowsAffected()
+	return n > 0, nil
 }
 
 // StartDeadlineChecker starts a background goroutine that checks deadlines periodically
@@ -0,0 +1,53 @@
+package services
+
+import "sync"
+
+// NotificationStream tells open notification streams that a user's
+// notifications changed. It only carries the signal; subscribers read the
+// changes from the database, so a missed or coalesced signal loses nothing.
+type NotificationStream struct {
+	mu          sync.Mutex
+	subscribers map[string]map[chan struct{}]struct{}
+}
+
+// NewNotificationStream creates a new NotificationStream
+func NewNotificationStream() *NotificationStream {
+	return &NotificationStream{subscribers: make(map[string]map[chan struct{}]struct{})}
+}
+
+// Subscribe returns a channel that receives a value whenever the user's
+// notifications change, and a function that ends the subscription. Changes
+// that happen while the subscriber is busy are coalesced into one signal.
+func (s *NotificationStream) Subscribe(userID string) (<-chan struct{}, func()) {
+	ch := make(chan struct{}, 1)
+
+	s.mu.Lock()
+	if s.subscribers[userID] == nil {
+		s.subscribers[userID] = make(map[chan struct{}]struct{})
+	}
+	s.subscribers[userID][ch] = struct{}{}
+	s.mu.Unlock()
+
+	unsubscribe := func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		delete(s.subscribers[userID], ch)
+		if len(s.subscribers[userID]) == 0 {
+			delete(s.subscribers, userID)
+		}
+	}
+	return ch, unsubscribe
+}
+
+// Notify signals every stream the user has open
+func (s *NotificationStream) Notify(userID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for ch := range s.subscribers[userID] {
+		select {
+		case ch <- struct{}{}:
+		default:
+			// A signal is already pending
+		}
+	}
+}
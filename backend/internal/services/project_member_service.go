@@ -3,21 +3,35 @@ package services
 import (
 	"database/sql"
 	"fmt"
+	"net/mail"
+	"os"
+	"strings"
 	"time"
 
 	"backend/internal/auth/repository"
+	"backend/internal/auth/services"
 	"backend/internal/models"
 	pmrepository "backend/internal/repository"
 
 	"github.com/google/uuid"
 )
 
+// ConnectionManager tracks live hub connections for project members
+type ConnectionManager interface {
+	DisconnectUser(projectID int64, userID string)
+	OnlineUsers(projectID int64) []string
+}
+
 // ProjectMemberService handles business logic for project members
 type ProjectMemberService struct {
-	db         *sql.DB
-	pmRepo     *pmrepository.ProjectMemberRepository
-	inviteRepo *pmrepository.InviteRepository
-	userRepo   *repository.UserRepository
+	db          *sql.DB
+	pmRepo      *pmrepository.ProjectMemberRepository
+	inviteRepo  *pmrepository.InviteRepository
+	userRepo    *repository.UserRepository
+	connections ConnectionManager
+	emails      *services.EmailService
+
+	requireVerifiedEmail bool
 }
 
 // NewProjectMemberService creates a new ProjectMemberService
@@ -30,6 +44,24 @@ func NewProjectMemberService(db *sql.DB) *ProjectMemberService {
 	}
 }
 
+// SetConnectionManager registers the hub whose connections are closed when a
+// member is removed from a project and which reports who is online
+func (s *ProjectMemberService) SetConnectionManager(connections ConnectionManager) {
+	s.connections = connections
+}
+
+// SetEmailService enables sending invite links by email
+func (s *ProjectMemberService) SetEmailService(emails *services.EmailService) {
+	s.emails = emails
+}
+
+// SetRequireVerifiedEmail makes accepting an invite require a verified email
+// address, so an account registered under someone else's address cannot
+// join projects in their name
+func (s *ProjectMemberService) SetRequireVerifiedEmail(require bool) {
+	s.requireVerifiedEmail = require
+}
+
 // CreateInvite creates an invite link for a project
 func (s *ProjectMemberService) CreateInvite(projectID int64, invitedBy string, expiresInHours int) (*models.ProjectInvite, error) {
 	// Check if user is owner
@@ -77,8 +109,67 @@ func (s *ProjectMemberService) CreateInvite(projectID int64, invitedBy string, e
 	return invite, nil
 }
 
+// InviteByEmail creates an invite link for a project and emails it to
+// toEmail, in the language of the account with that address if there is one
+func (s *ProjectMemberService) InviteByEmail(projectID int64, invitedBy string, expiresInHours int, toEmail string) (*models.ProjectInvite, error) {
+	toEmail = strings.ToLower(strings.TrimSpace(toEmail))
+	if _, err := mail.ParseAddress(toEmail); err != nil {
+		return nil, &ServiceError{Code: "INVALID_REQUEST", Message: "invalid email address"}
+	}
+	if s.emails == nil {
+		return nil, fmt.Errorf("invite emails are not configured")
+	}
+
+	invite, err := s.CreateInvite(projectID, invitedBy, expiresInHours)
+	if err != nil {
+		return nil, err
+	}
+
+	data := services.InviteEmail{
+		InviterName: "someone",
+		AcceptURL:   inviteURL(invite.ID),
+		ExpiresAt:   invite.ExpiresAt,
+	}
+	if inviter, err := s.userRepo.GetUserByID(invitedBy); err == nil && inviter != nil {
+		data.InviterName = inviter.Name
+	}
+	if err := s.db.QueryRow("SELECT name FROM projects WHERE id = ?", projectID).Scan(&data.ProjectName); err != nil {
+		return nil, fmt.Errorf("failed to get project name: %v", err)
+	}
+
+	var locale string
+	if user, err := s.userRepo.GetUserByEmail(toEmail); err == nil && user != nil {
+		locale = user.Language
+	}
+
+	if err := s.emails.SendInvite(toEmail, locale, data); err != nil {
+		return nil, err
+	}
+
+	return invite, nil
+}
+
+// inviteURL is the frontend page where an invite is accepted
+func inviteURL(inviteID string) string {
+	frontendURL := os.Getenv("FRONTEND_URL")
+	if frontendURL == "" {
+		frontendURL = "http://localhost:4200"
+	}
+	return strings.TrimRight(frontendURL, "/") + "/invites/" + inviteID
+}
+
 // AcceptInviteByID accepts an invite using the invite ID
 func (s *ProjectMemberService) AcceptInviteByID(inviteID string, userID string) (*models.ProjectInvite, error) {
+	if s.requireVerifiedEmail {
+		user, err := s.userRepo.GetUserByID(userID)
+		if err != nil {
+			return nil, err
+		}
+		if user == nil || !user.EmailVerified {
+			return nil, &ServiceError{Code: "EMAIL_NOT_VERIFIED", Message: "verify your email address before accepting invites"}
+		}
+	}
+
 	// Get invite
 	invite, err := s.inviteRepo.GetInviteByID(inviteID)
 	if err != nil {
@@ -179,6 +270,11 @@ func (s *ProjectMemberService) AddMember(projectID int64, newMemberID, invitedBy
 		return nil, &ServiceError{Code: "INVALID_REQUEST", Message: "cannot add yourself as a member"}
 	}
 
+	// Same policy as accepting an invite
+	if s.requireVerifiedEmail && !user.EmailVerified {
+		return nil, &ServiceError{Code: "EMAIL_NOT_VERIFIED", Message: "the user has not verified their email address"}
+	}
+
 	// Add member
 	member, err := s.pmRepo.AddMember(projectID, newMemberID, string(models.RoleMember), invitedBy)
 	if err != nil {
@@ -219,6 +315,11 @@ func (s *ProjectMemberService) RemoveMember(projectID int64, targetUserID, remov
 		return err
 	}
 
+	// Drop any live chat/board connections the removed member still holds
+	if s.connections != nil {
+		s.connections.DisconnectUser(projectID, targetUserID)
+	}
+
 	return nil
 }
 
@@ -263,6 +364,56 @@ func (s *ProjectMemberService) GetMembers(projectID int64, requesterID string) (
 	return result, nil
 }
 
+// GetOnlineMembers returns the project members currently connected to the
+// project's hub, with display names from their user profiles
+func (s *ProjectMemberService) GetOnlineMembers(projectID int64, requesterID string) ([]models.ProjectPresence, error) {
+	exists, err := s.projectExists(projectID)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, &ServiceError{Code: "PROJECT_NOT_FOUND", Message: "project not found"}
+	}
+
+	hasAccess, err := s.HasAccess(projectID, requesterID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check access: %v", err)
+	}
+	if !hasAccess {
+		return nil, &ServiceError{Code: "ACCESS_DENIED", Message: "access denied"}
+	}
+
+	result := []models.ProjectPresence{}
+	if s.connections == nil {
+		return result, nil
+	}
+
+	online := make(map[string]bool)
+	for _, userID := range s.connections.OnlineUsers(projectID) {
+		online[userID] = true
+	}
+	if len(online) == 0 {
+		return result, nil
+	}
+
+	members, err := s.pmRepo.GetMembersWithUserInfo(projectID)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, m := range members {
+		if online[m.UserID] {
+			result = append(result, models.ProjectPresence{
+				UserID: m.UserID,
+				Name:   m.UserName,
+				Role:   string(m.Role),
+			})
+		}
+	}
+
+	return result, nil
+}
+
 // IsMember checks if a user is a member of a project
 func (s *ProjectMemberService) IsMember(projectID int64, userID string) (bool, error) {
 	return s.pmRepo.IsMember(projectID, userID)
@@ -51,15 +51,25 @@ func (s *ProjectService) CreateProject(ownerID, name, description string) (*mode
 	}, nil
 }
 
-// GetAllProjects retrieves all projects where user is owner or member
+// GetAllProjects retrieves all projects where user is owner or member, with
+// the number of chat messages from others the user has not read yet
 func (s *ProjectService) GetAllProjects(userID string) ([]models.Project, error) {
 	rows, err := s.db.Query(`
-		SELECT DISTINCT p.id, p.owner_id, p.name, p.description, p.created_at, p.updated_at 
+		SELECT DISTINCT p.id, p.owner_id, p.name, p.description, p.created_at, p.updated_at,
+			(
+				SELECT COUNT(*) FROM messages m
+				WHERE m.project_id = p.id
+					AND COALESCE(m.user_id, '') != ?
+					AND m.id > COALESCE(
+						(SELECT mr.last_read_message_id FROM message_reads mr WHERE mr.user_id = ? AND mr.project_id = p.id),
+						0
+					)
+			) AS unread_count
 		FROM projects p
 		LEFT JOIN project_members pm ON p.id = pm.project_id
 		WHERE p.owner_id = ? OR pm.user_id = ?
 		ORDER BY p.created_at DESC
-	`, userID, userID)
+	`, userID, userID, userID, userID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query projects: %v", err)
 	}
@@ -68,10 +78,12 @@ func (s *ProjectService) GetAllProjects(userID string) ([]models.Project, error)
 	var projects []models.Project
 	for rows.Next() {
 		var project models.Project
-		err := rows.Scan(&project.ID, &project.OwnerID, &project.Name, &project.Description, &project.CreatedAt, &project.UpdatedAt)
+		var unreadCount int
+		err := rows.Scan(&project.ID, &project.OwnerID, &project.Name, &project.Description, &project.CreatedAt, &project.UpdatedAt, &unreadCount)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan project: %v", err)
 		}
+		project.UnreadCount = &unreadCount
 		projects = append(projects, project)
 	}
 	return projects, nil
@@ -0,0 +1,50 @@
+package services
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"backend/internal/models"
+)
+
+// Broadcaster pushes JSON frames to every client connected to a project's hub
+type Broadcaster interface {
+	BroadcastJSON(projectID int64, payload interface{})
+}
+
+// publishBoardEvent wraps data in a versioned envelope and pushes it to the
+// project's connected clients. It is a no-op when no broadcaster is registered.
+func publishBoardEvent(broadcaster Broadcaster, projectID int64, eventType models.BoardEventType, actorID string, data interface{}) {
+	if broadcaster == nil || projectID == 0 {
+		return
+	}
+
+	broadcaster.BroadcastJSON(projectID, models.BoardEvent{
+		Type:       eventType,
+		Version:    models.BoardEventVersion,
+		ProjectID:  projectID,
+		ActorID:    actorID,
+		Data:       data,
+		OccurredAt: time.Now().UTC(),
+	})
+}
+
+// lookupTaskProjectID returns the project a task belongs to, or 0 if the task does not exist
+func lookupTaskProjectID(q queryable, taskID int64) (int64, error) {
+	var projectID int64
+	err := q.QueryRow(`
+		SELECT stages.project_id
+		FROM tasks
+		JOIN stages ON tasks.stage_id = stages.id
+		WHERE tasks.id = ?`,
+		taskID,
+	).Scan(&projectID)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to get task project: %v", err)
+	}
+	return projectID, nil
+}
@@ -0,0 +1,246 @@
+package services
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"backend/internal/models"
+)
+
+const (
+	// MaxReminderOffsets is how many reminders a task deadline can have
+	MaxReminderOffsets = 5
+	// MaxReminderOffsetMinutes is the earliest a reminder can go out (30 days)
+	MaxReminderOffsetMinutes = 30 * 24 * 60
+
+	// overdueLookback bounds how long after its deadline a task still gets an
+	// overdue notice
+	overdueLookback = 7 * 24 * time.Hour
+)
+
+// DefaultReminderOffsets applies when neither the user nor the project has
+// set offsets: one reminder a day before the deadline
+var DefaultReminderOffsets = []int{24 * 60}
+
+// GetUserReminderSettings returns the offsets the user set for all their
+// tasks, or the defaults
+func (s *NotificationService) GetUserReminderSettings(userID string) (*models.ReminderSettings, error) {
+	offsets, found, err := s.storedReminderOffsets(userID, 0)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return defaultReminderSettings(), nil
+	}
+	return &models.ReminderSettings{OffsetsMinutes: offsets, Source: models.ReminderSourceUser}, nil
+}
+
+// SetUserReminderOffsets stores the user's offsets; they take precedence over
+// project settings
+func (s *NotificationService) SetUserReminderOffsets(userID string, offsets []int) (*models.ReminderSettings, error) {
+	offsets, err := normalizeReminderOffsets(offsets)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.storeReminderOffsets(userID, 0, offsets); err != nil {
+		return nil, err
+	}
+	return &models.ReminderSettings{OffsetsMinutes: offsets, Source: models.ReminderSourceUser}, nil
+}
+
+// ResetUserReminderOffsets removes the user's offsets so project settings or
+// the defaults apply again
+func (s *NotificationService) ResetUserReminderOffsets(userID string) error {
+	return s.deleteReminderOffsets(userID, 0)
+}
+
+// GetProjectReminderSettings returns the project's offsets, or the defaults.
+// Any member can read them.
+func (s *NotificationService) GetProjectReminderSettings(projectID int64, requesterID string) (*models.ReminderSettings, error) {
+	isMember, err := s.pmRepo.IsMember(projectID, requesterID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check access: %v", err)
+	}
+	if !isMember {
+		return nil, &ServiceError{Code: "ACCESS_DENIED", Message: "access denied"}
+	}
+
+	offsets, found, err := s.storedReminderOffsets("", projectID)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return defaultReminderSettings(), nil
+	}
+	return &models.ReminderSettings{OffsetsMinutes: offsets, Source: models.ReminderSourceProject}, nil
+}
+
+// SetProjectReminderOffsets stores offsets for the project's members who
+// have not set their own. Only the owner can change them.
+func (s *NotificationService) SetProjectReminderOffsets(projectID int64, requesterID string, offsets []int) (*models.ReminderSettings, error) {
+	if err := s.checkReminderOwner(projectID, requesterID); err != nil {
+		return nil, err
+	}
+	offsets, err := normalizeReminderOffsets(offsets)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.storeReminderOffsets("", projectID, offsets); err != nil {
+		return nil, err
+	}
+	return &models.ReminderSettings{OffsetsMinutes: offsets, Source: models.ReminderSourceProject}, nil
+}
+
+// ResetProjectReminderOffsets removes the project's offsets so the defaults
+// apply again
+func (s *NotificationService) ResetProjectReminderOffsets(projectID int64, requesterID string) error {
+	if err := s.checkReminderOwner(projectID, requesterID); err != nil {
+		return err
+	}
+	return s.deleteReminderOffsets("", projectID)
+}
+
+// reminderOffsets resolves the offsets for a user's task in a project: the
+// user's own, then the project's, then the defaults. Longest first.
+func (s *NotificationService) reminderOffsets(userID string, projectID int64) ([]int, error) {
+	var stored string
+	err := s.db.QueryRow(`
+		SELECT offsets FROM reminder_settings
+		WHERE (user_id = ? AND project_id = 0) OR (user_id = '' AND project_id = ?)
+		ORDER BY user_id DESC
+		LIMIT 1
+	`, userID, projectID).Scan(&stored)
+	if err == sql.ErrNoRows {
+		return DefaultReminderOffsets, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get reminder settings: %v", err)
+	}
+	return parseReminderOffsets(stored), nil
+}
+
+func (s *NotificationService) checkReminderOwner(projectID int64, requesterID string) error {
+	isOwner, err := s.pmRepo.IsOwner(projectID, requesterID)
+	if err != nil {
+		return fmt.Errorf("failed to check permissions: %v", err)
+	}
+	if !isOwner {
+		return &ServiceError{Code: "ACCESS_DENIED", Message: "only owner can change reminder settings"}
+	}
+	return nil
+}
+
+func (s *NotificationService) storedReminderOffsets(userID string, projectID int64) ([]int, bool, error) {
+	var stored string
+	err := s.db.QueryRow(
+		"SELECT offsets FROM reminder_settings WHERE user_id = ? AND project_id = ?",
+		userID, projectID,
+	).Scan(&stored)
+	if err == sql.ErrNoRows {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to get reminder settings: %v", err)
+	}
+	return parseReminderOffsets(stored), true, nil
+}
+
+func (s *NotificationService) storeReminderOffsets(userID string, projectID int64, offsets []int) error {
+	parts := make([]string, len(offsets))
+	for i, offset := range offsets {
+		parts[i] = strconv.Itoa(offset)
+	}
+
+	_, err := s.db.Exec(`
+		INSERT INTO reminder_settings (user_id, project_id, offsets, updated_at)
+		VALUES (?, ?, ?, CURRENT_TIMESTAMP)
+		ON CONFLICT(user_id, project_id) DO UPDATE SET offsets = excluded.offsets, updated_at = excluded.updated_at
+	`, userID, projectID, strings.Join(parts, ","))
+	if err != nil {
+		return fmt.Errorf("failed to save reminder settings: %v", err)
+	}
+	return nil
+}
+
+func (s *NotificationService) deleteReminderOffsets(userID string, projectID int64) error {
+	_, err := s.db.Exec("DELETE FROM reminder_settings WHERE user_id = ? AND project_id = ?", userID, projectID)
+	if err != nil {
+		return fmt.Errorf("failed to reset reminder settings: %v", err)
+	}
+	return nil
+}
+
+func defaultReminderSettings() *models.ReminderSettings {
+	return &models.ReminderSettings{
+		OffsetsMinutes: append([]int(nil), DefaultReminderOffsets...),
+		Source:         models.ReminderSourceDefault,
+	}
+}
+
+// normalizeReminderOffsets validates offsets in minutes and returns them
+// deduplicated, longest first
+func normalizeReminderOffsets(offsets []int) ([]int, error) {
+	seen := make(map[int]bool, len(offsets))
+	normalized := []int{}
+	for _, offset := range offsets {
+		if offset < 1 || offset > MaxReminderOffsetMinutes {
+			return nil, &ServiceError{
+				Code:    "INVALID_REQUEST",
+				Message: fmt.Sprintf("reminder offsets must be between 1 and %d minutes", MaxReminderOffsetMinutes),
+			}
+		}
+		if !seen[offset] {
+			seen[offset] = true
+			normalized = append(normalized, offset)
+		}
+	}
+	if len(normalized) > MaxReminderOffsets {
+		return nil, &ServiceError{
+			Code:    "INVALID_REQUEST",
+			Message: fmt.Sprintf("at most %d reminder offsets are allowed", MaxReminderOffsets),
+		}
+	}
+	sort.Sort(sort.Reverse(sort.IntSlice(normalized)))
+	return normalized, nil
+}
+
+func parseReminderOffsets(stored string) []int {
+	offsets := []int{}
+	for _, part := range strings.Split(stored, ",") {
+		if offset, err := strconv.Atoi(strings.TrimSpace(part)); err == nil && offset > 0 {
+			offsets = append(offsets, offset)
+		}
+	}
+	sort.Sort(sort.Reverse(sort.IntSlice(offsets)))
+	return offsets
+}
+
+// formatReminderOffset renders an offset for a reminder message, e.g.
+// "1 week", "2 days" or "90 minutes"
+func formatReminderOffset(offset time.Duration) string {
+	units := []struct {
+		size time.Duration
+		name string
+	}{
+		{7 * 24 * time.Hour, "week"},
+		{24 * time.Hour, "day"},
+		{time.Hour, "hour"},
+	}
+	for _, unit := range units {
+		if offset >= unit.size && offset%unit.size == 0 {
+			return pluralizeUnit(int(offset/unit.size), unit.name)
+		}
+	}
+	return pluralizeUnit(int(offset/time.Minute), "minute")
+}
+
+func pluralizeUnit(n int, unit string) string {
+	if n == 1 {
+		return "1 " + unit
+	}
+	return fmt.Sprintf("%d %ss", n, unit)
+}
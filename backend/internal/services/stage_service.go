@@ -9,13 +9,19 @@ import (
 )
 
 type StageService struct {
-	db *sql.DB
+	db          *sql.DB
+	broadcaster Broadcaster
 }
 
 func NewStageService(db *sql.DB) *StageService {
 	return &StageService{db: db}
 }
 
+// SetBroadcaster registers the hub that receives board events for stage changes
+func (s *StageService) SetBroadcaster(broadcaster Broadcaster) {
+	s.broadcaster = broadcaster
+}
+
 // verifyProjectOwnership checks if project belongs to user
 func (s *StageService) verifyProjectOwnership(userID string, projectID int64) (bool, error) {
 	var count int
@@ -205,7 +211,17 @@ func (s *StageService) ReorderStages(userID string, projectID int64, stageIDs []
 		return nil, fmt.Errorf("failed to commit stage reorder: %v", err)
 	}
 
-	return s.GetStagesByProject(userID, projectID)
+	stages, err := s.GetStagesByProject(userID, projectID)
+	if err != nil {
+		return nil, err
+	}
+
+	publishBoardEvent(s.broadcaster, projectID, models.BoardStagesReordered, userID, models.StagesReorderedEventData{
+		StageIDs: stageIDs,
+		Stages:   stages,
+	})
+
+	return stages, nil
 }
 
 // GetStageByID retrieves a stage by ID (validates ownership)
@@ -4,6 +4,7 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"log"
 	"strings"
 
 	"backend/internal/models"
@@ -16,7 +17,8 @@ var (
 )
 
 type SubtaskService struct {
-	db *sql.DB
+	db          *sql.DB
+	broadcaster Broadcaster
 }
 
 type SubtaskPatch struct {
@@ -29,6 +31,11 @@ func NewSubtaskService(db *sql.DB) *SubtaskService {
 	return &SubtaskService{db: db}
 }
 
+// SetBroadcaster registers the hub that receives board events for subtask changes
+func (s *SubtaskService) SetBroadcaster(broadcaster Broadcaster) {
+	s.broadcaster = broadcaster
+}
+
 func (s *SubtaskService) CreateSubtask(userID string, taskID int64, title string, position *int) (*models.Subtask, error) {
 	normalizedTitle, err := normalizeSubtaskTitle(title)
 	if err != nil {
@@ -79,7 +86,14 @@ func (s *SubtaskService) CreateSubtask(userID string, taskID int64, title string
 		return nil, fmt.Errorf("failed to commit transaction: %v", err)
 	}
 
-	return s.GetSubtaskByID(userID, id)
+	subtask, err := s.GetSubtaskByID(userID, id)
+	if err != nil {
+		return nil, err
+	}
+
+	s.publishSubtaskEvent(taskID, models.BoardSubtaskCreated, userID, models.SubtaskEventData{Subtask: subtask})
+
+	return subtask, nil
 }
 
 func (s *SubtaskService) GetSubtasksByTask(userID string, taskID int64) ([]models.Subtask, error) {
@@ -187,7 +201,14 @@ func (s *SubtaskService) UpdateSubtask(userID string, subtaskID int64, patch Sub
 		return nil, fmt.Errorf("failed to commit transaction: %v", err)
 	}
 
-	return s.GetSubtaskByID(userID, subtaskID)
+	subtask, err := s.GetSubtaskByID(userID, subtaskID)
+	if err != nil {
+		return nil, err
+	}
+
+	s.publishSubtaskEvent(subtask.TaskID, models.BoardSubtaskUpdated, userID, models.SubtaskEventData{Subtask: subtask})
+
+	return subtask, nil
 }
 
 func (s *SubtaskService) DeleteSubtask(userID string, subtaskID int64) error {
@@ -217,9 +238,27 @@ func (s *SubtaskService) DeleteSubtask(userID string, subtaskID int64) error {
 		return fmt.Errorf("failed to commit transaction: %v", err)
 	}
 
+	s.publishSubtaskEvent(current.TaskID, models.BoardSubtaskDeleted, userID, models.SubtaskDeletedEventData{
+		SubtaskID: subtaskID,
+		TaskID:    current.TaskID,
+	})
+
 	return nil
 }
 
+// publishSubtaskEvent broadcasts a subtask event to the project that owns the task
+func (s *SubtaskService) publishSubtaskEvent(taskID int64, eventType models.BoardEventType, actorID string, data interface{}) {
+	if s.broadcaster == nil {
+		return
+	}
+	projectID, err := lookupTaskProjectID(s.db, taskID)
+	if err != nil {
+		log.Printf("Failed to publish subtask event for task %d: %v", taskID, err)
+		return
+	}
+	publishBoardEvent(s.broadcaster, projectID, eventType, actorID, data)
+}
+
 type subtaskScanner interface {
 	Scan(dest ...interface{}) error
 }
@@ -15,6 +15,7 @@ type TaskLabelService struct {
 	labelRepo     *repository.LabelRepository
 	pmService     *ProjectMemberService
 	activitySvc   *ActivityService
+	broadcaster   Broadcaster
 }
 
 // MaxLabelsPerTask defines the maximum number of labels that can be assigned to a task
@@ -31,6 +32,11 @@ func NewTaskLabelService(db *sql.DB, pmService *ProjectMemberService, activitySv
 	}
 }
 
+// SetBroadcaster registers the hub that receives board events for label changes
+func (s *TaskLabelService) SetBroadcaster(broadcaster Broadcaster) {
+	s.broadcaster = broadcaster
+}
+
 // AssignLabel assigns a label to a task
 func (s *TaskLabelService) AssignLabel(taskID, labelID int64, userID, userName string) error {
 	// Get task to find its project
@@ -85,6 +91,12 @@ func (s *TaskLabelService) AssignLabel(taskID, labelID int64, userID, userName s
 		s.activitySvc.LogLabelAssigned(taskProjectID, userID, userName, taskID, taskTitle, label.Name)
 	}
 
+	publishBoardEvent(s.broadcaster, taskProjectID, models.BoardTaskLabelAdded, userID, models.TaskLabelEventData{
+		TaskID:  taskID,
+		LabelID: labelID,
+		Label:   label,
+	})
+
 	return nil
 }
 
@@ -128,6 +140,12 @@ func (s *TaskLabelService) RemoveLabel(taskID, labelID int64, userID, userName s
 		s.activitySvc.LogLabelRemoved(taskProjectID, userID, userName, taskID, taskTitle, labelName)
 	}
 
+	publishBoardEvent(s.broadcaster, taskProjectID, models.BoardTaskLabelRemoved, userID, models.TaskLabelEventData{
+		TaskID:  taskID,
+		LabelID: labelID,
+		Label:   label,
+	})
+
 	return nil
 }
 
@@ -4,6 +4,7 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"log"
 	"strings"
 	"time"
 
@@ -11,8 +12,11 @@ import (
 )
 
 type TaskService struct {
-	db          *sql.DB
-	activitySvc *ActivityService
+	db            *sql.DB
+	activitySvc   *ActivityService
+	broadcaster   Broadcaster
+	notifications *NotificationService
+	watchers      *TaskWatcherService
 }
 
 var ErrInvalidTaskPriority = errors.New("invalid task priority")
@@ -22,6 +26,21 @@ func NewTaskService(db *sql.DB, activitySvc *ActivityService) *TaskService {
 	return &TaskService{db: db, activitySvc: activitySvc}
 }
 
+// SetBroadcaster registers the hub that receives board events for task changes
+func (s *TaskService) SetBroadcaster(broadcaster Broadcaster) {
+	s.broadcaster = broadcaster
+}
+
+// SetNotificationService enables task_completed notifications
+func (s *TaskService) SetNotificationService(notifications *NotificationService) {
+	s.notifications = notifications
+}
+
+// SetWatcherService subscribes creators and assignees to their tasks
+func (s *TaskService) SetWatcherService(watchers *TaskWatcherService) {
+	s.watchers = watchers
+}
+
 var allowedTaskPriorities = map[string]struct{}{
 	"low":    {},
 	"medium": {},
@@ -86,8 +105,12 @@ func (s *TaskService) CreateTask(userID string, stageID int64, title, descriptio
 	if s.activitySvc != nil {
 		s.activitySvc.LogTaskCreated(projectID, userID, "", id, title)
 	}
+	if s.watchers != nil {
+		s.watchers.autoWatch(id, userID)
+	}
+	s.handleAssignment(userID, id, title, "", stringValue(attrs.AssignedTo))
 
-	return &models.Task{
+	task := &models.Task{
 		ID:          id,
 		UserID:      userID,
 		StageID:     stageID,
@@ -100,7 +123,11 @@ func (s *TaskService) CreateTask(userID string, stageID int64, title, descriptio
 		AssignedTo:  attrs.AssignedTo,
 		CreatedAt:   time.Now(),
 		UpdatedAt:   time.Now(),
-	}, nil
+	}
+
+	publishBoardEvent(s.broadcaster, projectID, models.BoardTaskCreated, userID, models.TaskEventData{Task: task})
+
+	return task, nil
 }
 
 // GetTasksByStage retrieves all tasks for a stage (validates ownership)
@@ -321,6 +348,9 @@ func (s *TaskService) UpdateTask(userID string, id int64, title, description str
 		return nil, err
 	}
 
+	var previousAssignee sql.NullString
+	s.db.QueryRow("SELECT assigned_to FROM tasks WHERE id = ? AND user_id = ?", id, userID).Scan(&previousAssignee)
+
 	_, err = s.db.Exec(
 		"UPDATE tasks SET title = ?, description = ?, position = ?, start_date = ?, deadline = ?, priority = ?, assigned_to = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ? AND user_id = ?",
 		title, description, position, nullableTime(attrs.StartDate), nullableTime(attrs.Deadline), nullableString(attrs.Priority), nullableString(attrs.AssignedTo), id, userID,
@@ -329,7 +359,22 @@ func (s *TaskService) UpdateTask(userID string, id int64, title, description str
 		return nil, fmt.Errorf("failed to update task: %v", err)
 	}
 
-	return s.GetTaskByID(userID, id)
+	task, err := s.GetTaskByID(userID, id)
+	if err != nil || task == nil {
+		return task, err
+	}
+
+	if projectID, err := lookupTaskProjectID(s.db, id); err != nil {
+		log.Printf("Failed to publish update of task %d: %v", id, err)
+	} else {
+		publishBoardEvent(s.broadcaster, projectID, models.BoardTaskUpdated, userID, models.TaskEventData{Task: task})
+		if s.activitySvc != nil {
+			s.activitySvc.LogTaskUpdated(projectID, userID, "", id, task.Title)
+		}
+	}
+	s.handleAssignment(userID, id, task.Title, previousAssignee.String, stringValue(task.AssignedTo))
+
+	return task, nil
 }
 
 func (s *TaskService) hasProjectAccess(userID string, projectID int64) (bool, error) {
@@ -458,7 +503,72 @@ func (s *TaskService) MoveTask(userID string, id int64, newStageID int64, newPos
 		return nil, fmt.Errorf("task not found or access denied")
 	}
 
-	return s.getTaskForProjectAccess(userID, id)
+	moved, err := s.getTaskForProjectAccess(userID, id)
+	if err != nil || moved == nil {
+		return moved, err
+	}
+
+	publishBoardEvent(s.broadcaster, newProj, models.BoardTaskMoved, userID, models.TaskMovedEventData{
+		TaskID:       id,
+		FromStageID:  existing.StageID,
+		ToStageID:    newStageID,
+		FromPosition: existing.Position,
+		ToPosition:   newPosition,
+		Task:         moved,
+	})
+
+	if existing.StageID != newStageID {
+		if s.activitySvc != nil {
+			var fromStage, toStage string
+			s.db.QueryRow("SELECT name FROM stages WHERE id = ?", existing.StageID).Scan(&fromStage)
+			s.db.QueryRow("SELECT name FROM stages WHERE id = ?", newStageID).Scan(&toStage)
+			s.activitySvc.LogTaskMoved(newProj, userID, "", id, moved.Title, fromStage, toStage)
+		}
+		s.notifyIfCompleted(userID, id, existing.StageID, newStageID)
+	}
+
+	return moved, nil
+}
+
+// handleAssignment subscribes a new assignee to the task and sends them a
+// task_assigned notification
+func (s *TaskService) handleAssignment(actorID string, taskID int64, title, previous, assignee string) {
+	if assignee == "" || assignee == previous {
+		return
+	}
+	if s.watchers != nil {
+		s.watchers.autoWatch(taskID, assignee)
+	}
+	if s.notifications != nil {
+		s.notifications.NotifyTaskAssigned(taskID, assignee, actorID, s.notifications.userName(actorID), title)
+	}
+}
+
+// notifyIfCompleted sends task_completed notifications when a move takes a
+// task from a regular stage into a final one
+func (s *TaskService) notifyIfCompleted(userID string, taskID, fromStageID, toStageID int64) {
+	if s.notifications == nil {
+		return
+	}
+
+	var fromFinal, toFinal bool
+	err := s.db.QueryRow(`
+		SELECT
+			COALESCE((SELECT is_final FROM stages WHERE id = ?), 0),
+			COALESCE((SELECT is_final FROM stages WHERE id = ?), 0)`,
+		fromStageID, toStageID,
+	).Scan(&fromFinal, &toFinal)
+	if err != nil {
+		log.Printf("Failed to check stages for task %d: %v", taskID, err)
+		return
+	}
+	if fromFinal || !toFinal {
+		return
+	}
+
+	if err := s.notifications.NotifyTaskCompleted(taskID, userID); err != nil {
+		log.Printf("Failed to notify completion of task %d: %v", taskID, err)
+	}
 }
 
 // AssignTask assigns/unassigns a task to a user
@@ -588,18 +698,25 @@ func (s *TaskService) AssignTask(taskID int64, assignedTo *string, requesterID s
 		resultTask.AssignedTo = &assignedToRes.String
 	}
 
+	publishBoardEvent(s.broadcaster, taskProjectID, models.BoardTaskAssigned, requesterID, models.TaskEventData{Task: &resultTask})
+
+	if s.activitySvc != nil {
+		s.activitySvc.LogTaskAssigned(taskProjectID, requesterID, currentAssigneeStr, newAssigneeStr, taskID)
+	}
+	s.handleAssignment(requesterID, taskID, title, currentAssigneeStr, newAssigneeStr)
+
 	return &resultTask, nil
 }
 
 // DeleteTask deletes a task (validates ownership)
 func (s *TaskService) DeleteTask(userID string, id int64) error {
-	// Get projectID for logging before deleting
-	var projectID int64
+	// Get projectID and stage for logging and the board event before deleting
+	var projectID, stageID int64
 	s.db.QueryRow(`
-		SELECT stages.project_id 
+		SELECT stages.project_id, tasks.stage_id
 		FROM tasks 
 		JOIN stages ON tasks.stage_id = stages.id 
-		WHERE tasks.id = ? AND tasks.user_id = ?`, id, userID).Scan(&projectID)
+		WHERE tasks.id = ? AND tasks.user_id = ?`, id, userID).Scan(&projectID, &stageID)
 
 	result, err := s.db.Exec("DELETE FROM tasks WHERE id = ? AND user_id = ?", id, userID)
 	if err != nil {
@@ -620,6 +737,8 @@ func (s *TaskService) DeleteTask(userID string, id int64) error {
 		s.activitySvc.LogTaskDeleted(projectID, userID, "", "")
 	}
 
+	publishBoardEvent(s.broadcaster, projectID, models.BoardTaskDeleted, userID, models.TaskDeletedEventData{TaskID: id, StageID: stageID})
+
 	return nil
 }
 
@@ -777,6 +896,13 @@ func nullableString(value *string) interface{} {
 	return *value
 }
 
+func stringValue(value *string) string {
+	if value == nil {
+		return ""
+	}
+	return *value
+}
+
 func nullableTimePtr(value sql.NullTime) *time.Time {
 	if !value.Valid {
 		return nil
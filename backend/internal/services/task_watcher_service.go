@@ -0,0 +1,122 @@
+package services
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+
+	"backend/internal/models"
+	"backend/internal/repository"
+)
+
+// TaskWatcherService manages who follows a task. Watchers are notified about
+// updates, moves, comments and label changes on the task.
+type TaskWatcherService struct {
+	db     *sql.DB
+	pmRepo *repository.ProjectMemberRepository
+}
+
+// NewTaskWatcherService creates a new TaskWatcherService
+func NewTaskWatcherService(db *sql.DB) *TaskWatcherService {
+	return &TaskWatcherService{
+		db:     db,
+		pmRepo: repository.NewProjectMemberRepository(db),
+	}
+}
+
+// WatchTask subscribes the user to a task in one of their projects. Watching
+// a task twice is a no-op.
+func (s *TaskWatcherService) WatchTask(userID string, taskID int64) ([]models.TaskWatcher, error) {
+	if err := s.verifyTaskAccess(userID, taskID); err != nil {
+		return nil, err
+	}
+	if err := s.watch(taskID, userID); err != nil {
+		return nil, err
+	}
+	return s.listWatchers(taskID)
+}
+
+// UnwatchTask unsubscribes the user from a task
+func (s *TaskWatcherService) UnwatchTask(userID string, taskID int64) error {
+	if err := s.verifyTaskAccess(userID, taskID); err != nil {
+		return err
+	}
+	if _, err := s.db.Exec("DELETE FROM task_watchers WHERE task_id = ? AND user_id = ?", taskID, userID); err != nil {
+		return fmt.Errorf("failed to unwatch task: %v", err)
+	}
+	return nil
+}
+
+// GetWatchers lists a task's watchers who are still project members
+func (s *TaskWatcherService) GetWatchers(userID string, taskID int64) ([]models.TaskWatcher, error) {
+	if err := s.verifyTaskAccess(userID, taskID); err != nil {
+		return nil, err
+	}
+	return s.listWatchers(taskID)
+}
+
+// autoWatch subscribes users to a task as a side effect of creating,
+// being assigned to or commenting on it. Failures are only logged.
+func (s *TaskWatcherService) autoWatch(taskID int64, userIDs ...string) {
+	for _, userID := range userIDs {
+		if userID == "" {
+			continue
+		}
+		if err := s.watch(taskID, userID); err != nil {
+			log.Printf("Failed to subscribe %s to task %d: %v", userID, taskID, err)
+		}
+	}
+}
+
+func (s *TaskWatcherService) watch(taskID int64, userID string) error {
+	_, err := s.db.Exec("INSERT OR IGNORE INTO task_watchers (task_id, user_id) VALUES (?, ?)", taskID, userID)
+	if err != nil {
+		return fmt.Errorf("failed to watch task: %v", err)
+	}
+	return nil
+}
+
+func (s *TaskWatcherService) listWatchers(taskID int64) ([]models.TaskWatcher, error) {
+	rows, err := s.db.Query(`
+		SELECT w.task_id, w.user_id, COALESCE(u.name, ''), COALESCE(u.email, ''), w.created_at
+		FROM task_watchers w
+		JOIN tasks t ON t.id = w.task_id
+		JOIN stages st ON st.id = t.stage_id
+		JOIN project_members pm ON pm.project_id = st.project_id AND pm.user_id = w.user_id
+		LEFT JOIN users u ON u.id = w.user_id
+		WHERE w.task_id = ?
+		ORDER BY w.created_at ASC, w.user_id ASC
+	`, taskID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get watchers: %v", err)
+	}
+	defer rows.Close()
+
+	watchers := []models.TaskWatcher{}
+	for rows.Next() {
+		var watcher models.TaskWatcher
+		if err := rows.Scan(&watcher.TaskID, &watcher.UserID, &watcher.Name, &watcher.Email, &watcher.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan watcher: %v", err)
+		}
+		watchers = append(watchers, watcher)
+	}
+	return watchers, rows.Err()
+}
+
+func (s *TaskWatcherService) verifyTaskAccess(userID string, taskID int64) error {
+	projectID, err := lookupTaskProjectID(s.db, taskID)
+	if err != nil {
+		return err
+	}
+	if projectID == 0 {
+		return ErrTaskNotFoundOrAccessDenied
+	}
+	isMember, err := s.pmRepo.IsMember(projectID, userID)
+	if err != nil {
+		return fmt.Errorf("failed to check access: %v", err)
+	}
+	if !isMember {
+		return ErrTaskNotFoundOrAccessDenied
+	}
+	return nil
+}
@@ -0,0 +1,88 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// carrierGradeNAT is the shared address space of RFC 6598, which is not
+// reachable from the internet either
+var carrierGradeNAT = &net.IPNet{IP: net.IPv4(100, 64, 0, 0), Mask: net.CIDRMask(10, 32)}
+
+// isPublicIP reports whether ip is an address on the internet rather than
+// one of this machine's or its network's
+func isPublicIP(ip net.IP) bool {
+	return !ip.IsLoopback() && !ip.IsPrivate() && !ip.IsUnspecified() &&
+		!ip.IsLinkLocalUnicast() && !ip.IsLinkLocalMulticast() &&
+		!ip.IsInterfaceLocalMulticast() && !ip.IsMulticast() &&
+		!carrierGradeNAT.Contains(ip)
+}
+
+// isAllowedWebhookHost reports whether host is in allowedHosts, which may
+// reach internal addresses
+func isAllowedWebhookHost(host string, allowedHosts []string) bool {
+	for _, allowed := range allowedHosts {
+		if strings.EqualFold(host, allowed) {
+			return true
+		}
+	}
+	return false
+}
+
+// newWebhookClient returns the HTTP client webhooks are delivered with.
+// Webhook URLs are chosen by users, so unless their host is in allowedHosts
+// it refuses to connect to loopback, private, link-local and other internal
+// addresses. The check runs on the address actually dialed, after DNS, so a
+// name that resolves to an internal address is refused too. Redirects are
+// not followed; the redirect response is recorded as the delivery's result.
+func newWebhookClient(allowedHosts []string) *http.Client {
+	dialer := &net.Dialer{Timeout: DefaultWebhookTimeout, KeepAlive: 30 * time.Second}
+	guarded := &net.Dialer{
+		Timeout:   DefaultWebhookTimeout,
+		KeepAlive: 30 * time.Second,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); ip == nil || !isPublicIP(ip) {
+				return fmt.Errorf("webhook address %s is not allowed", host)
+			}
+			return nil
+		},
+	}
+
+	transport := &http.Transport{
+		// Deliveries go straight to the webhook; a proxy would be the address
+		// checked instead
+		Proxy: nil,
+		DialContext: func(ctx context.Context, network, address string) (net.Conn, error) {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return nil, err
+			}
+			if isAllowedWebhookHost(host, allowedHosts) {
+				return dialer.DialContext(ctx, network, address)
+			}
+			return guarded.DialContext(ctx, network, address)
+		},
+		ForceAttemptHTTP2:     true,
+		MaxIdleConns:          100,
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   DefaultWebhookTimeout,
+		ExpectContinueTimeout: time.Second,
+	}
+
+	return &http.Client{
+		Timeout:   DefaultWebhookTimeout,
+		Transport: transport,
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
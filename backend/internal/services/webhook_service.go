@@ -0,0 +1,572 @@
+package services
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"backend/internal/models"
+	"backend/internal/repository"
+)
+
+const (
+	// DefaultWebhookMaxAttempts is how many times an event is sent before the
+	// delivery is marked failed
+	DefaultWebhookMaxAttempts = 5
+	// DefaultWebhookBaseDelay is the wait after the first failed attempt; it
+	// doubles with every further failure
+	DefaultWebhookBaseDelay = 30 * time.Second
+	// DefaultWebhookMaxDelay caps the wait between attempts
+	DefaultWebhookMaxDelay = time.Hour
+	// DefaultWebhookTimeout bounds each HTTP request to a webhook
+	DefaultWebhookTimeout = 10 * time.Second
+
+	// WebhookSignatureHeader carries "sha256=" and the hex HMAC-SHA256 of the
+	// request body, keyed with the webhook's secret
+	WebhookSignatureHeader = "X-Taskify-Signature"
+	// WebhookEventHeader carries the event name
+	WebhookEventHeader = "X-Taskify-Event"
+	// WebhookDeliveryHeader carries the delivery ID, the same on every retry
+	WebhookDeliveryHeader = "X-Taskify-Delivery"
+
+	// webhookLease is how long a claimed delivery is held before another
+	// worker may retry it, in case this one dies mid-request
+	webhookLease = 2 * time.Minute
+	// webhookBatchSize is how many deliveries one pass of the worker sends
+	webhookBatchSize = 50
+	// webhookResponseLimit is how much of a response body is logged
+	webhookResponseLimit = 1024
+	// minWebhookSecretLength applies to secrets chosen by the user
+	minWebhookSecretLength = 16
+	// maxWebhookURLLength bounds registered URLs
+	maxWebhookURLLength = 2048
+)
+
+var (
+	ErrWebhookNotFound         = errors.New("webhook not found")
+	ErrWebhookDeliveryNotFound = errors.New("webhook delivery not found")
+)
+
+// WebhookUpdate holds the fields of a webhook to change; nil fields are
+// left as they are
+type WebhookUpdate struct {
+	URL    *string
+	Events []models.ActivityAction
+	Active *bool
+}
+
+// WebhookService manages project webhooks and delivers activity to them in
+// the background, retrying failures with exponential backoff
+type WebhookService struct {
+	db           *sql.DB
+	repo         *repository.WebhookRepository
+	pmService    *ProjectMemberService
+	client       *http.Client
+	allowedHosts []string
+	maxAttempts  int
+	baseDelay    time.Duration
+	maxDelay     time.Duration
+	wake         chan struct{}
+}
+
+// NewWebhookService creates a new WebhookService
+func NewWebhookService(db *sql.DB, pmService *ProjectMemberService) *WebhookService {
+	return &WebhookService{
+		db:          db,
+		repo:        repository.NewWebhookRepository(db),
+		pmService:   pmService,
+		client:      newWebhookClient(nil),
+		maxAttempts: DefaultWebhookMaxAttempts,
+		baseDelay:   DefaultWebhookBaseDelay,
+		maxDelay:    DefaultWebhookMaxDelay,
+		wake:        make(chan struct{}, 1),
+	}
+}
+
+// SetClient changes the HTTP client used for deliveries. It replaces the
+// client that refuses internal addresses, so it is meant for tests.
+func (s *WebhookService) SetClient(client *http.Client) {
+	s.client = client
+}
+
+// SetAllowedHosts lets webhooks on the given hosts reach internal addresses,
+// such as a receiver on localhost during development
+func (s *WebhookService) SetAllowedHosts(hosts []string) {
+	s.allowedHosts = hosts
+	s.client = newWebhookClient(hosts)
+}
+
+// SetRetryPolicy changes how often and how far apart failed deliveries are
+// retried
+func (s *WebhookService) SetRetryPolicy(maxAttempts int, baseDelay, maxDelay time.Duration) {
+	s.maxAttempts = maxAttempts
+	s.baseDelay = baseDelay
+	s.maxDelay = maxDelay
+}
+
+// ListWebhooks returns the project's webhooks. Only the owner can see them.
+func (s *WebhookService) ListWebhooks(projectID int64, requesterID string) ([]models.Webhook, error) {
+	if err := s.checkOwner(projectID, requesterID); err != nil {
+		return nil, err
+	}
+	webhooks, err := s.repo.GetProjectWebhooks(projectID, false)
+	if err != nil {
+		return nil, err
+	}
+	for i := range webhooks {
+		webhooks[i].Secret = ""
+	}
+	return webhooks, nil
+}
+
+// CreateWebhook registers a URL for the given events. When secret is empty
+// one is generated; either way it is only returned here.
+func (s *WebhookService) CreateWebhook(projectID int64, requesterID, rawURL, secret string, events []models.ActivityAction, active bool) (*models.Webhook, error) {
+	if err := s.checkOwner(projectID, requesterID); err != nil {
+		return nil, err
+	}
+	if err := s.validateWebhookURL(rawURL); err != nil {
+		return nil, err
+	}
+	events, err := normalizeWebhookEvents(events)
+	if err != nil {
+		return nil, err
+	}
+
+	if secret == "" {
+		secret, err = generateWebhookSecret()
+		if err != nil {
+			return nil, err
+		}
+	} else if len(secret) < minWebhookSecretLength {
+		return nil, &ServiceError{Code: "INVALID_REQUEST", Message: fmt.Sprintf("secret must be at least %d characters", minWebhookSecretLength)}
+	}
+
+	return s.repo.CreateWebhook(projectID, rawURL, secret, events, active, requesterID)
+}
+
+// GetWebhook returns one of the project's webhooks, without its secret
+func (s *WebhookService) GetWebhook(projectID, webhookID int64, requesterID string) (*models.Webhook, error) {
+	if err := s.checkOwner(projectID, requesterID); err != nil {
+		return nil, err
+	}
+	webhook, err := s.projectWebhook(projectID, webhookID)
+	if err != nil {
+		return nil, err
+	}
+	webhook.Secret = ""
+	return webhook, nil
+}
+
+// UpdateWebhook changes a webhook's URL, events or active flag
+func (s *WebhookService) UpdateWebhook(projectID, webhookID int64, requesterID string, update WebhookUpdate) (*models.Webhook, error) {
+	if err := s.checkOwner(projectID, requesterID); err != nil {
+		return nil, err
+	}
+	webhook, err := s.projectWebhook(projectID, webhookID)
+	if err != nil {
+		return nil, err
+	}
+
+	if update.URL != nil {
+		if err := s.validateWebhookURL(*update.URL); err != nil {
+			return nil, err
+		}
+		webhook.URL = *update.URL
+	}
+	if update.Events != nil {
+		events, err := normalizeWebhookEvents(update.Events)
+		if err != nil {
+			return nil, err
+		}
+		webhook.Events = events
+	}
+	if update.Active != nil {
+		webhook.Active = *update.Active
+	}
+
+	if err := s.repo.UpdateWebhook(webhook); err != nil {
+		return nil, err
+	}
+	webhook.Secret = ""
+	return webhook, nil
+}
+
+// DeleteWebhook removes a webhook and its delivery log
+func (s *WebhookService) DeleteWebhook(projectID, webhookID int64, requesterID string) error {
+	if err := s.checkOwner(projectID, requesterID); err != nil {
+		return err
+	}
+	if _, err := s.projectWebhook(projectID, webhookID); err != nil {
+		return err
+	}
+	return s.repo.DeleteWebhook(webhookID)
+}
+
+// SendTestEvent sends a ping event to the webhook right away, even when it
+// is disabled, and returns the delivery with its single attempt. Test
+// events are not retried.
+func (s *WebhookService) SendTestEvent(projectID, webhookID int64, requesterID string) (*models.WebhookDelivery, error) {
+	if err := s.checkOwner(projectID, requesterID); err != nil {
+		return nil, err
+	}
+	webhook, err := s.projectWebhook(projectID, webhookID)
+	if err != nil {
+		return nil, err
+	}
+
+	payload, err := json.Marshal(models.WebhookPayload{
+		Event:     models.WebhookPingEvent,
+		ProjectID: projectID,
+		CreatedAt: time.Now().UTC(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	delivery, err := s.repo.CreateDelivery(webhook.ID, models.WebhookPingEvent, string(payload), 1)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	claimed, err := s.repo.ClaimDelivery(delivery.ID, now, now.Add(webhookLease))
+	if err != nil {
+		return nil, err
+	}
+	if claimed {
+		delivery.Attempts++
+		if _, err := s.attempt(webhook, delivery); err != nil {
+			return nil, err
+		}
+	}
+	return s.deliveryWithAttempts(delivery.ID)
+}
+
+// ListDeliveries returns a page of the webhook's deliveries, newest first
+func (s *WebhookService) ListDeliveries(projectID, webhookID int64, requesterID string, page, limit int) ([]models.WebhookDelivery, int64, error) {
+	if err := s.checkOwner(projectID, requesterID); err != nil {
+		return nil, 0, err
+	}
+	if _, err := s.projectWebhook(projectID, webhookID); err != nil {
+		return nil, 0, err
+	}
+	return s.repo.GetDeliveries(webhookID, limit, (page-1)*limit)
+}
+
+// GetDelivery returns one of the webhook's deliveries with every attempt
+func (s *WebhookService) GetDelivery(projectID, webhookID, deliveryID int64, requesterID string) (*models.WebhookDelivery, error) {
+	if err := s.checkOwner(projectID, requesterID); err != nil {
+		return nil, err
+	}
+	if _, err := s.projectWebhook(projectID, webhookID); err != nil {
+		return nil, err
+	}
+	delivery, err := s.deliveryWithAttempts(deliveryID)
+	if err != nil {
+		return nil, err
+	}
+	if delivery.WebhookID != webhookID {
+		return nil, ErrWebhookDeliveryNotFound
+	}
+	return delivery, nil
+}
+
+// ActivityLogged queues a delivery of the activity for every active webhook
+// of the project subscribed to its action, and wakes the delivery worker
+func (s *WebhookService) ActivityLogged(entry *models.ActivityLog) {
+	webhooks, err := s.repo.GetProjectWebhooks(entry.ProjectID, true)
+	if err != nil {
+		log.Printf("Failed to load webhooks for project %d: %v", entry.ProjectID, err)
+		return
+	}
+
+	var payload []byte
+	queued := false
+	for _, webhook := range webhooks {
+		if !webhook.Subscribes(entry.Action) {
+			continue
+		}
+		if payload == nil {
+			payload, err = json.Marshal(models.WebhookPayload{
+				Event:     string(entry.Action),
+				ProjectID: entry.ProjectID,
+				CreatedAt: entry.CreatedAt.UTC(),
+				Activity: &models.ActivityLogResponse{
+					ID:          entry.ID,
+					ProjectID:   entry.ProjectID,
+					UserID:      entry.UserID,
+					UserName:    entry.UserName,
+					Action:      entry.Action,
+					EntityType:  entry.EntityType,
+					EntityID:    entry.EntityID,
+					Description: entry.Description,
+					CreatedAt:   entry.CreatedAt.UTC(),
+				},
+			})
+			if err != nil {
+				log.Printf("Failed to encode webhook payload: %v", err)
+				return
+			}
+		}
+		if _, err := s.repo.CreateDelivery(webhook.ID, string(entry.Action), string(payload), s.maxAttempts); err != nil {
+			log.Printf("Failed to queue delivery for webhook %d: %v", webhook.ID, err)
+			continue
+		}
+		queued = true
+	}
+
+	if queued {
+		select {
+		case s.wake <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// ProcessDue sends every delivery that is due and returns how many
+// succeeded
+func (s *WebhookService) ProcessDue() (int, error) {
+	now := time.Now().UTC()
+	ids, err := s.repo.DueDeliveryIDs(now, webhookBatchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	succeeded := 0
+	for _, id := range ids {
+		claimed, err := s.repo.ClaimDelivery(id, now, now.Add(webhookLease))
+		if err != nil {
+			log.Printf("Failed to claim webhook delivery %d: %v", id, err)
+			continue
+		}
+		if !claimed {
+			continue
+		}
+
+		delivery, err := s.repo.GetDelivery(id)
+		if err != nil || delivery == nil {
+			log.Printf("Failed to load webhook delivery %d: %v", id, err)
+			continue
+		}
+		webhook, err := s.repo.GetWebhook(delivery.WebhookID)
+		if err != nil || webhook == nil {
+			log.Printf("Failed to load webhook %d: %v", delivery.WebhookID, err)
+			continue
+		}
+
+		ok, err := s.attempt(webhook, delivery)
+		if err != nil {
+			log.Printf("Failed to record webhook delivery %d: %v", id, err)
+			continue
+		}
+		if ok {
+			succeeded++
+		}
+	}
+
+	return succeeded, nil
+}
+
+// Start starts a background goroutine that delivers due events
+// periodically, and right away whenever new events are queued
+func (s *WebhookService) Start(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+			case <-s.wake:
+			}
+			if _, err := s.ProcessDue(); err != nil {
+				log.Printf("Webhook delivery error: %v", err)
+			}
+		}
+	}()
+}
+
+// attempt makes one signed request for a claimed delivery and records the
+// outcome, reporting whether the webhook accepted it. delivery.Attempts must
+// already count this attempt.
+func (s *WebhookService) attempt(webhook *models.Webhook, delivery *models.WebhookDelivery) (bool, error) {
+	result := &models.WebhookDeliveryAttempt{DeliveryID: delivery.ID, Attempt: delivery.Attempts}
+
+	start := time.Now()
+	status, body, err := s.post(webhook, delivery)
+	result.DurationMs = time.Since(start).Milliseconds()
+	result.ResponseStatus = status
+	result.ResponseBody = body
+
+	switch {
+	case err != nil:
+		result.Error = err.Error()
+	case status < 200 || status > 299:
+		result.Error = fmt.Sprintf("unexpected status %d", status)
+	default:
+		return true, s.repo.RecordAttempt(result, models.WebhookDeliverySucceeded, time.Now())
+	}
+
+	if delivery.Attempts >= delivery.MaxAttempts {
+		log.Printf("Webhook delivery %d to %s failed after %d attempts: %s", delivery.ID, webhook.URL, delivery.Attempts, result.Error)
+		return false, s.repo.RecordAttempt(result, models.WebhookDeliveryFailed, time.Now())
+	}
+	return false, s.repo.RecordAttempt(result, models.WebhookDeliveryPending, time.Now().Add(s.backoff(delivery.Attempts)))
+}
+
+// post sends the delivery's payload and returns the response status and the
+// start of the response body
+func (s *WebhookService) post(webhook *models.Webhook, delivery *models.WebhookDelivery) (int, string, error) {
+	payload := []byte(delivery.Payload)
+	req, err := http.NewRequest(http.MethodPost, webhook.URL, bytes.NewReader(payload))
+	if err != nil {
+		return 0, "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "Taskify-Webhooks/1.0")
+	req.Header.Set(WebhookEventHeader, delivery.Event)
+	req.Header.Set(WebhookDeliveryHeader, strconv.FormatInt(delivery.ID, 10))
+	req.Header.Set(WebhookSignatureHeader, SignWebhookPayload(webhook.Secret, payload))
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return 0, "", err
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, webhookResponseLimit))
+	// Drain a little more so the connection can be reused
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+	return resp.StatusCode, string(body), nil
+}
+
+// backoff is the wait after the given number of failed attempts
+func (s *WebhookService) backoff(attempts int) time.Duration {
+	delay := s.baseDelay
+	for i := 1; i < attempts && delay < s.maxDelay; i++ {
+		delay *= 2
+	}
+	if delay > s.maxDelay {
+		delay = s.maxDelay
+	}
+	return delay
+}
+
+// SignWebhookPayload returns the signature header value for a payload:
+// "sha256=" followed by the hex HMAC-SHA256 of the body
+func SignWebhookPayload(secret string, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(payload)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func (s *WebhookService) deliveryWithAttempts(deliveryID int64) (*models.WebhookDelivery, error) {
+	delivery, err := s.repo.GetDelivery(deliveryID)
+	if err != nil {
+		return nil, err
+	}
+	if delivery == nil {
+		return nil, ErrWebhookDeliveryNotFound
+	}
+	delivery.AttemptLog, err = s.repo.GetAttempts(deliveryID)
+	if err != nil {
+		return nil, err
+	}
+	return delivery, nil
+}
+
+// projectWebhook loads a webhook and checks that it belongs to the project
+func (s *WebhookService) projectWebhook(projectID, webhookID int64) (*models.Webhook, error) {
+	webhook, err := s.repo.GetWebhook(webhookID)
+	if err != nil {
+		return nil, err
+	}
+	if webhook == nil || webhook.ProjectID != projectID {
+		return nil, ErrWebhookNotFound
+	}
+	return webhook, nil
+}
+
+// checkOwner allows only the project's owner to manage its webhooks
+func (s *WebhookService) checkOwner(projectID int64, requesterID string) error {
+	var count int
+	if err := s.db.QueryRow("SELECT COUNT(*) FROM projects WHERE id = ?", projectID).Scan(&count); err != nil {
+		return fmt.Errorf("failed to check project: %v", err)
+	}
+	if count == 0 {
+		return &ServiceError{Code: "PROJECT_NOT_FOUND", Message: "project not found"}
+	}
+
+	isOwner, err := s.pmService.IsOwner(projectID, requesterID)
+	if err != nil {
+		return fmt.Errorf("failed to check ownership: %v", err)
+	}
+	if !isOwner {
+		return &ServiceError{Code: "ACCESS_DENIED", Message: "only the project owner can manage webhooks"}
+	}
+	return nil
+}
+
+// validateWebhookURL accepts absolute http and https URLs. URLs naming an
+// internal address outright are refused here; names that resolve to one are
+// refused when a delivery dials them.
+func (s *WebhookService) validateWebhookURL(rawURL string) error {
+	if rawURL == "" {
+		return &ServiceError{Code: "INVALID_REQUEST", Message: "url is required"}
+	}
+	parsed, err := url.Parse(rawURL)
+	if err != nil || len(rawURL) > maxWebhookURLLength || parsed.Host == "" || (parsed.Scheme != "http" && parsed.Scheme != "https") {
+		return &ServiceError{Code: "INVALID_REQUEST", Message: "url must be an absolute http or https URL"}
+	}
+
+	host := parsed.Hostname()
+	if isAllowedWebhookHost(host, s.allowedHosts) {
+		return nil
+	}
+	if ip := net.ParseIP(host); (ip != nil && !isPublicIP(ip)) || strings.EqualFold(host, "localhost") {
+		return &ServiceError{Code: "INVALID_REQUEST", Message: "url must not point to an internal address"}
+	}
+	return nil
+}
+
+// normalizeWebhookEvents checks the events are activity actions and drops
+// duplicates
+func normalizeWebhookEvents(events []models.ActivityAction) ([]models.ActivityAction, error) {
+	if len(events) == 0 {
+		return nil, &ServiceError{Code: "INVALID_REQUEST", Message: "at least one event is required"}
+	}
+	seen := make(map[models.ActivityAction]bool)
+	var normalized []models.ActivityAction
+	for _, event := range events {
+		if !event.IsValid() {
+			return nil, &ServiceError{Code: "INVALID_REQUEST", Message: fmt.Sprintf("unknown event %q", event)}
+		}
+		if !seen[event] {
+			seen[event] = true
+			normalized = append(normalized, event)
+		}
+	}
+	return normalized, nil
+}
+
+func generateWebhookSecret() (string, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", fmt.Errorf("failed to generate webhook secret: %v", err)
+	}
+	return hex.EncodeToString(secret), nil
+}
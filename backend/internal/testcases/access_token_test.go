@@ -0,0 +1,279 @@
+package testcases
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"backend/internal/auth/controller"
+	"backend/internal/auth/middleware"
+	"backend/internal/auth/models"
+	"backend/internal/auth/repository"
+	"backend/internal/auth/services"
+
+	"github.com/gorilla/mux"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+type accessTokenTestEnv struct {
+	db       *sql.DB
+	userRepo *repository.UserRepository
+	tokens   *services.AccessTokenService
+	jwt      *services.JWTService
+	router   *mux.Router
+	userID   string
+	login    string
+}
+
+func newAccessTokenTestEnv(t *testing.T) *accessTokenTestEnv {
+	t.Helper()
+
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { db.Close() })
+
+	userRepo := repository.NewUserRepository(db)
+	if err := userRepo.InitTable(); err != nil {
+		t.Fatalf("Failed to create users table: %v", err)
+	}
+	tokenRepo := repository.NewAccessTokenRepository(db)
+	if err := tokenRepo.InitTable(); err != nil {
+		t.Fatalf("Failed to create personal access tokens table: %v", err)
+	}
+
+	now := time.Now()
+	user := &models.User{
+		ID:        "pat-user",
+		Name:      "Script Owner",
+		Email:     "owner@example.com",
+		Role:      models.RoleUser,
+		IsActive:  true,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if err := userRepo.CreateUser(user); err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+
+	jwtService := services.NewJWTService("test-secret", 24)
+	tokenService := services.NewAccessTokenService(tokenRepo, userRepo)
+	jwtService.SetPersonalTokenAuthenticator(tokenService)
+
+	login, err := jwtService.GenerateToken(user.ID, user.Email)
+	if err != nil {
+		t.Fatalf("GenerateToken() error = %v", err)
+	}
+
+	ctrl := controller.NewAuthController(&services.AuthService{})
+	ctrl.SetAccessTokenService(tokenService)
+
+	echoUser := func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(middleware.GetUserID(r.Context())))
+	}
+
+	router := mux.NewRouter()
+	protected := router.PathPrefix("/api").Subrouter()
+	protected.Use(middleware.JWTAuthMiddleware(jwtService))
+	protected.HandleFunc("/me/tokens", ctrl.ListAccessTokens).Methods("GET")
+	protected.HandleFunc("/me/tokens", ctrl.CreateAccessToken).Methods("POST")
+	protected.HandleFunc("/me/tokens/{id}", ctrl.RevokeAccessToken).Methods("DELETE")
+	protected.HandleFunc("/projects", echoUser).Methods("GET", "POST")
+	protected.HandleFunc("/tasks/{id}", echoUser).Methods("PUT")
+	protected.HandleFunc("/projects/{projectId}/stages/{stageId}/tasks", echoUser).Methods("POST")
+	protected.HandleFunc("/admin/emails", echoUser).Methods("GET")
+
+	return &accessTokenTestEnv{
+		db:       db,
+		userRepo: userRepo,
+		tokens:   tokenService,
+		jwt:      jwtService,
+		router:   router,
+		userID:   user.ID,
+		login:    login,
+	}
+}
+
+func (env *accessTokenTestEnv) do(method, url, token string, body interface{}) *httptest.ResponseRecorder {
+	var bodyBytes []byte
+	if body != nil {
+		bodyBytes, _ = json.Marshal(body)
+	}
+	req := httptest.NewRequest(method, url, bytes.NewBuffer(bodyBytes))
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	w := httptest.NewRecorder()
+	env.router.ServeHTTP(w, req)
+	return w
+}
+
+func (env *accessTokenTestEnv) create(t *testing.T, scopes ...models.TokenScope) *models.PersonalAccessToken {
+	t.Helper()
+
+	token, err := env.tokens.CreateToken(env.userID, "ci", scopes, 0)
+	if err != nil {
+		t.Fatalf("CreateToken() error = %v", err)
+	}
+	return token
+}
+
+func TestAccessTokens_CreateShowsValueOnceAndStoresHash(t *testing.T) {
+	env := newAccessTokenTestEnv(t)
+
+	w := env.do("POST", "/api/me/tokens", env.login, map[string]interface{}{
+		"name":            "deploy bot",
+		"scopes":          []string{"read:projects", "write:tasks", "read:projects"},
+		"expires_in_days": 7,
+	})
+	if w.Code != http.StatusCreated {
+		t.Fatalf("CreateAccessToken status = %d, body %s", w.Code, w.Body.String())
+	}
+	var created models.PersonalAccessToken
+	if err := json.NewDecoder(w.Body).Decode(&created); err != nil {
+		t.Fatalf("Failed to decode token: %v", err)
+	}
+	if !strings.HasPrefix(created.Token, "tfy_") {
+		t.Errorf("token = %q, want tfy_ prefix", created.Token)
+	}
+	if !strings.HasPrefix(created.Token, created.Prefix) {
+		t.Errorf("prefix %q does not start the token", created.Prefix)
+	}
+	if len(created.Scopes) != 2 {
+		t.Errorf("scopes = %v, want duplicates dropped", created.Scopes)
+	}
+	if days := created.ExpiresAt.Sub(created.CreatedAt).Hours() / 24; days != 7 {
+		t.Errorf("token lives %v days, want 7", days)
+	}
+
+	var stored int
+	env.db.QueryRow("SELECT COUNT(*) FROM personal_access_tokens WHERE token_hash = ?", created.Token).Scan(&stored)
+	if stored != 0 {
+		t.Error("token is stored in plain text")
+	}
+
+	w = env.do("GET", "/api/me/tokens", env.login, nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("ListAccessTokens status = %d", w.Code)
+	}
+	if strings.Contains(w.Body.String(), created.Token) {
+		t.Error("ListAccessTokens leaks the token value")
+	}
+	var listed []models.PersonalAccessToken
+	json.NewDecoder(w.Body).Decode(&listed)
+	if len(listed) != 1 || listed[0].ID != created.ID || listed[0].Name != "deploy bot" {
+		t.Errorf("ListAccessTokens = %+v", listed)
+	}
+
+	invalid := []map[string]interface{}{
+		{"name": "", "scopes": []string{"admin"}},
+		{"name": "x", "scopes": []string{}},
+		{"name": "x", "scopes": []string{"delete:everything"}},
+		{"name": "x", "scopes": []string{"admin"}, "expires_in_days": 400},
+	}
+	for _, body := range invalid {
+		if w := env.do("POST", "/api/me/tokens", env.login, body); w.Code != http.StatusBadRequest {
+			t.Errorf("CreateAccessToken(%v) status = %d, want 400", body, w.Code)
+		}
+	}
+}
+
+func TestAccessTokens_ScopesLimitRequests(t *testing.T) {
+	env := newAccessTokenTestEnv(t)
+	reader := env.create(t, models.ScopeReadProjects)
+	writer := env.create(t, models.ScopeWriteTasks)
+	admin := env.create(t, models.ScopeAdmin)
+
+	tests := []struct {
+		name   string
+		token  string
+		method string
+		url    string
+		want   int
+	}{
+		{"reader lists projects", reader.Token, "GET", "/api/projects", http.StatusOK},
+		{"reader cannot update tasks", reader.Token, "PUT", "/api/tasks/1", http.StatusForbidden},
+		{"writer updates tasks", writer.Token, "PUT", "/api/tasks/1", http.StatusOK},
+		{"writer creates tasks", writer.Token, "POST", "/api/projects/1/stages/2/tasks", http.StatusOK},
+		{"writer cannot read", writer.Token, "GET", "/api/projects", http.StatusForbidden},
+		{"writer cannot create projects", writer.Token, "POST", "/api/projects", http.StatusForbidden},
+		{"reader cannot use admin routes", reader.Token, "GET", "/api/admin/emails", http.StatusForbidden},
+		{"admin creates projects", admin.Token, "POST", "/api/projects", http.StatusOK},
+		{"admin reads admin routes", admin.Token, "GET", "/api/admin/emails", http.StatusOK},
+		{"tokens cannot manage tokens", admin.Token, "GET", "/api/me/tokens", http.StatusForbidden},
+		{"tokens cannot create tokens", admin.Token, "POST", "/api/me/tokens", http.StatusForbidden},
+		{"unknown token", "tfy_not-a-real-token", "GET", "/api/projects", http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := env.do(tt.method, tt.url, tt.token, map[string]string{})
+			if w.Code != tt.want {
+				t.Fatalf("status = %d, want %d (body %s)", w.Code, tt.want, w.Body.String())
+			}
+			if tt.want == http.StatusOK && w.Body.String() != env.userID {
+				t.Errorf("request ran as %q, want %q", w.Body.String(), env.userID)
+			}
+		})
+	}
+}
+
+func TestAccessTokens_LastUsedRevokeAndExpiry(t *testing.T) {
+	env := newAccessTokenTestEnv(t)
+	token := env.create(t, models.ScopeReadProjects)
+
+	if token.LastUsedAt != nil {
+		t.Error("new token already has a last-used time")
+	}
+	if w := env.do("GET", "/api/projects", token.Token, nil); w.Code != http.StatusOK {
+		t.Fatalf("GET /projects status = %d", w.Code)
+	}
+	listed, err := env.tokens.ListTokens(env.userID)
+	if err != nil {
+		t.Fatalf("ListTokens() error = %v", err)
+	}
+	if len(listed) != 1 || listed[0].LastUsedAt == nil {
+		t.Fatalf("last-used time not recorded: %+v", listed)
+	}
+
+	// Expired tokens are rejected
+	expired := env.create(t, models.ScopeReadProjects)
+	env.db.Exec("UPDATE personal_access_tokens SET expires_at = ? WHERE id = ?", time.Now().UTC().Add(-time.Minute), expired.ID)
+	if w := env.do("GET", "/api/projects", expired.Token, nil); w.Code != http.StatusUnauthorized {
+		t.Errorf("expired token status = %d, want 401", w.Code)
+	}
+
+	if w := env.do("DELETE", "/api/me/tokens/"+token.ID, env.login, nil); w.Code != http.StatusNoContent {
+		t.Fatalf("RevokeAccessToken status = %d, body %s", w.Code, w.Body.String())
+	}
+	if w := env.do("GET", "/api/projects", token.Token, nil); w.Code != http.StatusUnauthorized {
+		t.Errorf("revoked token status = %d, want 401", w.Code)
+	}
+	if w := env.do("DELETE", "/api/me/tokens/"+token.ID, env.login, nil); w.Code != http.StatusNotFound {
+		t.Errorf("revoking twice status = %d, want 404", w.Code)
+	}
+
+	listed, _ = env.tokens.ListTokens(env.userID)
+	if len(listed) != 1 || listed[0].ID != expired.ID {
+		t.Errorf("ListTokens after revoke = %+v, want only the expired token", listed)
+	}
+}
+
+func TestAccessTokens_InactiveUserRejected(t *testing.T) {
+	env := newAccessTokenTestEnv(t)
+	token := env.create(t, models.ScopeAdmin)
+
+	if err := env.userRepo.SetUserActive(env.userID, false); err != nil {
+		t.Fatalf("SetUserActive() error = %v", err)
+	}
+
+	if w := env.do("GET", "/api/projects", token.Token, nil); w.Code != http.StatusUnauthorized {
+		t.Errorf("inactive user token status = %d, want 401", w.Code)
+	}
+}
@@ -0,0 +1,352 @@
+package testcases
+
+import (
+	"database/sql"
+	"encoding/json"
+	"sync"
+	"testing"
+
+	"backend/internal/models"
+	"backend/internal/services"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// recordingBroadcaster captures every frame a service publishes
+type recordingBroadcaster struct {
+	mu     sync.Mutex
+	frames []recordedFrame
+}
+
+type recordedFrame struct {
+	projectID int64
+	payload   interface{}
+}
+
+func (b *recordingBroadcaster) BroadcastJSON(projectID int64, payload interface{}) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.frames = append(b.frames, recordedFrame{projectID: projectID, payload: payload})
+}
+
+// boardEvents returns the recorded board events of the given type
+func (b *recordingBroadcaster) boardEvents(eventType models.BoardEventType) []models.BoardEvent {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var events []models.BoardEvent
+	for _, frame := range b.frames {
+		if event, ok := frame.payload.(models.BoardEvent); ok && event.Type == eventType {
+			events = append(events, event)
+		}
+	}
+	return events
+}
+
+func (b *recordingBroadcaster) singleBoardEvent(t *testing.T, eventType models.BoardEventType) models.BoardEvent {
+	t.Helper()
+	events := b.boardEvents(eventType)
+	if len(events) != 1 {
+		t.Fatalf("got %d %q events, want 1", len(events), eventType)
+	}
+	return events[0]
+}
+
+func newBoardEventTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+	return newSchemaTestDB(t)
+}
+
+// seedBoardEventProject creates an owner, a project with two stages and one task.
+// Returns (projectID, todoStageID, doneStageID, taskID).
+func seedBoardEventProject(t *testing.T, db *sql.DB, ownerID string) (int64, int64, int64, int64) {
+	t.Helper()
+
+	if _, err := db.Exec("INSERT INTO users (id, name, email) VALUES (?, ?, ?)", ownerID, "Owner", ownerID+"@example.com"); err != nil {
+		t.Fatalf("insert user error = %v", err)
+	}
+
+	result, err := db.Exec("INSERT INTO projects (owner_id, name) VALUES (?, ?)", ownerID, "Board")
+	if err != nil {
+		t.Fatalf("insert project error = %v", err)
+	}
+	projectID, _ := result.LastInsertId()
+
+	if _, err := db.Exec("INSERT INTO project_members (project_id, user_id, role) VALUES (?, ?, 'owner')", projectID, ownerID); err != nil {
+		t.Fatalf("insert member error = %v", err)
+	}
+
+	stageIDs := make([]int64, 0, 2)
+	for position, name := range []string{"To Do", "Done"} {
+		result, err := db.Exec("INSERT INTO stages (user_id, project_id, name, position) VALUES (?, ?, ?, ?)", ownerID, projectID, name, position)
+		if err != nil {
+			t.Fatalf("insert stage error = %v", err)
+		}
+		stageID, _ := result.LastInsertId()
+		stageIDs = append(stageIDs, stageID)
+	}
+
+	result, err = db.Exec("INSERT INTO tasks (user_id, stage_id, title, description, position) VALUES (?, ?, ?, ?, ?)", ownerID, stageIDs[0], "Write docs", "", 0)
+	if err != nil {
+		t.Fatalf("insert task error = %v", err)
+	}
+	taskID, _ := result.LastInsertId()
+
+	return projectID, stageIDs[0], stageIDs[1], taskID
+}
+
+func assertBoardEnvelope(t *testing.T, event models.BoardEvent, projectID int64, actorID string) {
+	t.Helper()
+	if event.Version != models.BoardEventVersion {
+		t.Errorf("Version = %d, want %d", event.Version, models.BoardEventVersion)
+	}
+	if event.ProjectID != projectID {
+		t.Errorf("ProjectID = %d, want %d", event.ProjectID, projectID)
+	}
+	if event.ActorID != actorID {
+		t.Errorf("ActorID = %q, want %q", event.ActorID, actorID)
+	}
+	if event.OccurredAt.IsZero() {
+		t.Error("OccurredAt should be set")
+	}
+}
+
+func TestBoardEvents_TaskCreateUpdateDelete(t *testing.T) {
+	db := newBoardEventTestDB(t)
+	projectID, todoID, _, _ := seedBoardEventProject(t, db, "owner-1")
+
+	broadcaster := &recordingBroadcaster{}
+	service := services.NewTaskService(db, nil)
+	service.SetBroadcaster(broadcaster)
+
+	task, err := service.CreateTask("owner-1", todoID, "New task", "", 1, services.TaskAttributes{})
+	if err != nil {
+		t.Fatalf("CreateTask() error = %v", err)
+	}
+	created := broadcaster.singleBoardEvent(t, models.BoardTaskCreated)
+	assertBoardEnvelope(t, created, projectID, "owner-1")
+	if data := created.Data.(models.TaskEventData); data.Task.ID != task.ID {
+		t.Errorf("created task id = %d, want %d", data.Task.ID, task.ID)
+	}
+
+	if _, err := service.UpdateTask("owner-1", task.ID, "Renamed", "", 1, services.TaskAttributes{}); err != nil {
+		t.Fatalf("UpdateTask() error = %v", err)
+	}
+	updated := broadcaster.singleBoardEvent(t, models.BoardTaskUpdated)
+	if data := updated.Data.(models.TaskEventData); data.Task.Title != "Renamed" {
+		t.Errorf("updated title = %q, want Renamed", data.Task.Title)
+	}
+
+	if err := service.DeleteTask("owner-1", task.ID); err != nil {
+		t.Fatalf("DeleteTask() error = %v", err)
+	}
+	deleted := broadcaster.singleBoardEvent(t, models.BoardTaskDeleted)
+	assertBoardEnvelope(t, deleted, projectID, "owner-1")
+	data := deleted.Data.(models.TaskDeletedEventData)
+	if data.TaskID != task.ID || data.StageID != todoID {
+		t.Errorf("deleted data = %+v, want task %d in stage %d", data, task.ID, todoID)
+	}
+}
+
+func TestBoardEvents_TaskMovedCarriesFromAndTo(t *testing.T) {
+	db := newBoardEventTestDB(t)
+	projectID, todoID, doneID, taskID := seedBoardEventProject(t, db, "owner-1")
+
+	broadcaster := &recordingBroadcaster{}
+	service := services.NewTaskService(db, nil)
+	service.SetBroadcaster(broadcaster)
+
+	if _, err := service.MoveTask("owner-1", taskID, doneID, 3); err != nil {
+		t.Fatalf("MoveTask() error = %v", err)
+	}
+
+	event := broadcaster.singleBoardEvent(t, models.BoardTaskMoved)
+	assertBoardEnvelope(t, event, projectID, "owner-1")
+	data := event.Data.(models.TaskMovedEventData)
+	if data.TaskID != taskID || data.FromStageID != todoID || data.ToStageID != doneID {
+		t.Errorf("moved data = %+v, want task %d from %d to %d", data, taskID, todoID, doneID)
+	}
+	if data.FromPosition != 0 || data.ToPosition != 3 {
+		t.Errorf("positions = %d -> %d, want 0 -> 3", data.FromPosition, data.ToPosition)
+	}
+
+	// The wire format is what the board client consumes
+	raw, err := json.Marshal(event)
+	if err != nil {
+		t.Fatalf("json.Marshal() error = %v", err)
+	}
+	var wire map[string]interface{}
+	json.Unmarshal(raw, &wire)
+	if wire["type"] != "task.moved" || wire["version"] != float64(models.BoardEventVersion) {
+		t.Errorf("wire envelope = %v", wire)
+	}
+	payload := wire["data"].(map[string]interface{})
+	if payload["to_stage_id"] != float64(doneID) || payload["task"] == nil {
+		t.Errorf("wire data = %v", payload)
+	}
+}
+
+func TestBoardEvents_TaskAssigned(t *testing.T) {
+	db := newBoardEventTestDB(t)
+	projectID, _, _, taskID := seedBoardEventProject(t, db, "owner-1")
+	if _, err := db.Exec("INSERT INTO users (id, name, email) VALUES ('member-1', 'Member', 'member-1@example.com')"); err != nil {
+		t.Fatalf("insert user error = %v", err)
+	}
+	if _, err := db.Exec("INSERT INTO project_members (project_id, user_id, role) VALUES (?, 'member-1', 'member')", projectID); err != nil {
+		t.Fatalf("insert member error = %v", err)
+	}
+
+	broadcaster := &recordingBroadcaster{}
+	service := services.NewTaskService(db, nil)
+	service.SetBroadcaster(broadcaster)
+
+	assignee := "member-1"
+	if _, err := service.AssignTask(taskID, &assignee, "owner-1"); err != nil {
+		t.Fatalf("AssignTask() error = %v", err)
+	}
+	event := broadcaster.singleBoardEvent(t, models.BoardTaskAssigned)
+	assertBoardEnvelope(t, event, projectID, "owner-1")
+	if data := event.Data.(models.TaskEventData); data.Task.AssignedTo == nil || *data.Task.AssignedTo != "member-1" {
+		t.Errorf("assigned_to = %v, want member-1", data.Task.AssignedTo)
+	}
+
+	// Re-assigning to the same user changes nothing and publishes nothing
+	if _, err := service.AssignTask(taskID, &assignee, "owner-1"); err != nil {
+		t.Fatalf("AssignTask() error = %v", err)
+	}
+	if n := len(broadcaster.boardEvents(models.BoardTaskAssigned)); n != 1 {
+		t.Errorf("got %d task.assigned events after a no-op, want 1", n)
+	}
+}
+
+func TestBoardEvents_StagesReordered(t *testing.T) {
+	db := newBoardEventTestDB(t)
+	projectID, todoID, doneID, _ := seedBoardEventProject(t, db, "owner-1")
+
+	broadcaster := &recordingBroadcaster{}
+	service := services.NewStageService(db)
+	service.SetBroadcaster(broadcaster)
+
+	if _, err := service.ReorderStages("owner-1", projectID, []int64{doneID, todoID}); err != nil {
+		t.Fatalf("ReorderStages() error = %v", err)
+	}
+
+	event := broadcaster.singleBoardEvent(t, models.BoardStagesReordered)
+	assertBoardEnvelope(t, event, projectID, "owner-1")
+	data := event.Data.(models.StagesReorderedEventData)
+	if len(data.Stages) != 2 || data.Stages[0].ID != doneID {
+		t.Errorf("stages = %+v, want %d first", data.Stages, doneID)
+	}
+}
+
+func TestBoardEvents_TaskLabelAddedAndRemoved(t *testing.T) {
+	db := newBoardEventTestDB(t)
+	projectID, _, _, taskID := seedBoardEventProject(t, db, "owner-1")
+	result, err := db.Exec("INSERT INTO labels (project_id, name, color, created_by) VALUES (?, 'bug', '#ff0000', 'owner-1')", projectID)
+	if err != nil {
+		t.Fatalf("insert label error = %v", err)
+	}
+	labelID, _ := result.LastInsertId()
+
+	broadcaster := &recordingBroadcaster{}
+	pmService := services.NewProjectMemberService(db)
+	service := services.NewTaskLabelService(db, pmService, nil)
+	service.SetBroadcaster(broadcaster)
+
+	if err := service.AssignLabel(taskID, labelID, "owner-1", "Owner"); err != nil {
+		t.Fatalf("AssignLabel() error = %v", err)
+	}
+	added := broadcaster.singleBoardEvent(t, models.BoardTaskLabelAdded)
+	assertBoardEnvelope(t, added, projectID, "owner-1")
+	if data := added.Data.(models.TaskLabelEventData); data.TaskID != taskID || data.Label == nil || data.Label.Name != "bug" {
+		t.Errorf("label_added data = %+v", data)
+	}
+
+	if err := service.RemoveLabel(taskID, labelID, "owner-1", "Owner"); err != nil {
+		t.Fatalf("RemoveLabel() error = %v", err)
+	}
+	removed := broadcaster.singleBoardEvent(t, models.BoardTaskLabelRemoved)
+	if data := removed.Data.(models.TaskLabelEventData); data.LabelID != labelID {
+		t.Errorf("label_removed label_id = %d, want %d", data.LabelID, labelID)
+	}
+}
+
+func TestBoardEvents_CommentLifecycle(t *testing.T) {
+	db := newBoardEventTestDB(t)
+	projectID, _, _, taskID := seedBoardEventProject(t, db, "owner-1")
+
+	broadcaster := &recordingBroadcaster{}
+	service := services.NewCommentService(db)
+	service.SetBroadcaster(broadcaster)
+
+	comment, err := service.CreateComment("owner-1", taskID, "Looks good")
+	if err != nil {
+		t.Fatalf("CreateComment() error = %v", err)
+	}
+	created := broadcaster.singleBoardEvent(t, models.BoardCommentCreated)
+	assertBoardEnvelope(t, created, projectID, "owner-1")
+
+	if _, err := service.UpdateComment("owner-1", comment.ID, "Looks great"); err != nil {
+		t.Fatalf("UpdateComment() error = %v", err)
+	}
+	updated := broadcaster.singleBoardEvent(t, models.BoardCommentUpdated)
+	if data := updated.Data.(models.CommentEventData); data.Comment.Content != "Looks great" {
+		t.Errorf("updated content = %q", data.Comment.Content)
+	}
+
+	if err := service.DeleteComment("owner-1", comment.ID); err != nil {
+		t.Fatalf("DeleteComment() error = %v", err)
+	}
+	deleted := broadcaster.singleBoardEvent(t, models.BoardCommentDeleted)
+	assertBoardEnvelope(t, deleted, projectID, "owner-1")
+	if data := deleted.Data.(models.CommentDeletedEventData); data.CommentID != comment.ID || data.TaskID != taskID {
+		t.Errorf("deleted data = %+v", data)
+	}
+}
+
+func TestBoardEvents_SubtaskLifecycle(t *testing.T) {
+	db := newBoardEventTestDB(t)
+	projectID, _, _, taskID := seedBoardEventProject(t, db, "owner-1")
+
+	broadcaster := &recordingBroadcaster{}
+	service := services.NewSubtaskService(db)
+	service.SetBroadcaster(broadcaster)
+
+	subtask, err := service.CreateSubtask("owner-1", taskID, "Draft outline", nil)
+	if err != nil {
+		t.Fatalf("CreateSubtask() error = %v", err)
+	}
+	assertBoardEnvelope(t, broadcaster.singleBoardEvent(t, models.BoardSubtaskCreated), projectID, "owner-1")
+
+	if _, err := service.UpdateSubtask("owner-1", subtask.ID, services.SubtaskPatch{IsCompleted: boolPtr(true)}); err != nil {
+		t.Fatalf("UpdateSubtask() error = %v", err)
+	}
+	updated := broadcaster.singleBoardEvent(t, models.BoardSubtaskUpdated)
+	if data := updated.Data.(models.SubtaskEventData); !data.Subtask.IsCompleted {
+		t.Error("updated subtask should be completed")
+	}
+
+	if err := service.DeleteSubtask("owner-1", subtask.ID); err != nil {
+		t.Fatalf("DeleteSubtask() error = %v", err)
+	}
+	deleted := broadcaster.singleBoardEvent(t, models.BoardSubtaskDeleted)
+	if data := deleted.Data.(models.SubtaskDeletedEventData); data.SubtaskID != subtask.ID || data.TaskID != taskID {
+		t.Errorf("deleted data = %+v", data)
+	}
+}
+
+func TestBoardEvents_FailedMutationPublishesNothing(t *testing.T) {
+	db := newBoardEventTestDB(t)
+	_, _, _, taskID := seedBoardEventProject(t, db, "owner-1")
+
+	broadcaster := &recordingBroadcaster{}
+	service := services.NewTaskService(db, nil)
+	service.SetBroadcaster(broadcaster)
+
+	if err := service.DeleteTask("someone-else", taskID); err == nil {
+		t.Fatal("DeleteTask() by a non-owner should fail")
+	}
+	if len(broadcaster.frames) != 0 {
+		t.Errorf("published %d frames for a failed delete, want 0", len(broadcaster.frames))
+	}
+}
@@ -0,0 +1,281 @@
+package testcases
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"backend/internal/chat"
+	"backend/internal/services"
+
+	"github.com/gorilla/websocket"
+)
+
+// backplaneTestEnvs runs two chat "instances" on one database, joined by the
+// backplanes that newBackplane returns for each hub
+func backplaneTestEnvs(t *testing.T, db *sql.DB, newBackplane func() chat.Backplane) (*chatTestEnv, *chatTestEnv) {
+	t.Helper()
+
+	attach := func(hub *chat.Hub) {
+		backplane := newBackplane()
+		t.Cleanup(func() { backplane.Close() })
+		if err := hub.SetBackplane(backplane); err != nil {
+			t.Fatalf("SetBackplane() error = %v", err)
+		}
+	}
+	return startChatTestServer(t, db, attach), startChatTestServer(t, db, attach)
+}
+
+func assertMessagesCrossInstances(t *testing.T, first, second *chatTestEnv) {
+	t.Helper()
+
+	projectID := seedProjectAndOwnerPM(t, first.db, "owner-1")
+	seedChatMember(t, first.db, projectID, "member-1", "Member")
+
+	onFirst := first.dial(t, "1", "owner-1")
+	onSecond := second.dial(t, "1", "member-1")
+	waitForClientCount(t, first.hub, projectID, 1)
+	waitForClientCount(t, second.hub, projectID, 1)
+
+	// A chat frame sent to the first instance reaches the client on the second
+	if err := onFirst.WriteJSON(map[string]string{"type": "message", "content": "hello from A"}); err != nil {
+		t.Fatalf("WriteJSON() error = %v", err)
+	}
+	if frame := readChatFrame(t, onSecond, services.ChatFrameMessage); frame["content"] != "hello from A" {
+		t.Fatalf("second instance got content %v, want hello from A", frame["content"])
+	}
+
+	// ...and the sender's own instance delivers it exactly once: nothing
+	// echoed back over the backplane arrives before a later marker frame
+	second.hub.BroadcastJSON(projectID, map[string]string{"type": "marker"})
+	if n := countFramesUntil(t, onFirst, services.ChatFrameMessage, "marker"); n != 1 {
+		t.Fatalf("sender's instance delivered the message %d times, want 1", n)
+	}
+
+	// Removing the member on the first instance closes their socket on the second
+	if err := first.members.RemoveMember(projectID, "member-1", "owner-1"); err != nil {
+		t.Fatalf("RemoveMember() error = %v", err)
+	}
+	onSecond.SetReadDeadline(time.Now().Add(3 * time.Second))
+	for {
+		if _, _, err := onSecond.ReadMessage(); err != nil {
+			if !websocket.IsCloseError(err, websocket.CloseNormalClosure, websocket.CloseNoStatusReceived) {
+				t.Fatalf("ReadMessage() error = %v, want close frame", err)
+			}
+			break
+		}
+	}
+	waitForClientCount(t, second.hub, projectID, 0)
+}
+
+// assertPresenceCrossInstances checks that users connected to one instance
+// are announced to and listed by the other
+func assertPresenceCrossInstances(t *testing.T, first, second *chatTestEnv) {
+	t.Helper()
+
+	projectID := seedProjectAndOwnerPM(t, first.db, "owner-1")
+	seedChatMember(t, first.db, projectID, "member-1", "Member")
+
+	onFirst := first.dial(t, "1", "owner-1")
+	waitForOnlineUsers(t, second.hub, projectID, "owner-1")
+
+	onSecond := second.dial(t, "1", "member-1")
+	readPresenceFrame(t, onFirst, chat.FramePresenceJoin, "member-1")
+	waitForOnlineUsers(t, first.hub, projectID, "member-1", "owner-1")
+	waitForOnlineUsers(t, second.hub, projectID, "member-1", "owner-1")
+
+	// A second tab on the other instance is not a new join, and closing it
+	// is not a leave while the first tab is open
+	extra := first.dial(t, "1", "member-1")
+	waitForClientCount(t, first.hub, projectID, 2)
+	extra.Close()
+	waitForClientCount(t, first.hub, projectID, 1)
+
+	onSecond.Close()
+	readPresenceFrame(t, onFirst, chat.FramePresenceLeave, "member-1")
+	waitForOnlineUsers(t, first.hub, projectID, "owner-1")
+}
+
+// readPresenceFrame reads frames until a presence frame of the given type for
+// userID arrives, failing on any other presence frame for that user
+func readPresenceFrame(t *testing.T, conn *websocket.Conn, frameType, userID string) {
+	t.Helper()
+	conn.SetReadDeadline(time.Now().Add(3 * time.Second))
+	defer conn.SetReadDeadline(time.Time{})
+
+	for {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			t.Fatalf("ReadMessage() waiting for %s of %s: %v", frameType, userID, err)
+		}
+		for _, line := range bytes.Split(data, []byte{'\n'}) {
+			var frame chat.PresenceFrame
+			if err := json.Unmarshal(line, &frame); err != nil {
+				t.Fatalf("invalid frame %q: %v", line, err)
+			}
+			if frame.UserID != userID || !strings.HasPrefix(frame.Type, "presence.") {
+				continue
+			}
+			if frame.Type != frameType {
+				t.Fatalf("got %s of %s, want %s", frame.Type, userID, frameType)
+			}
+			return
+		}
+	}
+}
+
+// waitForOnlineUsers waits until the hub lists exactly the given users
+func waitForOnlineUsers(t *testing.T, hub *chat.Hub, projectID int64, want ...string) {
+	t.Helper()
+	deadline := time.Now().Add(3 * time.Second)
+	var users []string
+	for time.Now().Before(deadline) {
+		users = hub.OnlineUsers(projectID)
+		if strings.Join(users, ",") == strings.Join(want, ",") {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("OnlineUsers(%d) = %v, want %v", projectID, users, want)
+}
+
+// countFramesUntil reads frames until one of type stop arrives and counts those of type count
+func countFramesUntil(t *testing.T, conn *websocket.Conn, count, stop string) int {
+	t.Helper()
+	conn.SetReadDeadline(time.Now().Add(3 * time.Second))
+	defer conn.SetReadDeadline(time.Time{})
+
+	n := 0
+	for {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			t.Fatalf("ReadMessage() waiting for %q frame: %v", stop, err)
+		}
+		for _, line := range bytes.Split(data, []byte{'\n'}) {
+			var frame map[string]interface{}
+			if err := json.Unmarshal(line, &frame); err != nil {
+				t.Fatalf("invalid frame %q: %v", line, err)
+			}
+			switch frame["type"] {
+			case count:
+				n++
+			case stop:
+				return n
+			}
+		}
+	}
+}
+
+func TestChatBackplane_MemoryBusConnectsHubs(t *testing.T) {
+	bus := chat.NewMemoryBus()
+	first, second := backplaneTestEnvs(t, newChatTestDB(t), bus.Backplane)
+	assertMessagesCrossInstances(t, first, second)
+}
+
+func TestChatBackplane_SQLiteConnectsHubs(t *testing.T) {
+	// Both instances poll the same database, as two processes would
+	db := newChatTestDB(t)
+	first, second := backplaneTestEnvs(t, db, func() chat.Backplane {
+		return chat.NewSQLiteBackplane(db, 20*time.Millisecond)
+	})
+	assertMessagesCrossInstances(t, first, second)
+}
+
+func TestChatBackplane_SQLiteFallsBackToDefaultPollInterval(t *testing.T) {
+	// Zero and negative intervals would make time.NewTicker panic
+	db := newChatTestDB(t)
+	intervals := []time.Duration{0, -time.Second}
+	first, second := backplaneTestEnvs(t, db, func() chat.Backplane {
+		interval := intervals[0]
+		intervals = intervals[1:]
+		return chat.NewSQLiteBackplane(db, interval)
+	})
+	assertMessagesCrossInstances(t, first, second)
+}
+
+func TestChatBackplane_PresenceAcrossInstances(t *testing.T) {
+	t.Run("memory bus", func(t *testing.T) {
+		bus := chat.NewMemoryBus()
+		first, second := backplaneTestEnvs(t, newChatTestDB(t), bus.Backplane)
+		assertPresenceCrossInstances(t, first, second)
+	})
+	t.Run("sqlite", func(t *testing.T) {
+		db := newChatTestDB(t)
+		first, second := backplaneTestEnvs(t, db, func() chat.Backplane {
+			return chat.NewSQLiteBackplane(db, 20*time.Millisecond)
+		})
+		assertPresenceCrossInstances(t, first, second)
+	})
+}
+
+func TestChatBackplane_PresenceOfStoppedInstanceExpires(t *testing.T) {
+	db := newChatTestDB(t)
+	bus := chat.NewMemoryBus()
+	backplanes := make(map[*chat.Hub]chat.Backplane)
+	attach := func(hub *chat.Hub) {
+		hub.SetPresenceRefresh(50 * time.Millisecond)
+		backplane := bus.Backplane()
+		t.Cleanup(func() { backplane.Close() })
+		if err := hub.SetBackplane(backplane); err != nil {
+			t.Fatalf("SetBackplane() error = %v", err)
+		}
+		backplanes[hub] = backplane
+	}
+	first := startChatTestServer(t, db, attach)
+	second := startChatTestServer(t, db, attach)
+
+	projectID := seedProjectAndOwnerPM(t, db, "owner-1")
+	seedChatMember(t, db, projectID, "member-1", "Member")
+	onSecond := second.dial(t, "1", "member-1")
+	first.dial(t, "1", "owner-1")
+	readPresenceFrame(t, onSecond, chat.FramePresenceJoin, "owner-1")
+
+	// An instance started later learns who is online from the others'
+	// snapshots rather than from join messages it missed
+	third := startChatTestServer(t, db, attach)
+	waitForOnlineUsers(t, third.hub, projectID, "member-1", "owner-1")
+
+	// The first instance goes away without announcing that owner-1 left
+	backplanes[first.hub].Close()
+	readPresenceFrame(t, onSecond, chat.FramePresenceLeave, "owner-1")
+	waitForOnlineUsers(t, second.hub, projectID, "member-1")
+	waitForOnlineUsers(t, third.hub, projectID, "member-1")
+}
+
+func TestChatBackplane_ClosedMemoryEndpointStopsDelivery(t *testing.T) {
+	bus := chat.NewMemoryBus()
+	first := bus.Backplane()
+	second := bus.Backplane()
+
+	received := make(chan chat.BackplaneMessage, 1)
+	if err := second.Subscribe(func(msg chat.BackplaneMessage) { received <- msg }); err != nil {
+		t.Fatalf("Subscribe() error = %v", err)
+	}
+	first.Subscribe(func(chat.BackplaneMessage) {
+		t.Error("publisher received its own message")
+	})
+
+	if err := first.Publish(chat.BackplaneMessage{Kind: chat.BackplaneBroadcast, ProjectID: 1, Payload: []byte("{}")}); err != nil {
+		t.Fatalf("Publish() error = %v", err)
+	}
+	select {
+	case msg := <-received:
+		if msg.ProjectID != 1 || string(msg.Payload) != "{}" {
+			t.Errorf("received %+v", msg)
+		}
+	default:
+		t.Fatal("subscriber did not receive the message")
+	}
+
+	second.Close()
+	first.Publish(chat.BackplaneMessage{Kind: chat.BackplaneBroadcast, ProjectID: 1})
+	if len(received) != 0 {
+		t.Error("closed endpoint should not receive messages")
+	}
+	if err := second.Publish(chat.BackplaneMessage{Kind: chat.BackplaneBroadcast}); err != chat.ErrBackplaneClosed {
+		t.Errorf("Publish() on closed endpoint error = %v, want ErrBackplaneClosed", err)
+	}
+}
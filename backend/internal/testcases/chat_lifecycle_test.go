@@ -0,0 +1,161 @@
+package testcases
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"backend/internal/chat"
+
+	"github.com/gorilla/websocket"
+)
+
+// These tests are meant to be run with the race detector:
+//   go test -race ./internal/testcases -run ChatLifecycle
+
+func TestChatLifecycle_ConcurrentJoinsLeavesAndBroadcasts(t *testing.T) {
+	env := newChatTestServer(t)
+	projectID := seedProjectAndOwnerPM(t, env.db, "owner-1")
+
+	const members = 8
+	const tabsPerMember = 4
+	for i := 0; i < members; i++ {
+		seedChatMember(t, env.db, projectID, fmt.Sprintf("member-%d", i), fmt.Sprintf("Member %d", i))
+	}
+
+	stop := make(chan struct{})
+	var broadcasters sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		broadcasters.Add(1)
+		go func(i int) {
+			defer broadcasters.Done()
+			for n := 0; ; n++ {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				env.hub.BroadcastJSON(projectID, map[string]interface{}{"type": "stress", "from": i, "n": n})
+				env.hub.OnlineUsers(projectID)
+				env.hub.GetClientCount(projectID)
+			}
+		}(i)
+	}
+
+	var clients sync.WaitGroup
+	for i := 0; i < members; i++ {
+		for tab := 0; tab < tabsPerMember; tab++ {
+			clients.Add(1)
+			go func(userID string, tab int) {
+				defer clients.Done()
+
+				conn, _, err := websocket.DefaultDialer.Dial(env.url("1", "token="+env.token(t, userID)), chatTestHeader())
+				if err != nil {
+					t.Errorf("Dial(%s) error = %v", userID, err)
+					return
+				}
+				defer conn.Close()
+
+				conn.WriteJSON(map[string]string{"type": chat.FrameTyping})
+				conn.SetReadDeadline(time.Now().Add(200 * time.Millisecond))
+				for j := 0; j < 5; j++ {
+					if _, _, err := conn.ReadMessage(); err != nil {
+						break
+					}
+				}
+
+				// Half the tabs are torn down by the server, racing the client's own close
+				if tab%2 == 0 {
+					env.hub.DisconnectUser(projectID, userID)
+				}
+			}(fmt.Sprintf("member-%d", i), tab)
+		}
+	}
+
+	clients.Wait()
+	close(stop)
+	broadcasters.Wait()
+
+	waitForClientCount(t, env.hub, projectID, 0)
+	if users := env.hub.OnlineUsers(projectID); len(users) != 0 {
+		t.Fatalf("OnlineUsers() = %v, want none after every client left", users)
+	}
+}
+
+func TestChatLifecycle_HalfOpenConnectionIsDropped(t *testing.T) {
+	env := newChatTestServer(t, func(hub *chat.Hub) {
+		hub.SetTimeouts(time.Second, 300*time.Millisecond)
+	})
+	projectID := seedProjectAndOwnerPM(t, env.db, "owner-1")
+
+	conn := env.dial(t, "1", "owner-1")
+	// Swallow pings without answering, like a peer that vanished without a FIN
+	conn.SetPingHandler(func(string) error { return nil })
+	go func() {
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	waitForClientCount(t, env.hub, projectID, 1)
+	waitForClientCount(t, env.hub, projectID, 0)
+}
+
+func TestChatLifecycle_HealthyConnectionSurvivesPongTimeout(t *testing.T) {
+	env := newChatTestServer(t, func(hub *chat.Hub) {
+		hub.SetTimeouts(time.Second, 300*time.Millisecond)
+	})
+	projectID := seedProjectAndOwnerPM(t, env.db, "owner-1")
+
+	conn := env.dial(t, "1", "owner-1")
+	// The default ping handler answers with a pong while we keep reading
+	go func() {
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	waitForClientCount(t, env.hub, projectID, 1)
+	time.Sleep(time.Second)
+	if got := env.hub.GetClientCount(projectID); got != 1 {
+		t.Fatalf("GetClientCount() = %d after several ping periods, want 1", got)
+	}
+}
+
+func TestChatLifecycle_OversizedFrameClosesConnection(t *testing.T) {
+	env := newChatTestServer(t)
+	projectID := seedProjectAndOwnerPM(t, env.db, "owner-1")
+
+	conn := env.dial(t, "1", "owner-1")
+	waitForClientCount(t, env.hub, projectID, 1)
+
+	frame := `{"type":"message","content":"` + strings.Repeat("a", 64*1024) + `"}`
+	conn.WriteMessage(websocket.TextMessage, []byte(frame))
+
+	waitForClientCount(t, env.hub, projectID, 0)
+}
+
+func TestChatLifecycle_SlowConsumerIsDropped(t *testing.T) {
+	env := newChatTestServer(t, func(hub *chat.Hub) {
+		hub.SetTimeouts(200*time.Millisecond, time.Minute)
+	})
+	projectID := seedProjectAndOwnerPM(t, env.db, "owner-1")
+
+	// Never read from this connection
+	env.dial(t, "1", "owner-1")
+	waitForClientCount(t, env.hub, projectID, 1)
+
+	payload := map[string]string{"type": "stress", "blob": strings.Repeat("x", 32*1024)}
+	deadline := time.Now().Add(5 * time.Second)
+	for env.hub.GetClientCount(projectID) > 0 && time.Now().Before(deadline) {
+		env.hub.BroadcastJSON(projectID, payload)
+	}
+
+	waitForClientCount(t, env.hub, projectID, 0)
+}
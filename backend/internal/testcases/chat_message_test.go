@@ -0,0 +1,189 @@
+package testcases
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"backend/internal/services"
+
+	"github.com/gorilla/websocket"
+)
+
+// readChatFrame reads frames from conn until one of the given type arrives.
+// The hub may batch several frames into one message separated by newlines.
+func readChatFrame(t *testing.T, conn *websocket.Conn, frameType string) map[string]interface{} {
+	t.Helper()
+	return readChatFrames(t, conn, frameType, 1)[0]
+}
+
+// readChatFrames reads frames from conn until n of the given type arrived,
+// including ones batched into the same message
+func readChatFrames(t *testing.T, conn *websocket.Conn, frameType string, n int) []map[string]interface{} {
+	t.Helper()
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	defer conn.SetReadDeadline(time.Time{})
+
+	var frames []map[string]interface{}
+	for len(frames) < n {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			t.Fatalf("ReadMessage() waiting for %q frame: %v", frameType, err)
+		}
+		for _, line := range bytes.Split(data, []byte{'\n'}) {
+			var frame map[string]interface{}
+			if err := json.Unmarshal(line, &frame); err != nil {
+				t.Fatalf("invalid frame %q: %v", line, err)
+			}
+			if frame["type"] == frameType && len(frames) < n {
+				frames = append(frames, frame)
+			}
+		}
+	}
+	return frames
+}
+
+func TestChatMessage_SocketFrameIsPersistedWithServerSender(t *testing.T) {
+	env := newChatTestServer(t)
+	projectID := seedProjectAndOwnerPM(t, env.db, "owner-1")
+	seedChatMember(t, env.db, projectID, "member-1", "Member")
+
+	ownerConn := env.dial(t, "1", "owner-1")
+	memberConn := env.dial(t, "1", "member-1")
+	waitForClientCount(t, env.hub, projectID, 2)
+
+	// Client-supplied sender and timestamp must be ignored
+	err := ownerConn.WriteJSON(map[string]interface{}{
+		"type":        "message",
+		"content":     "  hello team  ",
+		"sender_name": "Mallory",
+		"created_at":  "2001-01-01T00:00:00Z",
+	})
+	if err != nil {
+		t.Fatalf("WriteJSON() error = %v", err)
+	}
+
+	frame := readChatFrame(t, memberConn, services.ChatFrameMessage)
+	if frame["sender_name"] != "Owner User" {
+		t.Errorf("sender_name = %v, want Owner User", frame["sender_name"])
+	}
+	if frame["user_id"] != "owner-1" {
+		t.Errorf("user_id = %v, want owner-1", frame["user_id"])
+	}
+	if frame["content"] != "hello team" {
+		t.Errorf("content = %v, want trimmed content", frame["content"])
+	}
+	if id, _ := frame["id"].(float64); id <= 0 {
+		t.Errorf("id = %v, want stored message id", frame["id"])
+	}
+	if createdAt, _ := frame["created_at"].(string); createdAt == "2001-01-01T00:00:00Z" {
+		t.Error("created_at should be assigned by the server")
+	}
+
+	// The sender receives the same stored message
+	readChatFrame(t, ownerConn, services.ChatFrameMessage)
+
+	history, err := env.messages.GetMessagesByProject("member-1", projectID, services.MessageCursor{})
+	if err != nil {
+		t.Fatalf("GetMessagesByProject() error = %v", err)
+	}
+	if len(history) != 1 {
+		t.Fatalf("GetMessagesByProject() returned %d messages, want 1", len(history))
+	}
+	if int64(frame["id"].(float64)) != history[0].ID || history[0].SenderName != "Owner User" {
+		t.Errorf("history = %+v, want the broadcast message", history[0])
+	}
+}
+
+func TestChatMessage_EmptyFrameReturnsError(t *testing.T) {
+	env := newChatTestServer(t)
+	projectID := seedProjectAndOwnerPM(t, env.db, "owner-1")
+
+	conn := env.dial(t, "1", "owner-1")
+	waitForClientCount(t, env.hub, projectID, 1)
+
+	if err := conn.WriteJSON(map[string]string{"type": "message", "content": "   "}); err != nil {
+		t.Fatalf("WriteJSON() error = %v", err)
+	}
+
+	frame := readChatFrame(t, conn, "error")
+	if frame["error"] != services.ErrMessageContentRequired.Error() {
+		t.Errorf("error = %v, want %q", frame["error"], services.ErrMessageContentRequired)
+	}
+}
+
+func TestChatMessage_DeleteBroadcastsEvent(t *testing.T) {
+	env := newChatTestServer(t)
+	projectID := seedProjectAndOwnerPM(t, env.db, "owner-1")
+
+	message, err := env.messages.CreateMessage("owner-1", projectID, "to be deleted")
+	if err != nil {
+		t.Fatalf("CreateMessage() error = %v", err)
+	}
+
+	conn := env.dial(t, "1", "owner-1")
+	waitForClientCount(t, env.hub, projectID, 1)
+
+	if err := env.messages.DeleteMessage("owner-1", message.ID); err != nil {
+		t.Fatalf("DeleteMessage() error = %v", err)
+	}
+
+	frame := readChatFrame(t, conn, services.ChatFrameMessageDeleted)
+	if int64(frame["id"].(float64)) != message.ID {
+		t.Errorf("id = %v, want %d", frame["id"], message.ID)
+	}
+	if int64(frame["project_id"].(float64)) != projectID {
+		t.Errorf("project_id = %v, want %d", frame["project_id"], projectID)
+	}
+}
+
+func TestChatMessage_RejectsNonMember(t *testing.T) {
+	env := newChatTestServer(t)
+	projectID := seedProjectAndOwnerPM(t, env.db, "owner-1")
+
+	_, err := env.messages.CreateMessage("outsider", projectID, "hi")
+	if !errors.Is(err, services.ErrProjectNotFoundOrAccessDenied) {
+		t.Fatalf("CreateMessage() error = %v, want %v", err, services.ErrProjectNotFoundOrAccessDenied)
+	}
+}
+
+func TestChatMessage_LengthIsCountedInCharacters(t *testing.T) {
+	env := newChatTestServer(t)
+	projectID := seedProjectAndOwnerPM(t, env.db, "owner-1")
+
+	// Each of these takes several bytes of UTF-8 but is one character
+	for _, char := range []string{"é", "日", "🚀"} {
+		longest := strings.Repeat(char, services.MaxMessageLength)
+		message, err := env.messages.CreateMessage("owner-1", projectID, longest)
+		if err != nil {
+			t.Fatalf("CreateMessage() with %d %q error = %v", services.MaxMessageLength, char, err)
+		}
+		if message.Content != longest {
+			t.Errorf("CreateMessage() stored %d bytes, want %d", len(message.Content), len(longest))
+		}
+
+		_, err = env.messages.CreateMessage("owner-1", projectID, longest+char)
+		if !errors.Is(err, services.ErrMessageTooLong) {
+			t.Errorf("CreateMessage() with %d %q error = %v, want %v", services.MaxMessageLength+1, char, err, services.ErrMessageTooLong)
+		}
+	}
+}
+
+func TestChatMessage_OnlySenderCanDelete(t *testing.T) {
+	env := newChatTestServer(t)
+	projectID := seedProjectAndOwnerPM(t, env.db, "owner-1")
+	seedChatMember(t, env.db, projectID, "member-1", "Member")
+
+	message, err := env.messages.CreateMessage("owner-1", projectID, "mine")
+	if err != nil {
+		t.Fatalf("CreateMessage() error = %v", err)
+	}
+
+	err = env.messages.DeleteMessage("member-1", message.ID)
+	if !errors.Is(err, services.ErrMessageNotFoundOrAccessDenied) {
+		t.Fatalf("DeleteMessage() error = %v, want %v", err, services.ErrMessageNotFoundOrAccessDenied)
+	}
+}
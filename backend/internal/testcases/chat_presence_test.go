@@ -0,0 +1,106 @@
+package testcases
+
+import (
+	"testing"
+
+	"backend/internal/chat"
+)
+
+func TestChatPresence_JoinAndLeave(t *testing.T) {
+	env := newChatTestServer(t)
+	projectID := seedProjectAndOwnerPM(t, env.db, "owner-1")
+	seedChatMember(t, env.db, projectID, "member-1", "Member")
+
+	ownerConn := env.dial(t, "1", "owner-1")
+	if frame := readChatFrame(t, ownerConn, chat.FramePresenceJoin); frame["user_id"] != "owner-1" {
+		t.Fatalf("first join user_id = %v, want owner-1", frame["user_id"])
+	}
+
+	memberConn := env.dial(t, "1", "member-1")
+	if frame := readChatFrame(t, ownerConn, chat.FramePresenceJoin); frame["user_id"] != "member-1" {
+		t.Fatalf("join user_id = %v, want member-1", frame["user_id"])
+	}
+	waitForClientCount(t, env.hub, projectID, 2)
+
+	online, err := env.members.GetOnlineMembers(projectID, "owner-1")
+	if err != nil {
+		t.Fatalf("GetOnlineMembers() error = %v", err)
+	}
+	names := map[string]string{}
+	for _, p := range online {
+		names[p.UserID] = p.Name
+	}
+	if len(online) != 2 || names["owner-1"] != "Owner User" || names["member-1"] != "Member" {
+		t.Fatalf("GetOnlineMembers() = %+v, want owner and member with names", online)
+	}
+
+	memberConn.Close()
+	frame := readChatFrame(t, ownerConn, chat.FramePresenceLeave)
+	if frame["user_id"] != "member-1" {
+		t.Fatalf("leave user_id = %v, want member-1", frame["user_id"])
+	}
+	if users := env.hub.OnlineUsers(projectID); len(users) != 1 || users[0] != "owner-1" {
+		t.Fatalf("OnlineUsers() = %v, want [owner-1]", users)
+	}
+}
+
+func TestChatPresence_MultipleTabsCountOnce(t *testing.T) {
+	env := newChatTestServer(t)
+	projectID := seedProjectAndOwnerPM(t, env.db, "owner-1")
+	seedChatMember(t, env.db, projectID, "member-1", "Member")
+
+	ownerConn := env.dial(t, "1", "owner-1")
+	readChatFrame(t, ownerConn, chat.FramePresenceJoin)
+
+	firstTab := env.dial(t, "1", "member-1")
+	readChatFrame(t, ownerConn, chat.FramePresenceJoin)
+	env.dial(t, "1", "member-1")
+	waitForClientCount(t, env.hub, projectID, 3)
+
+	if users := env.hub.OnlineUsers(projectID); len(users) != 2 {
+		t.Fatalf("OnlineUsers() = %v, want 2 distinct users", users)
+	}
+
+	// Closing one of two tabs keeps the member online
+	firstTab.Close()
+	waitForClientCount(t, env.hub, projectID, 2)
+
+	online, err := env.members.GetOnlineMembers(projectID, "owner-1")
+	if err != nil {
+		t.Fatalf("GetOnlineMembers() error = %v", err)
+	}
+	if len(online) != 2 {
+		t.Fatalf("GetOnlineMembers() = %+v, want member still online", online)
+	}
+}
+
+func TestChatPresence_TypingIsRelayedToOthers(t *testing.T) {
+	env := newChatTestServer(t)
+	projectID := seedProjectAndOwnerPM(t, env.db, "owner-1")
+	seedChatMember(t, env.db, projectID, "member-1", "Member")
+
+	ownerConn := env.dial(t, "1", "owner-1")
+	memberConn := env.dial(t, "1", "member-1")
+	waitForClientCount(t, env.hub, projectID, 2)
+
+	if err := memberConn.WriteJSON(map[string]string{"type": chat.FrameTyping}); err != nil {
+		t.Fatalf("WriteJSON() error = %v", err)
+	}
+
+	frame := readChatFrame(t, ownerConn, chat.FrameTyping)
+	if frame["user_id"] != "member-1" {
+		t.Errorf("typing user_id = %v, want member-1", frame["user_id"])
+	}
+	if frame["expires_in_ms"] != float64(chat.TypingTTL.Milliseconds()) {
+		t.Errorf("expires_in_ms = %v, want %d", frame["expires_in_ms"], chat.TypingTTL.Milliseconds())
+	}
+}
+
+func TestChatPresence_NonMemberCannotListPresence(t *testing.T) {
+	env := newChatTestServer(t)
+	projectID := seedProjectAndOwnerPM(t, env.db, "owner-1")
+
+	if _, err := env.members.GetOnlineMembers(projectID, "outsider"); err == nil {
+		t.Fatal("GetOnlineMembers() should deny non-members")
+	}
+}
@@ -0,0 +1,139 @@
+package testcases
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"backend/internal/chat"
+	"backend/internal/controllers"
+	"backend/internal/models"
+	"backend/internal/services"
+
+	"github.com/gorilla/mux"
+)
+
+func TestChatRead_SocketReadBroadcastsReceiptAndNeverMovesBack(t *testing.T) {
+	env := newChatTestServer(t)
+	projectID := seedProjectAndOwnerPM(t, env.db, "owner-1")
+	seedChatMember(t, env.db, projectID, "member-1", "Member")
+	ids := seedChatHistory(t, env.messages, "owner-1", projectID, 3)
+
+	sender := newChatFrameReader(t, env.dial(t, "1", "owner-1"))
+	reader := env.dial(t, "1", "member-1")
+	waitForClientCount(t, env.hub, projectID, 2)
+
+	if err := reader.WriteJSON(map[string]interface{}{"type": chat.FrameRead, "message_id": ids[1]}); err != nil {
+		t.Fatalf("WriteJSON() error = %v", err)
+	}
+	receipt := sender.next(services.ChatFrameMessageRead)
+	if receipt["user_id"] != "member-1" || receipt["last_read_message_id"] != float64(ids[1]) {
+		t.Fatalf("read receipt = %v, want member-1 at %d", receipt, ids[1])
+	}
+
+	// Reading an older message keeps the position and sends no receipt
+	read, err := env.messages.MarkRead("member-1", projectID, ids[0])
+	if err != nil {
+		t.Fatalf("MarkRead() error = %v", err)
+	}
+	if read.LastReadMessageID != ids[1] {
+		t.Errorf("MarkRead() older message moved position to %d, want %d", read.LastReadMessageID, ids[1])
+	}
+	env.hub.BroadcastJSON(projectID, map[string]string{"type": "marker"})
+	if n := countFramesUntil(t, sender.conn, services.ChatFrameMessageRead, "marker"); n != 0 {
+		t.Errorf("got %d read receipts for a backwards read, want 0", n)
+	}
+
+	reads, err := env.messages.GetReads("owner-1", projectID)
+	if err != nil {
+		t.Fatalf("GetReads() error = %v", err)
+	}
+	if len(reads) != 1 || reads[0].UserID != "member-1" || reads[0].LastReadMessageID != ids[1] {
+		t.Errorf("GetReads() = %+v, want member-1 at %d", reads, ids[1])
+	}
+}
+
+func TestChatRead_ProjectListShowsUnreadCounts(t *testing.T) {
+	env := newChatTestServer(t)
+	projectID := seedProjectAndOwnerPM(t, env.db, "owner-1")
+	seedChatMember(t, env.db, projectID, "member-1", "Member")
+	quietProject := seedProjectAndOwnerPM(t, env.db, "owner-2")
+	if _, err := env.db.Exec(`INSERT INTO project_members (project_id, user_id, role) VALUES (?, 'member-1', 'member')`, quietProject); err != nil {
+		t.Fatalf("insert member: %v", err)
+	}
+	env.db.Exec(`UPDATE projects SET description = ''`)
+
+	ids := seedChatHistory(t, env.messages, "owner-1", projectID, 4)
+	if _, err := env.messages.CreateMessage("member-1", projectID, "my own message"); err != nil {
+		t.Fatalf("CreateMessage() error = %v", err)
+	}
+	projects := services.NewProjectService(env.db)
+
+	unread := func() map[int64]int {
+		t.Helper()
+		list, err := projects.GetAllProjects("member-1")
+		if err != nil {
+			t.Fatalf("GetAllProjects() error = %v", err)
+		}
+		counts := map[int64]int{}
+		for _, project := range list {
+			if project.UnreadCount == nil {
+				t.Fatalf("project %d has no unread_count", project.ID)
+			}
+			counts[project.ID] = *project.UnreadCount
+		}
+		return counts
+	}
+
+	// The member's own message never counts as unread
+	if counts := unread(); counts[projectID] != 4 || counts[quietProject] != 0 {
+		t.Fatalf("unread counts = %v, want 4 and 0", counts)
+	}
+
+	if _, err := env.messages.MarkRead("member-1", projectID, ids[2]); err != nil {
+		t.Fatalf("MarkRead() error = %v", err)
+	}
+	if counts := unread(); counts[projectID] != 1 {
+		t.Fatalf("unread count after reading up to the third message = %d, want 1", counts[projectID])
+	}
+}
+
+func TestChatRead_MarkReadEndpoint(t *testing.T) {
+	env := newChatTestServer(t)
+	projectID := seedProjectAndOwnerPM(t, env.db, "owner-1")
+	otherProject := seedProjectAndOwnerPM(t, env.db, "owner-2")
+	ids := seedChatHistory(t, env.messages, "owner-1", projectID, 2)
+	foreign := seedChatHistory(t, env.messages, "owner-2", otherProject, 1)
+	controller := controllers.NewMessageController(env.messages)
+
+	post := func(body interface{}) *httptest.ResponseRecorder {
+		req := createRequestWithUser(http.MethodPost, "/api/projects/1/messages/read", body, "owner-1")
+		req = mux.SetURLVars(req, map[string]string{"projectId": toString(projectID)})
+		w := httptest.NewRecorder()
+		controller.MarkRead(w, req)
+		return w
+	}
+
+	// No body marks everything read
+	w := post(nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("MarkRead() status = %d, want 200; body=%s", w.Code, w.Body.String())
+	}
+	var read models.MessageRead
+	if err := json.NewDecoder(w.Body).Decode(&read); err != nil {
+		t.Fatalf("decode response error = %v", err)
+	}
+	if read.LastReadMessageID != ids[1] {
+		t.Errorf("last_read_message_id = %d, want latest %d", read.LastReadMessageID, ids[1])
+	}
+
+	// Messages from another project cannot be used as a read position
+	if w := post(map[string]int64{"message_id": foreign[0]}); w.Code != http.StatusNotFound {
+		t.Errorf("MarkRead() with another project's message status = %d, want 404", w.Code)
+	}
+	if _, err := env.messages.MarkRead("owner-2", projectID, 0); !errors.Is(err, services.ErrProjectNotFoundOrAccessDenied) {
+		t.Errorf("MarkRead() by non-member error = %v, want ErrProjectNotFoundOrAccessDenied", err)
+	}
+}
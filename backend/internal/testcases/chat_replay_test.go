@@ -0,0 +1,267 @@
+package testcases
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"backend/internal/chat"
+	"backend/internal/controllers"
+	"backend/internal/models"
+	"backend/internal/services"
+
+	"github.com/gorilla/mux"
+	"github.com/gorilla/websocket"
+)
+
+// seedChatHistory posts count messages as userID and returns their ids in order
+func seedChatHistory(t *testing.T, messages *services.MessageService, userID string, projectID int64, count int) []int64 {
+	t.Helper()
+	ids := make([]int64, 0, count)
+	for i := 1; i <= count; i++ {
+		message, err := messages.CreateMessage(userID, projectID, fmt.Sprintf("message %d", i))
+		if err != nil {
+			t.Fatalf("CreateMessage() error = %v", err)
+		}
+		ids = append(ids, message.ID)
+	}
+	return ids
+}
+
+func messageIDs(messages []models.Message) []int64 {
+	ids := make([]int64, 0, len(messages))
+	for _, message := range messages {
+		ids = append(ids, message.ID)
+	}
+	return ids
+}
+
+func assertMessageIDs(t *testing.T, name string, got []models.Message, want []int64) {
+	t.Helper()
+	if fmt.Sprint(messageIDs(got)) != fmt.Sprint(want) {
+		t.Errorf("%s returned ids %v, want %v", name, messageIDs(got), want)
+	}
+}
+
+func TestChatHistory_CursorPagination(t *testing.T) {
+	env := newChatTestServer(t)
+	projectID := seedProjectAndOwnerPM(t, env.db, "owner-1")
+	ids := seedChatHistory(t, env.messages, "owner-1", projectID, 6)
+
+	tests := []struct {
+		name   string
+		cursor services.MessageCursor
+		want   []int64
+	}{
+		{"latest page", services.MessageCursor{Limit: 2}, ids[4:]},
+		{"scroll back", services.MessageCursor{Before: ids[4], Limit: 2}, ids[2:4]},
+		{"catch up", services.MessageCursor{After: ids[1], Limit: 2}, ids[2:4]},
+		{"fill gap", services.MessageCursor{Before: ids[5], After: ids[0], Limit: 2}, ids[3:5]},
+		{"default limit", services.MessageCursor{}, ids},
+		{"past the end", services.MessageCursor{After: ids[5]}, nil},
+	}
+	for _, tt := range tests {
+		got, err := env.messages.GetMessagesByProject("owner-1", projectID, tt.cursor)
+		if err != nil {
+			t.Fatalf("%s: GetMessagesByProject() error = %v", tt.name, err)
+		}
+		assertMessageIDs(t, tt.name, got, tt.want)
+	}
+
+	if _, err := env.messages.GetMessagesByProject("owner-1", projectID, services.MessageCursor{Limit: -1}); err != services.ErrInvalidMessageCursor {
+		t.Errorf("negative limit error = %v, want ErrInvalidMessageCursor", err)
+	}
+	if _, err := env.messages.GetMessagesByProject("outsider", projectID, services.MessageCursor{}); err != services.ErrProjectNotFoundOrAccessDenied {
+		t.Errorf("outsider error = %v, want ErrProjectNotFoundOrAccessDenied", err)
+	}
+}
+
+func TestChatHistory_ControllerReadsCursorParams(t *testing.T) {
+	env := newChatTestServer(t)
+	projectID := seedProjectAndOwnerPM(t, env.db, "owner-1")
+	ids := seedChatHistory(t, env.messages, "owner-1", projectID, 4)
+	controller := controllers.NewMessageController(env.messages)
+
+	get := func(query string) *httptest.ResponseRecorder {
+		req := createRequestWithUser(http.MethodGet, "/api/projects/1/messages?"+query, nil, "owner-1")
+		req = mux.SetURLVars(req, map[string]string{"projectId": toString(projectID)})
+		w := httptest.NewRecorder()
+		controller.GetMessagesByProject(w, req)
+		return w
+	}
+
+	w := get(fmt.Sprintf("before=%d&limit=2", ids[3]))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200; body=%s", w.Code, w.Body.String())
+	}
+	var page []models.Message
+	if err := json.NewDecoder(w.Body).Decode(&page); err != nil {
+		t.Fatalf("decode response error = %v", err)
+	}
+	assertMessageIDs(t, "before page", page, ids[1:3])
+
+	for _, query := range []string{"before=abc", "after=-3", "limit=0", "limit=ten"} {
+		if w := get(query); w.Code != http.StatusBadRequest {
+			t.Errorf("%s: status = %d, want 400", query, w.Code)
+		}
+	}
+}
+
+// chatFrameReader reads frames one at a time, keeping the rest of a batched
+// WebSocket message for the next call
+type chatFrameReader struct {
+	t      *testing.T
+	conn   *websocket.Conn
+	queued []map[string]interface{}
+}
+
+func newChatFrameReader(t *testing.T, conn *websocket.Conn) *chatFrameReader {
+	return &chatFrameReader{t: t, conn: conn}
+}
+
+// next returns the next frame whose type is one of types
+func (r *chatFrameReader) next(types ...string) map[string]interface{} {
+	r.t.Helper()
+	r.conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	defer r.conn.SetReadDeadline(time.Time{})
+
+	for {
+		for len(r.queued) > 0 {
+			frame := r.queued[0]
+			r.queued = r.queued[1:]
+			for _, frameType := range types {
+				if frame["type"] == frameType {
+					return frame
+				}
+			}
+		}
+
+		_, data, err := r.conn.ReadMessage()
+		if err != nil {
+			r.t.Fatalf("ReadMessage() waiting for %v frame: %v", types, err)
+		}
+		for _, line := range bytes.Split(data, []byte{'\n'}) {
+			var frame map[string]interface{}
+			if err := json.Unmarshal(line, &frame); err != nil {
+				r.t.Fatalf("invalid frame %q: %v", line, err)
+			}
+			r.queued = append(r.queued, frame)
+		}
+	}
+}
+
+// replay reads frames until replay.complete and returns the replayed message ids
+func (r *chatFrameReader) replay() ([]int64, map[string]interface{}) {
+	r.t.Helper()
+	var ids []int64
+	for {
+		frame := r.next(chat.FrameMessage, chat.FrameReplayComplete)
+		if frame["type"] == chat.FrameReplayComplete {
+			return ids, frame
+		}
+		ids = append(ids, int64(frame["id"].(float64)))
+	}
+}
+
+func (env *chatTestEnv) dialWithReplay(t *testing.T, userID string, lastMessageID int64) *chatFrameReader {
+	t.Helper()
+	query := fmt.Sprintf("token=%s&last_message_id=%d", env.token(t, userID), lastMessageID)
+	conn, _, err := websocket.DefaultDialer.Dial(env.url("1", query), chatTestHeader())
+	if err != nil {
+		t.Fatalf("Dial(%s) error = %v", userID, err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return newChatFrameReader(t, conn)
+}
+
+func TestChatReplay_ReconnectReceivesMissedMessagesBeforeLive(t *testing.T) {
+	env := newChatTestServer(t)
+	projectID := seedProjectAndOwnerPM(t, env.db, "owner-1")
+	seedChatMember(t, env.db, projectID, "member-1", "Member")
+	ids := seedChatHistory(t, env.messages, "owner-1", projectID, 4)
+
+	reader := env.dialWithReplay(t, "member-1", ids[1])
+	replayed, complete := reader.replay()
+	if fmt.Sprint(replayed) != fmt.Sprint(ids[2:]) {
+		t.Fatalf("replayed ids %v, want %v", replayed, ids[2:])
+	}
+	if complete["count"] != float64(2) || complete["has_more"] != false {
+		t.Errorf("replay.complete = %v, want count 2 and has_more false", complete)
+	}
+
+	live, err := env.messages.CreateMessage("owner-1", projectID, "live")
+	if err != nil {
+		t.Fatalf("CreateMessage() error = %v", err)
+	}
+	frame := reader.next(chat.FrameMessage)
+	if int64(frame["id"].(float64)) != live.ID {
+		t.Errorf("live frame id = %v, want %d", frame["id"], live.ID)
+	}
+}
+
+func TestChatReplay_MessagesDuringReplayAreDeliveredOnceInOrder(t *testing.T) {
+	env := newChatTestServer(t)
+	projectID := seedProjectAndOwnerPM(t, env.db, "owner-1")
+	seedChatMember(t, env.db, projectID, "member-1", "Member")
+
+	// Keep posting while the member reconnects so messages race the replay
+	done := make(chan []int64)
+	go func() {
+		var posted []int64
+		for i := 0; i < 40; i++ {
+			message, err := env.messages.CreateMessage("owner-1", projectID, fmt.Sprintf("racing %d", i))
+			if err != nil {
+				t.Errorf("CreateMessage() error = %v", err)
+				break
+			}
+			posted = append(posted, message.ID)
+		}
+		done <- posted
+	}()
+
+	reader := env.dialWithReplay(t, "member-1", 0)
+	posted := <-done
+
+	received, _ := reader.replay()
+	for len(received) == 0 || received[len(received)-1] != posted[len(posted)-1] {
+		frame := reader.next(chat.FrameMessage)
+		received = append(received, int64(frame["id"].(float64)))
+	}
+
+	if fmt.Sprint(received) != fmt.Sprint(posted[len(posted)-len(received):]) {
+		t.Fatalf("received %v, want a gap-free, duplicate-free tail of %v", received, posted)
+	}
+}
+
+func TestChatReplay_TruncatesToNewestMessages(t *testing.T) {
+	env := newChatTestServer(t)
+	projectID := seedProjectAndOwnerPM(t, env.db, "owner-1")
+	ids := seedChatHistory(t, env.messages, "owner-1", projectID, chat.MaxReplayMessages+3)
+
+	reader := env.dialWithReplay(t, "owner-1", 0)
+	replayed, complete := reader.replay()
+	if len(replayed) != chat.MaxReplayMessages || replayed[0] != ids[3] {
+		t.Fatalf("replayed %d messages starting at %d, want %d starting at %d", len(replayed), replayed[0], chat.MaxReplayMessages, ids[3])
+	}
+	if complete["has_more"] != true {
+		t.Errorf("replay.complete has_more = %v, want true", complete["has_more"])
+	}
+}
+
+func TestChatReplay_RejectsInvalidLastMessageID(t *testing.T) {
+	env := newChatTestServer(t)
+	seedProjectAndOwnerPM(t, env.db, "owner-1")
+
+	query := "token=" + env.token(t, "owner-1") + "&last_message_id=abc"
+	_, resp, err := websocket.DefaultDialer.Dial(env.url("1", query), chatTestHeader())
+	if err == nil {
+		t.Fatal("Dial() should fail with an invalid last_message_id")
+	}
+	if resp == nil || resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("Dial() status = %v, want 400", resp)
+	}
+}
@@ -0,0 +1,221 @@
+package testcases
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"backend/internal/controllers"
+	"backend/internal/models"
+	"backend/internal/services"
+
+	"github.com/gorilla/mux"
+)
+
+func TestChatThread_EditStampsEditedAtAndBroadcasts(t *testing.T) {
+	env := newChatTestServer(t)
+	projectID := seedProjectAndOwnerPM(t, env.db, "owner-1")
+	seedChatMember(t, env.db, projectID, "member-1", "Member")
+
+	message, err := env.messages.CreateMessage("owner-1", projectID, "frist")
+	if err != nil {
+		t.Fatalf("CreateMessage() error = %v", err)
+	}
+
+	reader := newChatFrameReader(t, env.dial(t, "1", "member-1"))
+	waitForClientCount(t, env.hub, projectID, 1)
+
+	if _, err := env.messages.UpdateMessage("member-1", message.ID, "hijacked"); !errors.Is(err, services.ErrMessageNotFoundOrAccessDenied) {
+		t.Fatalf("UpdateMessage() by another user error = %v, want ErrMessageNotFoundOrAccessDenied", err)
+	}
+	if _, err := env.messages.UpdateMessage("owner-1", message.ID, "  "); !errors.Is(err, services.ErrMessageContentRequired) {
+		t.Fatalf("UpdateMessage() with empty content error = %v, want ErrMessageContentRequired", err)
+	}
+
+	updated, err := env.messages.UpdateMessage("owner-1", message.ID, " first ")
+	if err != nil {
+		t.Fatalf("UpdateMessage() error = %v", err)
+	}
+	if updated.Content != "first" || updated.EditedAt == nil || !updated.CreatedAt.Equal(message.CreatedAt) {
+		t.Errorf("UpdateMessage() = %+v, want trimmed content, edited_at set and created_at kept", updated)
+	}
+
+	frame := reader.next(services.ChatFrameMessageUpdated)
+	if frame["content"] != "first" || frame["edited_at"] == nil {
+		t.Errorf("message_updated frame = %v, want edited content and edited_at", frame)
+	}
+}
+
+func TestChatThread_ReactionsAreUniquePerUserAndBroadcast(t *testing.T) {
+	env := newChatTestServer(t)
+	projectID := seedProjectAndOwnerPM(t, env.db, "owner-1")
+	seedChatMember(t, env.db, projectID, "member-1", "Member")
+	seedChatMember(t, env.db, projectID, "member-2", "Member Two")
+
+	message, err := env.messages.CreateMessage("owner-1", projectID, "ship it?")
+	if err != nil {
+		t.Fatalf("CreateMessage() error = %v", err)
+	}
+
+	reader := newChatFrameReader(t, env.dial(t, "1", "owner-1"))
+	waitForClientCount(t, env.hub, projectID, 1)
+
+	for _, userID := range []string{"member-1", "member-1", "member-2"} {
+		if _, err := env.messages.AddReaction(userID, message.ID, "👍"); err != nil {
+			t.Fatalf("AddReaction(%s) error = %v", userID, err)
+		}
+	}
+	reactions, err := env.messages.AddReaction("member-1", message.ID, "🎉")
+	if err != nil {
+		t.Fatalf("AddReaction() error = %v", err)
+	}
+
+	want := []models.MessageReactionSummary{
+		{Emoji: "👍", Count: 2, UserIDs: []string{"member-1", "member-2"}},
+		{Emoji: "🎉", Count: 1, UserIDs: []string{"member-1"}},
+	}
+	if got, _ := json.Marshal(reactions); string(got) != string(mustJSON(t, want)) {
+		t.Errorf("reactions = %s, want %s", got, mustJSON(t, want))
+	}
+
+	// The duplicate reaction is not broadcast
+	env.hub.BroadcastJSON(projectID, map[string]string{"type": "marker"})
+	if n := countFramesUntil(t, reader.conn, services.ChatFrameReactionAdded, "marker"); n != 3 {
+		t.Errorf("broadcast %d reaction_added frames, want 3", n)
+	}
+
+	reactions, err = env.messages.RemoveReaction("member-1", message.ID, "👍")
+	if err != nil {
+		t.Fatalf("RemoveReaction() error = %v", err)
+	}
+	if len(reactions) != 2 || reactions[0].Count != 1 || reactions[0].UserIDs[0] != "member-2" {
+		t.Errorf("reactions after removal = %+v", reactions)
+	}
+	frame := reader.next(services.ChatFrameReactionRemoved)
+	if frame["user_id"] != "member-1" || frame["emoji"] != "👍" || frame["message_id"] != float64(message.ID) {
+		t.Errorf("reaction_removed frame = %v", frame)
+	}
+
+	if _, err := env.messages.AddReaction("outsider", message.ID, "👍"); !errors.Is(err, services.ErrMessageNotFoundOrAccessDenied) {
+		t.Errorf("AddReaction() by outsider error = %v, want ErrMessageNotFoundOrAccessDenied", err)
+	}
+	if _, err := env.messages.AddReaction("member-1", message.ID, "thumbs up"); !errors.Is(err, services.ErrInvalidReaction) {
+		t.Errorf("AddReaction() with spaces error = %v, want ErrInvalidReaction", err)
+	}
+}
+
+func TestChatThread_RepliesStayOutOfTheMainHistory(t *testing.T) {
+	env := newChatTestServer(t)
+	projectID := seedProjectAndOwnerPM(t, env.db, "owner-1")
+	seedChatMember(t, env.db, projectID, "member-1", "Member")
+
+	parent, err := env.messages.CreateMessage("owner-1", projectID, "release notes?")
+	if err != nil {
+		t.Fatalf("CreateMessage() error = %v", err)
+	}
+
+	conn := env.dial(t, "1", "member-1")
+	waitForClientCount(t, env.hub, projectID, 1)
+
+	// Replies can be sent over the socket
+	if err := conn.WriteJSON(map[string]interface{}{"type": "message", "content": "on it", "parent_message_id": parent.ID}); err != nil {
+		t.Fatalf("WriteJSON() error = %v", err)
+	}
+	frame := readChatFrame(t, conn, services.ChatFrameMessage)
+	if frame["parent_message_id"] != float64(parent.ID) {
+		t.Fatalf("reply frame parent_message_id = %v, want %d", frame["parent_message_id"], parent.ID)
+	}
+	replyID := int64(frame["id"].(float64))
+
+	if _, err := env.messages.CreateReply("owner-1", projectID, replyID, "nested"); !errors.Is(err, services.ErrInvalidParentMessage) {
+		t.Errorf("CreateReply() to a reply error = %v, want ErrInvalidParentMessage", err)
+	}
+	otherProject := seedProjectAndOwnerPM(t, env.db, "owner-2")
+	if _, err := env.messages.CreateReply("owner-2", otherProject, parent.ID, "wrong project"); !errors.Is(err, services.ErrInvalidParentMessage) {
+		t.Errorf("CreateReply() across projects error = %v, want ErrInvalidParentMessage", err)
+	}
+
+	history, err := env.messages.GetMessagesByProject("owner-1", projectID, services.MessageCursor{})
+	if err != nil {
+		t.Fatalf("GetMessagesByProject() error = %v", err)
+	}
+	if len(history) != 1 || history[0].ID != parent.ID || history[0].ReplyCount != 1 {
+		t.Fatalf("history = %+v, want only the parent with reply_count 1", history)
+	}
+
+	replies, err := env.messages.GetReplies("owner-1", parent.ID, services.MessageCursor{})
+	if err != nil {
+		t.Fatalf("GetReplies() error = %v", err)
+	}
+	if len(replies) != 1 || replies[0].ID != replyID || replies[0].Content != "on it" {
+		t.Fatalf("replies = %+v, want the socket reply", replies)
+	}
+
+	// Deleting the parent removes its thread, reactions and mentions
+	if _, err := env.messages.AddReaction("owner-1", replyID, "👀"); err != nil {
+		t.Fatalf("AddReaction() error = %v", err)
+	}
+	env.db.Exec(`INSERT INTO mentions (project_id, user_id, author_id, entity_type, entity_id, excerpt)
+		VALUES (?, 'owner-1', 'member-1', 'message', ?, 'on it')`, projectID, replyID)
+	if err := env.messages.DeleteMessage("owner-1", parent.ID); err != nil {
+		t.Fatalf("DeleteMessage() error = %v", err)
+	}
+	var remaining int
+	env.db.QueryRow("SELECT (SELECT COUNT(*) FROM messages) + (SELECT COUNT(*) FROM message_reactions) + (SELECT COUNT(*) FROM mentions)").Scan(&remaining)
+	if remaining != 0 {
+		t.Errorf("%d messages, reactions and mentions left after deleting the thread, want 0", remaining)
+	}
+
+	// Clients hear about the reply, which was another user's, and then the parent
+	deleted := readChatFrames(t, conn, services.ChatFrameMessageDeleted, 2)
+	if deleted[0]["id"] != float64(replyID) || deleted[0]["parent_message_id"] != float64(parent.ID) {
+		t.Errorf("first message_deleted frame = %v, want reply %d of %d", deleted[0], replyID, parent.ID)
+	}
+	if deleted[1]["id"] != float64(parent.ID) || deleted[1]["parent_message_id"] != nil {
+		t.Errorf("second message_deleted frame = %v, want parent %d", deleted[1], parent.ID)
+	}
+}
+
+func TestChatThread_ReactionRoutesDecodeEmoji(t *testing.T) {
+	env := newChatTestServer(t)
+	projectID := seedProjectAndOwnerPM(t, env.db, "owner-1")
+	message, err := env.messages.CreateMessage("owner-1", projectID, "hello")
+	if err != nil {
+		t.Fatalf("CreateMessage() error = %v", err)
+	}
+
+	controller := controllers.NewMessageController(env.messages)
+	router := mux.NewRouter()
+	router.HandleFunc("/api/messages/{id}/reactions", controller.AddReaction).Methods("POST")
+	router.HandleFunc("/api/messages/{id}/reactions/{emoji}", controller.RemoveReaction).Methods("DELETE")
+	path := "/api/messages/" + toString(message.ID) + "/reactions"
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, createRequestWithUser(http.MethodPost, path, map[string]string{"emoji": "🚀"}, "owner-1"))
+	if w.Code != http.StatusOK {
+		t.Fatalf("AddReaction() status = %d, want 200; body=%s", w.Code, w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, createRequestWithUser(http.MethodDelete, path+"/%F0%9F%9A%80", nil, "owner-1"))
+	if w.Code != http.StatusOK || w.Body.String() != "[]\n" {
+		t.Fatalf("RemoveReaction() status = %d body = %q, want 200 with no reactions left", w.Code, w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, createRequestWithUser(http.MethodPost, path, map[string]string{"emoji": ""}, "owner-1"))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("AddReaction() with empty emoji status = %d, want 400", w.Code)
+	}
+}
+
+func mustJSON(t *testing.T, value interface{}) []byte {
+	t.Helper()
+	data, err := json.Marshal(value)
+	if err != nil {
+		t.Fatalf("json.Marshal() error = %v", err)
+	}
+	return data
+}
@@ -0,0 +1,245 @@
+package testcases
+
+import (
+	"database/sql"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	authservices "backend/internal/auth/services"
+	"backend/internal/chat"
+	"backend/internal/services"
+
+	"github.com/gorilla/mux"
+	"github.com/gorilla/websocket"
+)
+
+const chatTestOrigin = "http://localhost:4200"
+
+// chatTestEnv is a /ws/{projectId} server backed by a real hub and services
+type chatTestEnv struct {
+	server   *httptest.Server
+	hub      *chat.Hub
+	jwt      *authservices.JWTService
+	members  *services.ProjectMemberService
+	messages *services.MessageService
+	db       *sql.DB
+}
+
+// newChatTestServer starts the server; configure runs against the hub before it starts
+func newChatTestServer(t *testing.T, configure ...func(*chat.Hub)) *chatTestEnv {
+	t.Helper()
+	return startChatTestServer(t, newChatTestDB(t), configure...)
+}
+
+func newChatTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+	return newSchemaTestDB(t)
+}
+
+// startChatTestServer starts a server with its own hub on an existing database,
+// so several "instances" can share one database
+func startChatTestServer(t *testing.T, db *sql.DB, configure ...func(*chat.Hub)) *chatTestEnv {
+	t.Helper()
+
+	hub := chat.NewHub()
+	for _, fn := range configure {
+		fn(hub)
+	}
+	go hub.Run()
+
+	jwtService := authservices.NewJWTService("chat-test-secret", 1)
+	pmService := services.NewProjectMemberService(db)
+	pmService.SetConnectionManager(hub)
+	messageService := services.NewMessageService(db)
+	messageService.SetBroadcaster(hub)
+
+	router := mux.NewRouter()
+	wsHandler := chat.NewWsHandler(hub, jwtService, pmService, messageService, []string{chatTestOrigin})
+	router.HandleFunc("/ws/{projectId}", wsHandler.ServeWs)
+
+	server := httptest.NewServer(router)
+	t.Cleanup(server.Close)
+
+	return &chatTestEnv{
+		server:   server,
+		hub:      hub,
+		jwt:      jwtService,
+		members:  pmService,
+		messages: messageService,
+		db:       db,
+	}
+}
+
+func (env *chatTestEnv) url(projectID string, query string) string {
+	url := "ws" + strings.TrimPrefix(env.server.URL, "http") + "/ws/" + projectID
+	if query != "" {
+		url += "?" + query
+	}
+	return url
+}
+
+func (env *chatTestEnv) token(t *testing.T, userID string) string {
+	t.Helper()
+	token, err := env.jwt.GenerateToken(userID, userID+"@test.com")
+	if err != nil {
+		t.Fatalf("GenerateToken() error = %v", err)
+	}
+	return token
+}
+
+// dial connects userID to the project's socket with an allowed origin
+func (env *chatTestEnv) dial(t *testing.T, projectID string, userID string) *websocket.Conn {
+	t.Helper()
+	conn, _, err := websocket.DefaultDialer.Dial(env.url(projectID, "token="+env.token(t, userID)), chatTestHeader())
+	if err != nil {
+		t.Fatalf("Dial(%s) error = %v", userID, err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func chatTestHeader() http.Header {
+	header := http.Header{}
+	header.Set("Origin", chatTestOrigin)
+	return header
+}
+
+func seedChatMember(t *testing.T, db *sql.DB, projectID int64, userID, name string) {
+	t.Helper()
+	if _, err := db.Exec(`INSERT INTO users (id, email, name) VALUES (?, ?, ?)`, userID, userID+"@test.com", name); err != nil {
+		t.Fatalf("insert user: %v", err)
+	}
+	if _, err := db.Exec(`INSERT INTO project_members (project_id, user_id, role) VALUES (?, ?, 'member')`, projectID, userID); err != nil {
+		t.Fatalf("insert member: %v", err)
+	}
+}
+
+func waitForClientCount(t *testing.T, hub *chat.Hub, projectID int64, want int) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		if hub.GetClientCount(projectID) == want {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("GetClientCount(%d) = %d, want %d", projectID, hub.GetClientCount(projectID), want)
+}
+
+func TestChatWs_RejectsMissingToken(t *testing.T) {
+	env := newChatTestServer(t)
+	seedProjectAndOwnerPM(t, env.db, "owner-1")
+
+	_, resp, err := websocket.DefaultDialer.Dial(env.url("1", ""), chatTestHeader())
+	if err == nil {
+		t.Fatal("Dial() should fail without a token")
+	}
+	if resp == nil || resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("Dial() status = %v, want 401", resp)
+	}
+}
+
+func TestChatWs_RejectsInvalidToken(t *testing.T) {
+	env := newChatTestServer(t)
+	seedProjectAndOwnerPM(t, env.db, "owner-1")
+
+	_, resp, err := websocket.DefaultDialer.Dial(env.url("1", "token=not-a-jwt"), chatTestHeader())
+	if err == nil {
+		t.Fatal("Dial() should fail with an invalid token")
+	}
+	if resp == nil || resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("Dial() status = %v, want 401", resp)
+	}
+}
+
+func TestChatWs_RejectsNonMember(t *testing.T) {
+	env := newChatTestServer(t)
+	seedProjectAndOwnerPM(t, env.db, "owner-1")
+	token := env.token(t, "outsider")
+
+	_, resp, err := websocket.DefaultDialer.Dial(env.url("1", "token="+token), chatTestHeader())
+	if err == nil {
+		t.Fatal("Dial() should fail for a non-member")
+	}
+	if resp == nil || resp.StatusCode != http.StatusForbidden {
+		t.Fatalf("Dial() status = %v, want 403", resp)
+	}
+}
+
+func TestChatWs_RejectsDisallowedOrigin(t *testing.T) {
+	env := newChatTestServer(t)
+	seedProjectAndOwnerPM(t, env.db, "owner-1")
+	token := env.token(t, "owner-1")
+
+	header := http.Header{}
+	header.Set("Origin", "http://evil.example.com")
+	_, resp, err := websocket.DefaultDialer.Dial(env.url("1", "token="+token), header)
+	if err == nil {
+		t.Fatal("Dial() should fail for a disallowed origin")
+	}
+	if resp == nil || resp.StatusCode != http.StatusForbidden {
+		t.Fatalf("Dial() status = %v, want 403", resp)
+	}
+}
+
+func TestChatWs_AcceptsQueryToken(t *testing.T) {
+	env := newChatTestServer(t)
+	projectID := seedProjectAndOwnerPM(t, env.db, "owner-1")
+	token := env.token(t, "owner-1")
+
+	conn, _, err := websocket.DefaultDialer.Dial(env.url("1", "token="+token), chatTestHeader())
+	if err != nil {
+		t.Fatalf("Dial() error = %v", err)
+	}
+	defer conn.Close()
+
+	waitForClientCount(t, env.hub, projectID, 1)
+}
+
+func TestChatWs_AcceptsSubprotocolToken(t *testing.T) {
+	env := newChatTestServer(t)
+	projectID := seedProjectAndOwnerPM(t, env.db, "owner-1")
+	token := env.token(t, "owner-1")
+
+	dialer := websocket.Dialer{Subprotocols: []string{"bearer", token}}
+	conn, resp, err := dialer.Dial(env.url("1", ""), chatTestHeader())
+	if err != nil {
+		t.Fatalf("Dial() error = %v", err)
+	}
+	defer conn.Close()
+
+	if got := resp.Header.Get("Sec-WebSocket-Protocol"); got != "bearer" {
+		t.Errorf("Sec-WebSocket-Protocol = %q, want bearer", got)
+	}
+	waitForClientCount(t, env.hub, projectID, 1)
+}
+
+func TestChatWs_RemovedMemberIsDisconnected(t *testing.T) {
+	env := newChatTestServer(t)
+	projectID := seedProjectAndOwnerPM(t, env.db, "owner-1")
+	seedChatMember(t, env.db, projectID, "member-1", "Member")
+
+	env.dial(t, "1", "owner-1")
+	memberConn := env.dial(t, "1", "member-1")
+
+	waitForClientCount(t, env.hub, projectID, 2)
+
+	if err := env.members.RemoveMember(projectID, "member-1", "owner-1"); err != nil {
+		t.Fatalf("RemoveMember() error = %v", err)
+	}
+
+	memberConn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	for {
+		if _, _, err := memberConn.ReadMessage(); err != nil {
+			if !websocket.IsCloseError(err, websocket.CloseNormalClosure, websocket.CloseNoStatusReceived) {
+				t.Fatalf("ReadMessage() error = %v, want close frame", err)
+			}
+			break
+		}
+	}
+
+	waitForClientCount(t, env.hub, projectID, 1)
+}
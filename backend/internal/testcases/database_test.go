@@ -3,11 +3,34 @@ package testcases
 import (
 	"database/sql"
 	"os"
+	"path/filepath"
 	"testing"
 
+	authrepo "backend/internal/auth/repository"
+	"backend/internal/database"
+
 	_ "github.com/mattn/go-sqlite3"
 )
 
+// newSchemaTestDB opens a database in a temporary file with the server's
+// schema, created by database.Open, plus the users table. Test fixtures build
+// on it rather than copying CREATE TABLE statements, so they always match the
+// real schema.
+func newSchemaTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+
+	db, err := database.Open(filepath.Join(t.TempDir(), "taskify.db"))
+	if err != nil {
+		t.Fatalf("database.Open() error = %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	if err := authrepo.NewUserRepository(db.DB).InitTable(); err != nil {
+		t.Fatalf("Failed to create users table: %v", err)
+	}
+	return db.DB
+}
+
 func TestNewDB_Success(t *testing.T) {
 	tmpFile, err := os.CreateTemp("", "test_taskify_*.db")
 	if err != nil {
@@ -122,3 +145,52 @@ func TestTransactionSupport(t *testing.T) {
 		t.Errorf("count = %v, want 1", count)
 	}
 }
+
+func TestOpen_CreatesAndMigratesSchema(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "legacy.db")
+
+	// A database from before stages had is_final
+	legacy, err := sql.Open("sqlite3", path)
+	if err != nil {
+		t.Fatalf("sql.Open() error = %v", err)
+	}
+	if _, err := legacy.Exec(`CREATE TABLE stages (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		project_id INTEGER NOT NULL,
+		name TEXT NOT NULL,
+		position INTEGER DEFAULT 0
+	)`); err != nil {
+		t.Fatalf("Failed to create legacy stages table: %v", err)
+	}
+	legacy.Exec("INSERT INTO stages (project_id, name) VALUES (1, 'To Do')")
+	legacy.Close()
+
+	// Opening twice must be a no-op the second time
+	for i := 0; i < 2; i++ {
+		db, err := database.Open(path)
+		if err != nil {
+			t.Fatalf("database.Open() #%d error = %v", i+1, err)
+		}
+		db.Close()
+	}
+
+	db, err := database.Open(path)
+	if err != nil {
+		t.Fatalf("database.Open() error = %v", err)
+	}
+	defer db.Close()
+
+	var isFinal int
+	if err := db.QueryRow("SELECT is_final FROM stages WHERE name = 'To Do'").Scan(&isFinal); err != nil {
+		t.Fatalf("select stages.is_final error = %v", err)
+	}
+	if isFinal != 0 {
+		t.Errorf("migrated stage is_final = %d, want 0", isFinal)
+	}
+	for _, table := range []string{"projects", "tasks", "notifications", "webhooks"} {
+		var name string
+		if err := db.QueryRow("SELECT name FROM sqlite_master WHERE type = 'table' AND name = ?", table).Scan(&name); err != nil {
+			t.Errorf("table %s missing: %v", table, err)
+		}
+	}
+}
@@ -0,0 +1,217 @@
+package testcases
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"backend/internal/controllers"
+	"backend/internal/models"
+	"backend/internal/services"
+
+	"github.com/gorilla/mux"
+)
+
+func newDeadlineReminderTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+
+	db := newSchemaTestDB(t)
+	_, err := db.Exec(`
+		INSERT INTO project_members (project_id, user_id, role) VALUES (1, 'owner-1', 'owner'), (1, 'member-1', 'member');
+		INSERT INTO stages (id, project_id, name, is_final) VALUES (1, 1, 'To Do', 0), (2, 1, 'Done', 1);
+	`)
+	if err != nil {
+		t.Fatalf("Failed to seed deadline reminder project: %v", err)
+	}
+	return db
+}
+
+func seedDeadlineTask(t *testing.T, db *sql.DB, stageID int64, assignee string, deadline time.Time) int64 {
+	t.Helper()
+	result, err := db.Exec(`INSERT INTO tasks (stage_id, title, assigned_to, deadline) VALUES (?, 'Ship it', ?, ?)`, stageID, assignee, deadline)
+	if err != nil {
+		t.Fatalf("insert task: %v", err)
+	}
+	id, _ := result.LastInsertId()
+	return id
+}
+
+// deadlineMessages returns the messages of the user's notifications of the given type
+func deadlineMessages(t *testing.T, db *sql.DB, userID string, notificationType models.NotificationType) []string {
+	t.Helper()
+	rows, err := db.Query(`SELECT message FROM notifications WHERE user_id = ? AND type = ? ORDER BY id`, userID, notificationType)
+	if err != nil {
+		t.Fatalf("query notifications: %v", err)
+	}
+	defer rows.Close()
+
+	var messages []string
+	for rows.Next() {
+		var message string
+		rows.Scan(&message)
+		messages = append(messages, message)
+	}
+	return messages
+}
+
+func TestDeadlineReminders_SentOncePerDeadline(t *testing.T) {
+	db := newDeadlineReminderTestDB(t)
+	svc := services.NewNotificationService(db, nil)
+	taskID := seedDeadlineTask(t, db, 1, "member-1", time.Now().Add(2*time.Hour))
+
+	for i := 0; i < 3; i++ {
+		if err := svc.CheckDeadlines(); err != nil {
+			t.Fatalf("CheckDeadlines() error = %v", err)
+		}
+	}
+	messages := deadlineMessages(t, db, "member-1", models.NotificationDeadlineNear)
+	if len(messages) != 1 || messages[0] != "Task 'Ship it' is due within 1 day" {
+		t.Fatalf("reminders after 3 checks = %q, want one 1 day reminder", messages)
+	}
+
+	// Moving the deadline resets the reminders for the task
+	if _, err := db.Exec(`UPDATE tasks SET deadline = ? WHERE id = ?`, time.Now().Add(3*time.Hour), taskID); err != nil {
+		t.Fatalf("update deadline: %v", err)
+	}
+	svc.CheckDeadlines()
+	svc.CheckDeadlines()
+	if messages := deadlineMessages(t, db, "member-1", models.NotificationDeadlineNear); len(messages) != 2 {
+		t.Errorf("reminders after moving the deadline = %d, want 2", len(messages))
+	}
+
+	var stale int
+	db.QueryRow(`SELECT COUNT(*) FROM deadline_reminders WHERE task_id = ?`, taskID).Scan(&stale)
+	if stale != 1 {
+		t.Errorf("deadline_reminders rows = %d, want 1 for the current deadline", stale)
+	}
+}
+
+func TestDeadlineReminders_ProjectAndUserOffsets(t *testing.T) {
+	db := newDeadlineReminderTestDB(t)
+	svc := services.NewNotificationService(db, nil)
+
+	settings, err := svc.SetProjectReminderOffsets(1, "owner-1", []int{60, 10080, 1440, 60})
+	if err != nil {
+		t.Fatalf("SetProjectReminderOffsets() error = %v", err)
+	}
+	if got := settings.OffsetsMinutes; len(got) != 3 || got[0] != 10080 || got[1] != 1440 || got[2] != 60 {
+		t.Fatalf("offsets = %v, want [10080 1440 60]", got)
+	}
+
+	// Three days out only the one-week reminder is due
+	taskID := seedDeadlineTask(t, db, 1, "member-1", time.Now().Add(72*time.Hour))
+	svc.CheckDeadlines()
+	// Within the hour the day reminder is skipped and only the hour one is sent
+	db.Exec(`UPDATE tasks SET deadline = ? WHERE id = ?`, time.Now().Add(30*time.Minute), taskID)
+	svc.CheckDeadlines()
+	svc.CheckDeadlines()
+
+	messages := deadlineMessages(t, db, "member-1", models.NotificationDeadlineNear)
+	want := []string{"Task 'Ship it' is due within 1 week", "Task 'Ship it' is due within 1 hour"}
+	if len(messages) != len(want) || messages[0] != want[0] || messages[1] != want[1] {
+		t.Fatalf("reminders = %q, want %q", messages, want)
+	}
+
+	// A user's own empty list turns their reminders off, overriding the project
+	if _, err := svc.SetUserReminderOffsets("owner-1", []int{}); err != nil {
+		t.Fatalf("SetUserReminderOffsets() error = %v", err)
+	}
+	seedDeadlineTask(t, db, 1, "owner-1", time.Now().Add(30*time.Minute))
+	svc.CheckDeadlines()
+	if messages := deadlineMessages(t, db, "owner-1", models.NotificationDeadlineNear); len(messages) != 0 {
+		t.Errorf("owner got %q with reminders off", messages)
+	}
+}
+
+func TestDeadlineReminders_OverdueTasks(t *testing.T) {
+	db := newDeadlineReminderTestDB(t)
+	svc := services.NewNotificationService(db, nil)
+
+	overdueID := seedDeadlineTask(t, db, 1, "member-1", time.Now().Add(-time.Hour))
+	seedDeadlineTask(t, db, 2, "member-1", time.Now().Add(-time.Hour))       // already done
+	seedDeadlineTask(t, db, 1, "member-1", time.Now().Add(-30*24*time.Hour)) // overdue for too long
+
+	svc.CheckDeadlines()
+	svc.CheckDeadlines()
+
+	var count int
+	var entityID int64
+	db.QueryRow(`SELECT COUNT(*), MAX(related_entity_id) FROM notifications WHERE user_id = 'member-1' AND type = ?`,
+		models.NotificationOverdue).Scan(&count, &entityID)
+	if count != 1 || entityID != overdueID {
+		t.Errorf("overdue notifications = %d (task %d), want 1 for task %d", count, entityID, overdueID)
+	}
+}
+
+func TestReminderSettings_Validation(t *testing.T) {
+	db := newDeadlineReminderTestDB(t)
+	svc := services.NewNotificationService(db, nil)
+
+	if _, err := svc.SetProjectReminderOffsets(1, "member-1", []int{60}); err == nil {
+		t.Error("SetProjectReminderOffsets() by a member should fail")
+	} else if se, ok := services.IsServiceError(err); !ok || se.Code != "ACCESS_DENIED" {
+		t.Errorf("SetProjectReminderOffsets() by a member error = %v, want ACCESS_DENIED", err)
+	}
+	if _, err := svc.GetProjectReminderSettings(1, "stranger"); err == nil {
+		t.Error("GetProjectReminderSettings() by a non-member should fail")
+	}
+
+	for _, offsets := range [][]int{{0}, {-5}, {services.MaxReminderOffsetMinutes + 1}, {1, 2, 3, 4, 5, 6}} {
+		if _, err := svc.SetUserReminderOffsets("member-1", offsets); err == nil {
+			t.Errorf("SetUserReminderOffsets(%v) should fail", offsets)
+		} else if se, ok := services.IsServiceError(err); !ok || se.Code != "INVALID_REQUEST" {
+			t.Errorf("SetUserReminderOffsets(%v) error = %v, want INVALID_REQUEST", offsets, err)
+		}
+	}
+
+	settings, err := svc.GetProjectReminderSettings(1, "member-1")
+	if err != nil || settings.Source != models.ReminderSourceDefault || len(settings.OffsetsMinutes) != 1 || settings.OffsetsMinutes[0] != 1440 {
+		t.Errorf("GetProjectReminderSettings() = %+v, %v, want the 1 day default", settings, err)
+	}
+}
+
+func TestReminderController_UserAndProjectSettings(t *testing.T) {
+	db := newDeadlineReminderTestDB(t)
+	controller := controllers.NewReminderController(services.NewNotificationService(db, nil))
+
+	w := httptest.NewRecorder()
+	controller.UpdateMySettings(w, createRequestWithUser(http.MethodPut, "/api/me/reminder-settings", map[string]interface{}{"offsets_minutes": []int{60, 1440}}, "member-1"))
+	if w.Code != http.StatusOK {
+		t.Fatalf("UpdateMySettings() status = %d, body=%s", w.Code, w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	controller.GetMySettings(w, createRequestWithUser(http.MethodGet, "/api/me/reminder-settings", nil, "member-1"))
+	var response struct {
+		Data models.ReminderSettings `json:"data"`
+	}
+	json.NewDecoder(w.Body).Decode(&response)
+	if response.Data.Source != models.ReminderSourceUser || len(response.Data.OffsetsMinutes) != 2 || response.Data.OffsetsMinutes[0] != 1440 {
+		t.Errorf("GetMySettings() = %+v, want user offsets [1440 60]", response.Data)
+	}
+
+	w = httptest.NewRecorder()
+	controller.UpdateMySettings(w, createRequestWithUser(http.MethodPut, "/api/me/reminder-settings", map[string]interface{}{}, "member-1"))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("UpdateMySettings() without offsets status = %d, want 400", w.Code)
+	}
+
+	req := createRequestWithUser(http.MethodPut, "/api/projects/1/reminder-settings", map[string]interface{}{"offsets_minutes": []int{60}}, "member-1")
+	req = mux.SetURLVars(req, map[string]string{"id": "1"})
+	w = httptest.NewRecorder()
+	controller.UpdateProjectSettings(w, req)
+	if w.Code != http.StatusForbidden {
+		t.Errorf("UpdateProjectSettings() by a member status = %d, want 403", w.Code)
+	}
+
+	req = createRequestWithUser(http.MethodDelete, "/api/projects/1/reminder-settings", nil, "owner-1")
+	req = mux.SetURLVars(req, map[string]string{"id": "1"})
+	w = httptest.NewRecorder()
+	controller.ResetProjectSettings(w, req)
+	if w.Code != http.StatusNoContent {
+		t.Errorf("ResetProjectSettings() by the owner status = %d, want 204", w.Code)
+	}
+}
@@ -0,0 +1,146 @@
+package testcases
+
+import (
+	"database/sql"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"backend/internal/controllers"
+	"backend/internal/models"
+	"backend/internal/services"
+)
+
+// newDigestTestDB seeds the task notification project. Returns (db,
+// projectID, taskID).
+func newDigestTestDB(t *testing.T) (*sql.DB, int64, int64) {
+	t.Helper()
+
+	db, projectID, _, _, taskID := newTaskNotificationTestDB(t)
+	return db, projectID, taskID
+}
+
+func TestDigest_SettingsDefaultAndUpdate(t *testing.T) {
+	db, _, _ := newDigestTestDB(t)
+	svc := services.NewNotificationService(db, nil)
+
+	settings, err := svc.GetDigestSettings("member-1")
+	if err != nil {
+		t.Fatalf("GetDigestSettings() error = %v", err)
+	}
+	if settings.Frequency != models.DigestImmediate || settings.LastSentAt != nil || settings.NextDigestAt != nil {
+		t.Errorf("default settings = %+v, want immediate", settings)
+	}
+
+	before := time.Now().Add(-time.Second)
+	settings, err = svc.UpdateDigestSettings("member-1", models.DigestWeekly)
+	if err != nil {
+		t.Fatalf("UpdateDigestSettings() error = %v", err)
+	}
+	if settings.Frequency != models.DigestWeekly || settings.LastSentAt == nil || settings.LastSentAt.Before(before) {
+		t.Fatalf("settings = %+v, want weekly starting now", settings)
+	}
+	if settings.NextDigestAt == nil || !settings.NextDigestAt.Equal(settings.LastSentAt.Add(7*24*time.Hour)) {
+		t.Errorf("NextDigestAt = %v, want a week after %v", settings.NextDigestAt, settings.LastSentAt)
+	}
+
+	controller := controllers.NewDigestController(svc)
+	req := createRequestWithUser("PUT", "/api/me/digest-settings", map[string]string{"frequency": "hourly"}, "member-1")
+	rr := httptest.NewRecorder()
+	controller.UpdateSettings(rr, req)
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("invalid frequency status = %d, want 400", rr.Code)
+	}
+}
+
+func TestDigest_BuildGroupsByProjectAndRespectsPreferences(t *testing.T) {
+	db, projectID, taskID := newDigestTestDB(t)
+	svc := services.NewNotificationService(db, nil)
+
+	db.Exec("INSERT INTO projects (owner_id, name) VALUES ('owner-1', 'Elsewhere')")
+	otherProjectID := projectID + 1
+
+	insertNotification := func(notificationType models.NotificationType, message string, isRead bool) {
+		db.Exec("INSERT INTO notifications (user_id, type, message, is_read, related_entity_type, related_entity_id) VALUES ('member-1', ?, ?, ?, 'task', ?)",
+			notificationType, message, isRead, taskID)
+	}
+	insertNotification(models.NotificationTaskAssigned, "Owner assigned you to 'Write docs'", false)
+	insertNotification(models.NotificationCommentAdded, "Owner commented on task 'Write docs'", true)
+	insertNotification(models.NotificationTaskUpdated, "Owner updated task 'Write docs'", false)
+	svc.UpdateNotificationPreferences("member-1", 0, []models.NotificationPreferenceUpdate{
+		{Type: models.NotificationTaskUpdated, Email: boolPtr(false)},
+	})
+
+	insertActivity := func(projectID int64, userID, description string) {
+		db.Exec("INSERT INTO activity_logs (project_id, user_id, user_name, action, entity_type, entity_id, description, created_at) VALUES (?, ?, 'Owner', 'task_created', 'task', ?, ?, ?)",
+			projectID, userID, taskID, description, time.Now().UTC())
+	}
+	insertActivity(projectID, "owner-1", "Owner created task 'Write docs'")
+	insertActivity(projectID, "member-1", "Member One's own change")
+	insertActivity(otherProjectID, "owner-1", "Not member-1's project")
+
+	digest, err := svc.BuildDigest("member-1", time.Now().Add(-time.Hour), time.Now().Add(time.Minute))
+	if err != nil {
+		t.Fatalf("BuildDigest() error = %v", err)
+	}
+	if len(digest.Projects) != 1 {
+		t.Fatalf("digest projects = %+v, want only the shared project", digest.Projects)
+	}
+	project := digest.Projects[0]
+	if project.ProjectID != projectID || project.ProjectName != "Board" {
+		t.Errorf("project = %d %q, want %d Board", project.ProjectID, project.ProjectName, projectID)
+	}
+	if len(project.Notifications) != 1 || project.Notifications[0].Type != models.NotificationTaskAssigned {
+		t.Errorf("notifications = %+v, want only the unread, emailed task_assigned", project.Notifications)
+	}
+	if len(project.Activity) != 1 || project.Activity[0].Description != "Owner created task 'Write docs'" {
+		t.Errorf("activity = %+v, want only the owner's entry", project.Activity)
+	}
+
+	// Nothing falls outside the period
+	digest, err = svc.BuildDigest("member-1", time.Now().Add(time.Minute), time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatalf("BuildDigest() error = %v", err)
+	}
+	if len(digest.Projects) != 0 {
+		t.Errorf("digest for a later period = %+v, want empty", digest.Projects)
+	}
+}
+
+func TestDigest_SendDigestsClaimsEachPeriodOnce(t *testing.T) {
+	db, _, _ := newDigestTestDB(t)
+	svc := services.NewNotificationService(db, nil)
+
+	svc.UpdateDigestSettings("member-1", models.DigestDaily)
+	svc.UpdateDigestSettings("member-2", models.DigestDaily)
+	db.Exec("UPDATE digest_settings SET last_sent_at = ? WHERE user_id = 'member-1'", time.Now().UTC().Add(-25*time.Hour))
+
+	lastSent := func(userID string) time.Time {
+		settings, err := svc.GetDigestSettings(userID)
+		if err != nil || settings.LastSentAt == nil {
+			t.Fatalf("GetDigestSettings(%s) = %+v, %v", userID, settings, err)
+		}
+		return *settings.LastSentAt
+	}
+	member2Before := lastSent("member-2")
+
+	if err := svc.SendDigests(); err != nil {
+		t.Fatalf("SendDigests() error = %v", err)
+	}
+	sent := lastSent("member-1")
+	if time.Since(sent) > time.Minute {
+		t.Fatalf("member-1 last_sent_at = %v, want now", sent)
+	}
+	if !lastSent("member-2").Equal(member2Before) {
+		t.Errorf("member-2 digest was sent before it was due")
+	}
+
+	// Running again (e.g. after a restart) does not send the period twice
+	if err := svc.SendDigests(); err != nil {
+		t.Fatalf("SendDigests() error = %v", err)
+	}
+	if again := lastSent("member-1"); !again.Equal(sent) {
+		t.Errorf("last_sent_at moved from %v to %v on a second run", sent, again)
+	}
+}
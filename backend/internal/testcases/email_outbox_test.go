@@ -0,0 +1,251 @@
+package testcases
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	authmiddleware "backend/internal/auth/middleware"
+	authmodels "backend/internal/auth/models"
+	authrepo "backend/internal/auth/repository"
+	authservices "backend/internal/auth/services"
+	"backend/internal/controllers"
+)
+
+// newEmailOutboxTestDB creates the users and email outbox tables with an
+// admin (admin-1) and a regular user (user-1)
+func newEmailOutboxTestDB(t *testing.T) (*sql.DB, *authrepo.EmailOutboxRepository) {
+	t.Helper()
+
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("Failed to open test database: %v", err)
+	}
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { db.Close() })
+
+	if err := authrepo.NewUserRepository(db).InitTable(); err != nil {
+		t.Fatalf("users InitTable() error = %v", err)
+	}
+	repo := authrepo.NewEmailOutboxRepository(db)
+	if err := repo.InitTable(); err != nil {
+		t.Fatalf("email outbox InitTable() error = %v", err)
+	}
+	db.Exec("INSERT INTO users (id, name, email, role) VALUES ('admin-1', 'Admin', 'admin@example.com', 'admin')")
+	db.Exec("INSERT INTO users (id, name, email, role) VALUES ('user-1', 'User', 'user@example.com', 'user')")
+
+	return db, repo
+}
+
+func outboxEmail(t *testing.T, outbox *authservices.EmailOutbox, id int64) *authmodels.OutboxEmail {
+	t.Helper()
+	email, err := outbox.GetEmail(id)
+	if err != nil {
+		t.Fatalf("GetEmail(%d) error = %v", id, err)
+	}
+	return email
+}
+
+func TestEmailOutbox_QueuesAndDelivers(t *testing.T) {
+	_, repo := newEmailOutboxTestDB(t)
+	mailer := authservices.NewMemoryMailer()
+	outbox := authservices.NewEmailOutbox(repo, mailer)
+	emailService := authservices.NewEmailServiceWithMailer(mailer)
+	emailService.SetOutbox(outbox)
+
+	if err := emailService.SendNotification("jane@example.com", "", "Task Assigned", "You were assigned"); err != nil {
+		t.Fatalf("SendNotification() error = %v", err)
+	}
+	if n := len(mailer.Messages()); n != 0 {
+		t.Fatalf("mailer got %d messages before the worker ran, want 0", n)
+	}
+
+	sent, err := outbox.ProcessDue()
+	if err != nil || sent != 1 {
+		t.Fatalf("ProcessDue() = %d, %v; want 1", sent, err)
+	}
+	messages := mailer.Messages()
+	if len(messages) != 1 || messages[0].To != "jane@example.com" || messages[0].Subject != "Task Assigned" ||
+		!strings.Contains(messages[0].Body, "You were assigned") {
+		t.Fatalf("messages = %+v", messages)
+	}
+
+	email := outboxEmail(t, outbox, 1)
+	if email.Status != authmodels.EmailSent || email.Attempts != 1 || email.SentAt == nil {
+		t.Errorf("email = %+v, want sent after one attempt", email)
+	}
+	if email.Body != "" || email.HTMLBody != "" {
+		t.Errorf("sent email kept its body: %q, %q", email.Body, email.HTMLBody)
+	}
+	if sent, _ := outbox.ProcessDue(); sent != 0 {
+		t.Errorf("second ProcessDue() sent %d, want 0", sent)
+	}
+}
+
+func TestEmailOutbox_BacksOffThenDeadLetters(t *testing.T) {
+	_, repo := newEmailOutboxTestDB(t)
+	mailer := authservices.NewMemoryMailer()
+	mailer.SetError(errors.New("connection refused"))
+	outbox := authservices.NewEmailOutbox(repo, mailer)
+
+	id, _ := outbox.Enqueue(authservices.EmailMessage{To: "jane@example.com", Subject: "Hi", Body: "Body"})
+
+	// With the default policy the first failure waits a minute
+	before := time.Now()
+	outbox.ProcessDue()
+	email := outboxEmail(t, outbox, id)
+	if email.Status != authmodels.EmailPending || email.Attempts != 1 || email.LastError != "connection refused" {
+		t.Fatalf("email after one failure = %+v", email)
+	}
+	if wait := email.NextAttemptAt.Sub(before); wait < 55*time.Second || wait > 65*time.Second {
+		t.Errorf("next attempt in %v, want about 1m", wait)
+	}
+	outbox.ProcessDue()
+	if email := outboxEmail(t, outbox, id); email.Attempts != 1 {
+		t.Errorf("attempts = %d before the backoff elapsed, want 1", email.Attempts)
+	}
+
+	// Each failure doubles the wait until it hits the cap
+	outbox.SetRetryPolicy(3, 10*time.Millisecond, 15*time.Millisecond)
+	repo.MarkFailed(id, "connection refused", time.Now(), false)
+	outbox.ProcessDue()
+	email = outboxEmail(t, outbox, id)
+	if email.Status != authmodels.EmailPending || email.Attempts != 2 {
+		t.Fatalf("email after two failures = %+v", email)
+	}
+	if wait := time.Until(email.NextAttemptAt); wait < 5*time.Millisecond || wait > 15*time.Millisecond {
+		t.Errorf("next attempt in %v, want the 15ms cap", wait)
+	}
+
+	time.Sleep(20 * time.Millisecond)
+	outbox.ProcessDue()
+	email = outboxEmail(t, outbox, id)
+	if email.Status != authmodels.EmailDead || email.Attempts != 3 {
+		t.Fatalf("email after max attempts = %+v, want dead", email)
+	}
+	time.Sleep(20 * time.Millisecond)
+	outbox.ProcessDue()
+	if email := outboxEmail(t, outbox, id); email.Attempts != 3 {
+		t.Errorf("dead email was retried by the worker (%d attempts)", email.Attempts)
+	}
+}
+
+func TestEmailOutbox_AdminInspectsAndRetries(t *testing.T) {
+	db, repo := newEmailOutboxTestDB(t)
+	mailer := authservices.NewMemoryMailer()
+	outbox := authservices.NewEmailOutbox(repo, mailer)
+	id, _ := outbox.Enqueue(authservices.EmailMessage{To: "jane@example.com", Subject: "Hi", Body: "Body"})
+	repo.MarkFailed(id, "mailbox unavailable", time.Now(), true)
+
+	controller := controllers.NewEmailOutboxController(outbox)
+	router := mux.NewRouter()
+	admin := router.PathPrefix("/api/admin").Subrouter()
+	admin.Use(authmiddleware.RequireAdmin(authrepo.NewUserRepository(db)))
+	admin.HandleFunc("/emails", controller.ListEmails).Methods("GET")
+	admin.HandleFunc("/emails/{id}/retry", controller.RetryEmail).Methods("POST")
+
+	serve := func(method, url, userID string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, createRequestWithUser(method, url, nil, userID))
+		return rr
+	}
+
+	if rr := serve("GET", "/api/admin/emails", "user-1"); rr.Code != http.StatusForbidden {
+		t.Errorf("non-admin status = %d, want 403", rr.Code)
+	}
+
+	rr := serve("GET", "/api/admin/emails?status=dead", "admin-1")
+	var resp struct {
+		Data  []authmodels.OutboxEmail `json:"data"`
+		Total int64                    `json:"total"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode response: %v (%s)", err, rr.Body.String())
+	}
+	if resp.Total != 1 || len(resp.Data) != 1 || resp.Data[0].LastError != "mailbox unavailable" {
+		t.Fatalf("dead emails = %+v", resp)
+	}
+	if strings.Contains(rr.Body.String(), `"body"`) {
+		t.Errorf("email bodies were listed: %s", rr.Body.String())
+	}
+	if rr := serve("GET", "/api/admin/emails?status=sent", "admin-1"); rr.Code != http.StatusBadRequest {
+		t.Errorf("listing sent emails status = %d, want 400", rr.Code)
+	}
+
+	if rr := serve("POST", "/api/admin/emails/"+toString(id)+"/retry", "admin-1"); rr.Code != http.StatusOK {
+		t.Fatalf("retry status = %d, body = %s", rr.Code, rr.Body.String())
+	}
+	if rr := serve("POST", "/api/admin/emails/"+toString(id)+"/retry", "admin-1"); rr.Code != http.StatusConflict {
+		t.Errorf("retrying a pending email status = %d, want 409", rr.Code)
+	}
+	if rr := serve("POST", "/api/admin/emails/999/retry", "admin-1"); rr.Code != http.StatusNotFound {
+		t.Errorf("retrying an unknown email status = %d, want 404", rr.Code)
+	}
+
+	if sent, _ := outbox.ProcessDue(); sent != 1 || len(mailer.Messages()) != 1 {
+		t.Errorf("retried email was not delivered (sent %d)", sent)
+	}
+}
+
+func TestEmailOutbox_PurgesSentEmails(t *testing.T) {
+	db, repo := newEmailOutboxTestDB(t)
+	outbox := authservices.NewEmailOutbox(repo, authservices.NewMemoryMailer())
+
+	oldID, _ := outbox.Enqueue(authservices.EmailMessage{To: "a@example.com", Subject: "Old", Body: "Code 123456"})
+	newID, _ := outbox.Enqueue(authservices.EmailMessage{To: "b@example.com", Subject: "New", Body: "Code 654321"})
+	deadID, _ := outbox.Enqueue(authservices.EmailMessage{To: "c@example.com", Subject: "Dead", Body: "Body"})
+	repo.MarkSent(oldID, time.Now().Add(-8*24*time.Hour))
+	repo.MarkSent(newID, time.Now())
+	repo.MarkFailed(deadID, "mailbox unavailable", time.Now(), true)
+
+	var bodies int
+	db.QueryRow("SELECT COUNT(*) FROM email_outbox WHERE status = 'sent' AND (body <> '' OR html_body IS NOT NULL)").Scan(&bodies)
+	if bodies != 0 {
+		t.Errorf("%d sent emails kept their bodies", bodies)
+	}
+
+	purged, err := outbox.PurgeSent(7 * 24 * time.Hour)
+	if err != nil || purged != 1 {
+		t.Fatalf("PurgeSent() = %d, %v; want 1", purged, err)
+	}
+	if _, err := outbox.GetEmail(oldID); !errors.Is(err, authservices.ErrOutboxEmailNotFound) {
+		t.Errorf("old sent email still exists (err = %v)", err)
+	}
+	for _, id := range []int64{newID, deadID} {
+		if _, err := outbox.GetEmail(id); err != nil {
+			t.Errorf("GetEmail(%d) error = %v, want it kept", id, err)
+		}
+	}
+}
+
+func TestFileMailer_WritesMaildir(t *testing.T) {
+	dir := t.TempDir()
+	mailer := authservices.NewFileMailer(dir, "noreply@example.com")
+
+	if err := mailer.Send(authservices.EmailMessage{To: "jane@example.com", Subject: "Welcome", Body: "Hello Jane"}); err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+
+	files, err := os.ReadDir(filepath.Join(dir, "new"))
+	if err != nil || len(files) != 1 {
+		t.Fatalf("maildir new/ has %d files (%v), want 1", len(files), err)
+	}
+	content, _ := os.ReadFile(filepath.Join(dir, "new", files[0].Name()))
+	for _, want := range []string{"To: jane@example.com", "Subject: Welcome", "Hello Jane"} {
+		if !strings.Contains(string(content), want) {
+			t.Errorf("message is missing %q:\n%s", want, content)
+		}
+	}
+	if tmp, _ := os.ReadDir(filepath.Join(dir, "tmp")); len(tmp) != 0 {
+		t.Errorf("maildir tmp/ has %d leftover files", len(tmp))
+	}
+}
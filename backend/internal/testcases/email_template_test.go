@@ -0,0 +1,212 @@
+package testcases
+
+import (
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	authservices "backend/internal/auth/services"
+)
+
+// writeEmailTemplates writes template overrides into a temporary directory
+func writeEmailTemplates(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatalf("Failed to write template %s: %v", name, err)
+		}
+	}
+	return dir
+}
+
+func TestEmailTemplates_RendersEachKind(t *testing.T) {
+	templates := authservices.NewEmailTemplates("")
+
+	cases := []struct {
+		kind    authservices.EmailKind
+		data    interface{}
+		subject string
+		text    string
+	}{
+		{authservices.EmailOTP, authservices.OTPEmail{Code: "123456", ExpiresInMinutes: 10},
+			"Taskify - Password Reset Code", "Your password reset verification code is: 123456"},
+		{authservices.EmailInvite, authservices.InviteEmail{InviterName: "Ann", ProjectName: "Apollo", AcceptURL: "http://localhost:4200/invites/abc", ExpiresAt: time.Now().Add(time.Hour)},
+			"Ann invited you to Apollo on Taskify", "http://localhost:4200/invites/abc"},
+		{authservices.EmailTaskAssigned, authservices.TaskAssignedEmail{ActorName: "Ann", TaskTitle: "<b>Ship</b>", ProjectName: "Apollo"},
+			"Task Assigned", "Ann assigned you the task '<b>Ship</b>' in Apollo"},
+		{authservices.EmailDeadline, authservices.DeadlineEmail{TaskTitle: "Ship", DueIn: "1 day"},
+			"Deadline Reminder", "is due within 1 day"},
+		{authservices.EmailDeadline, authservices.DeadlineEmail{TaskTitle: "Ship", Overdue: true},
+			"Task Overdue", "The task 'Ship' is overdue."},
+		{authservices.EmailDigest, authservices.DigestEmail{Frequency: "daily", Projects: []authservices.DigestEmailProject{
+			{Name: "Apollo", Notifications: []string{"Task 'Ship' is overdue"}, Activity: []string{"Ann: created task 'Ship'"}, MoreActivity: 2},
+		}}, "Your daily Taskify digest", "- Ann: created task 'Ship'\n- ...and 2 more"},
+		{authservices.EmailNotification, authservices.NotificationEmail{Subject: "Project Invitation", Message: "You were added"},
+			"Project Invitation", "Hello,\n\nYou were added\n\nThis is an automated notification from Taskify."},
+		{authservices.EmailVerifyEmail, authservices.VerifyEmail{Name: "Ann", VerifyURL: "http://localhost:4200/verify-email?token=abc", ExpiresInHours: 24},
+			"Taskify - Confirm your email address", "http://localhost:4200/verify-email?token=abc"},
+	}
+
+	for _, tc := range cases {
+		msg, err := templates.Render(tc.kind, "", tc.data)
+		if err != nil {
+			t.Fatalf("Render(%s) error = %v", tc.kind, err)
+		}
+		if msg.Subject != tc.subject {
+			t.Errorf("Render(%s) subject = %q, want %q", tc.kind, msg.Subject, tc.subject)
+		}
+		if !strings.Contains(msg.Body, tc.text) {
+			t.Errorf("Render(%s) text body is missing %q:\n%s", tc.kind, tc.text, msg.Body)
+		}
+		if !strings.Contains(msg.HTMLBody, "<html>") {
+			t.Errorf("Render(%s) has no HTML body", tc.kind)
+		}
+	}
+
+	msg, _ := templates.Render(authservices.EmailTaskAssigned, "", authservices.TaskAssignedEmail{ActorName: "Ann", TaskTitle: "<b>Ship</b>"})
+	if strings.Contains(msg.HTMLBody, "<b>Ship</b>") || !strings.Contains(msg.HTMLBody, "&lt;b&gt;Ship&lt;/b&gt;") {
+		t.Errorf("HTML body does not escape the task title:\n%s", msg.HTMLBody)
+	}
+}
+
+func TestEmailTemplates_LocaleFallback(t *testing.T) {
+	dir := writeEmailTemplates(t, map[string]string{
+		"otp.pt.txt":    `{{define "subject"}}Código de redefinição{{end}}Seu código é {{.Code}}`,
+		"otp.pt-br.txt": `{{define "subject"}}Código de redefinição de senha{{end}}Seu código de verificação é {{.Code}}`,
+	})
+	templates := authservices.NewEmailTemplates(dir)
+	data := authservices.OTPEmail{Code: "424242", ExpiresInMinutes: 10}
+
+	cases := []struct {
+		locale  string
+		subject string
+	}{
+		{"pt-BR", "Código de redefinição de senha"},
+		{"pt_br", "Código de redefinição de senha"},
+		{"pt-PT", "Código de redefinição"},
+		{"fr", "Taskify - Password Reset Code"},
+		{"", "Taskify - Password Reset Code"},
+		{"../otp", "Taskify - Password Reset Code"},
+	}
+	for _, tc := range cases {
+		msg, err := templates.Render(authservices.EmailOTP, tc.locale, data)
+		if err != nil {
+			t.Fatalf("Render(%q) error = %v", tc.locale, err)
+		}
+		if msg.Subject != tc.subject {
+			t.Errorf("Render(%q) subject = %q, want %q", tc.locale, msg.Subject, tc.subject)
+		}
+		if !strings.Contains(msg.Body, "424242") {
+			t.Errorf("Render(%q) body is missing the code:\n%s", tc.locale, msg.Body)
+		}
+	}
+
+	// Without a translated HTML template the default one is used
+	msg, _ := templates.Render(authservices.EmailOTP, "pt-BR", data)
+	if !strings.Contains(msg.HTMLBody, "424242") {
+		t.Errorf("pt-BR email has no default HTML body:\n%s", msg.HTMLBody)
+	}
+}
+
+func TestEmailTemplates_DirectoryOverride(t *testing.T) {
+	dir := writeEmailTemplates(t, map[string]string{
+		"notification.txt":  `{{define "subject"}}[Acme] {{.Subject}}{{end}}{{.Message}}`,
+		"notification.html": `{{define "content"}}<p class="acme">{{.Message}}</p>{{end}}`,
+		"layout.html":       `{{define "layout"}}<html><body>{{template "content" .}}</body></html>{{end}}`,
+	})
+	mailer := authservices.NewMemoryMailer()
+	emailService := authservices.NewEmailServiceWithMailer(mailer)
+	emailService.SetTemplates(authservices.NewEmailTemplates(dir))
+
+	if err := emailService.SendNotification("jane@example.com", "", "Task Overdue", "Task 'Ship' is overdue"); err != nil {
+		t.Fatalf("SendNotification() error = %v", err)
+	}
+	if err := emailService.SendOTP("jane@example.com", "", "123456"); err != nil {
+		t.Fatalf("SendOTP() error = %v", err)
+	}
+
+	messages := mailer.Messages()
+	if len(messages) != 2 {
+		t.Fatalf("mailer got %d messages, want 2", len(messages))
+	}
+	if messages[0].Subject != "[Acme] Task Overdue" || messages[0].Body != "Task 'Ship' is overdue" ||
+		messages[0].HTMLBody != `<html><body><p class="acme">Task &#39;Ship&#39; is overdue</p></body></html>` {
+		t.Errorf("overridden notification = %+v", messages[0])
+	}
+	// Kinds that are not overridden keep the built-in templates, inside the
+	// overridden layout
+	if messages[1].Subject != "Taskify - Password Reset Code" || !strings.HasPrefix(messages[1].HTMLBody, "<html><body>") {
+		t.Errorf("built-in OTP email = %+v", messages[1])
+	}
+
+	broken := writeEmailTemplates(t, map[string]string{"otp.txt": `{{.Code`})
+	emailService.SetTemplates(authservices.NewEmailTemplates(broken))
+	if err := emailService.SendOTP("jane@example.com", "", "123456"); err == nil {
+		t.Error("SendOTP() with a broken template should fail")
+	}
+}
+
+func TestFileMailer_WritesMultipartAlternative(t *testing.T) {
+	dir := t.TempDir()
+	mailer := authservices.NewFileMailer(dir, "noreply@example.com")
+
+	err := mailer.Send(authservices.EmailMessage{
+		To:       "jane@example.com",
+		Subject:  "Código de verificação",
+		Body:     "Seu código é 123456",
+		HTMLBody: "<p>Seu código é <strong>123456</strong></p>",
+	})
+	if err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+
+	files, _ := os.ReadDir(filepath.Join(dir, "new"))
+	if len(files) != 1 {
+		t.Fatalf("maildir new/ has %d files, want 1", len(files))
+	}
+	file, err := os.Open(filepath.Join(dir, "new", files[0].Name()))
+	if err != nil {
+		t.Fatalf("Failed to open message: %v", err)
+	}
+	defer file.Close()
+
+	msg, err := mail.ReadMessage(file)
+	if err != nil {
+		t.Fatalf("ReadMessage() error = %v", err)
+	}
+	subject, err := new(mime.WordDecoder).DecodeHeader(msg.Header.Get("Subject"))
+	if err != nil || subject != "Código de verificação" {
+		t.Errorf("Subject = %q (%v)", subject, err)
+	}
+
+	mediaType, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/alternative" {
+		t.Fatalf("Content-Type = %q (%v), want multipart/alternative", msg.Header.Get("Content-Type"), err)
+	}
+
+	reader := multipart.NewReader(msg.Body, params["boundary"])
+	want := []struct{ contentType, body string }{
+		{"text/plain", "Seu código é 123456"},
+		{"text/html", "<p>Seu código é <strong>123456</strong></p>"},
+	}
+	for _, w := range want {
+		part, err := reader.NextPart()
+		if err != nil {
+			t.Fatalf("NextPart() error = %v", err)
+		}
+		body, _ := io.ReadAll(part)
+		if !strings.HasPrefix(part.Header.Get("Content-Type"), w.contentType) || string(body) != w.body {
+			t.Errorf("part %s = %q, want %q", part.Header.Get("Content-Type"), body, w.body)
+		}
+	}
+	if _, err := reader.NextPart(); err != io.EOF {
+		t.Errorf("message has more than two parts (%v)", err)
+	}
+}
@@ -0,0 +1,285 @@
+package testcases
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+	"time"
+
+	"backend/internal/auth/controller"
+	"backend/internal/auth/middleware"
+	"backend/internal/auth/repository"
+	authservices "backend/internal/auth/services"
+	"backend/internal/controllers"
+	"backend/internal/services"
+
+	"github.com/gorilla/mux"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+var verificationTokenPattern = regexp.MustCompile(`verify-email\?token=([A-Za-z0-9_-]+)`)
+
+type emailVerificationTestEnv struct {
+	db      *sql.DB
+	auth    *authservices.AuthService
+	jwt     *authservices.JWTService
+	mailer  *authservices.MemoryMailer
+	members *services.ProjectMemberService
+	router  *mux.Router
+}
+
+func newEmailVerificationTestEnv(t *testing.T) *emailVerificationTestEnv {
+	t.Helper()
+
+	db := newSchemaTestDB(t)
+	userRepo := repository.NewUserRepository(db)
+	identityRepo := repository.NewAuthIdentityRepository(db)
+	if err := identityRepo.InitTable(); err != nil {
+		t.Fatalf("Failed to create auth identities table: %v", err)
+	}
+	verificationRepo := repository.NewEmailVerificationRepository(db)
+	if err := verificationRepo.InitTable(); err != nil {
+		t.Fatalf("Failed to create email verifications table: %v", err)
+	}
+
+	mailer := authservices.NewMemoryMailer()
+	emailService := authservices.NewEmailServiceWithMailer(mailer)
+	jwtService := authservices.NewJWTService("test-secret", 24)
+	verificationService := authservices.NewEmailVerificationService(verificationRepo, userRepo, emailService, 24*time.Hour)
+	authService := authservices.NewAuthService(userRepo, identityRepo, jwtService, authservices.NewOTPService(repository.NewOTPRepository(db)), emailService, nil, nil)
+	authService.SetEmailVerificationService(verificationService)
+
+	members := services.NewProjectMemberService(db)
+
+	ctrl := controller.NewAuthController(authService)
+	ctrl.SetEmailVerificationService(verificationService)
+	memberCtrl := controllers.NewProjectMemberController(members)
+
+	router := mux.NewRouter()
+	router.HandleFunc("/api/auth/verify-email", ctrl.VerifyEmail).Methods("POST")
+	protected := router.PathPrefix("/api").Subrouter()
+	protected.Use(middleware.JWTAuthMiddleware(jwtService))
+	protected.HandleFunc("/auth/me", ctrl.GetMe).Methods("GET")
+	protected.HandleFunc("/auth/verify-email/resend", ctrl.ResendVerification).Methods("POST")
+	protected.HandleFunc("/invites/{id}/accept", memberCtrl.AcceptInvite).Methods("POST")
+
+	return &emailVerificationTestEnv{
+		db:      db,
+		auth:    authService,
+		jwt:     jwtService,
+		mailer:  mailer,
+		members: members,
+		router:  router,
+	}
+}
+
+func (env *emailVerificationTestEnv) register(t *testing.T, email string) *authservices.AuthResponse {
+	t.Helper()
+
+	resp, err := env.auth.Register(authservices.RegisterRequest{
+		Name:     "New User",
+		Email:    email,
+		Password: "password123",
+	})
+	if err != nil {
+		t.Fatalf("Register() error = %v", err)
+	}
+	return resp
+}
+
+// lastToken returns the verification token from the newest email
+func (env *emailVerificationTestEnv) lastToken(t *testing.T) string {
+	t.Helper()
+
+	messages := env.mailer.Messages()
+	if len(messages) == 0 {
+		t.Fatal("no email was sent")
+	}
+	match := verificationTokenPattern.FindStringSubmatch(messages[len(messages)-1].Body)
+	if match == nil {
+		t.Fatalf("email has no verification link:\n%s", messages[len(messages)-1].Body)
+	}
+	return match[1]
+}
+
+func (env *emailVerificationTestEnv) do(method, url, token string, body interface{}) *httptest.ResponseRecorder {
+	var bodyBytes []byte
+	if body != nil {
+		bodyBytes, _ = json.Marshal(body)
+	}
+	req := httptest.NewRequest(method, url, bytes.NewBuffer(bodyBytes))
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	w := httptest.NewRecorder()
+	env.router.ServeHTTP(w, req)
+	return w
+}
+
+func TestEmailVerification_RegisterSendsLink(t *testing.T) {
+	env := newEmailVerificationTestEnv(t)
+	resp := env.register(t, "new@example.com")
+
+	if resp.User.EmailVerified {
+		t.Fatal("new account is verified before the link was used")
+	}
+	messages := env.mailer.Messages()
+	if len(messages) != 1 || messages[0].To != "new@example.com" {
+		t.Fatalf("sent emails = %+v, want one to new@example.com", messages)
+	}
+	token := env.lastToken(t)
+
+	var stored int
+	env.db.QueryRow("SELECT COUNT(*) FROM email_verifications WHERE token_hash = ?", token).Scan(&stored)
+	if stored != 0 {
+		t.Error("verification token is stored in plain text")
+	}
+
+	if w := env.do("POST", "/api/auth/verify-email", "", map[string]string{"token": "bogus"}); w.Code != http.StatusBadRequest {
+		t.Errorf("verify with a bogus token status = %d, want 400", w.Code)
+	}
+
+	w := env.do("POST", "/api/auth/verify-email", "", map[string]string{"token": token})
+	if w.Code != http.StatusOK {
+		t.Fatalf("verify status = %d, body %s", w.Code, w.Body.String())
+	}
+	var user struct {
+		EmailVerified bool `json:"email_verified"`
+	}
+	json.NewDecoder(w.Body).Decode(&user)
+	if !user.EmailVerified {
+		t.Error("verify response does not show the address as verified")
+	}
+
+	w = env.do("GET", "/api/auth/me", resp.Token, nil)
+	json.NewDecoder(w.Body).Decode(&user)
+	if !user.EmailVerified {
+		t.Error("GET /me does not show the address as verified")
+	}
+
+	if w := env.do("POST", "/api/auth/verify-email", "", map[string]string{"token": token}); w.Code != http.StatusBadRequest {
+		t.Errorf("reused link status = %d, want 400", w.Code)
+	}
+}
+
+func TestEmailVerification_ResendAndExpiry(t *testing.T) {
+	env := newEmailVerificationTestEnv(t)
+	resp := env.register(t, "new@example.com")
+	first := env.lastToken(t)
+
+	if w := env.do("POST", "/api/auth/verify-email/resend", resp.Token, nil); w.Code != http.StatusTooManyRequests {
+		t.Errorf("immediate resend status = %d, want 429", w.Code)
+	}
+
+	env.db.Exec("UPDATE email_verifications SET sent_at = ?", time.Now().Add(-2*authservices.EmailVerificationResendInterval).UTC())
+	if w := env.do("POST", "/api/auth/verify-email/resend", resp.Token, nil); w.Code != http.StatusOK {
+		t.Fatalf("resend status = %d, body %s", w.Code, w.Body.String())
+	}
+	second := env.lastToken(t)
+	if second == first {
+		t.Fatal("resend mailed the same link again")
+	}
+	if w := env.do("POST", "/api/auth/verify-email", "", map[string]string{"token": first}); w.Code != http.StatusBadRequest {
+		t.Errorf("replaced link status = %d, want 400", w.Code)
+	}
+
+	env.db.Exec("UPDATE email_verifications SET expires_at = ?", time.Now().Add(-time.Minute).UTC())
+	if w := env.do("POST", "/api/auth/verify-email", "", map[string]string{"token": second}); w.Code != http.StatusBadRequest {
+		t.Errorf("expired link status = %d, want 400", w.Code)
+	}
+
+	env.db.Exec("UPDATE users SET email_verified = 1")
+	if w := env.do("POST", "/api/auth/verify-email/resend", resp.Token, nil); w.Code != http.StatusConflict {
+		t.Errorf("resend for a verified address status = %d, want 409", w.Code)
+	}
+}
+
+func TestEmailVerification_InvitePolicy(t *testing.T) {
+	env := newEmailVerificationTestEnv(t)
+	owner := env.register(t, "owner@example.com")
+	joiner := env.register(t, "joiner@example.com")
+	joinerToken := env.lastToken(t)
+
+	result, err := env.db.Exec("INSERT INTO projects (name, owner_id) VALUES ('Apollo', ?)", owner.User.ID)
+	if err != nil {
+		t.Fatalf("Failed to create project: %v", err)
+	}
+	projectID, _ := result.LastInsertId()
+	env.db.Exec("INSERT INTO project_members (project_id, user_id, role) VALUES (?, ?, 'owner')", projectID, owner.User.ID)
+
+	invite, err := env.members.CreateInvite(projectID, owner.User.ID, 0)
+	if err != nil {
+		t.Fatalf("CreateInvite() error = %v", err)
+	}
+
+	env.members.SetRequireVerifiedEmail(true)
+	_, err = env.members.AcceptInviteByID(invite.ID, joiner.User.ID)
+	var se *services.ServiceError
+	if !errors.As(err, &se) || se.Code != "EMAIL_NOT_VERIFIED" {
+		t.Fatalf("AcceptInviteByID() by an unverified user error = %v, want EMAIL_NOT_VERIFIED", err)
+	}
+	if w := env.do("POST", "/api/invites/"+invite.ID+"/accept", joiner.Token, nil); w.Code != http.StatusForbidden {
+		t.Errorf("accept by an unverified user status = %d, want 403", w.Code)
+	}
+	// The owner cannot add the unverified account directly either
+	_, err = env.members.AddMember(projectID, joiner.User.ID, owner.User.ID)
+	if !errors.As(err, &se) || se.Code != "EMAIL_NOT_VERIFIED" {
+		t.Errorf("AddMember() of an unverified user error = %v, want EMAIL_NOT_VERIFIED", err)
+	}
+
+	if w := env.do("POST", "/api/auth/verify-email", "", map[string]string{"token": joinerToken}); w.Code != http.StatusOK {
+		t.Fatalf("verify status = %d, body %s", w.Code, w.Body.String())
+	}
+	if w := env.do("POST", "/api/invites/"+invite.ID+"/accept", joiner.Token, nil); w.Code != http.StatusOK {
+		t.Fatalf("accept after verifying status = %d, body %s", w.Code, w.Body.String())
+	}
+
+	// Without the policy, unverified users accept invites as before
+	env.members.SetRequireVerifiedEmail(false)
+	other := env.register(t, "other@example.com")
+	invite, _ = env.members.CreateInvite(projectID, owner.User.ID, 0)
+	if _, err := env.members.AcceptInviteByID(invite.ID, other.User.ID); err != nil {
+		t.Errorf("AcceptInviteByID() without the policy error = %v", err)
+	}
+}
+
+func TestEmailVerification_ExistingUsersCountAsVerified(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	db.SetMaxOpenConns(1)
+	defer db.Close()
+
+	if _, err := db.Exec(`CREATE TABLE users (
+		id TEXT PRIMARY KEY,
+		name TEXT NOT NULL,
+		email TEXT UNIQUE NOT NULL,
+		password_hash TEXT,
+		role TEXT DEFAULT 'user',
+		is_active INTEGER DEFAULT 1,
+		language TEXT NOT NULL DEFAULT '',
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	)`); err != nil {
+		t.Fatalf("Failed to create legacy users table: %v", err)
+	}
+	db.Exec("INSERT INTO users (id, name, email, password_hash) VALUES ('u1', 'Old User', 'old@example.com', 'hash')")
+
+	userRepo := repository.NewUserRepository(db)
+	if err := userRepo.InitTable(); err != nil {
+		t.Fatalf("InitTable() error = %v", err)
+	}
+	user, err := userRepo.GetUserByID("u1")
+	if err != nil || user == nil {
+		t.Fatalf("GetUserByID() = %v, %v", user, err)
+	}
+	if !user.EmailVerified {
+		t.Error("account created before verification existed is not verified")
+	}
+}
@@ -0,0 +1,178 @@
+package testcases
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"backend/internal/controllers"
+	"backend/internal/models"
+	"backend/internal/services"
+)
+
+type mentionTestEnv struct {
+	db       *sql.DB
+	mentions *services.MentionService
+	messages *services.MessageService
+	comments *services.CommentService
+}
+
+func newMentionTestEnv(t *testing.T) *mentionTestEnv {
+	t.Helper()
+
+	db := newChatTestDB(t)
+	mentions := services.NewMentionService(db, services.NewNotificationService(db, nil))
+	messages := services.NewMessageService(db)
+	messages.SetMentionService(mentions)
+	comments := services.NewCommentService(db)
+	comments.SetMentionService(mentions)
+
+	return &mentionTestEnv{db: db, mentions: mentions, messages: messages, comments: comments}
+}
+
+// seedMentionProject creates a project owned by owner-1 with members Ann and
+// Ann Lee, plus an outsider who shares a name with nobody in the project
+func seedMentionProject(t *testing.T, db *sql.DB) int64 {
+	t.Helper()
+	projectID := seedProjectAndOwnerPM(t, db, "owner-1")
+	seedChatMember(t, db, projectID, "ann", "Ann")
+	seedChatMember(t, db, projectID, "ann-lee", "Ann Lee")
+	if _, err := db.Exec(`INSERT INTO users (id, email, name) VALUES ('outsider', 'outsider@test.com', 'Outsider')`); err != nil {
+		t.Fatalf("insert user: %v", err)
+	}
+	return projectID
+}
+
+func mentionNotifications(t *testing.T, db *sql.DB, userID string) []models.Notification {
+	t.Helper()
+	rows, err := db.Query(`SELECT type, related_entity_type, related_entity_id FROM notifications WHERE user_id = ? ORDER BY id`, userID)
+	if err != nil {
+		t.Fatalf("query notifications: %v", err)
+	}
+	defer rows.Close()
+
+	var notifications []models.Notification
+	for rows.Next() {
+		var n models.Notification
+		if err := rows.Scan(&n.Type, &n.RelatedEntityType, &n.RelatedEntityID); err != nil {
+			t.Fatalf("scan notification: %v", err)
+		}
+		notifications = append(notifications, n)
+	}
+	return notifications
+}
+
+func TestMentions_ChatMessageMatchesProjectMembers(t *testing.T) {
+	env := newMentionTestEnv(t)
+	projectID := seedMentionProject(t, env.db)
+
+	content := "@ann lee and @ANN@TEST.COM please review; cc @outsider, @owner-1@test.com and bob@ann.dev"
+	message, err := env.messages.CreateMessage("owner-1", projectID, content)
+	if err != nil {
+		t.Fatalf("CreateMessage() error = %v", err)
+	}
+
+	var mentioned []string
+	rows, _ := env.db.Query(`SELECT user_id FROM mentions WHERE entity_type = 'message' AND entity_id = ? ORDER BY id`, message.ID)
+	for rows.Next() {
+		var userID string
+		rows.Scan(&userID)
+		mentioned = append(mentioned, userID)
+	}
+	rows.Close()
+
+	// Longest name wins, email works case-insensitively, the author, outsiders
+	// and addresses inside words are skipped
+	if len(mentioned) != 2 || mentioned[0] != "ann-lee" || mentioned[1] != "ann" {
+		t.Fatalf("mentioned users = %v, want [ann-lee ann]", mentioned)
+	}
+
+	notifications := mentionNotifications(t, env.db, "ann")
+	if len(notifications) != 1 || notifications[0].Type != models.NotificationMentioned ||
+		notifications[0].RelatedEntityType != string(models.EntityMessage) || notifications[0].RelatedEntityID != message.ID {
+		t.Errorf("notifications for ann = %+v, want one mentioned notification for the message", notifications)
+	}
+	if n := mentionNotifications(t, env.db, "owner-1"); len(n) != 0 {
+		t.Errorf("author got %d notifications for mentioning themselves, want 0", len(n))
+	}
+}
+
+func TestMentions_CommentEditsOnlyNotifyNewMentions(t *testing.T) {
+	env := newMentionTestEnv(t)
+	projectID := seedMentionProject(t, env.db)
+	env.db.Exec(`INSERT INTO stages (id, project_id, name) VALUES (1, ?, 'To Do')`, projectID)
+	env.db.Exec(`INSERT INTO tasks (id, stage_id, title) VALUES (7, 1, 'Fix login')`)
+
+	comment, err := env.comments.CreateComment("owner-1", 7, "@Ann can you take this?")
+	if err != nil {
+		t.Fatalf("CreateComment() error = %v", err)
+	}
+	if _, err := env.comments.UpdateComment("owner-1", comment.ID, "@Ann can you take this? @Ann Lee to review"); err != nil {
+		t.Fatalf("UpdateComment() error = %v", err)
+	}
+
+	if n := mentionNotifications(t, env.db, "ann"); len(n) != 1 {
+		t.Errorf("ann got %d notifications, want 1 despite the edit", len(n))
+	}
+	if n := mentionNotifications(t, env.db, "ann-lee"); len(n) != 1 || n[0].RelatedEntityType != string(models.EntityComment) {
+		t.Errorf("ann-lee notifications = %+v, want one for the edited comment", n)
+	}
+
+	mentions, total, err := env.mentions.GetUserMentions("ann", 1, 20)
+	if err != nil {
+		t.Fatalf("GetUserMentions() error = %v", err)
+	}
+	if total != 1 || len(mentions) != 1 {
+		t.Fatalf("GetUserMentions() = %d mentions (total %d), want 1", len(mentions), total)
+	}
+	got := mentions[0]
+	if got.EntityType != models.EntityComment || got.EntityID != comment.ID || got.TaskID == nil || *got.TaskID != 7 ||
+		got.ProjectName != "Test Project" || got.AuthorName != "Owner User" || got.Excerpt != "@Ann can you take this?" {
+		t.Errorf("mention = %+v", got)
+	}
+
+	// Deleted comments drop out of the list
+	if err := env.comments.DeleteComment("owner-1", comment.ID); err != nil {
+		t.Fatalf("DeleteComment() error = %v", err)
+	}
+	if _, total, _ := env.mentions.GetUserMentions("ann", 1, 20); total != 0 {
+		t.Errorf("GetUserMentions() total after delete = %d, want 0", total)
+	}
+}
+
+func TestMentions_MeEndpointIsPaginated(t *testing.T) {
+	env := newMentionTestEnv(t)
+	projectID := seedMentionProject(t, env.db)
+	for _, content := range []string{"@ann one", "@ann two", "@ann three"} {
+		if _, err := env.messages.CreateMessage("owner-1", projectID, content); err != nil {
+			t.Fatalf("CreateMessage() error = %v", err)
+		}
+	}
+
+	controller := controllers.NewMentionController(env.mentions)
+	w := httptest.NewRecorder()
+	controller.GetMyMentions(w, createRequestWithUser(http.MethodGet, "/api/me/mentions?page=1&limit=2", nil, "ann"))
+	if w.Code != http.StatusOK {
+		t.Fatalf("GetMyMentions() status = %d, want 200; body=%s", w.Code, w.Body.String())
+	}
+
+	var response struct {
+		Data  []models.Mention `json:"data"`
+		Total int64            `json:"total"`
+		Limit int              `json:"limit"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+		t.Fatalf("decode response error = %v", err)
+	}
+	if response.Total != 3 || len(response.Data) != 2 || response.Data[0].Excerpt != "@ann three" {
+		t.Errorf("response = %+v, want newest 2 of 3 mentions", response)
+	}
+
+	w = httptest.NewRecorder()
+	controller.GetMyMentions(w, createRequestWithUser(http.MethodGet, "/api/me/mentions", nil, ""))
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("GetMyMentions() without user status = %d, want 401", w.Code)
+	}
+}
@@ -5,7 +5,9 @@ import (
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 
 	"backend/internal/auth/middleware"
 	"backend/internal/auth/services"
@@ -125,6 +127,55 @@ func TestJWTAuthMiddleware(t *testing.T) {
 	})
 }
 
+func TestRateLimitMiddleware(t *testing.T) {
+	limit := middleware.RateLimitMiddleware(2, time.Minute)
+	handler := limit(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// The body is still readable after the middleware looked for an email
+		var req struct {
+			Email string `json:"email"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		w.Write([]byte(req.Email))
+	}))
+
+	post := func(path, remoteAddr, email string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", path, strings.NewReader(`{"email":"`+email+`"}`))
+		req.RemoteAddr = remoteAddr
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("limits each IP, whatever the port", func(t *testing.T) {
+		post("/api/auth/login", "10.0.0.1:1111", "a@example.com")
+		w := post("/api/auth/login", "10.0.0.1:2222", "b@example.com")
+		if w.Code != http.StatusOK || w.Body.String() != "b@example.com" {
+			t.Fatalf("second request = %d %q, want 200 with the body intact", w.Code, w.Body.String())
+		}
+		if w := post("/api/auth/login", "10.0.0.1:3333", "c@example.com"); w.Code != http.StatusTooManyRequests {
+			t.Errorf("third request from the IP status = %d, want 429", w.Code)
+		}
+		if w := post("/api/auth/verify-otp", "10.0.0.1:4444", "d@example.com"); w.Code != http.StatusOK {
+			t.Errorf("request to another path status = %d, want 200", w.Code)
+		}
+	})
+
+	t.Run("limits each email, whatever the IP", func(t *testing.T) {
+		post("/api/auth/forgot-password", "10.0.1.1:1", "victim@example.com")
+		post("/api/auth/forgot-password", "10.0.1.2:1", "Victim@Example.com")
+		w := post("/api/auth/forgot-password", "10.0.1.3:1", "victim@example.com")
+		if w.Code != http.StatusTooManyRequests {
+			t.Errorf("third request for the email status = %d, want 429", w.Code)
+		}
+		if w.Header().Get("Retry-After") != "60" {
+			t.Errorf("Retry-After = %q, want 60", w.Header().Get("Retry-After"))
+		}
+		if w := post("/api/auth/forgot-password", "10.0.1.4:1", "other@example.com"); w.Code != http.StatusOK {
+			t.Errorf("request for another email status = %d, want 200", w.Code)
+		}
+	})
+}
+
 func TestWriteError(t *testing.T) {
 	t.Run("writes error response", func(t *testing.T) {
 		w := httptest.NewRecorder()
@@ -0,0 +1,233 @@
+package testcases
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"backend/internal/controllers"
+	"backend/internal/models"
+	notifrepo "backend/internal/repository"
+	"backend/internal/services"
+)
+
+type notificationManagementTestEnv struct {
+	db         *sql.DB
+	service    *services.NotificationService
+	controller *controllers.NotificationController
+	repo       *notifrepo.NotificationRepository
+	projectID  int64
+	taskID     int64
+}
+
+func newNotificationManagementTestEnv(t *testing.T) *notificationManagementTestEnv {
+	t.Helper()
+	db, projectID, _, _, taskID := newTaskNotificationTestDB(t)
+	service := services.NewNotificationService(db, nil)
+	return &notificationManagementTestEnv{
+		db:         db,
+		service:    service,
+		controller: controllers.NewNotificationController(service),
+		repo:       notifrepo.NewNotificationRepository(db),
+		projectID:  projectID,
+		taskID:     taskID,
+	}
+}
+
+// create stores a notification for the user and returns its ID
+func (env *notificationManagementTestEnv) create(t *testing.T, userID string, notificationType models.NotificationType, projectID int64) int64 {
+	t.Helper()
+	n, err := env.repo.CreateProjectNotification(userID, notificationType, "Something happened", string(models.EntityTask), env.taskID, projectID)
+	if err != nil {
+		t.Fatalf("CreateProjectNotification() error = %v", err)
+	}
+	return n.ID
+}
+
+// listIDs calls GET /api/notifications with the query and returns the IDs
+func (env *notificationManagementTestEnv) listIDs(t *testing.T, userID, query string) []int64 {
+	t.Helper()
+	rr := httptest.NewRecorder()
+	env.controller.GetNotifications(rr, createRequestWithUser("GET", "/api/notifications?"+query, nil, userID))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("GetNotifications(%q) status = %d, body = %s", query, rr.Code, rr.Body.String())
+	}
+	var resp models.NotificationListResponse
+	json.NewDecoder(rr.Body).Decode(&resp)
+
+	var ids []int64
+	for _, n := range resp.Data {
+		ids = append(ids, n.ID)
+	}
+	if resp.Total != int64(len(ids)) {
+		t.Errorf("GetNotifications(%q) total = %d, want %d", query, resp.Total, len(ids))
+	}
+	return ids
+}
+
+func sameIDs(got, want []int64) bool {
+	if len(got) != len(want) {
+		return false
+	}
+	for i := range got {
+		if got[i] != want[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestNotificationManagement_Filters(t *testing.T) {
+	env := newNotificationManagementTestEnv(t)
+
+	mention := env.create(t, "member-1", models.NotificationMentioned, env.projectID)
+	assigned := env.create(t, "member-1", models.NotificationTaskAssigned, env.projectID)
+	elsewhere := env.create(t, "member-1", models.NotificationMentioned, env.projectID+1)
+	env.create(t, "member-2", models.NotificationMentioned, env.projectID)
+	env.service.MarkAsRead(assigned, "member-1")
+
+	// Notifications created by the service get their project from the entity
+	if err := env.service.NotifyMemberAdded(env.projectID, "member-1", "owner-1", "Owner", "Apollo"); err != nil {
+		t.Fatalf("NotifyMemberAdded() error = %v", err)
+	}
+	added := env.listIDs(t, "member-1", "type=member_added")[0]
+
+	cases := []struct {
+		query string
+		want  []int64
+	}{
+		{"", []int64{added, elsewhere, assigned, mention}},
+		{"type=mentioned", []int64{elsewhere, mention}},
+		{"is_read=true", []int64{assigned}},
+		{"is_read=false&type=mentioned", []int64{elsewhere, mention}},
+		{"project_id=" + toString(env.projectID), []int64{added, assigned, mention}},
+		{"project_id=" + toString(env.projectID) + "&is_read=false", []int64{added, mention}},
+	}
+	for _, tc := range cases {
+		if got := env.listIDs(t, "member-1", tc.query); !sameIDs(got, tc.want) {
+			t.Errorf("GetNotifications(%q) = %v, want %v", tc.query, got, tc.want)
+		}
+	}
+
+	for _, query := range []string{"type=nonsense", "is_read=maybe", "project_id=abc"} {
+		rr := httptest.NewRecorder()
+		env.controller.GetNotifications(rr, createRequestWithUser("GET", "/api/notifications?"+query, nil, "member-1"))
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("GetNotifications(%q) status = %d, want 400", query, rr.Code)
+		}
+	}
+}
+
+func TestNotificationManagement_MarkUnreadAndDelete(t *testing.T) {
+	env := newNotificationManagementTestEnv(t)
+	id := env.create(t, "member-1", models.NotificationMentioned, env.projectID)
+	env.service.MarkAsRead(id, "member-1")
+
+	call := func(handler http.HandlerFunc, method, userID string, notificationID int64) int {
+		req := createRequestWithUser(method, "/api/notifications/"+toString(notificationID), nil, userID)
+		req = mux.SetURLVars(req, map[string]string{"id": toString(notificationID)})
+		rr := httptest.NewRecorder()
+		handler(rr, req)
+		return rr.Code
+	}
+
+	if code := call(env.controller.MarkAsUnread, "PATCH", "member-2", id); code != http.StatusNotFound {
+		t.Errorf("MarkAsUnread() of another user's notification status = %d, want 404", code)
+	}
+	if code := call(env.controller.MarkAsUnread, "PATCH", "member-1", id); code != http.StatusOK {
+		t.Fatalf("MarkAsUnread() status = %d, want 200", code)
+	}
+	if count, _ := env.service.GetUnreadCount("member-1"); count != 1 {
+		t.Errorf("unread count after MarkAsUnread = %d, want 1", count)
+	}
+
+	if code := call(env.controller.DeleteNotification, "DELETE", "member-2", id); code != http.StatusNotFound {
+		t.Errorf("DeleteNotification() of another user's notification status = %d, want 404", code)
+	}
+	if code := call(env.controller.DeleteNotification, "DELETE", "member-1", id); code != http.StatusNoContent {
+		t.Fatalf("DeleteNotification() status = %d, want 204", code)
+	}
+	if code := call(env.controller.DeleteNotification, "DELETE", "member-1", id); code != http.StatusNotFound {
+		t.Errorf("second DeleteNotification() status = %d, want 404", code)
+	}
+}
+
+func TestNotificationManagement_BulkActions(t *testing.T) {
+	env := newNotificationManagementTestEnv(t)
+	a := env.create(t, "member-1", models.NotificationMentioned, env.projectID)
+	b := env.create(t, "member-1", models.NotificationMentioned, env.projectID)
+	c := env.create(t, "member-1", models.NotificationMentioned, env.projectID)
+	other := env.create(t, "member-2", models.NotificationMentioned, env.projectID)
+
+	bulk := func(action string, ids []int64) (int, int64) {
+		rr := httptest.NewRecorder()
+		body := map[string]interface{}{"action": action, "ids": ids}
+		env.controller.BulkUpdate(rr, createRequestWithUser("POST", "/api/notifications/bulk", body, "member-1"))
+		var resp struct {
+			Data struct {
+				AffectedCount int64 `json:"affected_count"`
+			} `json:"data"`
+		}
+		json.NewDecoder(rr.Body).Decode(&resp)
+		return rr.Code, resp.Data.AffectedCount
+	}
+
+	// Another user's notification in the list is ignored
+	if code, n := bulk("read", []int64{a, b, other}); code != http.StatusOK || n != 2 {
+		t.Errorf("bulk read = %d, %d; want 200, 2", code, n)
+	}
+	if got := env.listIDs(t, "member-1", "is_read=false"); !sameIDs(got, []int64{c}) {
+		t.Errorf("unread after bulk read = %v, want [%d]", got, c)
+	}
+	if count, _ := env.service.GetUnreadCount("member-2"); count != 1 {
+		t.Errorf("member-2 unread count = %d, want 1", count)
+	}
+
+	if code, n := bulk("unread", []int64{a}); code != http.StatusOK || n != 1 {
+		t.Errorf("bulk unread = %d, %d; want 200, 1", code, n)
+	}
+	if code, n := bulk("delete", []int64{a, c, other}); code != http.StatusOK || n != 2 {
+		t.Errorf("bulk delete = %d, %d; want 200, 2", code, n)
+	}
+	if got := env.listIDs(t, "member-1", ""); !sameIDs(got, []int64{b}) {
+		t.Errorf("notifications after bulk delete = %v, want [%d]", got, b)
+	}
+
+	tooMany := make([]int64, 101)
+	for _, tc := range []struct {
+		action string
+		ids    []int64
+	}{{"archive", []int64{b}}, {"read", nil}, {"delete", tooMany}} {
+		if code, _ := bulk(tc.action, tc.ids); code != http.StatusBadRequest {
+			t.Errorf("bulk %q with %d ids status = %d, want 400", tc.action, len(tc.ids), code)
+		}
+	}
+}
+
+func TestNotificationManagement_RetentionPurgesOldReadNotifications(t *testing.T) {
+	env := newNotificationManagementTestEnv(t)
+	oldRead := env.create(t, "member-1", models.NotificationMentioned, env.projectID)
+	oldUnread := env.create(t, "member-1", models.NotificationMentioned, env.projectID)
+	newRead := env.create(t, "member-1", models.NotificationMentioned, env.projectID)
+
+	env.repo.SetReadForIDs("member-1", []int64{oldRead, newRead}, true)
+	old := time.Now().UTC().Add(-40 * 24 * time.Hour)
+	for _, id := range []int64{oldRead, oldUnread} {
+		if _, err := env.db.Exec("UPDATE notifications SET created_at = ? WHERE id = ?", old, id); err != nil {
+			t.Fatalf("Failed to backdate notification: %v", err)
+		}
+	}
+
+	purged, err := env.service.PurgeReadNotifications(30 * 24 * time.Hour)
+	if err != nil || purged != 1 {
+		t.Fatalf("PurgeReadNotifications() = %d, %v; want 1", purged, err)
+	}
+	if got := env.listIDs(t, "member-1", ""); !sameIDs(got, []int64{newRead, oldUnread}) {
+		t.Errorf("notifications after purge = %v, want [%d %d]", got, newRead, oldUnread)
+	}
+}
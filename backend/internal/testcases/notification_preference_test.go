@@ -0,0 +1,199 @@
+package testcases
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"backend/internal/controllers"
+	"backend/internal/models"
+	"backend/internal/services"
+)
+
+func TestNotificationPreferences_DefaultToAllChannels(t *testing.T) {
+	db := newDeadlineReminderTestDB(t)
+	svc := services.NewNotificationService(db, nil)
+
+	preferences, err := svc.GetNotificationPreferences("member-1", 0)
+	if err != nil {
+		t.Fatalf("GetNotificationPreferences() error = %v", err)
+	}
+	if len(preferences) != len(models.NotificationTypes) {
+		t.Fatalf("GetNotificationPreferences() returned %d types, want %d", len(preferences), len(models.NotificationTypes))
+	}
+	for _, pref := range preferences {
+		if !pref.InApp || !pref.Email || pref.ProjectID != nil {
+			t.Errorf("default preference = %+v, want both channels on for all projects", pref)
+		}
+	}
+}
+
+func TestNotificationPreferences_ProjectOverridesUserDefault(t *testing.T) {
+	db := newDeadlineReminderTestDB(t)
+	db.Exec(`INSERT INTO project_members (project_id, user_id, role) VALUES (2, 'member-1', 'member')`)
+	db.Exec(`INSERT INTO stages (id, project_id, name) VALUES (3, 2, 'To Do')`)
+	svc := services.NewNotificationService(db, nil)
+
+	// Off everywhere, then back on in project 1 only
+	off := []models.NotificationPreferenceUpdate{{Type: models.NotificationDeadlineNear, InApp: boolPtr(false), Email: boolPtr(false)}}
+	if _, err := svc.UpdateNotificationPreferences("member-1", 0, off); err != nil {
+		t.Fatalf("UpdateNotificationPreferences() error = %v", err)
+	}
+	on := []models.NotificationPreferenceUpdate{{Type: models.NotificationDeadlineNear, InApp: boolPtr(true)}}
+	preferences, err := svc.UpdateNotificationPreferences("member-1", 1, on)
+	if err != nil {
+		t.Fatalf("UpdateNotificationPreferences() for project error = %v", err)
+	}
+	for _, pref := range preferences {
+		if pref.Type == models.NotificationDeadlineNear && (!pref.InApp || pref.Email || pref.ProjectID == nil || *pref.ProjectID != 1) {
+			t.Errorf("project preference = %+v, want in-app only for project 1", pref)
+		}
+	}
+
+	inProject1 := seedDeadlineTask(t, db, 1, "member-1", time.Now().Add(time.Hour))
+	inProject2 := seedDeadlineTask(t, db, 3, "member-1", time.Now().Add(time.Hour))
+	svc.NotifyDeadlineNear(inProject1, "member-1", "Ship it", time.Hour)
+	svc.NotifyDeadlineNear(inProject2, "member-1", "Ship it", time.Hour)
+
+	var count int
+	var entityID int64
+	db.QueryRow(`SELECT COUNT(*), MAX(related_entity_id) FROM notifications WHERE user_id = 'member-1'`).Scan(&count, &entityID)
+	if count != 1 || entityID != inProject1 {
+		t.Errorf("notifications = %d (task %d), want only the project 1 task %d", count, entityID, inProject1)
+	}
+
+	// Other types are untouched
+	svc.NotifyTaskAssigned(inProject2, "member-1", "owner-1", "Owner", "Ship it")
+	if n := deadlineMessages(t, db, "member-1", models.NotificationTaskAssigned); len(n) != 1 {
+		t.Errorf("task assigned notifications = %d, want 1", len(n))
+	}
+
+	// Resetting the project falls back to the user's default (off)
+	if err := svc.ResetProjectNotificationPreferences("member-1", 1); err != nil {
+		t.Fatalf("ResetProjectNotificationPreferences() error = %v", err)
+	}
+	svc.NotifyDeadlineNear(inProject1, "member-1", "Ship it", time.Hour)
+	if n := deadlineMessages(t, db, "member-1", models.NotificationDeadlineNear); len(n) != 1 {
+		t.Errorf("deadline notifications after reset = %d, want still 1", len(n))
+	}
+}
+
+func TestNotificationPreferenceController_PartialUpdateKeepsOtherChannel(t *testing.T) {
+	db := newDeadlineReminderTestDB(t)
+	svc := services.NewNotificationService(db, nil)
+	controller := controllers.NewNotificationPreferenceController(svc)
+
+	put := func(preference map[string]interface{}) {
+		t.Helper()
+		body := map[string]interface{}{"preferences": []map[string]interface{}{preference}}
+		w := httptest.NewRecorder()
+		controller.UpdatePreferences(w, createRequestWithUser(http.MethodPut, "/api/me/notification-preferences", body, "member-1"))
+		if w.Code != http.StatusOK {
+			t.Fatalf("UpdatePreferences(%v) status = %d, body=%s", preference, w.Code, w.Body.String())
+		}
+	}
+	get := func() models.NotificationPreference {
+		t.Helper()
+		preferences, err := svc.GetNotificationPreferences("member-1", 0)
+		if err != nil {
+			t.Fatalf("GetNotificationPreferences() error = %v", err)
+		}
+		for _, pref := range preferences {
+			if pref.Type == models.NotificationTaskAssigned {
+				return pref
+			}
+		}
+		t.Fatal("GetNotificationPreferences() did not list task_assigned")
+		return models.NotificationPreference{}
+	}
+
+	put(map[string]interface{}{"type": "task_assigned", "email": false})
+	if pref := get(); !pref.InApp || pref.Email {
+		t.Errorf("after turning email off = %+v, want in-app still on", pref)
+	}
+
+	put(map[string]interface{}{"type": "task_assigned", "in_app": false})
+	if pref := get(); pref.InApp || pref.Email {
+		t.Errorf("after turning in-app off = %+v, want email still off", pref)
+	}
+
+	put(map[string]interface{}{"type": "task_assigned", "email": true})
+	if pref := get(); pref.InApp || !pref.Email {
+		t.Errorf("after turning email on = %+v, want in-app still off", pref)
+	}
+
+	w := httptest.NewRecorder()
+	body := map[string]interface{}{"preferences": []map[string]interface{}{{"type": "task_assigned"}}}
+	controller.UpdatePreferences(w, createRequestWithUser(http.MethodPut, "/api/me/notification-preferences", body, "member-1"))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("UpdatePreferences() without channels status = %d, want 400", w.Code)
+	}
+}
+
+func TestNotificationPreferences_Validation(t *testing.T) {
+	db := newDeadlineReminderTestDB(t)
+	svc := services.NewNotificationService(db, nil)
+
+	_, err := svc.UpdateNotificationPreferences("member-1", 0, []models.NotificationPreferenceUpdate{{Type: "carrier_pigeon", InApp: boolPtr(true)}})
+	if se, ok := services.IsServiceError(err); !ok || se.Code != "INVALID_REQUEST" {
+		t.Errorf("unknown type error = %v, want INVALID_REQUEST", err)
+	}
+	_, err = svc.UpdateNotificationPreferences("member-1", 0, []models.NotificationPreferenceUpdate{{Type: models.NotificationTaskAssigned}})
+	if se, ok := services.IsServiceError(err); !ok || se.Code != "INVALID_REQUEST" {
+		t.Errorf("update without channels error = %v, want INVALID_REQUEST", err)
+	}
+	_, err = svc.GetNotificationPreferences("member-1", 99)
+	if se, ok := services.IsServiceError(err); !ok || se.Code != "ACCESS_DENIED" {
+		t.Errorf("other project error = %v, want ACCESS_DENIED", err)
+	}
+}
+
+func TestNotificationPreferenceController_GetAndPut(t *testing.T) {
+	db := newDeadlineReminderTestDB(t)
+	controller := controllers.NewNotificationPreferenceController(services.NewNotificationService(db, nil))
+
+	body := map[string]interface{}{
+		"project_id":  1,
+		"preferences": []map[string]interface{}{{"type": "comment_added", "in_app": true, "email": false}},
+	}
+	w := httptest.NewRecorder()
+	controller.UpdatePreferences(w, createRequestWithUser(http.MethodPut, "/api/me/notification-preferences", body, "member-1"))
+	if w.Code != http.StatusOK {
+		t.Fatalf("UpdatePreferences() status = %d, body=%s", w.Code, w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	controller.GetPreferences(w, createRequestWithUser(http.MethodGet, "/api/me/notification-preferences?project_id=1", nil, "member-1"))
+	var response struct {
+		Data []models.NotificationPreference `json:"data"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+		t.Fatalf("decode response error = %v", err)
+	}
+	found := false
+	for _, pref := range response.Data {
+		if pref.Type == models.NotificationCommentAdded {
+			found = true
+			if !pref.InApp || pref.Email {
+				t.Errorf("comment_added preference = %+v, want in-app only", pref)
+			}
+		}
+	}
+	if !found {
+		t.Error("GetPreferences() did not list comment_added")
+	}
+
+	w = httptest.NewRecorder()
+	controller.GetPreferences(w, createRequestWithUser(http.MethodGet, "/api/me/notification-preferences?project_id=abc", nil, "member-1"))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("GetPreferences() with bad project_id status = %d, want 400", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	controller.UpdatePreferences(w, createRequestWithUser(http.MethodPut, "/api/me/notification-preferences", map[string]interface{}{"preferences": []interface{}{}}, "member-1"))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("UpdatePreferences() without preferences status = %d, want 400", w.Code)
+	}
+}
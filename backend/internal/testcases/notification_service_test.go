@@ -27,6 +27,7 @@ func newNotificationTestDB(t *testing.T) *sql.DB {
 			is_read INTEGER DEFAULT 0,
 			related_entity_type TEXT,
 			related_entity_id INTEGER,
+			project_id INTEGER,
 			created_at DATETIME DEFAULT CURRENT_TIMESTAMP
 		)
 	`)
@@ -0,0 +1,218 @@
+package testcases
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	authmiddleware "backend/internal/auth/middleware"
+	authservices "backend/internal/auth/services"
+	"backend/internal/controllers"
+	"backend/internal/models"
+	notifrepo "backend/internal/repository"
+	"backend/internal/services"
+)
+
+// streamEvent is one Server-Sent Event; comment lines are reported with
+// Comment set
+type streamEvent struct {
+	ID      string
+	Event   string
+	Data    models.NotificationStreamEvent
+	Comment string
+}
+
+type notificationStreamTestEnv struct {
+	server        *httptest.Server
+	jwt           *authservices.JWTService
+	notifications *services.NotificationService
+	repo          *notifrepo.NotificationRepository
+	projectID     int64
+}
+
+func newNotificationStreamTestEnv(t *testing.T, keepAlive time.Duration) *notificationStreamTestEnv {
+	t.Helper()
+
+	db, projectID, _, _, _ := newTaskNotificationTestDB(t)
+	stream := services.NewNotificationStream()
+	notificationService := services.NewNotificationService(db, nil)
+	notificationService.SetStream(stream)
+	controller := controllers.NewNotificationStreamController(notificationService, stream)
+	controller.SetKeepAlive(keepAlive)
+
+	jwtService := authservices.NewJWTService("stream-test-secret", 1)
+	router := mux.NewRouter()
+	router.Handle("/api/notifications/stream", authmiddleware.JWTQueryAuthMiddleware(jwtService)(http.HandlerFunc(controller.Stream)))
+
+	server := httptest.NewServer(router)
+	t.Cleanup(server.Close)
+
+	return &notificationStreamTestEnv{
+		server:        server,
+		jwt:           jwtService,
+		notifications: notificationService,
+		repo:          notifrepo.NewNotificationRepository(db),
+		projectID:     projectID,
+	}
+}
+
+// open connects userID to the stream and returns its events
+func (env *notificationStreamTestEnv) open(t *testing.T, userID string, header http.Header) <-chan streamEvent {
+	t.Helper()
+
+	token, err := env.jwt.GenerateToken(userID, userID+"@test.com")
+	if err != nil {
+		t.Fatalf("GenerateToken() error = %v", err)
+	}
+	req, _ := http.NewRequest("GET", env.server.URL+"/api/notifications/stream?token="+token, nil)
+	for key, values := range header {
+		req.Header[key] = values
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("stream request error = %v", err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	if resp.StatusCode != http.StatusOK || !strings.HasPrefix(resp.Header.Get("Content-Type"), "text/event-stream") {
+		t.Fatalf("stream status = %d, Content-Type = %q", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+
+	events := make(chan streamEvent, 16)
+	go func() {
+		defer close(events)
+		scanner := bufio.NewScanner(resp.Body)
+		var event streamEvent
+		for scanner.Scan() {
+			line := scanner.Text()
+			switch {
+			case line == "":
+				if event.Event != "" || event.Comment != "" {
+					events <- event
+				}
+				event = streamEvent{}
+			case strings.HasPrefix(line, ":"):
+				event.Comment = strings.TrimSpace(strings.TrimPrefix(line, ":"))
+			case strings.HasPrefix(line, "id: "):
+				event.ID = strings.TrimPrefix(line, "id: ")
+			case strings.HasPrefix(line, "event: "):
+				event.Event = strings.TrimPrefix(line, "event: ")
+			case strings.HasPrefix(line, "data: "):
+				json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &event.Data)
+			}
+		}
+	}()
+	return events
+}
+
+// nextEvent returns the next event, skipping keep-alives unless wanted
+func nextEvent(t *testing.T, events <-chan streamEvent, keepAlives bool) streamEvent {
+	t.Helper()
+	timeout := time.After(2 * time.Second)
+	for {
+		select {
+		case event, ok := <-events:
+			if !ok {
+				t.Fatal("stream closed")
+			}
+			if event.Comment != "" && !keepAlives {
+				continue
+			}
+			return event
+		case <-timeout:
+			t.Fatal("timed out waiting for a stream event")
+		}
+	}
+}
+
+func TestNotificationStream_PushesNotificationsAndUnreadCount(t *testing.T) {
+	env := newNotificationStreamTestEnv(t, time.Minute)
+	events := env.open(t, "member-1", nil)
+
+	if event := nextEvent(t, events, false); event.Event != "unread_count" || event.Data.UnreadCount != 0 {
+		t.Fatalf("first event = %+v, want unread_count 0", event)
+	}
+
+	if err := env.notifications.NotifyMemberAdded(env.projectID, "member-1", "owner-1", "Owner", "Apollo"); err != nil {
+		t.Fatalf("NotifyMemberAdded() error = %v", err)
+	}
+	event := nextEvent(t, events, false)
+	if event.Event != "notification" || event.Data.Notification == nil || event.Data.UnreadCount != 1 {
+		t.Fatalf("event = %+v, want a notification with unread_count 1", event)
+	}
+	if event.ID != toString(event.Data.Notification.ID) || event.Data.Notification.Type != string(models.NotificationMemberAdded) {
+		t.Errorf("notification event id = %q, data = %+v", event.ID, event.Data.Notification)
+	}
+
+	// Another user's notifications are not streamed
+	env.notifications.NotifyMemberAdded(env.projectID, "member-2", "owner-1", "Owner", "Apollo")
+
+	if _, err := env.notifications.MarkAllAsRead("member-1"); err != nil {
+		t.Fatalf("MarkAllAsRead() error = %v", err)
+	}
+	if event := nextEvent(t, events, false); event.Event != "unread_count" || event.Data.UnreadCount != 0 || event.ID != "" {
+		t.Errorf("event after MarkAllAsRead = %+v, want unread_count 0 without an id", event)
+	}
+}
+
+func TestNotificationStream_ResumesFromLastEventID(t *testing.T) {
+	env := newNotificationStreamTestEnv(t, time.Minute)
+
+	var ids []int64
+	for i := 0; i < 3; i++ {
+		n, err := env.repo.CreateNotification("member-1", models.NotificationMentioned, "Missed", "task", 1)
+		if err != nil {
+			t.Fatalf("CreateNotification() error = %v", err)
+		}
+		ids = append(ids, n.ID)
+	}
+
+	events := env.open(t, "member-1", http.Header{"Last-Event-ID": {toString(ids[0])}})
+	for _, want := range ids[1:] {
+		event := nextEvent(t, events, false)
+		if event.Event != "notification" || event.ID != toString(want) || event.Data.UnreadCount != 3 {
+			t.Fatalf("replayed event = %+v, want notification %d with unread_count 3", event, want)
+		}
+	}
+
+	if _, err := env.notifications.MarkAllAsRead("member-1"); err != nil {
+		t.Fatalf("MarkAllAsRead() error = %v", err)
+	}
+	if event := nextEvent(t, events, false); event.Event != "unread_count" || event.Data.UnreadCount != 0 {
+		t.Errorf("event after replay = %+v, want unread_count 0", event)
+	}
+}
+
+func TestNotificationStream_KeepAliveAndAuth(t *testing.T) {
+	env := newNotificationStreamTestEnv(t, 20*time.Millisecond)
+
+	resp, err := http.Get(env.server.URL + "/api/notifications/stream")
+	if err != nil {
+		t.Fatalf("stream request error = %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("stream without a token status = %d, want 401", resp.StatusCode)
+	}
+
+	events := env.open(t, "member-1", nil)
+	nextEvent(t, events, false)
+	if event := nextEvent(t, events, true); event.Comment != "keep-alive" {
+		t.Fatalf("event = %+v, want a keep-alive comment", event)
+	}
+
+	// Notifications inserted without a signal (as by another server
+	// instance) are picked up on the next keep-alive
+	n, err := env.repo.CreateNotification("member-1", models.NotificationMentioned, "From elsewhere", "task", 1)
+	if err != nil {
+		t.Fatalf("CreateNotification() error = %v", err)
+	}
+	if event := nextEvent(t, events, false); event.Event != "notification" || event.ID != toString(n.ID) {
+		t.Errorf("event = %+v, want notification %d", event, n.ID)
+	}
+}
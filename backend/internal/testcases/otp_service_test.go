@@ -1,14 +1,47 @@
 package testcases
 
 import (
+	"database/sql"
+	"errors"
 	"strings"
 	"testing"
+	"time"
 
+	"backend/internal/auth/repository"
 	"backend/internal/auth/services"
+
+	_ "github.com/mattn/go-sqlite3"
 )
 
+func newOTPTestService(t *testing.T) (*services.OTPService, *sql.DB) {
+	t.Helper()
+
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { db.Close() })
+
+	repo := repository.NewOTPRepository(db)
+	if err := repo.InitTable(); err != nil {
+		t.Fatalf("Failed to create password reset OTPs table: %v", err)
+	}
+	return services.NewOTPService(repo), db
+}
+
+// allowResend moves the last code sent to email past the resend cooldown
+func allowResend(t *testing.T, db *sql.DB, email string) {
+	t.Helper()
+
+	if _, err := db.Exec("UPDATE password_reset_otps SET sent_at = ? WHERE email = ?",
+		time.Now().Add(-2*services.OTPResendInterval).UTC(), email); err != nil {
+		t.Fatalf("Failed to move sent_at: %v", err)
+	}
+}
+
 func TestOTPService_GenerateOTP(t *testing.T) {
-	service := services.NewOTPService()
+	service, db := newOTPTestService(t)
 
 	t.Run("generates 6-digit OTP", func(t *testing.T) {
 		otp, err := service.GenerateOTP("test@example.com")
@@ -27,7 +60,9 @@ func TestOTPService_GenerateOTP(t *testing.T) {
 	})
 
 	t.Run("generates unique OTPs", func(t *testing.T) {
+		allowResend(t, db, "test@example.com")
 		otp1, _ := service.GenerateOTP("test@example.com")
+		allowResend(t, db, "test@example.com")
 		otp2, _ := service.GenerateOTP("test@example.com")
 		if otp1 == otp2 {
 			t.Error("GenerateOTP() should generate unique OTPs for same email")
@@ -36,7 +71,7 @@ func TestOTPService_GenerateOTP(t *testing.T) {
 }
 
 func TestOTPService_VerifyOTP(t *testing.T) {
-	service := services.NewOTPService()
+	service, _ := newOTPTestService(t)
 
 	t.Run("valid OTP verification", func(t *testing.T) {
 		email := "verify@example.com"
@@ -71,7 +106,7 @@ func TestOTPService_VerifyOTP(t *testing.T) {
 }
 
 func TestOTPService_ValidateResetToken(t *testing.T) {
-	service := services.NewOTPService()
+	service, _ := newOTPTestService(t)
 
 	t.Run("valid reset token", func(t *testing.T) {
 		email := "reset@example.com"
@@ -114,3 +149,84 @@ func TestOTPService_ValidateResetToken(t *testing.T) {
 		}
 	})
 }
+
+func TestOTPService_StoresHashesAndSurvivesRestart(t *testing.T) {
+	service, db := newOTPTestService(t)
+	email := "restart@example.com"
+
+	otp, err := service.GenerateOTP(email)
+	if err != nil {
+		t.Fatalf("GenerateOTP() error = %v", err)
+	}
+	var codeHash string
+	db.QueryRow("SELECT code_hash FROM password_reset_otps WHERE email = ?", email).Scan(&codeHash)
+	if codeHash == "" || strings.Contains(codeHash, otp) {
+		t.Errorf("code_hash = %q, want a hash of the code", codeHash)
+	}
+
+	// A new service on the same database, as after a restart
+	restarted := services.NewOTPService(repository.NewOTPRepository(db))
+	resetToken, err := restarted.VerifyOTP(email, otp)
+	if err != nil {
+		t.Fatalf("VerifyOTP() after restart error = %v", err)
+	}
+	if _, err := restarted.VerifyOTP(email, otp); !errors.Is(err, services.ErrOTPNotFound) {
+		t.Errorf("VerifyOTP() with a used code error = %v, want ErrOTPNotFound", err)
+	}
+
+	var stored int
+	db.QueryRow("SELECT COUNT(*) FROM password_reset_otps WHERE reset_token_hash = ?", resetToken).Scan(&stored)
+	if stored != 0 {
+		t.Error("reset token is stored in plain text")
+	}
+
+	db.Exec("UPDATE password_reset_otps SET reset_expires_at = ?", time.Now().Add(-time.Minute).UTC())
+	if _, err := restarted.ValidateResetToken(resetToken); !errors.Is(err, services.ErrResetTokenExpired) {
+		t.Errorf("ValidateResetToken() with an expired token error = %v, want ErrResetTokenExpired", err)
+	}
+}
+
+func TestOTPService_LockoutAndResendCooldown(t *testing.T) {
+	service, db := newOTPTestService(t)
+	email := "guess@example.com"
+
+	otp, err := service.GenerateOTP(email)
+	if err != nil {
+		t.Fatalf("GenerateOTP() error = %v", err)
+	}
+	if _, err := service.GenerateOTP(email); !errors.Is(err, services.ErrOTPResendTooSoon) {
+		t.Errorf("immediate GenerateOTP() error = %v, want ErrOTPResendTooSoon", err)
+	}
+
+	wrong := "000000"
+	if otp == wrong {
+		wrong = "111111"
+	}
+	for i := 1; i < 5; i++ {
+		if _, err := service.VerifyOTP(email, wrong); !errors.Is(err, services.ErrInvalidOTP) {
+			t.Fatalf("wrong code %d error = %v, want ErrInvalidOTP", i, err)
+		}
+	}
+	if _, err := service.VerifyOTP(email, wrong); !errors.Is(err, services.ErrOTPLocked) {
+		t.Fatalf("fifth wrong code error = %v, want ErrOTPLocked", err)
+	}
+
+	// Locked out: the right code no longer works and no new code is sent,
+	// even after the resend cooldown
+	if _, err := service.VerifyOTP(email, otp); !errors.Is(err, services.ErrOTPLocked) {
+		t.Errorf("right code while locked error = %v, want ErrOTPLocked", err)
+	}
+	allowResend(t, db, email)
+	if _, err := service.GenerateOTP(email); !errors.Is(err, services.ErrOTPLocked) {
+		t.Errorf("GenerateOTP() while locked error = %v, want ErrOTPLocked", err)
+	}
+
+	db.Exec("UPDATE password_reset_otps SET locked_until = ?", time.Now().Add(-time.Minute).UTC())
+	otp, err = service.GenerateOTP(email)
+	if err != nil {
+		t.Fatalf("GenerateOTP() after the lockout error = %v", err)
+	}
+	if _, err := service.VerifyOTP(email, otp); err != nil {
+		t.Errorf("VerifyOTP() after the lockout error = %v", err)
+	}
+}
@@ -0,0 +1,369 @@
+package testcases
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"backend/internal/auth/controller"
+	"backend/internal/auth/middleware"
+	"backend/internal/auth/models"
+	"backend/internal/auth/repository"
+	"backend/internal/auth/services"
+
+	"github.com/gorilla/mux"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+type sessionTestEnv struct {
+	db       *sql.DB
+	userRepo *repository.UserRepository
+	jwt      *services.JWTService
+	otp      *services.OTPService
+	sessions *services.SessionService
+	auth     *services.AuthService
+	router   *mux.Router
+}
+
+func newSessionTestEnv(t *testing.T) *sessionTestEnv {
+	t.Helper()
+
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { db.Close() })
+
+	userRepo := repository.NewUserRepository(db)
+	if err := userRepo.InitTable(); err != nil {
+		t.Fatalf("Failed to create users table: %v", err)
+	}
+	identityRepo := repository.NewAuthIdentityRepository(db)
+	if err := identityRepo.InitTable(); err != nil {
+		t.Fatalf("Failed to create auth identities table: %v", err)
+	}
+	sessionRepo := repository.NewSessionRepository(db)
+	if err := sessionRepo.InitTable(); err != nil {
+		t.Fatalf("Failed to create sessions table: %v", err)
+	}
+	otpRepo := repository.NewOTPRepository(db)
+	if err := otpRepo.InitTable(); err != nil {
+		t.Fatalf("Failed to create password reset OTPs table: %v", err)
+	}
+
+	jwtService := services.NewJWTService("test-secret", 24)
+	jwtService.SetAccessTokenTTL(15 * time.Minute)
+	sessionService := services.NewSessionService(sessionRepo, userRepo, jwtService, 30*24*time.Hour)
+	jwtService.SetSessionValidator(sessionService)
+
+	otpService := services.NewOTPService(otpRepo)
+	authService := services.NewAuthService(userRepo, identityRepo, jwtService, otpService, &services.EmailService{}, nil, nil)
+	authService.SetSessionService(sessionService)
+
+	ctrl := controller.NewAuthController(authService)
+	ctrl.SetSessionService(sessionService)
+
+	router := mux.NewRouter()
+	router.HandleFunc("/api/auth/refresh", ctrl.Refresh).Methods("POST")
+	router.HandleFunc("/api/auth/logout", ctrl.Logout).Methods("POST")
+	protected := router.PathPrefix("/api").Subrouter()
+	protected.Use(middleware.JWTAuthMiddleware(jwtService))
+	protected.HandleFunc("/auth/me", ctrl.GetMe).Methods("GET")
+	protected.HandleFunc("/auth/sessions", ctrl.ListSessions).Methods("GET")
+	protected.HandleFunc("/auth/sessions", ctrl.RevokeOtherSessions).Methods("DELETE")
+	protected.HandleFunc("/auth/sessions/{id}", ctrl.RevokeSession).Methods("DELETE")
+
+	return &sessionTestEnv{
+		db:       db,
+		userRepo: userRepo,
+		jwt:      jwtService,
+		otp:      otpService,
+		sessions: sessionService,
+		auth:     authService,
+		router:   router,
+	}
+}
+
+func (env *sessionTestEnv) register(t *testing.T) *services.AuthResponse {
+	t.Helper()
+
+	resp, err := env.auth.Register(services.RegisterRequest{
+		Name:     "Session User",
+		Email:    "session@example.com",
+		Password: "password123",
+		Client:   services.ClientInfo{UserAgent: "laptop", IPAddress: "10.0.0.1"},
+	})
+	if err != nil {
+		t.Fatalf("Register() error = %v", err)
+	}
+	return resp
+}
+
+func (env *sessionTestEnv) login(t *testing.T, userAgent string) *services.AuthResponse {
+	t.Helper()
+
+	resp, err := env.auth.Login(services.LoginRequest{
+		Email:    "session@example.com",
+		Password: "password123",
+		Client:   services.ClientInfo{UserAgent: userAgent, IPAddress: "10.0.0.2"},
+	})
+	if err != nil {
+		t.Fatalf("Login() error = %v", err)
+	}
+	return resp
+}
+
+func (env *sessionTestEnv) do(method, url, token string, body interface{}) *httptest.ResponseRecorder {
+	var bodyBytes []byte
+	if body != nil {
+		bodyBytes, _ = json.Marshal(body)
+	}
+	req := httptest.NewRequest(method, url, bytes.NewBuffer(bodyBytes))
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	w := httptest.NewRecorder()
+	env.router.ServeHTTP(w, req)
+	return w
+}
+
+func TestSessions_LoginIssuesShortLivedTokenAndRefreshRotates(t *testing.T) {
+	env := newSessionTestEnv(t)
+	resp := env.register(t)
+
+	if resp.RefreshToken == "" {
+		t.Fatal("Register() returned no refresh token")
+	}
+	if resp.ExpiresIn != int64((15 * time.Minute).Seconds()) {
+		t.Errorf("ExpiresIn = %d, want 900", resp.ExpiresIn)
+	}
+
+	claims, err := env.jwt.Authenticate(resp.Token)
+	if err != nil {
+		t.Fatalf("Authenticate() error = %v", err)
+	}
+	if claims.SessionID == "" {
+		t.Fatal("access token carries no session ID")
+	}
+	if ttl := claims.ExpiresAt.Sub(claims.IssuedAt.Time); ttl != 15*time.Minute {
+		t.Errorf("access token lifetime = %v, want 15m", ttl)
+	}
+
+	var stored string
+	if err := env.db.QueryRow("SELECT token_hash FROM sessions").Scan(&stored); err != nil {
+		t.Fatalf("Failed to read session: %v", err)
+	}
+	if stored == resp.RefreshToken {
+		t.Error("refresh token is stored in plain text")
+	}
+
+	w := env.do("POST", "/api/auth/refresh", "", map[string]string{"refresh_token": resp.RefreshToken})
+	if w.Code != http.StatusOK {
+		t.Fatalf("Refresh status = %d, body %s", w.Code, w.Body.String())
+	}
+	var refreshed services.AuthResponse
+	if err := json.NewDecoder(w.Body).Decode(&refreshed); err != nil {
+		t.Fatalf("Failed to decode refresh response: %v", err)
+	}
+	if refreshed.RefreshToken == "" || refreshed.RefreshToken == resp.RefreshToken {
+		t.Error("Refresh did not rotate the refresh token")
+	}
+	if refreshed.User.ID != resp.User.ID {
+		t.Errorf("Refresh user = %s, want %s", refreshed.User.ID, resp.User.ID)
+	}
+
+	newClaims, err := env.jwt.Authenticate(refreshed.Token)
+	if err != nil {
+		t.Fatalf("Authenticate(refreshed) error = %v", err)
+	}
+	if newClaims.SessionID != claims.SessionID {
+		t.Error("refreshed access token belongs to a different session")
+	}
+
+	if w := env.do("POST", "/api/auth/refresh", "", map[string]string{"refresh_token": "bogus"}); w.Code != http.StatusUnauthorized {
+		t.Errorf("Refresh with unknown token status = %d, want 401", w.Code)
+	}
+}
+
+func TestSessions_RefreshTokenReuseRevokesFamily(t *testing.T) {
+	env := newSessionTestEnv(t)
+	resp := env.register(t)
+
+	refreshed, err := env.sessions.Refresh(resp.RefreshToken, services.ClientInfo{})
+	if err != nil {
+		t.Fatalf("Refresh() error = %v", err)
+	}
+
+	// Replaying the rotated token looks like theft: the whole session goes
+	if _, err := env.sessions.Refresh(resp.RefreshToken, services.ClientInfo{}); !errors.Is(err, services.ErrRefreshTokenReused) {
+		t.Fatalf("Refresh(reused) error = %v, want ErrRefreshTokenReused", err)
+	}
+
+	if _, err := env.sessions.Refresh(refreshed.RefreshToken, services.ClientInfo{}); !errors.Is(err, services.ErrInvalidRefreshToken) {
+		t.Errorf("Refresh(latest after reuse) error = %v, want ErrInvalidRefreshToken", err)
+	}
+	if _, err := env.jwt.Authenticate(refreshed.Token); !errors.Is(err, services.ErrSessionRevoked) {
+		t.Errorf("Authenticate() after reuse error = %v, want ErrSessionRevoked", err)
+	}
+
+	w := env.do("GET", "/api/auth/me", refreshed.Token, nil)
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("GET /me after reuse status = %d, want 401", w.Code)
+	}
+}
+
+func TestSessions_LogoutRevokesAccessToken(t *testing.T) {
+	env := newSessionTestEnv(t)
+	resp := env.register(t)
+
+	if w := env.do("GET", "/api/auth/me", resp.Token, nil); w.Code != http.StatusOK {
+		t.Fatalf("GET /me status = %d, want 200", w.Code)
+	}
+
+	w := env.do("POST", "/api/auth/logout", "", map[string]string{"refresh_token": resp.RefreshToken})
+	if w.Code != http.StatusOK {
+		t.Fatalf("Logout status = %d, body %s", w.Code, w.Body.String())
+	}
+
+	w = env.do("GET", "/api/auth/me", resp.Token, nil)
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("GET /me after logout status = %d, want 401", w.Code)
+	}
+	var body map[string]string
+	json.NewDecoder(w.Body).Decode(&body)
+	if body["error"] != "Session has been revoked" {
+		t.Errorf("error = %q, want session revoked", body["error"])
+	}
+
+	if _, err := env.sessions.Refresh(resp.RefreshToken, services.ClientInfo{}); !errors.Is(err, services.ErrInvalidRefreshToken) {
+		t.Errorf("Refresh() after logout error = %v, want ErrInvalidRefreshToken", err)
+	}
+
+	// Logging out again is harmless
+	if w := env.do("POST", "/api/auth/logout", "", map[string]string{"refresh_token": resp.RefreshToken}); w.Code != http.StatusOK {
+		t.Errorf("second Logout status = %d, want 200", w.Code)
+	}
+}
+
+func TestSessions_ListAndRevoke(t *testing.T) {
+	env := newSessionTestEnv(t)
+	first := env.register(t)
+	second := env.login(t, "phone")
+	third := env.login(t, "tablet")
+
+	w := env.do("GET", "/api/auth/sessions", second.Token, nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("ListSessions status = %d, body %s", w.Code, w.Body.String())
+	}
+	var sessions []models.Session
+	if err := json.NewDecoder(w.Body).Decode(&sessions); err != nil {
+		t.Fatalf("Failed to decode sessions: %v", err)
+	}
+	if len(sessions) != 3 {
+		t.Fatalf("ListSessions returned %d sessions, want 3", len(sessions))
+	}
+	secondClaims, _ := env.jwt.ValidateToken(second.Token)
+	for _, session := range sessions {
+		if session.Current != (session.ID == secondClaims.SessionID) {
+			t.Errorf("session %s (%s) current = %v", session.ID, session.UserAgent, session.Current)
+		}
+	}
+
+	firstClaims, _ := env.jwt.ValidateToken(first.Token)
+	if w := env.do("DELETE", "/api/auth/sessions/"+firstClaims.SessionID, second.Token, nil); w.Code != http.StatusNoContent {
+		t.Fatalf("RevokeSession status = %d, body %s", w.Code, w.Body.String())
+	}
+	if w := env.do("GET", "/api/auth/me", first.Token, nil); w.Code != http.StatusUnauthorized {
+		t.Errorf("revoked session GET /me status = %d, want 401", w.Code)
+	}
+	if w := env.do("DELETE", "/api/auth/sessions/"+firstClaims.SessionID, second.Token, nil); w.Code != http.StatusNotFound {
+		t.Errorf("revoking a revoked session status = %d, want 404", w.Code)
+	}
+
+	w = env.do("DELETE", "/api/auth/sessions", second.Token, nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("RevokeOtherSessions status = %d, body %s", w.Code, w.Body.String())
+	}
+	var revoked map[string]int64
+	json.NewDecoder(w.Body).Decode(&revoked)
+	if revoked["revoked"] != 1 {
+		t.Errorf("revoked = %d, want 1", revoked["revoked"])
+	}
+	if w := env.do("GET", "/api/auth/me", third.Token, nil); w.Code != http.StatusUnauthorized {
+		t.Errorf("other session GET /me status = %d, want 401", w.Code)
+	}
+	if w := env.do("GET", "/api/auth/me", second.Token, nil); w.Code != http.StatusOK {
+		t.Errorf("current session GET /me status = %d, want 200", w.Code)
+	}
+}
+
+func TestSessions_InactiveUserRejected(t *testing.T) {
+	env := newSessionTestEnv(t)
+	resp := env.register(t)
+
+	if err := env.userRepo.SetUserActive(resp.User.ID, false); err != nil {
+		t.Fatalf("SetUserActive() error = %v", err)
+	}
+
+	w := env.do("GET", "/api/auth/me", resp.Token, nil)
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("inactive user GET /me status = %d, want 401", w.Code)
+	}
+
+	// Tokens issued without a session are checked for an active user first
+	legacy, _ := env.jwt.GenerateToken(resp.User.ID, resp.User.Email)
+	if _, err := env.jwt.Authenticate(legacy); !errors.Is(err, services.ErrUserInactive) {
+		t.Errorf("Authenticate(sessionless) error = %v, want ErrUserInactive", err)
+	}
+
+	if w := env.do("POST", "/api/auth/refresh", "", map[string]string{"refresh_token": resp.RefreshToken}); w.Code != http.StatusForbidden {
+		t.Errorf("inactive user Refresh status = %d, want 403", w.Code)
+	}
+}
+
+func TestSessions_TokenWithoutSessionRejected(t *testing.T) {
+	env := newSessionTestEnv(t)
+	resp := env.register(t)
+
+	// Such tokens could not be ended by logging out or revoking sessions
+	sessionless, _ := env.jwt.GenerateToken(resp.User.ID, resp.User.Email)
+	if _, err := env.jwt.Authenticate(sessionless); !errors.Is(err, services.ErrInvalidToken) {
+		t.Errorf("Authenticate(sessionless) error = %v, want ErrInvalidToken", err)
+	}
+	if w := env.do("GET", "/api/auth/me", sessionless, nil); w.Code != http.StatusUnauthorized {
+		t.Errorf("sessionless GET /me status = %d, want 401", w.Code)
+	}
+	if w := env.do("GET", "/api/auth/me", resp.Token, nil); w.Code != http.StatusOK {
+		t.Errorf("session token GET /me status = %d, want 200", w.Code)
+	}
+}
+
+func TestSessions_PasswordResetRevokesSessions(t *testing.T) {
+	env := newSessionTestEnv(t)
+	resp := env.register(t)
+
+	code, err := env.otp.GenerateOTP("session@example.com")
+	if err != nil {
+		t.Fatalf("GenerateOTP() error = %v", err)
+	}
+	resetToken, err := env.auth.VerifyResetOTP("session@example.com", code)
+	if err != nil {
+		t.Fatalf("VerifyResetOTP() error = %v", err)
+	}
+	if err := env.auth.ResetPassword(resetToken, "new-password-456"); err != nil {
+		t.Fatalf("ResetPassword() error = %v", err)
+	}
+
+	if _, err := env.jwt.Authenticate(resp.Token); !errors.Is(err, services.ErrSessionRevoked) {
+		t.Errorf("Authenticate() after reset error = %v, want ErrSessionRevoked", err)
+	}
+	if _, err := env.sessions.Refresh(resp.RefreshToken, services.ClientInfo{}); !errors.Is(err, services.ErrInvalidRefreshToken) {
+		t.Errorf("Refresh() after reset error = %v, want ErrInvalidRefreshToken", err)
+	}
+}
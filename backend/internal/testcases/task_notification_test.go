@@ -0,0 +1,120 @@
+package testcases
+
+import (
+	"database/sql"
+	"testing"
+
+	"backend/internal/models"
+	"backend/internal/services"
+)
+
+// newTaskNotificationTestDB seeds a project where owner-1 created a task
+// assigned to member-1. Returns (db, projectID, todoStageID, doneStageID, taskID).
+func newTaskNotificationTestDB(t *testing.T) (*sql.DB, int64, int64, int64, int64) {
+	t.Helper()
+
+	db := newBoardEventTestDB(t)
+	projectID, todoID, doneID, taskID := seedBoardEventProject(t, db, "owner-1")
+	for _, member := range []struct{ id, name string }{{"member-1", "Member One"}, {"member-2", "Member Two"}} {
+		db.Exec("INSERT INTO users (id, name, email) VALUES (?, ?, ?)", member.id, member.name, member.id+"@example.com")
+		db.Exec("INSERT INTO project_members (project_id, user_id, role) VALUES (?, ?, 'member')", projectID, member.id)
+	}
+	db.Exec("UPDATE stages SET is_final = 1 WHERE id = ?", doneID)
+	db.Exec("UPDATE tasks SET assigned_to = 'member-1' WHERE id = ?", taskID)
+
+	return db, projectID, todoID, doneID, taskID
+}
+
+// notificationsOfType returns the user's notifications of the given type
+func notificationsOfType(t *testing.T, db *sql.DB, userID string, notificationType models.NotificationType) []models.Notification {
+	t.Helper()
+	rows, err := db.Query(`SELECT message, related_entity_type, related_entity_id FROM notifications WHERE user_id = ? AND type = ? ORDER BY id`, userID, notificationType)
+	if err != nil {
+		t.Fatalf("query notifications: %v", err)
+	}
+	defer rows.Close()
+
+	var notifications []models.Notification
+	for rows.Next() {
+		var n models.Notification
+		rows.Scan(&n.Message, &n.RelatedEntityType, &n.RelatedEntityID)
+		notifications = append(notifications, n)
+	}
+	return notifications
+}
+
+func TestTaskNotifications_CompletedWhenMovedIntoFinalStage(t *testing.T) {
+	db, _, todoID, doneID, taskID := newTaskNotificationTestDB(t)
+	tasks := services.NewTaskService(db, nil)
+	tasks.SetNotificationService(services.NewNotificationService(db, nil))
+
+	if _, err := tasks.MoveTask("member-1", taskID, doneID, 0); err != nil {
+		t.Fatalf("MoveTask() error = %v", err)
+	}
+	// Reordering inside the final stage is not another completion
+	if _, err := tasks.MoveTask("member-1", taskID, doneID, 1); err != nil {
+		t.Fatalf("MoveTask() error = %v", err)
+	}
+
+	completed := notificationsOfType(t, db, "owner-1", models.NotificationTaskCompleted)
+	if len(completed) != 1 {
+		t.Fatalf("creator got %d task_completed notifications, want 1", len(completed))
+	}
+	if completed[0].RelatedEntityType != string(models.EntityTask) || completed[0].RelatedEntityID != taskID ||
+		completed[0].Message != "Task 'Write docs' was completed by Member One" {
+		t.Errorf("notification = %+v", completed[0])
+	}
+	if n := notificationsOfType(t, db, "member-1", models.NotificationTaskCompleted); len(n) != 0 {
+		t.Errorf("actor got %d task_completed notifications, want 0", len(n))
+	}
+
+	// The creator completing their own task is not notified
+	tasks.MoveTask("owner-1", taskID, todoID, 0)
+	tasks.MoveTask("owner-1", taskID, doneID, 0)
+	if n := notificationsOfType(t, db, "owner-1", models.NotificationTaskCompleted); len(n) != 1 {
+		t.Errorf("creator got %d task_completed notifications after completing it themselves, want 1", len(n))
+	}
+}
+
+func TestTaskNotifications_CommentAddedSkipsActorAndMentioned(t *testing.T) {
+	db, _, _, _, taskID := newTaskNotificationTestDB(t)
+	notifications := services.NewNotificationService(db, nil)
+	comments := services.NewCommentService(db)
+	comments.SetMentionService(services.NewMentionService(db, notifications))
+	comments.SetNotificationService(notifications)
+
+	// Earlier commenters: a member and someone who has since left the project
+	db.Exec("INSERT INTO comments (task_id, user_id, author_name, content) VALUES (?, 'member-2', 'Member Two', 'Looks good')", taskID)
+	db.Exec("INSERT INTO comments (task_id, user_id, author_name, content) VALUES (?, 'gone', 'Gone', 'Bye')", taskID)
+
+	comment, err := comments.CreateComment("owner-1", taskID, "Shipping tomorrow")
+	if err != nil {
+		t.Fatalf("CreateComment() error = %v", err)
+	}
+	for _, userID := range []string{"member-1", "member-2"} {
+		got := notificationsOfType(t, db, userID, models.NotificationCommentAdded)
+		if len(got) != 1 || got[0].RelatedEntityType != string(models.EntityComment) || got[0].RelatedEntityID != comment.ID ||
+			got[0].Message != "Owner commented on task 'Write docs'" {
+			t.Errorf("%s comment_added notifications = %+v, want one for comment %d", userID, got, comment.ID)
+		}
+	}
+	for _, userID := range []string{"owner-1", "gone"} {
+		if n := notificationsOfType(t, db, userID, models.NotificationCommentAdded); len(n) != 0 {
+			t.Errorf("%s got %d comment_added notifications, want 0", userID, len(n))
+		}
+	}
+
+	// A mentioned commenter gets the mention instead
+	if _, err := comments.CreateComment("owner-1", taskID, "@Member Two can you check?"); err != nil {
+		t.Fatalf("CreateComment() error = %v", err)
+	}
+	if n := notificationsOfType(t, db, "member-2", models.NotificationCommentAdded); len(n) != 1 {
+		t.Errorf("mentioned member got %d comment_added notifications, want still 1", len(n))
+	}
+	if n := notificationsOfType(t, db, "member-2", models.NotificationMentioned); len(n) != 1 {
+		t.Errorf("mentioned member got %d mention notifications, want 1", len(n))
+	}
+	if n := notificationsOfType(t, db, "member-1", models.NotificationCommentAdded); len(n) != 2 {
+		t.Errorf("assignee got %d comment_added notifications, want 2", len(n))
+	}
+}
@@ -0,0 +1,180 @@
+package testcases
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+
+	"backend/internal/controllers"
+	"backend/internal/models"
+	"backend/internal/services"
+)
+
+// watcherIDs returns the ids of the users watching a task, in subscription order
+func watcherIDs(t *testing.T, watchers *services.TaskWatcherService, taskID int64) []string {
+	t.Helper()
+	list, err := watchers.GetWatchers("owner-1", taskID)
+	if err != nil {
+		t.Fatalf("GetWatchers() error = %v", err)
+	}
+	ids := make([]string, 0, len(list))
+	for _, watcher := range list {
+		ids = append(ids, watcher.UserID)
+	}
+	return ids
+}
+
+func containsUser(ids []string, userID string) bool {
+	for _, id := range ids {
+		if id == userID {
+			return true
+		}
+	}
+	return false
+}
+
+func TestTaskWatchers_WatchUnwatchAndList(t *testing.T) {
+	db, _, _, _, taskID := newTaskNotificationTestDB(t)
+	controller := controllers.NewTaskWatcherController(services.NewTaskWatcherService(db))
+	vars := map[string]string{"id": toString(taskID)}
+
+	// Watching twice is idempotent
+	for i := 0; i < 2; i++ {
+		req := mux.SetURLVars(createRequestWithUser("POST", "/api/tasks/"+toString(taskID)+"/watchers", nil, "member-2"), vars)
+		rr := httptest.NewRecorder()
+		controller.WatchTask(rr, req)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("WatchTask() status = %d, body = %s", rr.Code, rr.Body.String())
+		}
+	}
+
+	req := mux.SetURLVars(createRequestWithUser("GET", "/api/tasks/"+toString(taskID)+"/watchers", nil, "member-1"), vars)
+	rr := httptest.NewRecorder()
+	controller.GetWatchers(rr, req)
+	var resp struct {
+		Data []models.TaskWatcher `json:"data"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if len(resp.Data) != 1 || resp.Data[0].UserID != "member-2" || resp.Data[0].Name != "Member Two" {
+		t.Fatalf("watchers = %+v, want only Member Two", resp.Data)
+	}
+
+	req = mux.SetURLVars(createRequestWithUser("DELETE", "/api/tasks/"+toString(taskID)+"/watchers", nil, "member-2"), vars)
+	rr = httptest.NewRecorder()
+	controller.UnwatchTask(rr, req)
+	if rr.Code != http.StatusNoContent {
+		t.Fatalf("UnwatchTask() status = %d, want 204", rr.Code)
+	}
+
+	// Non-members cannot see or watch the task
+	for _, handler := range []http.HandlerFunc{controller.WatchTask, controller.GetWatchers} {
+		req = mux.SetURLVars(createRequestWithUser("POST", "/api/tasks/"+toString(taskID)+"/watchers", nil, "stranger"), vars)
+		rr = httptest.NewRecorder()
+		handler(rr, req)
+		if rr.Code != http.StatusNotFound {
+			t.Errorf("non-member status = %d, want 404", rr.Code)
+		}
+	}
+}
+
+func TestTaskWatchers_AutoSubscribeOnCreateAssignAndComment(t *testing.T) {
+	db, _, todoID, _, taskID := newTaskNotificationTestDB(t)
+	watchers := services.NewTaskWatcherService(db)
+	tasks := services.NewTaskService(db, nil)
+	tasks.SetWatcherService(watchers)
+	comments := services.NewCommentService(db)
+	comments.SetWatcherService(watchers)
+
+	assignee := "member-1"
+	created, err := tasks.CreateTask("owner-1", todoID, "Plan release", "", 1, services.TaskAttributes{AssignedTo: &assignee})
+	if err != nil {
+		t.Fatalf("CreateTask() error = %v", err)
+	}
+	if ids := watcherIDs(t, watchers, created.ID); !containsUser(ids, "owner-1") || !containsUser(ids, "member-1") || len(ids) != 2 {
+		t.Errorf("watchers after create = %v, want creator and assignee", ids)
+	}
+
+	member2 := "member-2"
+	if _, err := tasks.AssignTask(taskID, &member2, "owner-1"); err != nil {
+		t.Fatalf("AssignTask() error = %v", err)
+	}
+	if ids := watcherIDs(t, watchers, taskID); len(ids) != 1 || ids[0] != "member-2" {
+		t.Errorf("watchers after assign = %v, want [member-2]", ids)
+	}
+
+	if _, err := comments.CreateComment("owner-1", taskID, "Thanks!"); err != nil {
+		t.Fatalf("CreateComment() error = %v", err)
+	}
+	if ids := watcherIDs(t, watchers, taskID); !containsUser(ids, "owner-1") {
+		t.Errorf("watchers after comment = %v, want commenter included", ids)
+	}
+}
+
+func TestTaskWatchers_NotifiedAboutChanges(t *testing.T) {
+	db, projectID, todoID, doneID, taskID := newTaskNotificationTestDB(t)
+	notifications := services.NewNotificationService(db, nil)
+	activity := services.NewActivityService(db, nil)
+	activity.AddObserver(notifications)
+	watchers := services.NewTaskWatcherService(db)
+	tasks := services.NewTaskService(db, activity)
+	tasks.SetNotificationService(notifications)
+	tasks.SetWatcherService(watchers)
+
+	for _, userID := range []string{"owner-1", "member-1", "member-2"} {
+		if _, err := watchers.WatchTask(userID, taskID); err != nil {
+			t.Fatalf("WatchTask(%s) error = %v", userID, err)
+		}
+	}
+
+	assignee := "member-1"
+	if _, err := tasks.UpdateTask("owner-1", taskID, "Write better docs", "", 0, services.TaskAttributes{AssignedTo: &assignee}); err != nil {
+		t.Fatalf("UpdateTask() error = %v", err)
+	}
+	for _, userID := range []string{"member-1", "member-2"} {
+		got := notificationsOfType(t, db, userID, models.NotificationTaskUpdated)
+		if len(got) != 1 || got[0].Message != "Owner updated task 'Write better docs'" ||
+			got[0].RelatedEntityType != string(models.EntityTask) || got[0].RelatedEntityID != taskID {
+			t.Fatalf("%s task_updated notifications = %+v", userID, got)
+		}
+	}
+	if n := notificationsOfType(t, db, "owner-1", models.NotificationTaskUpdated); len(n) != 0 {
+		t.Errorf("actor got %d task_updated notifications, want 0", len(n))
+	}
+
+	activity.LogLabelAssigned(projectID, "member-1", "Member One", taskID, "Write better docs", "urgent")
+	got := notificationsOfType(t, db, "member-2", models.NotificationTaskUpdated)
+	if len(got) != 2 || got[1].Message != "Member One added label 'urgent' to task 'Write better docs'" {
+		t.Errorf("label notification = %+v", got)
+	}
+
+	// Completing the task is reported as task_completed only
+	if _, err := tasks.MoveTask("member-1", taskID, doneID, 0); err != nil {
+		t.Fatalf("MoveTask() error = %v", err)
+	}
+	if n := notificationsOfType(t, db, "member-2", models.NotificationTaskUpdated); len(n) != 2 {
+		t.Errorf("watcher got %d task_updated notifications after completion, want still 2", len(n))
+	}
+	if n := notificationsOfType(t, db, "member-2", models.NotificationTaskCompleted); len(n) != 1 {
+		t.Errorf("watcher got %d task_completed notifications, want 1", len(n))
+	}
+
+	if _, err := tasks.MoveTask("member-1", taskID, todoID, 0); err != nil {
+		t.Fatalf("MoveTask() error = %v", err)
+	}
+	got = notificationsOfType(t, db, "member-2", models.NotificationTaskUpdated)
+	if len(got) != 3 || got[2].Message != "Member One moved task 'Write better docs' to 'To Do'" {
+		t.Errorf("move notification = %+v", got)
+	}
+
+	// Watchers who left the project are not notified
+	db.Exec("DELETE FROM project_members WHERE user_id = 'member-2'")
+	tasks.UpdateTask("owner-1", taskID, "Write the best docs", "", 0, services.TaskAttributes{AssignedTo: &assignee})
+	if n := notificationsOfType(t, db, "member-2", models.NotificationTaskUpdated); len(n) != 3 {
+		t.Errorf("former member got %d task_updated notifications, want still 3", len(n))
+	}
+}
@@ -0,0 +1,400 @@
+package testcases
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"backend/internal/auth/controller"
+	"backend/internal/auth/middleware"
+	"backend/internal/auth/repository"
+	"backend/internal/auth/services"
+
+	"github.com/gorilla/mux"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+type twoFactorTestEnv struct {
+	db        *sql.DB
+	auth      *services.AuthService
+	twoFactor *services.TwoFactorService
+	jwt       *services.JWTService
+	router    *mux.Router
+	userID    string
+}
+
+func newTwoFactorTestEnv(t *testing.T) *twoFactorTestEnv {
+	t.Helper()
+
+	// A file rather than :memory:, so parallel requests get their own
+	// connections as they would in production
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "two_factor.db"))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	userRepo := repository.NewUserRepository(db)
+	if err := userRepo.InitTable(); err != nil {
+		t.Fatalf("Failed to create users table: %v", err)
+	}
+	identityRepo := repository.NewAuthIdentityRepository(db)
+	if err := identityRepo.InitTable(); err != nil {
+		t.Fatalf("Failed to create auth identities table: %v", err)
+	}
+	twoFactorRepo := repository.NewTwoFactorRepository(db)
+	if err := twoFactorRepo.InitTable(); err != nil {
+		t.Fatalf("Failed to create two-factor tables: %v", err)
+	}
+
+	jwtService := services.NewJWTService("test-secret", 24)
+	twoFactorService := services.NewTwoFactorService(twoFactorRepo, userRepo)
+	authService := services.NewAuthService(userRepo, identityRepo, jwtService, services.NewOTPService(repository.NewOTPRepository(db)), &services.EmailService{}, nil, nil)
+	authService.SetTwoFactorService(twoFactorService)
+
+	resp, err := authService.Register(services.RegisterRequest{
+		Name:     "Careful User",
+		Email:    "careful@example.com",
+		Password: "password123",
+	})
+	if err != nil {
+		t.Fatalf("Register() error = %v", err)
+	}
+
+	ctrl := controller.NewAuthController(authService)
+	ctrl.SetTwoFactorService(twoFactorService)
+
+	router := mux.NewRouter()
+	router.HandleFunc("/api/auth/login", ctrl.Login).Methods("POST")
+	router.HandleFunc("/api/auth/2fa/verify", ctrl.VerifyTwoFactor).Methods("POST")
+	protected := router.PathPrefix("/api").Subrouter()
+	protected.Use(middleware.JWTAuthMiddleware(jwtService))
+	protected.HandleFunc("/auth/2fa", ctrl.GetTwoFactorStatus).Methods("GET")
+	protected.HandleFunc("/auth/2fa/setup", ctrl.SetupTwoFactor).Methods("POST")
+	protected.HandleFunc("/auth/2fa/enable", ctrl.EnableTwoFactor).Methods("POST")
+	protected.HandleFunc("/auth/2fa/disable", ctrl.DisableTwoFactor).Methods("POST")
+
+	return &twoFactorTestEnv{
+		db:        db,
+		auth:      authService,
+		twoFactor: twoFactorService,
+		jwt:       jwtService,
+		router:    router,
+		userID:    resp.User.ID,
+	}
+}
+
+func (env *twoFactorTestEnv) do(method, url, token string, body interface{}) *httptest.ResponseRecorder {
+	var bodyBytes []byte
+	if body != nil {
+		bodyBytes, _ = json.Marshal(body)
+	}
+	req := httptest.NewRequest(method, url, bytes.NewBuffer(bodyBytes))
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	w := httptest.NewRecorder()
+	env.router.ServeHTTP(w, req)
+	return w
+}
+
+// enable turns on 2FA with a code for the current time step and returns the
+// secret and recovery codes
+func (env *twoFactorTestEnv) enable(t *testing.T) (string, []string) {
+	t.Helper()
+
+	setup, err := env.twoFactor.Setup(env.userID)
+	if err != nil {
+		t.Fatalf("Setup() error = %v", err)
+	}
+	code, err := services.GenerateTOTPCode(setup.Secret, time.Now())
+	if err != nil {
+		t.Fatalf("GenerateTOTPCode() error = %v", err)
+	}
+	recoveryCodes, err := env.twoFactor.Enable(env.userID, code)
+	if err != nil {
+		t.Fatalf("Enable() error = %v", err)
+	}
+	return setup.Secret, recoveryCodes
+}
+
+func (env *twoFactorTestEnv) login(t *testing.T) *services.AuthResponse {
+	t.Helper()
+
+	resp, err := env.auth.Login(services.LoginRequest{Email: "careful@example.com", Password: "password123"})
+	if err != nil {
+		t.Fatalf("Login() error = %v", err)
+	}
+	return resp
+}
+
+func TestTOTP_RFC6238Vectors(t *testing.T) {
+	// The SHA-1 test secret of RFC 6238 appendix B, "12345678901234567890",
+	// base32 encoded; codes are the last six digits of the published ones
+	secret := "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+	vectors := map[int64]string{
+		59:         "287082",
+		1111111109: "081804",
+		1234567890: "005924",
+		2000000000: "279037",
+	}
+	for unix, want := range vectors {
+		got, err := services.GenerateTOTPCode(secret, time.Unix(unix, 0))
+		if err != nil {
+			t.Fatalf("GenerateTOTPCode() error = %v", err)
+		}
+		if got != want {
+			t.Errorf("code at %d = %s, want %s", unix, got, want)
+		}
+	}
+}
+
+func TestTwoFactor_Enrollment(t *testing.T) {
+	env := newTwoFactorTestEnv(t)
+	token, _ := env.jwt.GenerateToken(env.userID, "careful@example.com")
+
+	w := env.do("POST", "/api/auth/2fa/enable", token, map[string]string{"code": "123456"})
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("enable before setup status = %d, want 400", w.Code)
+	}
+
+	w = env.do("POST", "/api/auth/2fa/setup", token, nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("setup status = %d, body %s", w.Code, w.Body.String())
+	}
+	var setup struct {
+		Secret     string `json:"secret"`
+		OTPAuthURI string `json:"otpauth_uri"`
+	}
+	json.NewDecoder(w.Body).Decode(&setup)
+	uri, err := url.Parse(setup.OTPAuthURI)
+	if err != nil || uri.Scheme != "otpauth" || uri.Host != "totp" {
+		t.Fatalf("otpauth_uri = %q", setup.OTPAuthURI)
+	}
+	if uri.Query().Get("secret") != setup.Secret || uri.Query().Get("issuer") != "Taskify" {
+		t.Errorf("otpauth_uri query = %v", uri.Query())
+	}
+	if !strings.Contains(uri.Path, "careful@example.com") {
+		t.Errorf("otpauth_uri label = %q, want the user's email", uri.Path)
+	}
+
+	// Setup alone does not turn 2FA on
+	if resp := env.login(t); resp.TwoFactorRequired || resp.Token == "" {
+		t.Fatal("login asked for a second factor before enrollment finished")
+	}
+
+	if w := env.do("POST", "/api/auth/2fa/enable", token, map[string]string{"code": "000000"}); w.Code != http.StatusBadRequest {
+		t.Errorf("enable with wrong code status = %d, want 400", w.Code)
+	}
+
+	code, _ := services.GenerateTOTPCode(setup.Secret, time.Now())
+	w = env.do("POST", "/api/auth/2fa/enable", token, map[string]string{"code": code})
+	if w.Code != http.StatusOK {
+		t.Fatalf("enable status = %d, body %s", w.Code, w.Body.String())
+	}
+	var enabled struct {
+		RecoveryCodes []string `json:"recovery_codes"`
+	}
+	json.NewDecoder(w.Body).Decode(&enabled)
+	if len(enabled.RecoveryCodes) != 10 {
+		t.Fatalf("got %d recovery codes, want 10", len(enabled.RecoveryCodes))
+	}
+
+	var plain int
+	env.db.QueryRow("SELECT COUNT(*) FROM recovery_codes WHERE code_hash = ?", enabled.RecoveryCodes[0]).Scan(&plain)
+	if plain != 0 {
+		t.Error("recovery codes are stored in plain text")
+	}
+
+	w = env.do("GET", "/api/auth/2fa", token, nil)
+	var status struct {
+		Enabled                bool `json:"enabled"`
+		RecoveryCodesRemaining int  `json:"recovery_codes_remaining"`
+	}
+	json.NewDecoder(w.Body).Decode(&status)
+	if !status.Enabled || status.RecoveryCodesRemaining != 10 {
+		t.Errorf("status = %+v, want enabled with 10 codes", status)
+	}
+
+	if w := env.do("POST", "/api/auth/2fa/setup", token, nil); w.Code != http.StatusConflict {
+		t.Errorf("setup while enabled status = %d, want 409", w.Code)
+	}
+}
+
+func TestTwoFactor_LoginNeedsChallenge(t *testing.T) {
+	env := newTwoFactorTestEnv(t)
+	secret, recoveryCodes := env.enable(t)
+
+	w := env.do("POST", "/api/auth/login", "", map[string]string{"email": "careful@example.com", "password": "password123"})
+	if w.Code != http.StatusOK {
+		t.Fatalf("login status = %d, body %s", w.Code, w.Body.String())
+	}
+	var challenge map[string]interface{}
+	json.NewDecoder(w.Body).Decode(&challenge)
+	if challenge["two_factor_required"] != true || challenge["challenge_token"] == "" {
+		t.Fatalf("login response = %v, want a challenge", challenge)
+	}
+	if _, ok := challenge["token"]; ok {
+		t.Fatal("login returned an access token before the second factor")
+	}
+	challengeToken := challenge["challenge_token"].(string)
+
+	// The challenge token is not an access token
+	if _, err := env.jwt.ValidateToken(challengeToken); err == nil {
+		t.Error("challenge token is accepted as an access token")
+	}
+
+	if w := env.do("POST", "/api/auth/2fa/verify", "", map[string]string{"challenge_token": challengeToken, "code": "000000"}); w.Code != http.StatusBadRequest {
+		t.Errorf("verify with wrong code status = %d, want 400", w.Code)
+	}
+
+	// The code for the next step, since enrollment used the current one
+	code, _ := services.GenerateTOTPCode(secret, time.Now().Add(30*time.Second))
+	w = env.do("POST", "/api/auth/2fa/verify", "", map[string]string{"challenge_token": challengeToken, "code": code})
+	if w.Code != http.StatusOK {
+		t.Fatalf("verify status = %d, body %s", w.Code, w.Body.String())
+	}
+	var resp services.AuthResponse
+	json.NewDecoder(w.Body).Decode(&resp)
+	if resp.Token == "" || resp.User == nil || resp.User.ID != env.userID {
+		t.Fatalf("verify response = %+v, want the user's token", resp)
+	}
+	if _, err := env.jwt.ValidateToken(resp.Token); err != nil {
+		t.Errorf("issued token is invalid: %v", err)
+	}
+
+	// A challenge is redeemed once
+	if _, err := env.auth.VerifyTwoFactor(challengeToken, code, services.ClientInfo{}); !errors.Is(err, services.ErrInvalidChallenge) {
+		t.Errorf("reusing a challenge error = %v, want ErrInvalidChallenge", err)
+	}
+
+	// A TOTP code cannot be replayed
+	second := env.login(t)
+	if _, err := env.auth.VerifyTwoFactor(second.ChallengeToken, code, services.ClientInfo{}); !errors.Is(err, services.ErrInvalidTwoFactorCode) {
+		t.Errorf("replayed code error = %v, want ErrInvalidTwoFactorCode", err)
+	}
+
+	// Recovery codes work once, whatever their formatting
+	resp2, err := env.auth.VerifyTwoFactor(second.ChallengeToken, " "+strings.ToUpper(recoveryCodes[0])+" ", services.ClientInfo{})
+	if err != nil || resp2.Token == "" {
+		t.Fatalf("recovery code sign-in = %+v, %v", resp2, err)
+	}
+	third := env.login(t)
+	if _, err := env.auth.VerifyTwoFactor(third.ChallengeToken, recoveryCodes[0], services.ClientInfo{}); !errors.Is(err, services.ErrInvalidTwoFactorCode) {
+		t.Errorf("used recovery code error = %v, want ErrInvalidTwoFactorCode", err)
+	}
+	status, _ := env.twoFactor.Status(env.userID)
+	if status.RecoveryCodesRemaining != 9 {
+		t.Errorf("recovery codes remaining = %d, want 9", status.RecoveryCodesRemaining)
+	}
+}
+
+func TestTwoFactor_ChallengeLocksAfterFailedAttempts(t *testing.T) {
+	env := newTwoFactorTestEnv(t)
+	_, recoveryCodes := env.enable(t)
+
+	resp := env.login(t)
+	for i := 0; i < 5; i++ {
+		if _, err := env.auth.VerifyTwoFactor(resp.ChallengeToken, "wrong-code", services.ClientInfo{}); !errors.Is(err, services.ErrInvalidTwoFactorCode) {
+			t.Fatalf("attempt %d error = %v, want ErrInvalidTwoFactorCode", i+1, err)
+		}
+	}
+
+	if _, err := env.auth.VerifyTwoFactor(resp.ChallengeToken, recoveryCodes[0], services.ClientInfo{}); !errors.Is(err, services.ErrInvalidChallenge) {
+		t.Errorf("verify after lockout error = %v, want ErrInvalidChallenge", err)
+	}
+
+	// The locked challenge did not use up the recovery code
+	fresh := env.login(t)
+	if _, err := env.auth.VerifyTwoFactor(fresh.ChallengeToken, recoveryCodes[0], services.ClientInfo{}); err != nil {
+		t.Errorf("verify with a new challenge error = %v", err)
+	}
+}
+
+func TestTwoFactor_ParallelGuessesStopAtTheLimit(t *testing.T) {
+	env := newTwoFactorTestEnv(t)
+	secret, _ := env.enable(t)
+	resp := env.login(t)
+
+	// A well-formed code that matches none of the accepted time steps, so
+	// every guess is checked against the secret
+	valid := map[string]bool{}
+	for step := -2; step <= 2; step++ {
+		code, _ := services.GenerateTOTPCode(secret, time.Now().Add(time.Duration(step)*30*time.Second))
+		valid[code] = true
+	}
+	wrong := "000000"
+	for digit := '1'; valid[wrong]; digit++ {
+		wrong = strings.Repeat(string(digit), 6)
+	}
+
+	const guesses = 50
+	results := make(chan error, guesses)
+	var start sync.WaitGroup
+	start.Add(1)
+	for i := 0; i < guesses; i++ {
+		go func() {
+			start.Wait()
+			_, err := env.auth.VerifyTwoFactor(resp.ChallengeToken, wrong, services.ClientInfo{})
+			results <- err
+		}()
+	}
+	start.Done()
+
+	evaluated := 0
+	for i := 0; i < guesses; i++ {
+		switch err := <-results; {
+		case errors.Is(err, services.ErrInvalidTwoFactorCode):
+			evaluated++
+		case errors.Is(err, services.ErrInvalidChallenge):
+		default:
+			t.Errorf("guess error = %v", err)
+		}
+	}
+	// Only as many as a challenge allows (5) may reach the code check
+	if evaluated > 5 {
+		t.Errorf("%d of %d parallel guesses were checked, want at most 5", evaluated, guesses)
+	}
+}
+
+func TestTwoFactor_DisableRequiresReauthentication(t *testing.T) {
+	env := newTwoFactorTestEnv(t)
+	_, recoveryCodes := env.enable(t)
+	token, _ := env.jwt.GenerateToken(env.userID, "careful@example.com")
+
+	w := env.do("POST", "/api/auth/2fa/disable", token, map[string]string{"password": "not-my-password", "code": recoveryCodes[0]})
+	if w.Code != http.StatusForbidden {
+		t.Errorf("disable with wrong password status = %d, want 403", w.Code)
+	}
+	w = env.do("POST", "/api/auth/2fa/disable", token, map[string]string{"password": "password123", "code": "000000"})
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("disable with wrong code status = %d, want 400", w.Code)
+	}
+	w = env.do("POST", "/api/auth/2fa/disable", token, map[string]string{"password": "password123"})
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("disable without code status = %d, want 400", w.Code)
+	}
+
+	w = env.do("POST", "/api/auth/2fa/disable", token, map[string]string{"password": "password123", "code": recoveryCodes[1]})
+	if w.Code != http.StatusOK {
+		t.Fatalf("disable status = %d, body %s", w.Code, w.Body.String())
+	}
+
+	if resp := env.login(t); resp.TwoFactorRequired || resp.Token == "" {
+		t.Error("login still asks for a second factor after disabling")
+	}
+	var left int
+	env.db.QueryRow("SELECT COUNT(*) FROM recovery_codes").Scan(&left)
+	if left != 0 {
+		t.Errorf("%d recovery codes left after disabling, want 0", left)
+	}
+}
@@ -33,6 +33,8 @@ func setupTestDB(t *testing.T) *sql.DB {
 			password_hash TEXT NOT NULL,
 			role TEXT DEFAULT 'user',
 			is_active INTEGER DEFAULT 1,
+			email_verified INTEGER NOT NULL DEFAULT 0,
+			language TEXT NOT NULL DEFAULT '',
 			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
 			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
 		)
@@ -0,0 +1,453 @@
+package testcases
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"backend/internal/controllers"
+	"backend/internal/models"
+	"backend/internal/services"
+)
+
+// newWebhookTestDB creates a project owned by owner-1 with member-1
+func newWebhookTestDB(t *testing.T) (*sql.DB, int64) {
+	t.Helper()
+	db := newSchemaTestDB(t)
+
+	result, err := db.Exec("INSERT INTO projects (owner_id, name) VALUES ('owner-1', 'Apollo')")
+	if err != nil {
+		t.Fatalf("Failed to create project: %v", err)
+	}
+	projectID, _ := result.LastInsertId()
+	db.Exec("INSERT INTO project_members (project_id, user_id, role) VALUES (?, 'owner-1', 'owner'), (?, 'member-1', 'member')", projectID, projectID)
+
+	return db, projectID
+}
+
+// webhookRequest is one request received by a webhookReceiver
+type webhookRequest struct {
+	Header http.Header
+	Body   []byte
+}
+
+// webhookReceiver is an httptest server that answers with the queued status
+// codes (200 once they run out) and records every request
+type webhookReceiver struct {
+	server   *httptest.Server
+	mu       sync.Mutex
+	statuses []int
+	requests []webhookRequest
+	received chan struct{}
+}
+
+func newWebhookReceiver(t *testing.T, statuses ...int) *webhookReceiver {
+	t.Helper()
+	receiver := &webhookReceiver{statuses: statuses, received: make(chan struct{}, 16)}
+	receiver.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		receiver.mu.Lock()
+		receiver.requests = append(receiver.requests, webhookRequest{Header: r.Header.Clone(), Body: body})
+		status := http.StatusOK
+		if len(receiver.statuses) > 0 {
+			status, receiver.statuses = receiver.statuses[0], receiver.statuses[1:]
+		}
+		receiver.mu.Unlock()
+		w.WriteHeader(status)
+		w.Write([]byte("status " + http.StatusText(status)))
+		receiver.received <- struct{}{}
+	}))
+	t.Cleanup(receiver.server.Close)
+	return receiver
+}
+
+func (r *webhookReceiver) Requests() []webhookRequest {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]webhookRequest(nil), r.requests...)
+}
+
+type webhookTestEnv struct {
+	db         *sql.DB
+	projectID  int64
+	activity   *services.ActivityService
+	webhooks   *services.WebhookService
+	controller *controllers.WebhookController
+}
+
+func newWebhookTestEnv(t *testing.T) *webhookTestEnv {
+	t.Helper()
+	db, projectID := newWebhookTestDB(t)
+	pmService := services.NewProjectMemberService(db)
+	activity := services.NewActivityService(db, pmService)
+	webhooks := services.NewWebhookService(db, pmService)
+	// Receivers are httptest servers on the loopback address
+	webhooks.SetAllowedHosts([]string{"127.0.0.1"})
+	activity.AddObserver(webhooks)
+	return &webhookTestEnv{
+		db:         db,
+		projectID:  projectID,
+		activity:   activity,
+		webhooks:   webhooks,
+		controller: controllers.NewWebhookController(webhooks),
+	}
+}
+
+// call runs a webhook handler as userID with the route variables set
+func (env *webhookTestEnv) call(handler http.HandlerFunc, method, userID string, body interface{}, vars map[string]string) *httptest.ResponseRecorder {
+	if vars == nil {
+		vars = map[string]string{}
+	}
+	vars["id"] = toString(env.projectID)
+	req := createRequestWithUser(method, "/api/projects/"+vars["id"]+"/webhooks", body, userID)
+	req = mux.SetURLVars(req, vars)
+	rr := httptest.NewRecorder()
+	handler(rr, req)
+	return rr
+}
+
+// create registers a webhook as the owner and returns it with its secret
+func (env *webhookTestEnv) create(t *testing.T, url string, events ...models.ActivityAction) models.Webhook {
+	t.Helper()
+	rr := env.call(env.controller.CreateWebhook, "POST", "owner-1", map[string]interface{}{"url": url, "events": events}, nil)
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("CreateWebhook() status = %d, body = %s", rr.Code, rr.Body.String())
+	}
+	var resp struct {
+		Data models.Webhook `json:"data"`
+	}
+	json.NewDecoder(rr.Body).Decode(&resp)
+	return resp.Data
+}
+
+func (env *webhookTestEnv) deliveries(t *testing.T, webhookID int64) []models.WebhookDelivery {
+	t.Helper()
+	deliveries, _, err := env.webhooks.ListDeliveries(env.projectID, webhookID, "owner-1", 1, 100)
+	if err != nil {
+		t.Fatalf("ListDeliveries() error = %v", err)
+	}
+	return deliveries
+}
+
+func TestWebhooks_ManageWebhooks(t *testing.T) {
+	env := newWebhookTestEnv(t)
+
+	webhook := env.create(t, "https://ci.example.com/hook", models.ActivityTaskCreated, models.ActivityTaskMoved, models.ActivityTaskCreated)
+	if len(webhook.Secret) != 64 || !webhook.Active || len(webhook.Events) != 2 {
+		t.Errorf("created webhook = %+v, want a generated secret, active, 2 events", webhook)
+	}
+
+	invalid := []map[string]interface{}{
+		{"url": "ftp://ci.example.com", "events": []string{"task_created"}},
+		{"url": "/relative", "events": []string{"task_created"}},
+		{"url": "https://ci.example.com", "events": []string{}},
+		{"url": "https://ci.example.com", "events": []string{"task_exploded"}},
+		{"url": "https://ci.example.com", "events": []string{"task_created"}, "secret": "short"},
+	}
+	for _, body := range invalid {
+		if rr := env.call(env.controller.CreateWebhook, "POST", "owner-1", body, nil); rr.Code != http.StatusBadRequest {
+			t.Errorf("CreateWebhook(%v) status = %d, want 400", body, rr.Code)
+		}
+	}
+
+	// Members can see the project but not its webhooks
+	if rr := env.call(env.controller.ListWebhooks, "GET", "member-1", nil, nil); rr.Code != http.StatusForbidden {
+		t.Errorf("ListWebhooks() as member status = %d, want 403", rr.Code)
+	}
+
+	rr := env.call(env.controller.ListWebhooks, "GET", "owner-1", nil, nil)
+	if rr.Code != http.StatusOK || strings.Contains(rr.Body.String(), webhook.Secret) {
+		t.Errorf("ListWebhooks() status = %d and must not include the secret: %s", rr.Code, rr.Body.String())
+	}
+
+	vars := map[string]string{"webhookId": toString(webhook.ID)}
+	rr = env.call(env.controller.UpdateWebhook, "PUT", "owner-1", map[string]interface{}{"events": []string{"comment_added"}, "active": false}, vars)
+	var updated struct {
+		Data models.Webhook `json:"data"`
+	}
+	json.NewDecoder(rr.Body).Decode(&updated)
+	if rr.Code != http.StatusOK || updated.Data.Active || len(updated.Data.Events) != 1 || updated.Data.URL != webhook.URL {
+		t.Errorf("UpdateWebhook() = %d, %+v", rr.Code, updated.Data)
+	}
+
+	if rr := env.call(env.controller.DeleteWebhook, "DELETE", "owner-1", nil, vars); rr.Code != http.StatusNoContent {
+		t.Fatalf("DeleteWebhook() status = %d, want 204", rr.Code)
+	}
+	if rr := env.call(env.controller.GetWebhook, "GET", "owner-1", nil, vars); rr.Code != http.StatusNotFound {
+		t.Errorf("GetWebhook() after delete status = %d, want 404", rr.Code)
+	}
+}
+
+func TestWebhooks_DeliversSignedActivity(t *testing.T) {
+	env := newWebhookTestEnv(t)
+	receiver := newWebhookReceiver(t)
+	webhook := env.create(t, receiver.server.URL, models.ActivityTaskCreated)
+	inactive := env.create(t, receiver.server.URL, models.ActivityTaskCreated)
+	env.webhooks.UpdateWebhook(env.projectID, inactive.ID, "owner-1", services.WebhookUpdate{Active: new(bool)})
+
+	env.activity.LogTaskCreated(env.projectID, "owner-1", "Owner", 7, "Ship it")
+	env.activity.LogTaskDeleted(env.projectID, "owner-1", "Owner", "Old task")
+
+	if sent, err := env.webhooks.ProcessDue(); err != nil || sent != 1 {
+		t.Fatalf("ProcessDue() = %d, %v; want 1 delivery", sent, err)
+	}
+	requests := receiver.Requests()
+	if len(requests) != 1 {
+		t.Fatalf("receiver got %d requests, want 1", len(requests))
+	}
+	req := requests[0]
+
+	mac := hmac.New(sha256.New, []byte(webhook.Secret))
+	mac.Write(req.Body)
+	if got, want := req.Header.Get(services.WebhookSignatureHeader), "sha256="+hex.EncodeToString(mac.Sum(nil)); got != want {
+		t.Errorf("signature = %q, want %q", got, want)
+	}
+	if req.Header.Get(services.WebhookEventHeader) != "task_created" || req.Header.Get("Content-Type") != "application/json" {
+		t.Errorf("headers = %v", req.Header)
+	}
+
+	var payload models.WebhookPayload
+	if err := json.Unmarshal(req.Body, &payload); err != nil {
+		t.Fatalf("payload is not JSON: %v", err)
+	}
+	if payload.Event != "task_created" || payload.ProjectID != env.projectID || payload.Activity == nil ||
+		payload.Activity.EntityID != 7 || payload.Activity.UserName != "Owner" {
+		t.Errorf("payload = %s", req.Body)
+	}
+
+	deliveries := env.deliveries(t, webhook.ID)
+	if len(deliveries) != 1 || deliveries[0].Status != models.WebhookDeliverySucceeded || deliveries[0].DeliveredAt == nil ||
+		req.Header.Get(services.WebhookDeliveryHeader) != toString(deliveries[0].ID) {
+		t.Errorf("deliveries = %+v", deliveries)
+	}
+	if len(env.deliveries(t, inactive.ID)) != 0 {
+		t.Error("inactive webhook got a delivery")
+	}
+}
+
+func TestWebhooks_RetriesWithBackoffAndLogsAttempts(t *testing.T) {
+	env := newWebhookTestEnv(t)
+	env.webhooks.SetRetryPolicy(3, 0, 0)
+	flaky := newWebhookReceiver(t, http.StatusInternalServerError, http.StatusBadGateway)
+	down := newWebhookReceiver(t, 500, 500, 500, 500)
+	flakyHook := env.create(t, flaky.server.URL, models.ActivityCommentAdded)
+	downHook := env.create(t, down.server.URL, models.ActivityCommentAdded)
+
+	env.activity.LogActivity(env.projectID, "member-1", "Member", models.ActivityCommentAdded, models.EntityComment, 3, "Member commented", "")
+	for i := 0; i < 4; i++ {
+		if _, err := env.webhooks.ProcessDue(); err != nil {
+			t.Fatalf("ProcessDue() error = %v", err)
+		}
+	}
+
+	delivery, err := env.webhooks.GetDelivery(env.projectID, flakyHook.ID, env.deliveries(t, flakyHook.ID)[0].ID, "owner-1")
+	if err != nil {
+		t.Fatalf("GetDelivery() error = %v", err)
+	}
+	if delivery.Status != models.WebhookDeliverySucceeded || delivery.Attempts != 3 || len(delivery.AttemptLog) != 3 {
+		t.Fatalf("flaky delivery = %+v", delivery)
+	}
+	for i, want := range []int{500, 502, 200} {
+		if got := delivery.AttemptLog[i]; got.Attempt != i+1 || got.ResponseStatus != want {
+			t.Errorf("attempt %d = %+v, want status %d", i+1, got, want)
+		}
+	}
+	if delivery.AttemptLog[0].Error == "" || delivery.AttemptLog[2].Error != "" {
+		t.Errorf("attempt errors = %q, %q", delivery.AttemptLog[0].Error, delivery.AttemptLog[2].Error)
+	}
+
+	// Retries stop after the last attempt
+	failed := env.deliveries(t, downHook.ID)[0]
+	if failed.Status != models.WebhookDeliveryFailed || failed.Attempts != 3 || len(down.Requests()) != 3 {
+		t.Errorf("failing delivery = %+v after %d requests", failed, len(down.Requests()))
+	}
+
+	// Later attempts wait longer
+	env.webhooks.SetRetryPolicy(5, time.Hour, 4*time.Hour)
+	slow := newWebhookReceiver(t, 500, 500)
+	slowHook := env.create(t, slow.server.URL, models.ActivityCommentAdded)
+	env.activity.LogActivity(env.projectID, "member-1", "Member", models.ActivityCommentAdded, models.EntityComment, 4, "Member commented", "")
+	env.webhooks.ProcessDue()
+	env.webhooks.ProcessDue()
+
+	pending := env.deliveries(t, slowHook.ID)[0]
+	if len(slow.Requests()) != 1 || pending.Status != models.WebhookDeliveryPending || pending.NextAttemptAt == nil {
+		t.Fatalf("slow delivery = %+v after %d requests", pending, len(slow.Requests()))
+	}
+	if wait := time.Until(*pending.NextAttemptAt); wait < 55*time.Minute || wait > 65*time.Minute {
+		t.Errorf("next attempt in %v, want about an hour", wait)
+	}
+}
+
+func TestWebhooks_DisabledWebhookStopsRetrying(t *testing.T) {
+	env := newWebhookTestEnv(t)
+	env.webhooks.SetRetryPolicy(3, 0, 0)
+	down := newWebhookReceiver(t, 500, 500, 500)
+	hook := env.create(t, down.server.URL, models.ActivityCommentAdded)
+
+	env.activity.LogActivity(env.projectID, "member-1", "Member", models.ActivityCommentAdded, models.EntityComment, 3, "Member commented", "")
+	env.webhooks.ProcessDue()
+	if len(down.Requests()) != 1 {
+		t.Fatalf("requests before disabling = %d, want 1", len(down.Requests()))
+	}
+
+	inactive := false
+	if _, err := env.webhooks.UpdateWebhook(env.projectID, hook.ID, "owner-1", services.WebhookUpdate{Active: &inactive}); err != nil {
+		t.Fatalf("UpdateWebhook() error = %v", err)
+	}
+	env.webhooks.ProcessDue()
+	env.webhooks.ProcessDue()
+
+	delivery := env.deliveries(t, hook.ID)[0]
+	if len(down.Requests()) != 1 || delivery.Status != models.WebhookDeliveryPending || delivery.Attempts != 1 {
+		t.Fatalf("disabled delivery = %+v after %d requests", delivery, len(down.Requests()))
+	}
+
+	// Enabling the webhook again resumes the retries
+	active := true
+	if _, err := env.webhooks.UpdateWebhook(env.projectID, hook.ID, "owner-1", services.WebhookUpdate{Active: &active}); err != nil {
+		t.Fatalf("UpdateWebhook() error = %v", err)
+	}
+	env.webhooks.ProcessDue()
+	if len(down.Requests()) != 2 {
+		t.Errorf("requests after enabling = %d, want 2", len(down.Requests()))
+	}
+}
+
+func TestWebhooks_TestEventAndDeliveryLog(t *testing.T) {
+	env := newWebhookTestEnv(t)
+	receiver := newWebhookReceiver(t, http.StatusOK, http.StatusServiceUnavailable)
+	webhook := env.create(t, receiver.server.URL, models.ActivityTaskMoved)
+	vars := map[string]string{"webhookId": toString(webhook.ID)}
+
+	if rr := env.call(env.controller.SendTestEvent, "POST", "member-1", nil, vars); rr.Code != http.StatusForbidden {
+		t.Errorf("SendTestEvent() as member status = %d, want 403", rr.Code)
+	}
+
+	sendTest := func() models.WebhookDelivery {
+		rr := env.call(env.controller.SendTestEvent, "POST", "owner-1", nil, vars)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("SendTestEvent() status = %d, body = %s", rr.Code, rr.Body.String())
+		}
+		var resp struct {
+			Data models.WebhookDelivery `json:"data"`
+		}
+		json.NewDecoder(rr.Body).Decode(&resp)
+		return resp.Data
+	}
+
+	ok := sendTest()
+	if ok.Event != models.WebhookPingEvent || ok.Status != models.WebhookDeliverySucceeded || len(ok.AttemptLog) != 1 {
+		t.Errorf("test delivery = %+v", ok)
+	}
+	if req := receiver.Requests()[0]; req.Header.Get(services.WebhookEventHeader) != "ping" {
+		t.Errorf("test event header = %q", req.Header.Get(services.WebhookEventHeader))
+	}
+
+	// A failed test event is logged but not retried
+	failed := sendTest()
+	if failed.Status != models.WebhookDeliveryFailed || len(failed.AttemptLog) != 1 || failed.AttemptLog[0].ResponseStatus != 503 ||
+		!strings.Contains(failed.AttemptLog[0].ResponseBody, "Service Unavailable") {
+		t.Errorf("failed test delivery = %+v", failed)
+	}
+
+	rr := env.call(env.controller.ListDeliveries, "GET", "owner-1", nil, vars)
+	var list struct {
+		Data  []models.WebhookDelivery `json:"data"`
+		Total int64                    `json:"total"`
+	}
+	json.NewDecoder(rr.Body).Decode(&list)
+	if rr.Code != http.StatusOK || list.Total != 2 || list.Data[0].ID != failed.ID {
+		t.Errorf("ListDeliveries() = %d, %+v", rr.Code, list)
+	}
+
+	other := env.create(t, receiver.server.URL, models.ActivityTaskMoved)
+	otherVars := map[string]string{"webhookId": toString(other.ID), "deliveryId": toString(ok.ID)}
+	if rr := env.call(env.controller.GetDelivery, "GET", "owner-1", nil, otherVars); rr.Code != http.StatusNotFound {
+		t.Errorf("GetDelivery() through another webhook status = %d, want 404", rr.Code)
+	}
+}
+
+func TestWebhooks_RefusesInternalAddresses(t *testing.T) {
+	env := newWebhookTestEnv(t)
+	env.webhooks.SetAllowedHosts(nil)
+
+	for _, url := range []string{
+		"http://127.0.0.1:8080/hook",
+		"http://localhost:8080/hook",
+		"http://169.254.169.254/latest/meta-data/",
+		"http://10.0.0.5/hook",
+		"http://[::1]/hook",
+		"http://0.0.0.0/hook",
+	} {
+		body := map[string]interface{}{"url": url, "events": []string{"task_created"}}
+		if rr := env.call(env.controller.CreateWebhook, "POST", "owner-1", body, nil); rr.Code != http.StatusBadRequest {
+			t.Errorf("CreateWebhook(%s) status = %d, want 400", url, rr.Code)
+		}
+	}
+
+	// A name that resolves to an internal address is refused when dialed
+	receiver := newWebhookReceiver(t)
+	webhook := env.create(t, "https://ci.example.com/hook", models.ActivityTaskCreated)
+	if _, err := env.db.Exec("UPDATE webhooks SET url = ? WHERE id = ?", receiver.server.URL, webhook.ID); err != nil {
+		t.Fatalf("failed to point the webhook at the receiver: %v", err)
+	}
+	delivery, err := env.webhooks.SendTestEvent(env.projectID, webhook.ID, "owner-1")
+	if err != nil {
+		t.Fatalf("SendTestEvent() error = %v", err)
+	}
+	if delivery.Status != models.WebhookDeliveryFailed || len(delivery.AttemptLog) != 1 ||
+		!strings.Contains(delivery.AttemptLog[0].Error, "not allowed") {
+		t.Errorf("delivery to an internal address = %+v", delivery)
+	}
+	if n := len(receiver.Requests()); n != 0 {
+		t.Errorf("internal receiver got %d requests, want 0", n)
+	}
+
+	// Redirects are recorded, not followed
+	env.webhooks.SetAllowedHosts([]string{"127.0.0.1"})
+	redirector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, receiver.server.URL, http.StatusFound)
+	}))
+	t.Cleanup(redirector.Close)
+	redirected := env.create(t, redirector.URL, models.ActivityTaskCreated)
+	delivery, err = env.webhooks.SendTestEvent(env.projectID, redirected.ID, "owner-1")
+	if err != nil {
+		t.Fatalf("SendTestEvent() error = %v", err)
+	}
+	if delivery.Status != models.WebhookDeliveryFailed || delivery.AttemptLog[0].ResponseStatus != http.StatusFound {
+		t.Errorf("redirected delivery = %+v", delivery)
+	}
+	if n := len(receiver.Requests()); n != 0 {
+		t.Errorf("redirect target got %d requests, want 0", n)
+	}
+}
+
+func TestWebhooks_WorkerDeliversAsynchronously(t *testing.T) {
+	env := newWebhookTestEnv(t)
+	receiver := newWebhookReceiver(t)
+	env.create(t, receiver.server.URL, models.ActivityMemberAdded)
+	env.webhooks.Start(time.Hour)
+
+	env.activity.LogMemberAdded(env.projectID, "owner-1", "Owner", "member-2", "Member Two")
+
+	select {
+	case <-receiver.received:
+	case <-time.After(2 * time.Second):
+		t.Fatal("webhook was not delivered")
+	}
+	if got := receiver.Requests()[0].Header.Get(services.WebhookEventHeader); got != "member_added" {
+		t.Errorf("event = %q, want member_added", got)
+	}
+}
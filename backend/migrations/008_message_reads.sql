@@ -0,0 +1,12 @@
+-- Track how far each member has read a project's chat
+
+CREATE TABLE IF NOT EXISTS message_reads (
+    user_id TEXT NOT NULL,
+    project_id INTEGER NOT NULL,
+    last_read_message_id INTEGER NOT NULL,
+    read_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (user_id, project_id),
+    FOREIGN KEY (project_id) REFERENCES projects(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_message_reads_project ON message_reads(project_id);
@@ -0,0 +1,18 @@
+-- Store @mentions from task comments and chat messages
+
+CREATE TABLE IF NOT EXISTS mentions (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    project_id INTEGER NOT NULL,
+    user_id TEXT NOT NULL,
+    author_id TEXT NOT NULL,
+    entity_type TEXT NOT NULL,
+    entity_id INTEGER NOT NULL,
+    task_id INTEGER,
+    excerpt TEXT NOT NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (project_id) REFERENCES projects(id) ON DELETE CASCADE,
+    UNIQUE(entity_type, entity_id, user_id)
+);
+
+-- Index for GET /api/me/mentions
+CREATE INDEX IF NOT EXISTS idx_mentions_user_created ON mentions(user_id, created_at DESC);
@@ -0,0 +1,25 @@
+-- Deliver each deadline reminder once and make reminder offsets configurable
+
+-- A row holds either a user's own offsets (project_id 0) or a project's
+-- offsets (user_id ''). offsets is a comma-separated list of minutes before
+-- the deadline; an empty string turns reminders off.
+CREATE TABLE IF NOT EXISTS reminder_settings (
+    user_id TEXT NOT NULL DEFAULT '',
+    project_id INTEGER NOT NULL DEFAULT 0,
+    offsets TEXT NOT NULL,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (user_id, project_id)
+);
+
+-- Reminders already sent for a task's current deadline. offset_minutes 0 is
+-- the overdue notice. Rows for an old deadline are dropped when it changes.
+CREATE TABLE IF NOT EXISTS deadline_reminders (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    task_id INTEGER NOT NULL,
+    user_id TEXT NOT NULL,
+    deadline DATETIME NOT NULL,
+    offset_minutes INTEGER NOT NULL,
+    sent_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (task_id) REFERENCES tasks(id) ON DELETE CASCADE,
+    UNIQUE(task_id, user_id, deadline, offset_minutes)
+);
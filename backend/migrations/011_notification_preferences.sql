@@ -0,0 +1,13 @@
+-- Let users turn each notification type on or off per channel
+
+-- project_id 0 holds the user's defaults for all projects; a row for a
+-- project overrides them there. A missing row means both channels are on.
+CREATE TABLE IF NOT EXISTS notification_preferences (
+    user_id TEXT NOT NULL,
+    project_id INTEGER NOT NULL DEFAULT 0,
+    type TEXT NOT NULL,
+    in_app INTEGER NOT NULL DEFAULT 1,
+    email INTEGER NOT NULL DEFAULT 1,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (user_id, project_id, type)
+);
@@ -0,0 +1,11 @@
+-- Let users follow tasks they are not assigned to
+
+CREATE TABLE IF NOT EXISTS task_watchers (
+    task_id INTEGER NOT NULL,
+    user_id TEXT NOT NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (task_id, user_id),
+    FOREIGN KEY (task_id) REFERENCES tasks(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_task_watchers_user ON task_watchers(user_id);
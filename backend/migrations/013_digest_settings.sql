@@ -0,0 +1,8 @@
+-- Let users receive a daily or weekly digest instead of one email per notification
+
+CREATE TABLE IF NOT EXISTS digest_settings (
+    user_id TEXT PRIMARY KEY,
+    frequency TEXT NOT NULL DEFAULT 'immediate',
+    last_sent_at DATETIME,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
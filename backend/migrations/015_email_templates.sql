@@ -0,0 +1,6 @@
+-- Localized HTML emails: each user's preferred language and the HTML part
+-- of queued emails
+
+ALTER TABLE users ADD COLUMN language TEXT NOT NULL DEFAULT '';
+
+ALTER TABLE email_outbox ADD COLUMN html_body TEXT;
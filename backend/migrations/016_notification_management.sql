@@ -0,0 +1,17 @@
+-- Notification filtering and retention: the project each notification
+-- belongs to, and indexes for the type/project filters and the purge of old
+-- read notifications
+
+ALTER TABLE notifications ADD COLUMN project_id INTEGER;
+
+UPDATE notifications SET project_id = CASE related_entity_type
+    WHEN 'project' THEN related_entity_id
+    WHEN 'task' THEN (SELECT st.project_id FROM tasks t JOIN stages st ON st.id = t.stage_id WHERE t.id = notifications.related_entity_id)
+    WHEN 'comment' THEN (SELECT st.project_id FROM comments c JOIN tasks t ON t.id = c.task_id JOIN stages st ON st.id = t.stage_id WHERE c.id = notifications.related_entity_id)
+    WHEN 'message' THEN (SELECT m.project_id FROM messages m WHERE m.id = notifications.related_entity_id)
+END
+WHERE project_id IS NULL;
+
+CREATE INDEX IF NOT EXISTS idx_notifications_user_type ON notifications(user_id, type, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_notifications_user_project ON notifications(user_id, project_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_notifications_read_created ON notifications(is_read, created_at);
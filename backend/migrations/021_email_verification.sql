@@ -0,0 +1,14 @@
+-- Email verification: users.email_verified, and the pending verification
+-- link of each unverified user. Only a SHA-256 hash of the link's token is
+-- stored. Accounts that existed before count as verified.
+
+ALTER TABLE users ADD COLUMN email_verified INTEGER NOT NULL DEFAULT 0;
+UPDATE users SET email_verified = 1;
+
+CREATE TABLE IF NOT EXISTS email_verifications (
+    user_id TEXT PRIMARY KEY,
+    token_hash TEXT NOT NULL UNIQUE,
+    expires_at DATETIME NOT NULL,
+    sent_at DATETIME NOT NULL,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
@@ -0,0 +1,15 @@
+-- Password reset codes, kept in the database instead of in memory so they
+-- survive restarts. Codes are stored as bcrypt hashes and reset tokens as
+-- SHA-256 hashes; each address has at most one pending reset, with a count
+-- of wrong guesses and a lockout once it reaches the limit.
+
+CREATE TABLE IF NOT EXISTS password_reset_otps (
+    email TEXT PRIMARY KEY,
+    code_hash TEXT NOT NULL,
+    attempts INTEGER NOT NULL DEFAULT 0,
+    expires_at DATETIME NOT NULL,
+    sent_at DATETIME NOT NULL,
+    locked_until DATETIME,
+    reset_token_hash TEXT UNIQUE,
+    reset_expires_at DATETIME
+);
@@ -0,0 +1,4 @@
+-- Sent outbox emails no longer keep their bodies, which may hold one-time
+-- codes and links. Clear the ones sent before this change.
+
+UPDATE email_outbox SET body = '', html_body = NULL WHERE status = 'sent';
@@ -0,0 +1,14 @@
+-- Chat hub operations relayed between instances by the SQLite WebSocket
+-- backplane (WS_BACKPLANE=sqlite). Rows are deleted after a minute.
+
+CREATE TABLE IF NOT EXISTS hub_events (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    origin TEXT NOT NULL,
+    kind TEXT NOT NULL,
+    project_id INTEGER NOT NULL,
+    user_id TEXT,
+    payload BLOB,
+    created_at DATETIME NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_hub_events_created ON hub_events(created_at);